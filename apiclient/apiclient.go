@@ -1,5 +1,30 @@
 package apiclient
 
+import "context"
+
 type ApiClient interface {
-	Get(url string, params map[string]interface{}, response interface{}) error
+	// Get requests url with params encoded in the query string and decodes the JSON body into response.
+	Get(ctx context.Context, url string, params map[string]interface{}, response interface{}) error
+
+	// GetIfModified requests url like Get, sending validators as If-None-Match and If-Modified-Since.
+	// When the response was not modified response is left untouched and modified is false.
+	GetIfModified(ctx context.Context, url string, params map[string]interface{}, validators Validators, response interface{}) (Validators, bool, error)
+
+	// Post sends body as JSON to url and decodes the JSON body into response.
+	Post(ctx context.Context, url string, body interface{}, headers map[string]string, response interface{}) error
+
+	// Put sends body as JSON to url and decodes the JSON body into response.
+	Put(ctx context.Context, url string, body interface{}, headers map[string]string, response interface{}) error
+
+	// Patch sends body as JSON to url and decodes the JSON body into response.
+	Patch(ctx context.Context, url string, body interface{}, headers map[string]string, response interface{}) error
+
+	// Delete requests the deletion of url and decodes the JSON body into response.
+	Delete(ctx context.Context, url string, headers map[string]string, response interface{}) error
+}
+
+// Validators struct has the validators of a response, sent back to make conditional requests.
+type Validators struct {
+	ETag         string
+	LastModified string
 }
@@ -0,0 +1,116 @@
+package apiclient
+
+import (
+	"sync"
+	"time"
+)
+
+// BreakerPolicy struct configures the circuit breaker kept for every host.
+type BreakerPolicy struct {
+	// FailureThreshold is the amount of consecutive failures that opens the circuit. 0 disables it.
+	FailureThreshold int
+	// OpenTimeout is how long the circuit stays open before letting a trial request through.
+	OpenTimeout time.Duration
+}
+
+// DefaultBreakerPolicy is the BreakerPolicy used by the singleton client.
+var DefaultBreakerPolicy = BreakerPolicy{
+	FailureThreshold: 5,
+	OpenTimeout:      30 * time.Second,
+}
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// circuitBreaker tracks the failures of a single host.
+type circuitBreaker struct {
+	policy   BreakerPolicy
+	m        sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+}
+
+// allow tells if a request may be sent. Once the open timeout is over a single trial request is allowed.
+func (b *circuitBreaker) allow(now time.Time) bool {
+	if b.policy.FailureThreshold <= 0 {
+		return true
+	}
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if now.Sub(b.openedAt) < b.policy.OpenTimeout {
+			return false
+		}
+		b.state = breakerHalfOpen
+
+		return true
+	case breakerHalfOpen:
+		return false
+	}
+
+	return true
+}
+
+// record registers the outcome of a request.
+func (b *circuitBreaker) record(success bool, now time.Time) {
+	if b.policy.FailureThreshold <= 0 {
+		return
+	}
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	if success {
+		b.state = breakerClosed
+		b.failures = 0
+
+		return
+	}
+	b.failures++
+
+	if b.state == breakerHalfOpen || b.failures >= b.policy.FailureThreshold {
+		b.state = breakerOpen
+		b.openedAt = now
+	}
+}
+
+// abandon releases the trial request of a half open circuit that ended without an outcome.
+func (b *circuitBreaker) abandon() {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	if b.state == breakerHalfOpen {
+		b.state = breakerOpen
+	}
+}
+
+// breakerRegistry keeps a circuitBreaker per host.
+type breakerRegistry struct {
+	policy   BreakerPolicy
+	m        sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+func newBreakerRegistry(policy BreakerPolicy) *breakerRegistry {
+	return &breakerRegistry{policy: policy, breakers: map[string]*circuitBreaker{}}
+}
+
+func (r *breakerRegistry) get(host string) *circuitBreaker {
+	r.m.Lock()
+	defer r.m.Unlock()
+	b, ok := r.breakers[host]
+
+	if !ok {
+		b = &circuitBreaker{policy: r.policy}
+		r.breakers[host] = b
+	}
+
+	return b
+}
@@ -0,0 +1,100 @@
+package apiclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/EloYaniel/academy-go-q42021/cache"
+)
+
+// CachedApiClient struct implements ApiClient keeping the responses of Get in a cache by URL.
+// Not found responses are kept too, so missing resources are not asked for on every call.
+// The other requests are sent as they are, and purge the cache when they succeed.
+type CachedApiClient struct {
+	client ApiClient
+	cache  *cache.Cache
+}
+
+// NewCachedApiClient function creates a new instance of type CachedApiClient.
+func NewCachedApiClient(client ApiClient, c *cache.Cache) *CachedApiClient {
+	return &CachedApiClient{client: client, cache: c}
+}
+
+// Get requests url with params through the cache and decodes the JSON body into response.
+func (api *CachedApiClient) Get(ctx context.Context, url string, params map[string]interface{}, response interface{}) error {
+	key, err := encodeParams(url, params)
+
+	if err != nil {
+		return err
+	}
+	value, err := api.cache.GetOrLoad(ctx, key, func(ctx context.Context) (interface{}, error) {
+		var body json.RawMessage
+		err := api.client.Get(ctx, url, params, &body)
+		var statusErr *StatusError
+
+		if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+			return statusErr, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		return []byte(body), nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if statusErr, ok := value.(*StatusError); ok {
+		return statusErr
+	}
+
+	if response == nil {
+		return nil
+	}
+
+	if err = json.Unmarshal(value.([]byte), response); err != nil {
+		return errors.New(fmt.Sprint("error parsing body response:", err.Error()))
+	}
+
+	return nil
+}
+
+// GetIfModified sends the conditional request as it is, the validators already avoid the transfer.
+func (api *CachedApiClient) GetIfModified(ctx context.Context, url string, params map[string]interface{}, validators Validators, response interface{}) (Validators, bool, error) {
+	return api.client.GetIfModified(ctx, url, params, validators, response)
+}
+
+// Post sends body as JSON to url and decodes the JSON body into response.
+func (api *CachedApiClient) Post(ctx context.Context, url string, body interface{}, headers map[string]string, response interface{}) error {
+	return api.purgeOnSuccess(api.client.Post(ctx, url, body, headers, response))
+}
+
+// Put sends body as JSON to url and decodes the JSON body into response.
+func (api *CachedApiClient) Put(ctx context.Context, url string, body interface{}, headers map[string]string, response interface{}) error {
+	return api.purgeOnSuccess(api.client.Put(ctx, url, body, headers, response))
+}
+
+// Patch sends body as JSON to url and decodes the JSON body into response.
+func (api *CachedApiClient) Patch(ctx context.Context, url string, body interface{}, headers map[string]string, response interface{}) error {
+	return api.purgeOnSuccess(api.client.Patch(ctx, url, body, headers, response))
+}
+
+// Delete requests the deletion of url and decodes the JSON body into response.
+func (api *CachedApiClient) Delete(ctx context.Context, url string, headers map[string]string, response interface{}) error {
+	return api.purgeOnSuccess(api.client.Delete(ctx, url, headers, response))
+}
+
+// purgeOnSuccess drops the cached responses after a request that may have changed them.
+func (api *CachedApiClient) purgeOnSuccess(err error) error {
+	if err == nil {
+		api.cache.Purge()
+	}
+
+	return err
+}
@@ -0,0 +1,83 @@
+package apiclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/EloYaniel/academy-go-q42021/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+// newCountingServer answers status with body, counting the requests.
+func newCountingServer(status *int32, body string) (*httptest.Server, *int32) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		res.WriteHeader(int(atomic.LoadInt32(status)))
+		res.Write([]byte(body))
+	}))
+
+	return server, &hits
+}
+
+func Test_CachedApiClient_ShouldCacheResponses(t *testing.T) {
+	status := int32(http.StatusOK)
+	server, hits := newCountingServer(&status, `{"Name": "Juan", "LastName": "Alonso"}`)
+	defer server.Close()
+	client := NewCachedApiClient(NewHttpApiClient(time.Second, RetryPolicy{}, BreakerPolicy{}), cache.New("test", 10, time.Minute, 0))
+
+	for i := 0; i < 2; i++ {
+		resp := responseBody{}
+		err := client.Get(context.Background(), server.URL, map[string]interface{}{"id": 1}, &resp)
+
+		assert.Nil(t, err)
+		assert.Equal(t, responseBody{Name: "Juan", LastName: "Alonso"}, resp)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(hits))
+
+	err := client.Get(context.Background(), server.URL, map[string]interface{}{"id": 2}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(hits))
+
+	err = client.Post(context.Background(), server.URL, nil, nil, nil)
+	assert.Nil(t, err)
+	err = client.Get(context.Background(), server.URL, map[string]interface{}{"id": 1}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(4), atomic.LoadInt32(hits))
+}
+
+func Test_CachedApiClient_ShouldCacheNotFound(t *testing.T) {
+	status := int32(http.StatusNotFound)
+	server, hits := newCountingServer(&status, "{}")
+	defer server.Close()
+	client := NewCachedApiClient(NewHttpApiClient(time.Second, RetryPolicy{}, BreakerPolicy{}), cache.New("test", 10, time.Minute, 0))
+
+	for i := 0; i < 2; i++ {
+		err := client.Get(context.Background(), server.URL, nil, &responseBody{})
+
+		assert.EqualError(t, err, "unexpected status 404: {}")
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(hits))
+}
+
+func Test_CachedApiClient_ShouldServeStaleResponsesOnError(t *testing.T) {
+	status := int32(http.StatusOK)
+	server, hits := newCountingServer(&status, `{"Name": "Juan"}`)
+	defer server.Close()
+	client := NewCachedApiClient(NewHttpApiClient(time.Second, RetryPolicy{}, BreakerPolicy{}), cache.New("test", 10, time.Nanosecond, time.Minute))
+
+	err := client.Get(context.Background(), server.URL, nil, &responseBody{})
+	assert.Nil(t, err)
+	atomic.StoreInt32(&status, http.StatusInternalServerError)
+
+	resp := responseBody{}
+	err = client.Get(context.Background(), server.URL, nil, &resp)
+
+	assert.Nil(t, err)
+	assert.Equal(t, responseBody{Name: "Juan"}, resp)
+	assert.Equal(t, int32(2), atomic.LoadInt32(hits))
+}
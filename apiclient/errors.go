@@ -0,0 +1,28 @@
+package apiclient
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrCircuitOpen is returned without calling the host while its circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// maxErrorBody is the amount of body bytes included in the message of a StatusError.
+const maxErrorBody = 256
+
+// StatusError is returned when the upstream API answers with a non 2xx status.
+type StatusError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (err *StatusError) Error() string {
+	body := err.Body
+
+	if len(body) > maxErrorBody {
+		body = body[:maxErrorBody]
+	}
+
+	return fmt.Sprint("unexpected status ", err.StatusCode, ": ", string(body))
+}
@@ -1,11 +1,15 @@
 package apiclient
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"sync"
 	"time"
 )
@@ -13,39 +17,263 @@ import (
 var instance *HttpApiClient
 
 type HttpApiClient struct {
-	client *http.Client
+	client   *http.Client
+	retry    RetryPolicy
+	breakers *breakerRegistry
+	sleep    func(ctx context.Context, d time.Duration) error
+	now      func() time.Time
+	observer CallObserver
 }
 
 var once = sync.Once{}
 
 func GetHttpApiClientInstance() *HttpApiClient {
 	once.Do(func() {
-		client := &http.Client{
-			Timeout: time.Second * 10,
-		}
-		instance = &HttpApiClient{client: client}
+		instance = NewHttpApiClient(time.Second*10, DefaultRetryPolicy, DefaultBreakerPolicy)
 	})
 
 	return instance
 }
 
-func (api *HttpApiClient) Get(url string, params map[string]interface{}, response interface{}) error {
-	resp, err := api.client.Get(url)
+// NewHttpApiClient function creates a new instance of type HttpApiClient.
+func NewHttpApiClient(timeout time.Duration, retry RetryPolicy, breaker BreakerPolicy) *HttpApiClient {
+	return &HttpApiClient{
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: http.DefaultTransport.(*http.Transport).Clone(),
+		},
+		retry:    retry,
+		breakers: newBreakerRegistry(breaker),
+		sleep:    sleepContext,
+		now:      time.Now,
+		observer: nopCallObserver{},
+	}
+}
+
+// WithObserver sets the observer notified of every attempt made by the client.
+func (api *HttpApiClient) WithObserver(observer CallObserver) *HttpApiClient {
+	api.observer = observer
+
+	return api
+}
+
+// Get requests url with params encoded in the query string and decodes the JSON body into response.
+func (api *HttpApiClient) Get(ctx context.Context, url string, params map[string]interface{}, response interface{}) error {
+	_, err := api.do(ctx, http.MethodGet, url, params, nil, nil, response)
+
+	return err
+}
+
+// GetIfModified requests url like Get, sending validators as If-None-Match and If-Modified-Since.
+// When the upstream API answers 304 response is left untouched, modified is false and validators are returned as they are.
+func (api *HttpApiClient) GetIfModified(ctx context.Context, url string, params map[string]interface{}, validators Validators, response interface{}) (Validators, bool, error) {
+	headers := map[string]string{}
+
+	if validators.ETag != "" {
+		headers["If-None-Match"] = validators.ETag
+	}
+
+	if validators.LastModified != "" {
+		headers["If-Modified-Since"] = validators.LastModified
+	}
+	header, err := api.do(ctx, http.MethodGet, url, params, nil, headers, response)
+	var statusErr *StatusError
+
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotModified {
+		return validators, false, nil
+	}
+
+	if err != nil {
+		return validators, false, err
+	}
+
+	return Validators{ETag: header.Get("ETag"), LastModified: header.Get("Last-Modified")}, true, nil
+}
+
+// Post sends body as JSON to url and decodes the JSON body into response.
+func (api *HttpApiClient) Post(ctx context.Context, url string, body interface{}, headers map[string]string, response interface{}) error {
+	_, err := api.do(ctx, http.MethodPost, url, nil, body, headers, response)
+
+	return err
+}
+
+// Put sends body as JSON to url and decodes the JSON body into response.
+func (api *HttpApiClient) Put(ctx context.Context, url string, body interface{}, headers map[string]string, response interface{}) error {
+	_, err := api.do(ctx, http.MethodPut, url, nil, body, headers, response)
+
+	return err
+}
+
+// Patch sends body as JSON to url and decodes the JSON body into response.
+func (api *HttpApiClient) Patch(ctx context.Context, url string, body interface{}, headers map[string]string, response interface{}) error {
+	_, err := api.do(ctx, http.MethodPatch, url, nil, body, headers, response)
+
+	return err
+}
+
+// Delete requests the deletion of url and decodes the JSON body into response.
+func (api *HttpApiClient) Delete(ctx context.Context, url string, headers map[string]string, response interface{}) error {
+	_, err := api.do(ctx, http.MethodDelete, url, nil, nil, headers, response)
+
+	return err
+}
+
+// Close releases the idle connections kept by the client.
+func (api *HttpApiClient) Close(ctx context.Context) error {
+	api.client.CloseIdleConnections()
+
+	return nil
+}
+
+// do sends the request retrying it according to the RetryPolicy and decodes the JSON body into response.
+// It returns the header of the last response.
+func (api *HttpApiClient) do(ctx context.Context, method string, rawURL string, params map[string]interface{}, body interface{}, headers map[string]string, response interface{}) (http.Header, error) {
+	reqURL, err := encodeParams(rawURL, params)
+
+	if err != nil {
+		return nil, err
+	}
+	var reqBody []byte
+
+	if body != nil {
+		reqBody, err = json.Marshal(body)
+
+		if err != nil {
+			return nil, errors.New(fmt.Sprint("error encoding body request:", err.Error()))
+		}
+	}
+	u, err := url.Parse(reqURL)
 
 	if err != nil {
-		return err
+		return nil, errors.New(fmt.Sprint("error parsing url:", err.Error()))
+	}
+	breaker := api.breakers.get(u.Host)
+
+	for attempt := 0; ; attempt++ {
+		if !breaker.allow(api.now()) {
+			api.observer.ObserveCall(method, u.Host, 0, 0, ErrCircuitOpen)
+
+			return nil, fmt.Errorf("%w: %s", ErrCircuitOpen, u.Host)
+		}
+		started := api.now()
+		status, header, buf, err := api.send(ctx, method, reqURL, reqBody, headers)
+		api.observer.ObserveCall(method, u.Host, status, api.now().Sub(started), err)
+
+		if ctx.Err() != nil {
+			breaker.abandon()
+
+			return nil, ctx.Err()
+		}
+		breaker.record(err == nil && status < http.StatusInternalServerError, api.now())
+
+		if attempt < api.retry.MaxRetries && shouldRetry(method, status, err) {
+			delay := api.retry.backoff(attempt)
+
+			if after, ok := retryAfter(header, api.now()); ok {
+				delay = after
+			}
+
+			if api.retry.MaxDelay <= 0 || delay <= api.retry.MaxDelay {
+				if err := api.sleep(ctx, delay); err != nil {
+					return nil, err
+				}
+
+				continue
+			}
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if status < 200 || status > 299 {
+			return header, &StatusError{StatusCode: status, Body: buf}
+		}
+
+		if response == nil || status == http.StatusNoContent {
+			return header, nil
+		}
+
+		err = json.Unmarshal(buf, &response)
+		if err != nil {
+			return header, errors.New(fmt.Sprint("error parsing body response:", err.Error()))
+		}
+
+		return header, nil
+	}
+}
+
+// send makes a single attempt of the request.
+func (api *HttpApiClient) send(ctx context.Context, method string, reqURL string, body []byte, headers map[string]string) (int, http.Header, []byte, error) {
+	var reqBody io.Reader
+
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, reqBody)
+
+	if err != nil {
+		return 0, nil, nil, errors.New(fmt.Sprint("error creating request:", err.Error()))
+	}
+	req.Header.Set("Accept", "application/json")
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := api.client.Do(req)
+
+	if err != nil {
+		return 0, nil, nil, err
 	}
 	defer resp.Body.Close()
 	buf, err := ioutil.ReadAll(resp.Body)
 
 	if err != nil {
-		return errors.New(fmt.Sprint("error reading body response:", err.Error()))
+		return 0, nil, nil, errors.New(fmt.Sprint("error reading body response:", err.Error()))
+	}
+
+	return resp.StatusCode, resp.Header, buf, nil
+}
+
+// encodeParams adds params to the query string of rawURL.
+// Slices are encoded as repeated keys and any other value with its default format.
+func encodeParams(rawURL string, params map[string]interface{}) (string, error) {
+	if len(params) == 0 {
+		return rawURL, nil
 	}
+	u, err := url.Parse(rawURL)
 
-	err = json.Unmarshal(buf, &response)
 	if err != nil {
-		return errors.New(fmt.Sprint("error parsing body response:", err.Error()))
+		return "", errors.New(fmt.Sprint("error parsing url:", err.Error()))
 	}
+	q := u.Query()
 
-	return nil
+	for key, value := range params {
+		q.Del(key)
+
+		switch v := value.(type) {
+		case nil:
+		case []string:
+			for _, item := range v {
+				q.Add(key, item)
+			}
+		case []int:
+			for _, item := range v {
+				q.Add(key, fmt.Sprint(item))
+			}
+		case []interface{}:
+			for _, item := range v {
+				q.Add(key, fmt.Sprint(item))
+			}
+		default:
+			q.Add(key, fmt.Sprint(v))
+		}
+	}
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
 }
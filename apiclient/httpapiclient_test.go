@@ -1,10 +1,16 @@
 package apiclient
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"runtime"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -56,11 +62,11 @@ func Test_Get_Suite(t *testing.T) {
 			hasError:      false,
 		},
 		{
-			name:             "Should return error if GET client fails",
-			apiResponse:      nil,
+			name:             "Should return error on non 2xx status",
+			apiResponse:      []byte("moved"),
 			expectedResponse: nil,
 			statusCode:       301,
-			expectedError:    errors.New("301 response missing Location header"),
+			expectedError:    errors.New("unexpected status 301: moved"),
 			hasError:         true,
 		},
 	}
@@ -78,7 +84,7 @@ func Test_Get_Suite(t *testing.T) {
 
 			resp := responseBody{}
 
-			err := client.Get(testServer.URL, nil, &resp)
+			err := client.Get(context.Background(), testServer.URL, nil, &resp)
 
 			if tc.hasError {
 				assert.Contains(t, err.Error(), tc.expectedError.Error())
@@ -89,3 +95,483 @@ func Test_Get_Suite(t *testing.T) {
 		})
 	}
 }
+
+func Test_Get_ShouldEncodeParams(t *testing.T) {
+	testCases := []struct {
+		name          string
+		url           string
+		params        map[string]interface{}
+		expectedQuery string
+	}{
+		{
+			name:          "Should not change url without params",
+			url:           "/users?page=1",
+			params:        nil,
+			expectedQuery: "page=1",
+		},
+		{
+			name:          "Should encode scalar params",
+			url:           "/users",
+			params:        map[string]interface{}{"page": 2, "name": "Juan Alonso", "active": true},
+			expectedQuery: "active=true&name=Juan+Alonso&page=2",
+		},
+		{
+			name:          "Should encode slices as repeated keys",
+			url:           "/users",
+			params:        map[string]interface{}{"id": []int{1, 2}, "team": []string{"BAL", "NYY"}},
+			expectedQuery: "id=1&id=2&team=BAL&team=NYY",
+		},
+		{
+			name:          "Should replace params already in url",
+			url:           "/users?page=1&per_page=6",
+			params:        map[string]interface{}{"page": 3},
+			expectedQuery: "page=3&per_page=6",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var query string
+			testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+				query = req.URL.RawQuery
+				res.Write([]byte("{}"))
+			}))
+			defer testServer.Close()
+
+			resp := responseBody{}
+			err := GetHttpApiClientInstance().Get(context.Background(), testServer.URL+tc.url, tc.params, &resp)
+
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedQuery, query)
+		})
+	}
+}
+
+func Test_Verbs_Suite(t *testing.T) {
+	body := responseBody{Name: "Juan", LastName: "Alonso"}
+	headers := map[string]string{"Authorization": "Bearer token"}
+	testCases := []struct {
+		name           string
+		expectedMethod string
+		hasBody        bool
+		call           func(client *HttpApiClient, url string, response interface{}) error
+	}{
+		{
+			name:           "Should send POST with JSON body",
+			expectedMethod: http.MethodPost,
+			hasBody:        true,
+			call: func(client *HttpApiClient, url string, response interface{}) error {
+				return client.Post(context.Background(), url, body, headers, response)
+			},
+		},
+		{
+			name:           "Should send PUT with JSON body",
+			expectedMethod: http.MethodPut,
+			hasBody:        true,
+			call: func(client *HttpApiClient, url string, response interface{}) error {
+				return client.Put(context.Background(), url, body, headers, response)
+			},
+		},
+		{
+			name:           "Should send PATCH with JSON body",
+			expectedMethod: http.MethodPatch,
+			hasBody:        true,
+			call: func(client *HttpApiClient, url string, response interface{}) error {
+				return client.Patch(context.Background(), url, body, headers, response)
+			},
+		},
+		{
+			name:           "Should send DELETE without body",
+			expectedMethod: http.MethodDelete,
+			hasBody:        false,
+			call: func(client *HttpApiClient, url string, response interface{}) error {
+				return client.Delete(context.Background(), url, headers, response)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var method, contentType, authorization string
+			var received []byte
+			testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+				method = req.Method
+				contentType = req.Header.Get("Content-Type")
+				authorization = req.Header.Get("Authorization")
+				received, _ = ioutil.ReadAll(req.Body)
+				res.Write([]byte("{\"Name\": \"Ana\", \"LastName\": \"Perez\"}"))
+			}))
+			defer testServer.Close()
+
+			resp := responseBody{}
+			err := tc.call(GetHttpApiClientInstance(), testServer.URL, &resp)
+
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedMethod, method)
+			assert.Equal(t, "Bearer token", authorization)
+			assert.Equal(t, responseBody{Name: "Ana", LastName: "Perez"}, resp)
+
+			if tc.hasBody {
+				sent := responseBody{}
+				assert.Nil(t, json.Unmarshal(received, &sent))
+				assert.Equal(t, body, sent)
+				assert.Equal(t, "application/json", contentType)
+			} else {
+				assert.Empty(t, received)
+			}
+		})
+	}
+}
+
+func Test_Delete_ShouldIgnoreNoContent(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusNoContent)
+	}))
+	defer testServer.Close()
+
+	resp := responseBody{}
+	err := GetHttpApiClientInstance().Delete(context.Background(), testServer.URL, nil, &resp)
+
+	assert.Nil(t, err)
+	assert.Equal(t, responseBody{}, resp)
+}
+
+func Test_GetIfModified_ShouldSendValidators(t *testing.T) {
+	lastModified := "Tue, 02 Nov 2021 10:00:00 GMT"
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("If-None-Match") == `"v1"` && req.Header.Get("If-Modified-Since") == lastModified {
+			res.WriteHeader(http.StatusNotModified)
+
+			return
+		}
+		res.Header().Set("ETag", `"v1"`)
+		res.Header().Set("Last-Modified", lastModified)
+		res.Write([]byte("{\"Name\": \"Juan\", \"LastName\": \"Alonso\"}"))
+	}))
+	defer testServer.Close()
+	client := NewHttpApiClient(time.Second, RetryPolicy{}, BreakerPolicy{})
+
+	resp := responseBody{}
+	validators, modified, err := client.GetIfModified(context.Background(), testServer.URL, nil, Validators{}, &resp)
+
+	assert.Nil(t, err)
+	assert.True(t, modified)
+	assert.Equal(t, Validators{ETag: `"v1"`, LastModified: lastModified}, validators)
+	assert.Equal(t, responseBody{Name: "Juan", LastName: "Alonso"}, resp)
+
+	resp = responseBody{}
+	validators, modified, err = client.GetIfModified(context.Background(), testServer.URL, nil, validators, &resp)
+
+	assert.Nil(t, err)
+	assert.False(t, modified)
+	assert.Equal(t, Validators{ETag: `"v1"`, LastModified: lastModified}, validators)
+	assert.Equal(t, responseBody{}, resp)
+}
+
+func Test_GetIfModified_ShouldReturnStatusErrors(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusNotFound)
+		res.Write([]byte("not found"))
+	}))
+	defer testServer.Close()
+	client := NewHttpApiClient(time.Second, RetryPolicy{}, BreakerPolicy{})
+
+	validators, modified, err := client.GetIfModified(context.Background(), testServer.URL, nil, Validators{ETag: `"v1"`}, nil)
+
+	assert.EqualError(t, err, "unexpected status 404: not found")
+	assert.False(t, modified)
+	assert.Equal(t, Validators{ETag: `"v1"`}, validators)
+}
+
+func Test_Post_ShouldStopOnContextCancel(t *testing.T) {
+	release := make(chan struct{})
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		<-release
+	}))
+	defer testServer.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := GetHttpApiClientInstance().Post(ctx, testServer.URL, responseBody{}, nil, nil)
+
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+// newTestClient creates a client that records the delays it waits for instead of sleeping.
+func Test_Get_ShouldStopOnContextCancelWithoutLeaking(t *testing.T) {
+	before := runtime.NumGoroutine()
+	release := make(chan struct{})
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		select {
+		case <-release:
+		case <-req.Context().Done():
+		}
+	}))
+	client := NewHttpApiClient(time.Second, DefaultRetryPolicy, DefaultBreakerPolicy)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	started := time.Now()
+
+	err := client.Get(ctx, testServer.URL, nil, &responseBody{})
+
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Less(t, time.Since(started), 500*time.Millisecond)
+	close(release)
+	client.Close(context.Background())
+	testServer.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), before)
+}
+
+func newTestClient(retry RetryPolicy, breaker BreakerPolicy) (*HttpApiClient, *[]time.Duration) {
+	client := NewHttpApiClient(time.Second, retry, breaker)
+	delays := &[]time.Duration{}
+	m := new(sync.Mutex)
+	client.sleep = func(ctx context.Context, d time.Duration) error {
+		m.Lock()
+		defer m.Unlock()
+		*delays = append(*delays, d)
+
+		return nil
+	}
+
+	return client, delays
+}
+
+// newSequenceServer answers each request with the next status of the sequence, repeating the last one.
+func newSequenceServer(statuses []int, header http.Header) (*httptest.Server, *int) {
+	calls := new(int)
+	m := new(sync.Mutex)
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		m.Lock()
+		status := statuses[len(statuses)-1]
+		if *calls < len(statuses) {
+			status = statuses[*calls]
+		}
+		*calls++
+		m.Unlock()
+
+		for key := range header {
+			res.Header().Set(key, header.Get(key))
+		}
+		res.WriteHeader(status)
+
+		if status == http.StatusOK {
+			res.Write([]byte("{\"Name\": \"Juan\", \"LastName\": \"Alonso\"}"))
+		} else {
+			res.Write([]byte("<html>error</html>"))
+		}
+	}))
+
+	return server, calls
+}
+
+func Test_Retry_Suite(t *testing.T) {
+	policy := RetryPolicy{MaxRetries: 2, BaseDelay: 10 * time.Millisecond, MaxDelay: time.Second}
+	testCases := []struct {
+		name           string
+		method         string
+		statuses       []int
+		header         http.Header
+		expectedCalls  int
+		expectedDelays []time.Duration
+		expectedStatus int
+	}{
+		{
+			name:           "Should retry 5xx until success",
+			method:         http.MethodGet,
+			statuses:       []int{500, 502, 200},
+			expectedCalls:  3,
+			expectedDelays: []time.Duration{10 * time.Millisecond, 20 * time.Millisecond},
+		},
+		{
+			name:           "Should return status error when retries are exhausted",
+			method:         http.MethodGet,
+			statuses:       []int{503},
+			expectedCalls:  3,
+			expectedDelays: []time.Duration{10 * time.Millisecond, 20 * time.Millisecond},
+			expectedStatus: 503,
+		},
+		{
+			name:           "Should honor Retry-After on 429",
+			method:         http.MethodPost,
+			statuses:       []int{429, 200},
+			header:         http.Header{"Retry-After": []string{"1"}},
+			expectedCalls:  2,
+			expectedDelays: []time.Duration{time.Second},
+		},
+		{
+			name:           "Should not retry when Retry-After exceeds max delay",
+			method:         http.MethodGet,
+			statuses:       []int{429, 200},
+			header:         http.Header{"Retry-After": []string{"60"}},
+			expectedCalls:  1,
+			expectedDelays: []time.Duration{},
+			expectedStatus: 429,
+		},
+		{
+			name:           "Should not retry POST on 5xx",
+			method:         http.MethodPost,
+			statuses:       []int{500, 200},
+			expectedCalls:  1,
+			expectedDelays: []time.Duration{},
+			expectedStatus: 500,
+		},
+		{
+			name:           "Should not retry 4xx",
+			method:         http.MethodGet,
+			statuses:       []int{404, 200},
+			expectedCalls:  1,
+			expectedDelays: []time.Duration{},
+			expectedStatus: 404,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server, calls := newSequenceServer(tc.statuses, tc.header)
+			defer server.Close()
+			client, delays := newTestClient(policy, BreakerPolicy{})
+
+			resp := responseBody{}
+			var err error
+			if tc.method == http.MethodGet {
+				err = client.Get(context.Background(), server.URL, nil, &resp)
+			} else {
+				err = client.Post(context.Background(), server.URL, resp, nil, &resp)
+			}
+
+			assert.Equal(t, tc.expectedCalls, *calls)
+			assert.Equal(t, tc.expectedDelays, *delays)
+
+			if tc.expectedStatus == 0 {
+				assert.Nil(t, err)
+				assert.Equal(t, responseBody{Name: "Juan", LastName: "Alonso"}, resp)
+
+				return
+			}
+			var statusErr *StatusError
+			assert.True(t, errors.As(err, &statusErr))
+			assert.Equal(t, tc.expectedStatus, statusErr.StatusCode)
+			assert.Equal(t, "<html>error</html>", string(statusErr.Body))
+		})
+	}
+}
+
+func Test_Retry_ShouldRetryTransportErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {}))
+	url := server.URL
+	server.Close()
+	client, delays := newTestClient(RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: time.Second}, BreakerPolicy{})
+
+	err := client.Get(context.Background(), url, nil, &responseBody{})
+
+	assert.NotNil(t, err)
+	assert.Len(t, *delays, 2)
+}
+
+func Test_Retry_ShouldStopWhenContextIsDone(t *testing.T) {
+	server, calls := newSequenceServer([]int{500}, nil)
+	defer server.Close()
+	client := NewHttpApiClient(time.Second, RetryPolicy{MaxRetries: 5, BaseDelay: time.Hour, MaxDelay: time.Hour}, BreakerPolicy{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := client.Put(ctx, server.URL, responseBody{}, nil, nil)
+
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, 1, *calls)
+}
+
+func Test_CircuitBreaker_ShouldFailFastWhileOpen(t *testing.T) {
+	server, calls := newSequenceServer([]int{500, 500, 200}, nil)
+	defer server.Close()
+	client, _ := newTestClient(RetryPolicy{}, BreakerPolicy{FailureThreshold: 2, OpenTimeout: time.Minute})
+	now := time.Now()
+	client.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		var statusErr *StatusError
+		err := client.Get(context.Background(), server.URL, nil, &responseBody{})
+		assert.True(t, errors.As(err, &statusErr))
+	}
+
+	err := client.Get(context.Background(), server.URL, nil, &responseBody{})
+
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+	assert.Equal(t, 2, *calls)
+
+	other, otherCalls := newSequenceServer([]int{200}, nil)
+	defer other.Close()
+	err = client.Get(context.Background(), other.URL, nil, &responseBody{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, *otherCalls)
+}
+
+func Test_CircuitBreaker_ShouldCloseAfterSuccessfulTrial(t *testing.T) {
+	server, calls := newSequenceServer([]int{500, 500, 200}, nil)
+	defer server.Close()
+	client, _ := newTestClient(RetryPolicy{}, BreakerPolicy{FailureThreshold: 1, OpenTimeout: time.Minute})
+	now := time.Now()
+	client.now = func() time.Time { return now }
+
+	client.Get(context.Background(), server.URL, nil, &responseBody{})
+	now = now.Add(2 * time.Minute)
+	err := client.Get(context.Background(), server.URL, nil, &responseBody{})
+
+	var statusErr *StatusError
+	assert.True(t, errors.As(err, &statusErr))
+	assert.True(t, errors.Is(client.Get(context.Background(), server.URL, nil, &responseBody{}), ErrCircuitOpen))
+
+	now = now.Add(2 * time.Minute)
+	err = client.Get(context.Background(), server.URL, nil, &responseBody{})
+
+	assert.Nil(t, err)
+	assert.Nil(t, client.Get(context.Background(), server.URL, nil, &responseBody{}))
+	assert.Equal(t, 4, *calls)
+}
+
+// callObservation has the arguments of a call to ObserveCall.
+type callObservation struct {
+	status int
+	err    error
+}
+
+type recordingCallObserver struct {
+	observed []callObservation
+}
+
+func (o *recordingCallObserver) ObserveCall(method string, host string, status int, d time.Duration, err error) {
+	o.observed = append(o.observed, callObservation{status: status, err: err})
+}
+
+func Test_Observer_ShouldObserveEveryAttempt(t *testing.T) {
+	server, _ := newSequenceServer([]int{503, 200}, nil)
+	defer server.Close()
+	client, _ := newTestClient(RetryPolicy{MaxRetries: 1}, BreakerPolicy{FailureThreshold: 2, OpenTimeout: time.Minute})
+	observer := &recordingCallObserver{}
+	client.WithObserver(observer)
+
+	err := client.Get(context.Background(), server.URL, nil, &responseBody{})
+	assert.Nil(t, err)
+
+	failing, _ := newSequenceServer([]int{500}, nil)
+	defer failing.Close()
+	client.Get(context.Background(), failing.URL, nil, &responseBody{})
+	err = client.Get(context.Background(), failing.URL, nil, &responseBody{})
+
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+	assert.Equal(t, []callObservation{
+		{status: 503},
+		{status: 200},
+		{status: 500},
+		{status: 500},
+		{status: 0, err: ErrCircuitOpen},
+	}, observer.observed)
+}
@@ -0,0 +1,16 @@
+package apiclient
+
+import "time"
+
+// CallObserver is notified of every attempt made by HttpApiClient, to expose it as metrics.
+type CallObserver interface {
+	// ObserveCall is called after each attempt. status is 0 when no response was received,
+	// err is ErrCircuitOpen when the attempt was not made because the host circuit was open.
+	ObserveCall(method string, host string, status int, d time.Duration, err error)
+}
+
+// nopCallObserver ignores every call.
+type nopCallObserver struct{}
+
+func (nopCallObserver) ObserveCall(method string, host string, status int, d time.Duration, err error) {
+}
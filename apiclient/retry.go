@@ -0,0 +1,115 @@
+package apiclient
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy struct configures how failed requests are retried.
+// Requests are retried on 429 and, for idempotent methods, on 5xx and transport errors.
+type RetryPolicy struct {
+	// MaxRetries is the amount of retries after the first attempt.
+	MaxRetries int
+	// BaseDelay is the delay before the first retry, doubled on every following one.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between retries. A Retry-After longer than it stops retrying.
+	MaxDelay time.Duration
+	// Jitter is the fraction of the delay, between 0 and 1, randomly taken off to spread retries.
+	Jitter float64
+}
+
+// DefaultRetryPolicy is the RetryPolicy used by the singleton client.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 3,
+	BaseDelay:  200 * time.Millisecond,
+	MaxDelay:   5 * time.Second,
+	Jitter:     0.2,
+}
+
+// maxBackoff bounds the doubling of the delay when MaxDelay does not cap it, so it does not overflow.
+const maxBackoff = time.Duration(math.MaxInt64 / 2)
+
+// backoff gets the delay before the given retry, starting at 0. A MaxDelay of 0 does not cap it.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	delay := p.BaseDelay
+	for i := 0; i < retry && delay < maxBackoff && (p.MaxDelay <= 0 || delay < p.MaxDelay); i++ {
+		delay *= 2
+	}
+
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	if p.Jitter > 0 {
+		delay -= time.Duration(rand.Float64() * p.Jitter * float64(delay))
+	}
+
+	return delay
+}
+
+// shouldRetry tells if an attempt that got status or err may be retried.
+func shouldRetry(method string, status int, err error) bool {
+	if status == http.StatusTooManyRequests {
+		return true
+	}
+
+	if !isIdempotent(method) {
+		return false
+	}
+
+	return err != nil || status >= http.StatusInternalServerError
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return false
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP date.
+func retryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	value := header.Get("Retry-After")
+
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := http.ParseTime(value)
+
+	if err != nil {
+		return 0, false
+	}
+
+	if date.Before(now) {
+		return 0, true
+	}
+
+	return date.Sub(now), true
+}
+
+// sleepContext waits for d or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
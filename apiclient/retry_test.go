@@ -0,0 +1,100 @@
+package apiclient
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RetryPolicy_Backoff_Suite(t *testing.T) {
+	capped := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	uncapped := RetryPolicy{BaseDelay: 100 * time.Millisecond}
+	testCases := []struct {
+		name          string
+		policy        RetryPolicy
+		retry         int
+		expectedDelay time.Duration
+	}{
+		{name: "Should wait base delay on first retry", policy: capped, retry: 0, expectedDelay: 100 * time.Millisecond},
+		{name: "Should double delay", policy: capped, retry: 2, expectedDelay: 400 * time.Millisecond},
+		{name: "Should cap delay", policy: capped, retry: 10, expectedDelay: time.Second},
+		{name: "Should wait base delay on first retry without cap", policy: uncapped, retry: 0, expectedDelay: 100 * time.Millisecond},
+		{name: "Should double delay without cap", policy: uncapped, retry: 2, expectedDelay: 400 * time.Millisecond},
+		{name: "Should not cap delay without max delay", policy: uncapped, retry: 10, expectedDelay: 102400 * time.Millisecond},
+		{name: "Should not overflow without max delay", policy: uncapped, retry: 100, expectedDelay: 100 * time.Millisecond << 36},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedDelay, tc.policy.backoff(tc.retry))
+		})
+	}
+}
+
+func Test_RetryPolicy_Backoff_ShouldApplyJitter(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second, Jitter: 0.5}
+
+	for i := 0; i < 100; i++ {
+		delay := policy.backoff(1)
+
+		assert.LessOrEqual(t, delay, 200*time.Millisecond)
+		assert.GreaterOrEqual(t, delay, 100*time.Millisecond)
+	}
+}
+
+func Test_ShouldRetry_Suite(t *testing.T) {
+	testCases := []struct {
+		name     string
+		method   string
+		status   int
+		err      error
+		expected bool
+	}{
+		{name: "Should retry GET on 500", method: http.MethodGet, status: 500, expected: true},
+		{name: "Should retry GET on 429", method: http.MethodGet, status: 429, expected: true},
+		{name: "Should retry GET on transport error", method: http.MethodGet, err: errors.New("connection reset"), expected: true},
+		{name: "Should not retry GET on 404", method: http.MethodGet, status: 404, expected: false},
+		{name: "Should retry POST on 429", method: http.MethodPost, status: 429, expected: true},
+		{name: "Should not retry POST on 503", method: http.MethodPost, status: 503, expected: false},
+		{name: "Should not retry PATCH on transport error", method: http.MethodPatch, err: errors.New("connection reset"), expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, shouldRetry(tc.method, tc.status, tc.err))
+		})
+	}
+}
+
+func Test_RetryAfter_Suite(t *testing.T) {
+	now := time.Date(2021, 12, 1, 10, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name          string
+		value         string
+		expectedDelay time.Duration
+		expectedOk    bool
+	}{
+		{name: "Should ignore missing header", value: "", expectedOk: false},
+		{name: "Should parse seconds", value: "3", expectedDelay: 3 * time.Second, expectedOk: true},
+		{name: "Should parse HTTP date", value: "Wed, 01 Dec 2021 10:00:05 GMT", expectedDelay: 5 * time.Second, expectedOk: true},
+		{name: "Should not wait for past date", value: "Wed, 01 Dec 2021 09:00:00 GMT", expectedDelay: 0, expectedOk: true},
+		{name: "Should ignore invalid value", value: "soon", expectedOk: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			header := http.Header{}
+			if tc.value != "" {
+				header.Set("Retry-After", tc.value)
+			}
+
+			delay, ok := retryAfter(header, now)
+
+			assert.Equal(t, tc.expectedOk, ok)
+			assert.Equal(t, tc.expectedDelay, delay)
+		})
+	}
+}
@@ -1,35 +1,195 @@
 package app
 
 import (
+	"context"
+	"log/slog"
+	"net/http"
+
 	"github.com/EloYaniel/academy-go-q42021/apiclient"
+	"github.com/EloYaniel/academy-go-q42021/cache"
+	"github.com/EloYaniel/academy-go-q42021/config"
 	ctr "github.com/EloYaniel/academy-go-q42021/controllers"
+	"github.com/EloYaniel/academy-go-q42021/middleware"
+	r "github.com/EloYaniel/academy-go-q42021/repositories/contracts"
 	repo "github.com/EloYaniel/academy-go-q42021/repositories/implementations"
 	srv "github.com/EloYaniel/academy-go-q42021/services"
 	"github.com/gorilla/mux"
 )
 
-func InitApp() *mux.Router {
-	apiclient := apiclient.GetHttpApiClientInstance()
+// InitApp builds the router with the repositories, services and controllers set by cfg.
+// The hooks that release them are registered in lc.
+func InitApp(cfg config.Config, lc *Lifecycle, logger *slog.Logger) (http.Handler, error) {
+	appmetrics := newAppMetrics()
+	apiclient := newApiClient(cfg.Client).WithObserver(appmetrics)
+	lc.OnStop("api client", apiclient.Close)
+
+	healthservice := srv.NewHealthService(cfg.Health.CheckTimeout, logger)
+	var quarantine *repo.Quarantine
+
+	if cfg.Data.ParseMode == "lenient" {
+		quarantine = repo.NewQuarantine().WithObserver(appmetrics)
+	}
+	mlbplayerrepository, userrepository, err := newRepositories(cfg.Data, lc, appmetrics, quarantine, healthservice)
+
+	if err != nil {
+		return nil, err
+	}
 
-	csvmlbrepository := repo.NewCSVMLBPlayerRepository("data/mlb_players.csv")
-	csvuserrepository := repo.NewCSVUserRepository("data/users.csv")
+	if cfg.Cache.TTL > 0 {
+		userrepository = repo.NewCachedUserRepository(userrepository, newCache("users_repository", cfg.Cache, appmetrics))
+	}
 
-	mlbplayerservice := srv.NewMLBPlayerService(csvmlbrepository)
-	userservice := srv.NewUserService(csvuserrepository, apiclient, "https://reqres.in/api/users")
+	mlbplayerservice := srv.NewMLBPlayerService(mlbplayerrepository, logger)
+	probeclient := newProbeApiClient(cfg.Health)
+	lc.OnStop("health api client", probeclient.Close)
+	userservice := srv.NewUserService(userrepository, apiclient, cfg.Users.URL, logger).
+		WithImportRepository(repo.NewFileUserImportRepository(usersImportFile(cfg.Data))).
+		WithProbeClient(probeclient)
 
-	healthcontroller := ctr.NewHealthController()
-	mlbplayercontroller := ctr.NewMLBPlayerController(mlbplayerservice)
-	usercontroller := ctr.NewUserController(userservice)
+	if cfg.Cache.TTL > 0 {
+		userservice.WithLookupCache(newCache("users_api", cfg.Cache, appmetrics))
+	}
+	healthservice.Register("users_api", cfg.Health.UpstreamCritical, userservice.CheckUpstream)
+
+	if cfg.Users.SyncInterval > 0 {
+		usersyncer := srv.NewUserSyncer(userservice, cfg.Users.SyncInterval, cfg.Users.SyncJitter)
+		usersyncer.Start()
+		lc.OnStop("users syncer", usersyncer.Stop)
+	}
+
+	healthcontroller := ctr.NewHealthController(healthservice)
+	quarantinecontroller := ctr.NewQuarantineController(quarantine, cfg.Data.ParseMode)
+	mlbplayercontroller := ctr.NewMLBPlayerController(mlbplayerservice, ctr.DesiredLimits{
+		MaxItems:   cfg.Workers.MaxItems,
+		MaxWorkers: cfg.Workers.MaxWorkers,
+	}, logger)
+	usercontroller := ctr.NewUserController(userservice, logger)
 
 	r := mux.NewRouter()
-	r.HandleFunc("/health", healthcontroller.CheckHealth)
-	r.HandleFunc("/mlb-players", mlbplayercontroller.GetMLBPlayers)
-	r.HandleFunc("/mlb-players/{id}", mlbplayercontroller.GetMLBPlayerByID)
-	r.HandleFunc("/users", usercontroller.GetUsers)
-	r.HandleFunc("/users/{id}", usercontroller.GetUserByID)
+	r.HandleFunc("/health", healthcontroller.CheckLiveness).Methods(http.MethodGet)
+	r.HandleFunc("/health/live", healthcontroller.CheckLiveness).Methods(http.MethodGet)
+	r.HandleFunc("/health/ready", healthcontroller.CheckReadiness).Methods(http.MethodGet)
+	r.HandleFunc("/admin/quarantine", quarantinecontroller.GetQuarantinedRows).Methods(http.MethodGet)
+	r.Handle("/metrics", appmetrics.registry.Handler()).Methods(http.MethodGet)
+	r.Handle("/mlb-players", conditional(cfg.HTTP.MLBPlayers, cfg.Data, cfg.Data.PlayersFile, false, mlbplayercontroller.GetMLBPlayers)).Methods(http.MethodGet)
+	r.HandleFunc("/mlb-players", mlbplayercontroller.CreateMLBPlayer).Methods(http.MethodPost)
+	r.HandleFunc("/mlb-players/import", mlbplayercontroller.ImportMLBPlayers).Methods(http.MethodPost)
+	r.HandleFunc("/mlb-players/export", mlbplayercontroller.ExportMLBPlayers).Methods(http.MethodGet)
+	r.HandleFunc("/mlb-players/stats", mlbplayercontroller.GetMLBPlayerStats).Methods(http.MethodGet)
+	r.HandleFunc("/mlb-players/stats/{group}", mlbplayercontroller.GetMLBPlayerStats).Methods(http.MethodGet)
+	r.Handle("/mlb-players/{id}", conditional(cfg.HTTP.MLBPlayer, cfg.Data, cfg.Data.PlayersFile, false, mlbplayercontroller.GetMLBPlayerByID)).Methods(http.MethodGet)
+	r.HandleFunc("/mlb-players/{id}", mlbplayercontroller.UpdateMLBPlayer).Methods(http.MethodPut)
+	r.HandleFunc("/mlb-players/{id}", mlbplayercontroller.PatchMLBPlayer).Methods(http.MethodPatch)
+	r.HandleFunc("/mlb-players/{id}", mlbplayercontroller.DeleteMLBPlayer).Methods(http.MethodDelete)
+	r.Handle("/users", conditional(cfg.HTTP.Users, cfg.Data, cfg.Data.UsersFile, cfg.Cache.TTL > 0, usercontroller.GetUsers)).Methods(http.MethodGet)
+	r.HandleFunc("/users/export", usercontroller.ExportUsers).Methods(http.MethodGet)
+	r.HandleFunc("/users/import", usercontroller.GetLastImport).Methods(http.MethodGet)
+	r.HandleFunc("/users/sync", usercontroller.GetSyncStatus).Methods(http.MethodGet)
+	r.HandleFunc("/users/sync", usercontroller.SyncUsers).Methods(http.MethodPost)
+	r.Handle("/users/{id}", conditional(cfg.HTTP.User, cfg.Data, cfg.Data.UsersFile, cfg.Cache.TTL > 0, usercontroller.GetUserByID)).Methods(http.MethodGet)
 	r.Path("/random-mlb-players").
-		Queries("type", "{type}", "items", "{items}", "items_per_workers", "{items_per_workers}").
+		Queries("items", "{items}", "items_per_workers", "{items_per_workers}").
+		Methods(http.MethodGet).
 		HandlerFunc(mlbplayercontroller.GetMLBPlayerDesired)
 
-	return r
+	if cfg.Auth.Enabled {
+		if err = checkRouteRoles(r); err != nil {
+			return nil, err
+		}
+		authenticator, err := newAuthenticator(cfg.Auth)
+
+		if err != nil {
+			return nil, err
+		}
+		r.Use(middleware.Authorize(authenticator, routeRole))
+	}
+
+	handler := middleware.Metrics(appmetrics, routeTemplate(r))(r)
+
+	return middleware.RequestLogger(logger)(handler), nil
+}
+
+func newApiClient(cfg config.ClientConfig) *apiclient.HttpApiClient {
+	retry := apiclient.DefaultRetryPolicy
+	retry.MaxRetries = cfg.MaxRetries
+	retry.BaseDelay = cfg.RetryBaseDelay
+	retry.MaxDelay = cfg.RetryMaxDelay
+
+	return apiclient.NewHttpApiClient(cfg.Timeout, retry, apiclient.BreakerPolicy{
+		FailureThreshold: cfg.BreakerThreshold,
+		OpenTimeout:      cfg.BreakerOpenTimeout,
+	})
+}
+
+func newProbeApiClient(cfg config.HealthConfig) *apiclient.HttpApiClient {
+	return apiclient.NewHttpApiClient(cfg.CheckTimeout, apiclient.RetryPolicy{}, apiclient.BreakerPolicy{})
+}
+
+// conditional wraps handler to answer conditional GETs, with the validators set by the version of the data file at
+// filePath, so the responses are streamed. The sqlite backend, and the files read through a cache, get an ETag
+// from the content of the responses that are small enough to buffer, their versions may not match the responses.
+func conditional(cacheControl string, cfg config.DataConfig, filePath string, cached bool, handler http.HandlerFunc) http.Handler {
+	version := func(r *http.Request) (middleware.Version, bool) {
+		if cfg.Backend == "sqlite" || cached {
+			return middleware.Version{}, false
+		}
+		tag, modified, err := repo.FileVersion(filePath)
+
+		if err != nil {
+			return middleware.Version{}, false
+		}
+
+		return middleware.Version{Tag: tag, Modified: modified}, true
+	}
+
+	return middleware.Conditional(cacheControl, version)(handler)
+}
+
+// usersImportFile gets the path of the file that keeps the state of the import of Users, next to the stored Users.
+func usersImportFile(cfg config.DataConfig) string {
+	if cfg.Backend == "sqlite" {
+		return cfg.SQLiteFile + ".users-import.json"
+	}
+
+	return cfg.UsersFile + ".import.json"
+}
+
+func newCache(name string, cfg config.CacheConfig, observer cache.Observer) *cache.Cache {
+	return cache.New(name, cfg.MaxEntries, cfg.TTL, cfg.StaleTTL).WithLoadTimeout(cfg.LoadTimeout).WithObserver(observer)
+}
+
+// newRepositories opens the repositories of the backend set by cfg and registers their readiness checks in health.
+// The CSV files are read leniently when quarantine is not nil.
+func newRepositories(cfg config.DataConfig, lc *Lifecycle, observer repo.Observer, quarantine *repo.Quarantine, health *srv.HealthService) (r.MLBPlayerRepository, r.UserRepository, error) {
+	if cfg.Backend == "sqlite" {
+		db, err := repo.OpenSQLite(cfg.SQLiteFile)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if err = repo.ImportCSVIntoSQLite(context.Background(), db, cfg.PlayersFile, cfg.UsersFile, quarantine); err != nil {
+			db.Close()
+
+			return nil, nil, err
+		}
+
+		lc.OnStop("sqlite", func(ctx context.Context) error { return db.Close() })
+		mlbplayerrepository := repo.NewSQLiteMLBPlayerRepository(db).WithObserver(observer)
+		userrepository := repo.NewSQLiteUserRepository(db)
+		health.Register("mlb_players", true, mlbplayerrepository.CheckReadable)
+		health.Register("users", true, userrepository.CheckReadable)
+		health.Register("users_writable", true, userrepository.CheckWritable)
+
+		return mlbplayerrepository, userrepository, nil
+	}
+	mlbplayerrepository := repo.NewIndexedMLBPlayerRepository(cfg.PlayersFile).WithObserver(observer).WithQuarantine(quarantine)
+	userrepository := repo.NewIndexedUserRepository(cfg.UsersFile).WithObserver(observer).WithQuarantine(quarantine)
+	lc.OnStop("mlb players repository", mlbplayerrepository.Close)
+	lc.OnStop("users repository", userrepository.Close)
+	health.Register("mlb_players", true, mlbplayerrepository.CheckReadable)
+	health.Register("users", true, userrepository.CheckReadable)
+	health.Register("users_writable", true, userrepository.CheckWritable)
+
+	return mlbplayerrepository, userrepository, nil
 }
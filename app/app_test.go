@@ -0,0 +1,56 @@
+package app
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/EloYaniel/academy-go-q42021/config"
+	e "github.com/EloYaniel/academy-go-q42021/entities"
+	repo "github.com/EloYaniel/academy-go-q42021/repositories/implementations"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_conditional_ShouldChangeTheETagWhenTheFileIsRewrittenWithTheSameModTime(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "players.csv")
+	players := repo.NewCSVMLBPlayerRepository(filePath)
+	player := e.MLBPlayer{ID: 1, Name: "Adam Donachie", Team: "BAL", Position: "Catcher", Height: 74, Weight: 180, Age: 22.99}
+	_, err := players.ImportMLBPlayers(context.Background(), []e.MLBPlayer{player}, e.ImportReplace)
+	assert.Nil(t, err)
+	info, err := os.Stat(filePath)
+	assert.Nil(t, err)
+	handler := conditional("no-cache", config.DataConfig{Backend: "csv"}, filePath, false, func(w http.ResponseWriter, r *http.Request) {
+		content, _ := ioutil.ReadFile(filePath)
+		w.Header().Set("Content-Type", "text/csv")
+		w.Write(content)
+	})
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/mlb-players", nil))
+
+		return w
+	}
+	before := get()
+
+	player.Age = 22.98
+	_, err = players.ImportMLBPlayers(context.Background(), []e.MLBPlayer{player}, e.ImportReplace)
+	assert.Nil(t, err)
+	assert.Nil(t, os.Chtimes(filePath, info.ModTime(), info.ModTime()))
+	after, err := os.Stat(filePath)
+	assert.Nil(t, err)
+	assert.Equal(t, info.Size(), after.Size())
+	r := httptest.NewRequest(http.MethodGet, "/mlb-players", nil)
+	r.Header.Set("If-None-Match", before.Header().Get("ETag"))
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, w.Header().Get("ETag"))
+	assert.NotEqual(t, before.Header().Get("ETag"), w.Header().Get("ETag"))
+	assert.NotEqual(t, before.Body.String(), w.Body.String())
+}
@@ -0,0 +1,137 @@
+package app
+
+import (
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/EloYaniel/academy-go-q42021/auth"
+	"github.com/EloYaniel/academy-go-q42021/config"
+	"github.com/gorilla/mux"
+)
+
+// public marks the routes that need no credentials.
+const public auth.Role = ""
+
+// routeRoles has the role required by every route, by method and path template.
+var routeRoles = map[string]auth.Role{
+	"GET /health":                    public,
+	"GET /health/live":               public,
+	"GET /health/ready":              public,
+	"GET /metrics":                   public,
+	"GET /admin/quarantine":          auth.RoleAdmin,
+	"GET /mlb-players":               auth.RoleRead,
+	"POST /mlb-players":              auth.RoleWrite,
+	"POST /mlb-players/import":       auth.RoleWrite,
+	"GET /mlb-players/export":        auth.RoleRead,
+	"GET /mlb-players/stats":         auth.RoleRead,
+	"GET /mlb-players/stats/{group}": auth.RoleRead,
+	"GET /mlb-players/{id}":          auth.RoleRead,
+	"PUT /mlb-players/{id}":          auth.RoleWrite,
+	"PATCH /mlb-players/{id}":        auth.RoleWrite,
+	"DELETE /mlb-players/{id}":       auth.RoleWrite,
+	"GET /users":                     auth.RoleRead,
+	"GET /users/export":              auth.RoleRead,
+	"GET /users/import":              auth.RoleRead,
+	"GET /users/sync":                auth.RoleRead,
+	"POST /users/sync":               auth.RoleAdmin,
+	"GET /users/{id}":                auth.RoleRead,
+	"GET /random-mlb-players":        auth.RoleRead,
+}
+
+// routeRole tells the role required by the route matched by the router, routes missing from routeRoles need admin.
+func routeRole(r *http.Request) (auth.Role, bool) {
+	route := mux.CurrentRoute(r)
+
+	if route == nil {
+		return auth.RoleAdmin, true
+	}
+	template, err := route.GetPathTemplate()
+
+	if err != nil {
+		return auth.RoleAdmin, true
+	}
+	role, ok := routeRoles[r.Method+" "+template]
+
+	if !ok {
+		return auth.RoleAdmin, true
+	}
+
+	return role, role != public
+}
+
+// checkRouteRoles makes sure every route of router has its role in routeRoles, so none is left to the admin fallback by mistake.
+func checkRouteRoles(router *mux.Router) error {
+	var missing []string
+	err := router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+
+		if err != nil {
+			return err
+		}
+		methods, err := route.GetMethods()
+
+		if err != nil {
+			return err
+		}
+
+		for _, method := range methods {
+			if _, ok := routeRoles[method+" "+template]; !ok {
+				missing = append(missing, method+" "+template)
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if len(missing) > 0 {
+		return errors.New("routes without a role: " + strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// newAuthenticator loads the API keys and the JWT keys set by cfg.
+func newAuthenticator(cfg config.AuthConfig) (*auth.Authenticator, error) {
+	var keys []auth.APIKey
+
+	if cfg.APIKeysFile != "" {
+		loaded, err := auth.LoadAPIKeys(cfg.APIKeysFile)
+
+		if err != nil {
+			return nil, err
+		}
+		keys = loaded
+	}
+
+	if cfg.JWTHS256SecretFile == "" && cfg.JWTRS256PublicKeyFile == "" {
+		return auth.NewAuthenticator(keys, nil), nil
+	}
+	var secret []byte
+	var publicKey *rsa.PublicKey
+
+	if cfg.JWTHS256SecretFile != "" {
+		loaded, err := auth.LoadHS256Secret(cfg.JWTHS256SecretFile)
+
+		if err != nil {
+			return nil, err
+		}
+		secret = loaded
+	}
+
+	if cfg.JWTRS256PublicKeyFile != "" {
+		loaded, err := auth.LoadRS256PublicKey(cfg.JWTRS256PublicKeyFile)
+
+		if err != nil {
+			return nil, err
+		}
+		publicKey = loaded
+	}
+
+	return auth.NewAuthenticator(keys, auth.NewJWTKeys(secret, publicKey, cfg.JWTIssuer, cfg.JWTAudience, cfg.JWTLeeway)), nil
+}
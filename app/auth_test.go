@@ -0,0 +1,59 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/EloYaniel/academy-go-q42021/auth"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_checkRouteRoles_ShouldReturnTheRoutesWithoutRole(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {}
+	router := mux.NewRouter()
+	router.HandleFunc("/users", handler).Methods(http.MethodGet)
+	assert.Nil(t, checkRouteRoles(router))
+
+	router.HandleFunc("/users", handler).Methods(http.MethodDelete)
+	router.HandleFunc("/teams", handler).Methods(http.MethodGet)
+	err := checkRouteRoles(router)
+
+	assert.Equal(t, "routes without a role: DELETE /users, GET /teams", err.Error())
+}
+
+func Test_routeRole_Suite(t *testing.T) {
+	testCases := []struct {
+		name           string
+		method         string
+		path           string
+		expectedRole   auth.Role
+		expectedSecure bool
+	}{
+		{name: "Should not secure the health checks", method: http.MethodGet, path: "/health/ready"},
+		{name: "Should require read on reads", method: http.MethodGet, path: "/users/7", expectedRole: auth.RoleRead, expectedSecure: true},
+		{name: "Should require write on changes", method: http.MethodPatch, path: "/mlb-players/7", expectedRole: auth.RoleWrite, expectedSecure: true},
+		{name: "Should require admin on syncs", method: http.MethodPost, path: "/users/sync", expectedRole: auth.RoleAdmin, expectedSecure: true},
+		{name: "Should require admin on routes without role", method: http.MethodGet, path: "/teams", expectedRole: auth.RoleAdmin, expectedSecure: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var role auth.Role
+			var secure bool
+			handler := func(w http.ResponseWriter, r *http.Request) { role, secure = routeRole(r) }
+			router := mux.NewRouter()
+			router.HandleFunc("/health/ready", handler).Methods(http.MethodGet)
+			router.HandleFunc("/users/{id}", handler).Methods(http.MethodGet)
+			router.HandleFunc("/users/sync", handler).Methods(http.MethodPost)
+			router.HandleFunc("/mlb-players/{id}", handler).Methods(http.MethodPatch)
+			router.HandleFunc("/teams", handler).Methods(http.MethodGet)
+
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tc.method, tc.path, nil))
+
+			assert.Equal(t, tc.expectedRole, role)
+			assert.Equal(t, tc.expectedSecure, secure)
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// stopHook is a named function run when the application stops.
+type stopHook struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// Lifecycle struct is a registry of the hooks that flush and close the application resources.
+type Lifecycle struct {
+	mu    sync.Mutex
+	hooks []stopHook
+}
+
+// NewLifecycle function creates a new instance of type Lifecycle.
+func NewLifecycle() *Lifecycle {
+	return &Lifecycle{}
+}
+
+// OnStop registers fn to run on Stop, hooks run in the reverse order of registration.
+func (lc *Lifecycle) OnStop(name string, fn func(ctx context.Context) error) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.hooks = append(lc.hooks, stopHook{name: name, fn: fn})
+}
+
+// Stop runs every registered hook once, even if some fail, and returns all the failures.
+func (lc *Lifecycle) Stop(ctx context.Context) error {
+	lc.mu.Lock()
+	hooks := lc.hooks
+	lc.hooks = nil
+	lc.mu.Unlock()
+	var failures []string
+
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := hooks[i].fn(ctx); err != nil {
+			failures = append(failures, fmt.Sprint(hooks[i].name, ": ", err.Error()))
+		}
+	}
+
+	if len(failures) > 0 {
+		return errors.New(fmt.Sprint("error stopping the application: ", strings.Join(failures, "; ")))
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Lifecycle_Stop_ShouldRunHooksInReverseOrder(t *testing.T) {
+	lc := NewLifecycle()
+	var calls []string
+
+	for _, name := range []string{"first", "second", "third"} {
+		name := name
+		lc.OnStop(name, func(ctx context.Context) error {
+			calls = append(calls, name)
+
+			return nil
+		})
+	}
+
+	assert.Nil(t, lc.Stop(context.Background()))
+	assert.Equal(t, []string{"third", "second", "first"}, calls)
+
+	assert.Nil(t, lc.Stop(context.Background()))
+	assert.Len(t, calls, 3)
+}
+
+func Test_Lifecycle_Stop_ShouldRunEveryHookAndReturnFailures(t *testing.T) {
+	lc := NewLifecycle()
+	ran := 0
+	lc.OnStop("db", func(ctx context.Context) error {
+		ran++
+
+		return errors.New("database is locked")
+	})
+	lc.OnStop("cache", func(ctx context.Context) error {
+		ran++
+
+		return nil
+	})
+	lc.OnStop("client", func(ctx context.Context) error {
+		ran++
+
+		return errors.New("timeout")
+	})
+
+	err := lc.Stop(context.Background())
+
+	assert.Equal(t, 3, ran)
+	assert.Equal(t, errors.New("error stopping the application: client: timeout; db: database is locked"), err)
+}
@@ -0,0 +1,143 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/EloYaniel/academy-go-q42021/apiclient"
+	"github.com/EloYaniel/academy-go-q42021/metrics"
+	"github.com/gorilla/mux"
+)
+
+// appMetrics struct has the metrics exposed by /metrics and observes the layers that feed them.
+type appMetrics struct {
+	registry        *metrics.Registry
+	requests        *metrics.CounterVec
+	requestDuration *metrics.HistogramVec
+	calls           *metrics.CounterVec
+	callErrors      *metrics.CounterVec
+	callDuration    *metrics.HistogramVec
+	reads           *metrics.HistogramVec
+	readErrors      *metrics.CounterVec
+	workers         *metrics.GaugeVec
+	rowsRead        *metrics.CounterVec
+	rowsRejected    *metrics.CounterVec
+	quarantined     *metrics.GaugeVec
+	quarantinedRows *metrics.CounterVec
+	cacheLookups    *metrics.CounterVec
+}
+
+func newAppMetrics() *appMetrics {
+	reg := metrics.NewRegistry()
+
+	return &appMetrics{
+		registry: reg,
+		requests: reg.NewCounterVec("http_requests_total",
+			"Requests served by route, method and status.", "route", "method", "status"),
+		requestDuration: reg.NewHistogramVec("http_request_duration_seconds",
+			"Latency of the requests served by route and method.", metrics.DefBuckets, "route", "method"),
+		calls: reg.NewCounterVec("apiclient_requests_total",
+			"Outbound requests by host, method and status, status is error when no response was received.", "host", "method", "status"),
+		callErrors: reg.NewCounterVec("apiclient_request_errors_total",
+			"Outbound requests that failed by host, method and status.", "host", "method", "status"),
+		callDuration: reg.NewHistogramVec("apiclient_request_duration_seconds",
+			"Latency of the outbound requests by host and method.", metrics.DefBuckets, "host", "method"),
+		reads: reg.NewHistogramVec("repository_read_duration_seconds",
+			"Duration of the full reads of the repository files.", metrics.DefBuckets, "source"),
+		readErrors: reg.NewCounterVec("repository_read_errors_total",
+			"Reads of the repository files that failed.", "source"),
+		workers: reg.NewGaugeVec("mlb_players_desired_active_workers",
+			"Workers of the concurrent reads of MLB Players running now."),
+		rowsRead: reg.NewCounterVec("mlb_players_desired_rows_read_total",
+			"Rows handled by the workers of the concurrent reads of MLB Players."),
+		rowsRejected: reg.NewCounterVec("mlb_players_desired_rows_rejected_total",
+			"Rows dropped by the type and filter params of the concurrent reads of MLB Players."),
+		quarantined: reg.NewGaugeVec("repository_quarantined_rows",
+			"Malformed rows of the current repository files skipped by the lenient reads.", "source"),
+		quarantinedRows: reg.NewCounterVec("repository_quarantined_rows_total",
+			"Malformed rows quarantined by the lenient reads, counted once per version of the file.", "source"),
+		cacheLookups: reg.NewCounterVec("cache_lookups_total",
+			"Lookups of the caches by cache and result, hit, miss or stale.", "cache", "result"),
+	}
+}
+
+// ObserveRequest records a request served by the router.
+func (m *appMetrics) ObserveRequest(method string, route string, status int, d time.Duration) {
+	m.requests.Inc(route, method, strconv.Itoa(status))
+	m.requestDuration.Observe(d.Seconds(), route, method)
+}
+
+// ObserveCall records an attempt made by the API client.
+func (m *appMetrics) ObserveCall(method string, host string, status int, d time.Duration, err error) {
+	label := strconv.Itoa(status)
+
+	switch {
+	case errors.Is(err, apiclient.ErrCircuitOpen):
+		label = "circuit_open"
+	case err != nil:
+		label = "error"
+	}
+	m.calls.Inc(host, method, label)
+
+	if err != nil || status >= http.StatusBadRequest {
+		m.callErrors.Inc(host, method, label)
+	}
+
+	if !errors.Is(err, apiclient.ErrCircuitOpen) {
+		m.callDuration.Observe(d.Seconds(), host, method)
+	}
+}
+
+// ObserveRead records a full read of a repository file.
+func (m *appMetrics) ObserveRead(source string, d time.Duration, err error) {
+	m.reads.Observe(d.Seconds(), source)
+
+	if err != nil {
+		m.readErrors.Inc(source)
+	}
+}
+
+// ObserveWorkers records a worker starting or stopping.
+func (m *appMetrics) ObserveWorkers(delta int) {
+	m.workers.Add(float64(delta))
+}
+
+// ObserveRow records a row handled by a worker.
+func (m *appMetrics) ObserveRow(rejected bool) {
+	m.rowsRead.Inc()
+
+	if rejected {
+		m.rowsRejected.Inc()
+	}
+}
+
+// ObserveQuarantined records the malformed rows skipped by a lenient read.
+func (m *appMetrics) ObserveQuarantined(source string, added int, total int) {
+	m.quarantined.Set(float64(total), source)
+
+	if added > 0 {
+		m.quarantinedRows.Add(float64(added), source)
+	}
+}
+
+// ObserveCache records a lookup of a cache.
+func (m *appMetrics) ObserveCache(name string, result string) {
+	m.cacheLookups.Inc(name, result)
+}
+
+// routeTemplate names the route of router matching r, or unmatched when there is none.
+func routeTemplate(router *mux.Router) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		var match mux.RouteMatch
+
+		if router.Match(r, &match) && match.Route != nil {
+			if template, err := match.Route.GetPathTemplate(); err == nil {
+				return template
+			}
+		}
+
+		return "unmatched"
+	}
+}
@@ -0,0 +1,60 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/EloYaniel/academy-go-q42021/config"
+)
+
+// NewServer builds an http.Server with the timeouts set by cfg, its own errors are logged to logger.
+func NewServer(cfg config.ServerConfig, handler http.Handler, logger *slog.Logger) *http.Server {
+	return &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+	}
+}
+
+// Serve accepts connections on listener until ctx is done. Then it stops accepting new ones, waits up to
+// shutdownTimeout for the in-flight requests to finish and then gives the lifecycle hooks the same time to run.
+func Serve(ctx context.Context, server *http.Server, listener net.Listener, lc *Lifecycle, shutdownTimeout time.Duration, logger *slog.Logger) error {
+	served := make(chan error, 1)
+
+	go func() {
+		served <- server.Serve(listener)
+	}()
+	logger.Info("server started", "addr", listener.Addr().String())
+
+	select {
+	case err := <-served:
+		lc.Stop(context.Background())
+
+		return err
+	case <-ctx.Done():
+	}
+	logger.Info("shutting down, draining in-flight requests", "timeout", shutdownTimeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err := server.Shutdown(shutdownCtx)
+
+	if err != nil {
+		server.Close()
+	}
+	<-served
+	stopCtx, cancelStop := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelStop()
+
+	if stopErr := lc.Stop(stopCtx); err == nil {
+		err = stopErr
+	}
+
+	return err
+}
@@ -0,0 +1,105 @@
+package app
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/EloYaniel/academy-go-q42021/config"
+	"github.com/EloYaniel/academy-go-q42021/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+// startTestServer serves handler on a random port until the returned cancel is called.
+func startTestServer(t *testing.T, handler http.Handler, lc *Lifecycle, shutdownTimeout time.Duration) (string, context.CancelFunc, chan error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	server := NewServer(config.Default().Server, handler, logger.Discard())
+	served := make(chan error, 1)
+
+	go func() {
+		served <- Serve(ctx, server, listener, lc, shutdownTimeout, logger.Discard())
+	}()
+
+	return "http://" + listener.Addr().String(), cancel, served
+}
+
+func Test_Serve_ShouldDrainInFlightRequestsOnShutdown(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	mu := new(sync.Mutex)
+	var events []string
+	record := func(event string) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		record("request finished")
+		w.Write([]byte("done"))
+	})
+	lc := NewLifecycle()
+	lc.OnStop("repository", func(ctx context.Context) error {
+		record("repository closed")
+
+		return nil
+	})
+	url, cancel, served := startTestServer(t, handler, lc, 5*time.Second)
+	responses := make(chan string, 1)
+
+	go func() {
+		res, err := http.Get(url)
+		assert.Nil(t, err)
+		defer res.Body.Close()
+		body, _ := ioutil.ReadAll(res.Body)
+		responses <- string(body)
+	}()
+	<-started
+	cancel()
+
+	select {
+	case <-served:
+		t.Fatal("server stopped before the in-flight request finished")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	_, err := http.Get(url)
+	assert.NotNil(t, err)
+
+	close(release)
+	assert.Nil(t, <-served)
+	assert.Equal(t, "done", <-responses)
+	assert.Equal(t, []string{"request finished", "repository closed"}, events)
+}
+
+func Test_Serve_ShouldStopWhenShutdownTimesOut(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+	lc := NewLifecycle()
+	closed := false
+	lc.OnStop("repository", func(ctx context.Context) error {
+		closed = true
+
+		return ctx.Err()
+	})
+	url, cancel, served := startTestServer(t, handler, lc, 50*time.Millisecond)
+
+	go http.Get(url)
+	<-started
+	cancel()
+
+	assert.Equal(t, context.DeadlineExceeded, <-served)
+	assert.True(t, closed)
+}
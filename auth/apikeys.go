@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// APIKey struct has a static API key and the roles it grants to whoever sends it.
+type APIKey struct {
+	Name  string `yaml:"name"`
+	Key   string `yaml:"key"`
+	Roles []Role `yaml:"roles"`
+}
+
+// apiKeysFile struct is the layout of the API keys file, in YAML or JSON.
+type apiKeysFile struct {
+	Keys []struct {
+		Name  string   `yaml:"name"`
+		Key   string   `yaml:"key"`
+		Roles []string `yaml:"roles"`
+	} `yaml:"keys"`
+}
+
+// LoadAPIKeys reads the API keys of the YAML or JSON file at filePath. Every key needs a unique name,
+// a unique value and known roles.
+func LoadAPIKeys(filePath string) ([]APIKey, error) {
+	data, err := os.ReadFile(filePath)
+
+	if err != nil {
+		return nil, fmt.Errorf("error reading the api keys file: %w", err)
+	}
+	var file apiKeysFile
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	if err = decoder.Decode(&file); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("error parsing the api keys file: %w", err)
+	}
+	keys := make([]APIKey, 0, len(file.Keys))
+	names := make(map[string]bool)
+	values := make(map[string]bool)
+
+	for i, k := range file.Keys {
+		if k.Name == "" || k.Key == "" {
+			return nil, fmt.Errorf("error in the api keys file: key %d needs a name and a key", i+1)
+		}
+
+		if names[k.Name] || values[k.Key] {
+			return nil, fmt.Errorf("error in the api keys file: key %s is duplicated", k.Name)
+		}
+		names[k.Name] = true
+		values[k.Key] = true
+		key := APIKey{Name: k.Name, Key: k.Key}
+
+		for _, name := range k.Roles {
+			role, err := ParseRole(name)
+
+			if err != nil {
+				return nil, fmt.Errorf("error in the api keys file: key %s: %w", k.Name, err)
+			}
+			key.Roles = append(key.Roles, role)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Role is a level of access to the API, every role includes the ones below it.
+type Role string
+
+const (
+	// RoleRead can read MLB Players and Users.
+	RoleRead Role = "read"
+	// RoleWrite can also change them.
+	RoleWrite Role = "write"
+	// RoleAdmin can also use the admin and maintenance routes.
+	RoleAdmin Role = "admin"
+)
+
+// roleRanks orders the roles, a higher rank includes the lower ones.
+var roleRanks = map[Role]int{RoleRead: 1, RoleWrite: 2, RoleAdmin: 3}
+
+// ParseRole converts read, write or admin into its Role.
+func ParseRole(name string) (Role, error) {
+	role := Role(strings.ToLower(strings.TrimSpace(name)))
+
+	if _, ok := roleRanks[role]; !ok {
+		return "", errors.New("unknown role: " + name)
+	}
+
+	return role, nil
+}
+
+var (
+	// ErrMissingCredentials is returned when a request has neither an API key nor a bearer token.
+	ErrMissingCredentials = errors.New("missing credentials")
+	// ErrInvalidCredentials is returned when the API key or bearer token of a request is not accepted.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// APIKeyHeader is the header used to send an API key.
+const APIKeyHeader = "X-API-Key"
+
+// Identity struct has the caller of a request and the roles it was granted.
+type Identity struct {
+	Subject string `json:"subject"`
+	Method  string `json:"method"`
+	Roles   []Role `json:"roles"`
+}
+
+// Allows tells if any of the roles of the caller includes role.
+func (id Identity) Allows(role Role) bool {
+	for _, r := range id.Roles {
+		if roleRanks[r] >= roleRanks[role] {
+			return true
+		}
+	}
+
+	return false
+}
+
+type identityKey struct{}
+
+// WithIdentity returns a copy of ctx carrying the identity of the caller.
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext gets the identity of the caller carried by ctx, false when the request was not authenticated.
+func FromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+
+	return id, ok
+}
+
+// Authenticator struct identifies the callers by their API key or their JWT bearer token.
+type Authenticator struct {
+	keys map[[sha256.Size]byte]Identity
+	jwt  *JWTKeys
+	now  func() time.Time
+}
+
+// NewAuthenticator function creates a new instance of type Authenticator. jwt is nil when bearer tokens are not accepted.
+func NewAuthenticator(keys []APIKey, jwt *JWTKeys) *Authenticator {
+	authenticator := &Authenticator{keys: make(map[[sha256.Size]byte]Identity), jwt: jwt, now: time.Now}
+
+	for _, k := range keys {
+		authenticator.keys[sha256.Sum256([]byte(k.Key))] = Identity{Subject: k.Name, Method: "api_key", Roles: k.Roles}
+	}
+
+	return authenticator
+}
+
+// Authenticate identifies the caller of r by the X-API-Key header or else by the bearer token of the Authorization header.
+func (a *Authenticator) Authenticate(r *http.Request) (Identity, error) {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		// Keys are looked up by their hash, so the time taken does not depend on how much of a key matches.
+		id, ok := a.keys[sha256.Sum256([]byte(key))]
+
+		if !ok {
+			return Identity{}, ErrInvalidCredentials
+		}
+
+		return id, nil
+	}
+	authorization := r.Header.Get("Authorization")
+
+	if authorization == "" {
+		return Identity{}, ErrMissingCredentials
+	}
+	scheme, token, _ := strings.Cut(authorization, " ")
+
+	if !strings.EqualFold(scheme, "Bearer") || a.jwt == nil {
+		return Identity{}, ErrInvalidCredentials
+	}
+
+	return a.jwt.Verify(strings.TrimSpace(token), a.now())
+}
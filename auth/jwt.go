@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// JWTKeys struct verifies HS256 and RS256 bearer tokens with the keys configured locally.
+type JWTKeys struct {
+	hmacSecret []byte
+	rsaKey     *rsa.PublicKey
+	issuer     string
+	audience   string
+	leeway     time.Duration
+}
+
+// NewJWTKeys function creates a new instance of type JWTKeys. Tokens signed with an algorithm whose key is
+// nil are rejected, issuer and audience are only checked when they are not empty.
+func NewJWTKeys(hmacSecret []byte, rsaKey *rsa.PublicKey, issuer string, audience string, leeway time.Duration) *JWTKeys {
+	return &JWTKeys{hmacSecret: hmacSecret, rsaKey: rsaKey, issuer: issuer, audience: audience, leeway: leeway}
+}
+
+// LoadHS256Secret reads the shared secret of the HS256 tokens, surrounding whitespace is ignored.
+func LoadHS256Secret(filePath string) ([]byte, error) {
+	data, err := os.ReadFile(filePath)
+
+	if err != nil {
+		return nil, fmt.Errorf("error reading the jwt secret file: %w", err)
+	}
+	secret := []byte(strings.TrimSpace(string(data)))
+
+	if len(secret) < 32 {
+		return nil, errors.New("the jwt secret must have at least 32 bytes")
+	}
+
+	return secret, nil
+}
+
+// LoadRS256PublicKey reads the PEM encoded RSA public key of the RS256 tokens.
+func LoadRS256PublicKey(filePath string) (*rsa.PublicKey, error) {
+	data, err := os.ReadFile(filePath)
+
+	if err != nil {
+		return nil, fmt.Errorf("error reading the jwt public key file: %w", err)
+	}
+	block, _ := pem.Decode(data)
+
+	if block == nil {
+		return nil, errors.New("error parsing the jwt public key file: no PEM data")
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+
+	if err != nil {
+		if key, pkcs1Err := x509.ParsePKCS1PublicKey(block.Bytes); pkcs1Err == nil {
+			return key, nil
+		}
+
+		return nil, fmt.Errorf("error parsing the jwt public key file: %w", err)
+	}
+	key, ok := parsed.(*rsa.PublicKey)
+
+	if !ok {
+		return nil, errors.New("error parsing the jwt public key file: not an RSA key")
+	}
+
+	return key, nil
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+}
+
+type jwtClaims struct {
+	Subject   string          `json:"sub"`
+	Issuer    string          `json:"iss"`
+	Audience  json.RawMessage `json:"aud"`
+	ExpiresAt *int64          `json:"exp"`
+	NotBefore *int64          `json:"nbf"`
+	Roles     []string        `json:"roles"`
+}
+
+// Verify checks the signature and claims of token at now and returns the identity it carries. The token needs
+// a sub and an exp claim, its roles are taken from the roles claim.
+func (k *JWTKeys) Verify(token string, now time.Time) (Identity, error) {
+	parts := strings.Split(token, ".")
+
+	if len(parts) != 3 {
+		return Identity{}, ErrInvalidCredentials
+	}
+	var header jwtHeader
+
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return Identity{}, ErrInvalidCredentials
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+
+	if err != nil || !k.verifySignature(header.Alg, parts[0]+"."+parts[1], signature) {
+		return Identity{}, ErrInvalidCredentials
+	}
+	var claims jwtClaims
+
+	if err = decodeSegment(parts[1], &claims); err != nil || !k.validClaims(claims, now) {
+		return Identity{}, ErrInvalidCredentials
+	}
+	id := Identity{Subject: claims.Subject, Method: "jwt"}
+
+	for _, name := range claims.Roles {
+		// Unknown roles are ignored, so tokens shared with other services may carry their own.
+		if role, err := ParseRole(name); err == nil {
+			id.Roles = append(id.Roles, role)
+		}
+	}
+
+	return id, nil
+}
+
+// verifySignature checks signature with the key of alg, the algorithm named by the token must have a key configured.
+func (k *JWTKeys) verifySignature(alg string, signed string, signature []byte) bool {
+	switch alg {
+	case "HS256":
+		if len(k.hmacSecret) == 0 {
+			return false
+		}
+		mac := hmac.New(sha256.New, k.hmacSecret)
+		mac.Write([]byte(signed))
+
+		return hmac.Equal(signature, mac.Sum(nil))
+	case "RS256":
+		if k.rsaKey == nil {
+			return false
+		}
+		digest := sha256.Sum256([]byte(signed))
+
+		return rsa.VerifyPKCS1v15(k.rsaKey, crypto.SHA256, digest[:], signature) == nil
+	default:
+		return false
+	}
+}
+
+// validClaims checks the subject, the validity period, the issuer and the audience of a token.
+func (k *JWTKeys) validClaims(claims jwtClaims, now time.Time) bool {
+	if claims.Subject == "" || claims.ExpiresAt == nil {
+		return false
+	}
+
+	if !now.Before(time.Unix(*claims.ExpiresAt, 0).Add(k.leeway)) {
+		return false
+	}
+
+	if claims.NotBefore != nil && now.Add(k.leeway).Before(time.Unix(*claims.NotBefore, 0)) {
+		return false
+	}
+
+	if k.issuer != "" && claims.Issuer != k.issuer {
+		return false
+	}
+
+	if k.audience == "" {
+		return true
+	}
+
+	for _, aud := range audiences(claims.Audience) {
+		if aud == k.audience {
+			return true
+		}
+	}
+
+	return false
+}
+
+// audiences reads the aud claim, which is either a string or an array of strings.
+func audiences(raw json.RawMessage) []string {
+	var single string
+
+	if err := json.Unmarshal(raw, &single); err == nil {
+		return []string{single}
+	}
+	var many []string
+	json.Unmarshal(raw, &many)
+
+	return many
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
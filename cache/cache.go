@@ -0,0 +1,245 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultLoadTimeout is the time a load may take unless WithLoadTimeout sets another one.
+const DefaultLoadTimeout = 10 * time.Second
+
+// Results of a lookup, as notified to the Observer.
+const (
+	Hit   = "hit"
+	Miss  = "miss"
+	Stale = "stale"
+)
+
+// Observer is notified of every lookup of a Cache, to expose it as metrics.
+type Observer interface {
+	// ObserveCache is called after each lookup of the cache named name with its result, Hit, Miss or Stale.
+	ObserveCache(name string, result string)
+}
+
+// nopObserver ignores every lookup.
+type nopObserver struct{}
+
+func (nopObserver) ObserveCache(name string, result string) {}
+
+// entry is a value kept by the cache.
+type entry struct {
+	key       string
+	value     interface{}
+	expiresAt time.Time
+}
+
+// call is a load in progress, shared by every lookup of its key.
+type call struct {
+	done       chan struct{}
+	generation int
+	value      interface{}
+	err        error
+}
+
+// Cache struct keeps values by key for a TTL, dropping the least recently used ones past its capacity.
+// Concurrent misses of a key are loaded once, and when a load fails the expired value is served
+// while it is not older than the stale window.
+type Cache struct {
+	name     string
+	capacity int
+	ttl      time.Duration
+	stale    time.Duration
+	timeout  time.Duration
+	mu       sync.Mutex
+	items    map[string]*list.Element
+	order    *list.List
+	calls    map[string]*call
+	// generation changes on every Delete and Purge, so loads started before them are neither kept nor shared.
+	generation int
+	now        func() time.Time
+	observer   Observer
+}
+
+// New function creates a new instance of type Cache. Values are fresh for ttl and then served for
+// stale more when loading them again fails.
+func New(name string, capacity int, ttl time.Duration, stale time.Duration) *Cache {
+	return &Cache{
+		name:     name,
+		capacity: capacity,
+		ttl:      ttl,
+		stale:    stale,
+		timeout:  DefaultLoadTimeout,
+		items:    make(map[string]*list.Element),
+		order:    list.New(),
+		calls:    make(map[string]*call),
+		now:      time.Now,
+		observer: nopObserver{},
+	}
+}
+
+// WithObserver sets the observer notified of every lookup.
+func (c *Cache) WithObserver(observer Observer) *Cache {
+	c.observer = observer
+
+	return c
+}
+
+// WithLoadTimeout sets the time a load may take. A load does not stop when the lookup that started it
+// gives up, since other lookups may be waiting for it.
+func (c *Cache) WithLoadTimeout(timeout time.Duration) *Cache {
+	c.timeout = timeout
+
+	return c
+}
+
+// Get gets the fresh value of key.
+func (c *Cache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.lookup(key)
+
+	if !ok || !c.now().Before(e.expiresAt) {
+		return nil, false
+	}
+
+	return e.value, true
+}
+
+// Set keeps value for key, fresh for the TTL.
+func (c *Cache) Set(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(key, value)
+}
+
+// Delete drops the value of key.
+func (c *Cache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	delete(c.calls, key)
+
+	if el, ok := c.items[key]; ok {
+		c.order.Remove(el)
+		delete(c.items, key)
+	}
+}
+
+// Purge drops every value.
+func (c *Cache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	c.calls = make(map[string]*call)
+	c.items = make(map[string]*list.Element)
+	c.order.Init()
+}
+
+// Len gets the number of values kept, fresh or not.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
+
+// GetOrLoad gets the fresh value of key, or else loads it with load and keeps it. Concurrent calls for a key
+// share a single load, which runs detached from the cancellation of ctx, but with its values, until the load
+// timeout; every call stops waiting for it when its own ctx is done. When load fails the expired value of key is returned instead, if it is still in the
+// stale window, otherwise the error of load is.
+func (c *Cache) GetOrLoad(ctx context.Context, key string, load func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	c.mu.Lock()
+	e, cached := c.lookup(key)
+
+	if cached && c.now().Before(e.expiresAt) {
+		c.mu.Unlock()
+		c.observer.ObserveCache(c.name, Hit)
+
+		return e.value, nil
+	}
+	cl, loading := c.calls[key]
+
+	if !loading {
+		cl = &call{done: make(chan struct{}), generation: c.generation}
+		c.calls[key] = cl
+	}
+	c.mu.Unlock()
+
+	if !loading {
+		go c.load(context.WithoutCancel(ctx), key, cl, load)
+	}
+
+	select {
+	case <-cl.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if cl.err == nil {
+		c.observer.ObserveCache(c.name, Miss)
+
+		return cl.value, nil
+	}
+	c.mu.Lock()
+	e, cached = c.lookup(key)
+	c.mu.Unlock()
+
+	if cached && c.now().Before(e.expiresAt.Add(c.stale)) {
+		c.observer.ObserveCache(c.name, Stale)
+
+		return e.value, nil
+	}
+	c.observer.ObserveCache(c.name, Miss)
+
+	return nil, cl.err
+}
+
+// load runs load for the call of key, keeping its value when it succeeds.
+func (c *Cache) load(ctx context.Context, key string, cl *call, load func(ctx context.Context) (interface{}, error)) {
+	defer close(cl.done)
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	cl.value, cl.err = load(ctx)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.calls[key] == cl {
+		delete(c.calls, key)
+	}
+
+	if cl.err == nil && cl.generation == c.generation {
+		c.set(key, cl.value)
+	}
+}
+
+// lookup finds the entry of key, fresh or not, marking it as the most recently used.
+func (c *Cache) lookup(key string) (*entry, bool) {
+	el, ok := c.items[key]
+
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+
+	return el.Value.(*entry), true
+}
+
+func (c *Cache) set(key string, value interface{}) {
+	expiresAt := c.now().Add(c.ttl)
+
+	if el, ok := c.items[key]; ok {
+		el.Value = &entry{key: key, value: value, expiresAt: expiresAt}
+		c.order.MoveToFront(el)
+
+		return
+	}
+	c.items[key] = c.order.PushFront(&entry{key: key, value: value, expiresAt: expiresAt})
+
+	for c.capacity > 0 && c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*entry).key)
+	}
+}
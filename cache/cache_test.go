@@ -0,0 +1,278 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingObserver struct {
+	mu      sync.Mutex
+	results []string
+}
+
+func (o *recordingObserver) ObserveCache(name string, result string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.results = append(o.results, name+":"+result)
+}
+
+// newTestCache creates a cache with a clock moved by the returned function.
+func newTestCache(capacity int, ttl time.Duration, stale time.Duration) (*Cache, func(d time.Duration)) {
+	now := time.Date(2021, time.November, 2, 10, 0, 0, 0, time.UTC)
+	c := New("test", capacity, ttl, stale)
+	c.now = func() time.Time { return now }
+
+	return c, func(d time.Duration) { now = now.Add(d) }
+}
+
+func loadValue(value interface{}, err error) func(ctx context.Context) (interface{}, error) {
+	return func(ctx context.Context) (interface{}, error) { return value, err }
+}
+
+func Test_Cache_ShouldExpireValues(t *testing.T) {
+	c, advance := newTestCache(10, time.Minute, 0)
+	c.Set("a", 1)
+
+	value, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, value)
+
+	advance(time.Minute)
+	value, ok = c.Get("a")
+	assert.False(t, ok)
+	assert.Nil(t, value)
+	assert.Equal(t, 1, c.Len())
+}
+
+func Test_Cache_ShouldDropTheLeastRecentlyUsed(t *testing.T) {
+	c, _ := newTestCache(2, time.Minute, 0)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Get("a")
+	c.Set("c", 3)
+
+	_, ok := c.Get("b")
+	assert.False(t, ok)
+	_, ok = c.Get("a")
+	assert.True(t, ok)
+	_, ok = c.Get("c")
+	assert.True(t, ok)
+	assert.Equal(t, 2, c.Len())
+
+	c.Delete("a")
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+
+	c.Purge()
+	assert.Equal(t, 0, c.Len())
+}
+
+func Test_GetOrLoad_Suite(t *testing.T) {
+	loadErr := errors.New("upstream is down")
+	testCases := []struct {
+		name             string
+		cached           interface{}
+		age              time.Duration
+		loadValue        interface{}
+		loadErr          error
+		expectedValue    interface{}
+		expectedErr      error
+		expectedResult   string
+		expectedLoadCall bool
+	}{
+		{
+			name:           "Should return the fresh value without loading it",
+			cached:         "cached",
+			age:            time.Second,
+			loadValue:      "loaded",
+			expectedValue:  "cached",
+			expectedResult: "test:hit",
+		},
+		{
+			name:             "Should load a missing value",
+			loadValue:        "loaded",
+			expectedValue:    "loaded",
+			expectedResult:   "test:miss",
+			expectedLoadCall: true,
+		},
+		{
+			name:             "Should load an expired value",
+			cached:           "cached",
+			age:              time.Minute,
+			loadValue:        "loaded",
+			expectedValue:    "loaded",
+			expectedResult:   "test:miss",
+			expectedLoadCall: true,
+		},
+		{
+			name:             "Should return the stale value when loading fails",
+			cached:           "cached",
+			age:              5 * time.Minute,
+			loadErr:          loadErr,
+			expectedValue:    "cached",
+			expectedResult:   "test:stale",
+			expectedLoadCall: true,
+		},
+		{
+			name:             "Should return the error when the value is too old to be served",
+			cached:           "cached",
+			age:              11 * time.Minute,
+			loadErr:          loadErr,
+			expectedErr:      loadErr,
+			expectedResult:   "test:miss",
+			expectedLoadCall: true,
+		},
+		{
+			name:             "Should return the error when nothing is cached",
+			loadErr:          loadErr,
+			expectedErr:      loadErr,
+			expectedResult:   "test:miss",
+			expectedLoadCall: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			observer := &recordingObserver{}
+			c, advance := newTestCache(10, time.Minute, 10*time.Minute)
+			c.WithObserver(observer)
+
+			if tc.cached != nil {
+				c.Set("key", tc.cached)
+			}
+			advance(tc.age)
+			loaded := false
+
+			value, err := c.GetOrLoad(context.Background(), "key", func(ctx context.Context) (interface{}, error) {
+				loaded = true
+
+				return tc.loadValue, tc.loadErr
+			})
+
+			assert.Equal(t, tc.expectedErr, err)
+			assert.Equal(t, tc.expectedValue, value)
+			assert.Equal(t, tc.expectedLoadCall, loaded)
+			assert.Equal(t, []string{tc.expectedResult}, observer.results)
+		})
+	}
+}
+
+func Test_GetOrLoad_ShouldLoadConcurrentMissesOnce(t *testing.T) {
+	c := New("test", 10, time.Minute, 0)
+	release := make(chan struct{})
+	var loads int32
+	var wg sync.WaitGroup
+	values := make([]interface{}, 10)
+
+	for i := range values {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			values[i], _ = c.GetOrLoad(context.Background(), "key", func(ctx context.Context) (interface{}, error) {
+				atomic.AddInt32(&loads, 1)
+				<-release
+
+				return "loaded", nil
+			})
+		}(i)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), loads)
+	for _, value := range values {
+		assert.Equal(t, "loaded", value)
+	}
+}
+
+func Test_GetOrLoad_ShouldNotKeepLoadsStartedBeforePurge(t *testing.T) {
+	c := New("test", 10, time.Minute, 0)
+
+	value, err := c.GetOrLoad(context.Background(), "key", func(ctx context.Context) (interface{}, error) {
+		c.Purge()
+
+		return "outdated", nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "outdated", value)
+	_, ok := c.Get("key")
+	assert.False(t, ok)
+
+	value, err = c.GetOrLoad(context.Background(), "key", loadValue("loaded", nil))
+	assert.Nil(t, err)
+	assert.Equal(t, "loaded", value)
+}
+
+func Test_GetOrLoad_ShouldStopWaitingWhenContextIsDone(t *testing.T) {
+	c := New("test", 10, time.Minute, 0)
+	release := make(chan struct{})
+	defer close(release)
+	go c.GetOrLoad(context.Background(), "key", func(ctx context.Context) (interface{}, error) {
+		<-release
+
+		return "loaded", nil
+	})
+	time.Sleep(10 * time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	value, err := c.GetOrLoad(ctx, "key", loadValue("other", nil))
+
+	assert.Equal(t, context.Canceled, err)
+	assert.Nil(t, value)
+}
+
+func Test_GetOrLoad_ShouldNotFailTheOthersWhenTheFirstCallerGivesUp(t *testing.T) {
+	c := New("test", 10, time.Minute, 0)
+	release := make(chan struct{})
+	var loadErr error
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() {
+		_, err := c.GetOrLoad(ctx, "key", func(ctx context.Context) (interface{}, error) {
+			<-release
+			loadErr = ctx.Err()
+
+			return "loaded", nil
+		})
+		first <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	second := make(chan interface{})
+	go func() {
+		value, _ := c.GetOrLoad(context.Background(), "key", loadValue("other", nil))
+		second <- value
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-first)
+	close(release)
+
+	assert.Equal(t, "loaded", <-second)
+	assert.Nil(t, loadErr)
+	value, ok := c.Get("key")
+	assert.True(t, ok)
+	assert.Equal(t, "loaded", value)
+}
+
+func Test_GetOrLoad_ShouldStopTheLoadAfterTheTimeout(t *testing.T) {
+	c := New("test", 10, time.Minute, 0).WithLoadTimeout(10 * time.Millisecond)
+
+	value, err := c.GetOrLoad(context.Background(), "key", func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+
+		return nil, ctx.Err()
+	})
+
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Nil(t, value)
+}
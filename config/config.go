@@ -0,0 +1,361 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/EloYaniel/academy-go-q42021/logger"
+	"gopkg.in/yaml.v3"
+)
+
+// Config struct has every setting needed to start the application.
+type Config struct {
+	Server  ServerConfig  `yaml:"server"`
+	Data    DataConfig    `yaml:"data"`
+	Users   UsersConfig   `yaml:"users"`
+	Cache   CacheConfig   `yaml:"cache"`
+	HTTP    HTTPConfig    `yaml:"http_cache"`
+	Client  ClientConfig  `yaml:"client"`
+	Workers WorkersConfig `yaml:"workers"`
+	Log     LogConfig     `yaml:"log"`
+	Health  HealthConfig  `yaml:"health"`
+	Auth    AuthConfig    `yaml:"auth"`
+}
+
+// ServerConfig struct has the HTTP server settings.
+type ServerConfig struct {
+	Addr              string        `yaml:"addr"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout"`
+}
+
+// DataConfig struct has the storage settings.
+type DataConfig struct {
+	Backend     string `yaml:"backend"`
+	PlayersFile string `yaml:"players_file"`
+	UsersFile   string `yaml:"users_file"`
+	SQLiteFile  string `yaml:"sqlite_file"`
+	ParseMode   string `yaml:"parse_mode"`
+}
+
+// UsersConfig struct has the upstream users API settings.
+// A SyncInterval of 0, the default, disables the background sync.
+type UsersConfig struct {
+	URL          string        `yaml:"url"`
+	SyncInterval time.Duration `yaml:"sync_interval"`
+	SyncJitter   time.Duration `yaml:"sync_jitter"`
+}
+
+// CacheConfig struct has the settings of the caches of the Users read from the repository and the upstream API.
+// A TTL of 0 disables them.
+type CacheConfig struct {
+	TTL         time.Duration `yaml:"ttl"`
+	StaleTTL    time.Duration `yaml:"stale_ttl"`
+	MaxEntries  int           `yaml:"max_entries"`
+	LoadTimeout time.Duration `yaml:"load_timeout"`
+}
+
+// HTTPConfig struct has the Cache-Control directives of the read endpoints, an empty one sets no header.
+type HTTPConfig struct {
+	MLBPlayers string `yaml:"mlb_players"`
+	MLBPlayer  string `yaml:"mlb_player"`
+	Users      string `yaml:"users"`
+	User       string `yaml:"user"`
+}
+
+// ClientConfig struct has the HTTP client settings used to call upstream APIs.
+type ClientConfig struct {
+	Timeout            time.Duration `yaml:"timeout"`
+	MaxRetries         int           `yaml:"max_retries"`
+	RetryBaseDelay     time.Duration `yaml:"retry_base_delay"`
+	RetryMaxDelay      time.Duration `yaml:"retry_max_delay"`
+	BreakerThreshold   int           `yaml:"breaker_threshold"`
+	BreakerOpenTimeout time.Duration `yaml:"breaker_open_timeout"`
+}
+
+// WorkersConfig struct has the limits of the concurrent reads of MLB Players.
+type WorkersConfig struct {
+	MaxItems   int `yaml:"max_items"`
+	MaxWorkers int `yaml:"max_workers"`
+}
+
+// LogConfig struct has the logging settings.
+type LogConfig struct {
+	Level string `yaml:"level"`
+}
+
+// HealthConfig struct has the readiness checks settings.
+type HealthConfig struct {
+	CheckTimeout     time.Duration `yaml:"check_timeout"`
+	UpstreamCritical bool          `yaml:"upstream_critical"`
+}
+
+// AuthConfig struct has the authentication settings. When it is enabled every route but the health checks and
+// the metrics needs an API key of the APIKeysFile or a JWT signed with one of the JWT keys.
+type AuthConfig struct {
+	Enabled               bool          `yaml:"enabled"`
+	APIKeysFile           string        `yaml:"api_keys_file"`
+	JWTHS256SecretFile    string        `yaml:"jwt_hs256_secret_file"`
+	JWTRS256PublicKeyFile string        `yaml:"jwt_rs256_public_key_file"`
+	JWTIssuer             string        `yaml:"jwt_issuer"`
+	JWTAudience           string        `yaml:"jwt_audience"`
+	JWTLeeway             time.Duration `yaml:"jwt_leeway"`
+}
+
+// Default returns the settings used when no file nor environment variable sets them.
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Addr:              ":8080",
+			ReadTimeout:       10 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      60 * time.Second,
+			IdleTimeout:       120 * time.Second,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		Data: DataConfig{
+			Backend:     "csv",
+			PlayersFile: "data/mlb_players.csv",
+			UsersFile:   "data/users.csv",
+			SQLiteFile:  "data/academy.db",
+			ParseMode:   "strict",
+		},
+		Users: UsersConfig{
+			URL:          "https://reqres.in/api/users",
+			SyncInterval: 0,
+			SyncJitter:   time.Minute,
+		},
+		Cache: CacheConfig{
+			TTL:         time.Minute,
+			StaleTTL:    10 * time.Minute,
+			LoadTimeout: 10 * time.Second,
+			MaxEntries:  1000,
+		},
+		HTTP: HTTPConfig{
+			MLBPlayers: "public, no-cache",
+			MLBPlayer:  "public, no-cache",
+			Users:      "private, no-cache",
+			User:       "private, no-cache",
+		},
+		Client: ClientConfig{
+			Timeout:            10 * time.Second,
+			MaxRetries:         3,
+			RetryBaseDelay:     200 * time.Millisecond,
+			RetryMaxDelay:      5 * time.Second,
+			BreakerThreshold:   5,
+			BreakerOpenTimeout: 30 * time.Second,
+		},
+		Workers: WorkersConfig{
+			MaxItems:   10000,
+			MaxWorkers: 100,
+		},
+		Log:    LogConfig{Level: "info"},
+		Health: HealthConfig{CheckTimeout: 2 * time.Second},
+		Auth:   AuthConfig{JWTLeeway: 30 * time.Second},
+	}
+}
+
+// Load reads the YAML or JSON file at filePath over the defaults, applies the environment overrides and validates the result.
+// An empty filePath only uses the defaults and the environment.
+func Load(filePath string) (*Config, error) {
+	cfg := Default()
+
+	if filePath != "" {
+		data, err := ioutil.ReadFile(filePath)
+
+		if err != nil {
+			return nil, errors.New(fmt.Sprint("error reading the config file:", err.Error()))
+		}
+
+		if err = decode(data, &cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := applyEnv(&cfg, os.LookupEnv); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+// decode parses YAML, which is a superset of JSON, rejecting unknown keys.
+func decode(data []byte, cfg *Config) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	if err := decoder.Decode(cfg); err != nil && err != io.EOF {
+		return errors.New(fmt.Sprint("error parsing the config file:", err.Error()))
+	}
+
+	return nil
+}
+
+// envOverride binds an environment variable to a setting.
+type envOverride struct {
+	name string
+	set  func(cfg *Config, value string) error
+}
+
+var envOverrides = []envOverride{
+	{"LISTEN_ADDR", func(cfg *Config, v string) error { cfg.Server.Addr = v; return nil }},
+	{"SERVER_READ_TIMEOUT", func(cfg *Config, v string) error { return setDuration(&cfg.Server.ReadTimeout, v) }},
+	{"SERVER_READ_HEADER_TIMEOUT", func(cfg *Config, v string) error { return setDuration(&cfg.Server.ReadHeaderTimeout, v) }},
+	{"SERVER_WRITE_TIMEOUT", func(cfg *Config, v string) error { return setDuration(&cfg.Server.WriteTimeout, v) }},
+	{"SERVER_IDLE_TIMEOUT", func(cfg *Config, v string) error { return setDuration(&cfg.Server.IdleTimeout, v) }},
+	{"SERVER_SHUTDOWN_TIMEOUT", func(cfg *Config, v string) error { return setDuration(&cfg.Server.ShutdownTimeout, v) }},
+	{"REPOSITORY_BACKEND", func(cfg *Config, v string) error { cfg.Data.Backend = v; return nil }},
+	{"PLAYERS_FILE", func(cfg *Config, v string) error { cfg.Data.PlayersFile = v; return nil }},
+	{"USERS_FILE", func(cfg *Config, v string) error { cfg.Data.UsersFile = v; return nil }},
+	{"SQLITE_PATH", func(cfg *Config, v string) error { cfg.Data.SQLiteFile = v; return nil }},
+	{"DATA_PARSE_MODE", func(cfg *Config, v string) error { cfg.Data.ParseMode = v; return nil }},
+	{"USERS_URL", func(cfg *Config, v string) error { cfg.Users.URL = v; return nil }},
+	{"USERS_SYNC_INTERVAL", func(cfg *Config, v string) error { return setDuration(&cfg.Users.SyncInterval, v) }},
+	{"USERS_SYNC_JITTER", func(cfg *Config, v string) error { return setDuration(&cfg.Users.SyncJitter, v) }},
+	{"CACHE_TTL", func(cfg *Config, v string) error { return setDuration(&cfg.Cache.TTL, v) }},
+	{"CACHE_STALE_TTL", func(cfg *Config, v string) error { return setDuration(&cfg.Cache.StaleTTL, v) }},
+	{"CACHE_MAX_ENTRIES", func(cfg *Config, v string) error { return setInt(&cfg.Cache.MaxEntries, v) }},
+	{"CACHE_LOAD_TIMEOUT", func(cfg *Config, v string) error { return setDuration(&cfg.Cache.LoadTimeout, v) }},
+	{"HTTP_CACHE_MLB_PLAYERS", func(cfg *Config, v string) error { cfg.HTTP.MLBPlayers = v; return nil }},
+	{"HTTP_CACHE_MLB_PLAYER", func(cfg *Config, v string) error { cfg.HTTP.MLBPlayer = v; return nil }},
+	{"HTTP_CACHE_USERS", func(cfg *Config, v string) error { cfg.HTTP.Users = v; return nil }},
+	{"HTTP_CACHE_USER", func(cfg *Config, v string) error { cfg.HTTP.User = v; return nil }},
+	{"CLIENT_TIMEOUT", func(cfg *Config, v string) error { return setDuration(&cfg.Client.Timeout, v) }},
+	{"CLIENT_MAX_RETRIES", func(cfg *Config, v string) error { return setInt(&cfg.Client.MaxRetries, v) }},
+	{"CLIENT_RETRY_BASE_DELAY", func(cfg *Config, v string) error { return setDuration(&cfg.Client.RetryBaseDelay, v) }},
+	{"CLIENT_RETRY_MAX_DELAY", func(cfg *Config, v string) error { return setDuration(&cfg.Client.RetryMaxDelay, v) }},
+	{"CLIENT_BREAKER_THRESHOLD", func(cfg *Config, v string) error { return setInt(&cfg.Client.BreakerThreshold, v) }},
+	{"CLIENT_BREAKER_OPEN_TIMEOUT", func(cfg *Config, v string) error { return setDuration(&cfg.Client.BreakerOpenTimeout, v) }},
+	{"WORKERS_MAX_ITEMS", func(cfg *Config, v string) error { return setInt(&cfg.Workers.MaxItems, v) }},
+	{"WORKERS_MAX_WORKERS", func(cfg *Config, v string) error { return setInt(&cfg.Workers.MaxWorkers, v) }},
+	{"LOG_LEVEL", func(cfg *Config, v string) error { cfg.Log.Level = v; return nil }},
+	{"HEALTH_CHECK_TIMEOUT", func(cfg *Config, v string) error { return setDuration(&cfg.Health.CheckTimeout, v) }},
+	{"HEALTH_UPSTREAM_CRITICAL", func(cfg *Config, v string) error { return setBool(&cfg.Health.UpstreamCritical, v) }},
+	{"AUTH_ENABLED", func(cfg *Config, v string) error { return setBool(&cfg.Auth.Enabled, v) }},
+	{"AUTH_API_KEYS_FILE", func(cfg *Config, v string) error { cfg.Auth.APIKeysFile = v; return nil }},
+	{"AUTH_JWT_HS256_SECRET_FILE", func(cfg *Config, v string) error { cfg.Auth.JWTHS256SecretFile = v; return nil }},
+	{"AUTH_JWT_RS256_PUBLIC_KEY_FILE", func(cfg *Config, v string) error { cfg.Auth.JWTRS256PublicKeyFile = v; return nil }},
+	{"AUTH_JWT_ISSUER", func(cfg *Config, v string) error { cfg.Auth.JWTIssuer = v; return nil }},
+	{"AUTH_JWT_AUDIENCE", func(cfg *Config, v string) error { cfg.Auth.JWTAudience = v; return nil }},
+	{"AUTH_JWT_LEEWAY", func(cfg *Config, v string) error { return setDuration(&cfg.Auth.JWTLeeway, v) }},
+}
+
+// applyEnv overrides the settings with the environment variables that are set.
+func applyEnv(cfg *Config, lookup func(string) (string, bool)) error {
+	for _, o := range envOverrides {
+		value, ok := lookup(o.name)
+
+		if !ok {
+			continue
+		}
+
+		if err := o.set(cfg, value); err != nil {
+			return errors.New(fmt.Sprint("error reading ", o.name, ":", err.Error()))
+		}
+	}
+
+	return nil
+}
+
+func setInt(dst *int, value string) error {
+	n, err := strconv.Atoi(value)
+
+	if err != nil {
+		return err
+	}
+	*dst = n
+
+	return nil
+}
+
+func setBool(dst *bool, value string) error {
+	b, err := strconv.ParseBool(value)
+
+	if err != nil {
+		return err
+	}
+	*dst = b
+
+	return nil
+}
+
+func setDuration(dst *time.Duration, value string) error {
+	d, err := time.ParseDuration(value)
+
+	if err != nil {
+		return err
+	}
+	*dst = d
+
+	return nil
+}
+
+// Validate checks every setting and returns all the problems found.
+func (cfg Config) Validate() error {
+	var problems []string
+	check := func(ok bool, problem string) {
+		if !ok {
+			problems = append(problems, problem)
+		}
+	}
+
+	check(cfg.Server.Addr != "", "server.addr is required")
+	check(cfg.Server.ReadTimeout >= 0, "server.read_timeout must not be negative")
+	check(cfg.Server.ReadHeaderTimeout >= 0, "server.read_header_timeout must not be negative")
+	check(cfg.Server.WriteTimeout >= 0, "server.write_timeout must not be negative")
+	check(cfg.Server.IdleTimeout >= 0, "server.idle_timeout must not be negative")
+	check(cfg.Server.ShutdownTimeout > 0, "server.shutdown_timeout must be positive")
+	check(cfg.Data.Backend == "csv" || cfg.Data.Backend == "sqlite", "data.backend must be csv or sqlite")
+	check(cfg.Data.PlayersFile != "", "data.players_file is required")
+	check(cfg.Data.UsersFile != "", "data.users_file is required")
+	check(cfg.Data.Backend != "sqlite" || cfg.Data.SQLiteFile != "", "data.sqlite_file is required by the sqlite backend")
+	check(cfg.Data.ParseMode == "strict" || cfg.Data.ParseMode == "lenient", "data.parse_mode must be strict or lenient")
+	check(isAbsoluteURL(cfg.Users.URL), "users.url must be an absolute http(s) URL")
+	check(cfg.Users.SyncInterval >= 0, "users.sync_interval must not be negative")
+	check(cfg.Users.SyncJitter >= 0, "users.sync_jitter must not be negative")
+	check(cfg.Cache.TTL >= 0, "cache.ttl must not be negative")
+	check(cfg.Cache.StaleTTL >= 0, "cache.stale_ttl must not be negative")
+	check(cfg.Cache.MaxEntries > 0, "cache.max_entries must be positive")
+	check(cfg.Cache.LoadTimeout > 0, "cache.load_timeout must be positive")
+	check(cfg.Client.Timeout > 0, "client.timeout must be positive")
+	check(cfg.Client.MaxRetries >= 0, "client.max_retries must not be negative")
+	check(cfg.Client.RetryBaseDelay >= 0, "client.retry_base_delay must not be negative")
+	check(cfg.Client.RetryMaxDelay >= cfg.Client.RetryBaseDelay, "client.retry_max_delay must not be less than client.retry_base_delay")
+	check(cfg.Client.BreakerThreshold > 0, "client.breaker_threshold must be positive")
+	check(cfg.Client.BreakerOpenTimeout > 0, "client.breaker_open_timeout must be positive")
+	check(cfg.Workers.MaxItems > 0, "workers.max_items must be positive")
+	check(cfg.Workers.MaxWorkers > 0, "workers.max_workers must be positive")
+	check(cfg.Health.CheckTimeout > 0, "health.check_timeout must be positive")
+	check(!cfg.Auth.Enabled || cfg.Auth.APIKeysFile != "" || cfg.Auth.JWTHS256SecretFile != "" || cfg.Auth.JWTRS256PublicKeyFile != "",
+		"auth.api_keys_file, auth.jwt_hs256_secret_file or auth.jwt_rs256_public_key_file is required when auth is enabled")
+	check(cfg.Auth.JWTLeeway >= 0, "auth.jwt_leeway must not be negative")
+	_, err := logger.ParseLevel(cfg.Log.Level)
+	check(err == nil, "log.level must be debug, info, warn or error")
+
+	if len(problems) > 0 {
+		return errors.New(fmt.Sprint("invalid config: ", strings.Join(problems, "; ")))
+	}
+
+	return nil
+}
+
+func isAbsoluteURL(value string) bool {
+	u, err := url.Parse(value)
+
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
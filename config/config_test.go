@@ -0,0 +1,163 @@
+package config
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfigFile(t *testing.T, name string, content string) string {
+	filePath := filepath.Join(t.TempDir(), name)
+	assert.Nil(t, ioutil.WriteFile(filePath, []byte(content), 0644))
+
+	return filePath
+}
+
+func Test_Load_ShouldReadTheExampleFile(t *testing.T) {
+	cfg, err := Load("../config.example.yaml")
+
+	assert.Nil(t, err)
+	assert.Equal(t, Default(), *cfg)
+}
+
+func Test_Load_Suite(t *testing.T) {
+	testCases := []struct {
+		name          string
+		fileName      string
+		content       string
+		env           map[string]string
+		expected      func(cfg *Config)
+		expectedError string
+	}{
+		{
+			name:     "Should use the defaults without file",
+			expected: func(cfg *Config) {},
+		},
+		{
+			name:     "Should read a YAML file",
+			fileName: "config.yaml",
+			content:  "server:\n  addr: \":9090\"\nclient:\n  timeout: 2s\n",
+			expected: func(cfg *Config) {
+				cfg.Server.Addr = ":9090"
+				cfg.Client.Timeout = 2 * time.Second
+			},
+		},
+		{
+			name:     "Should read a JSON file",
+			fileName: "config.json",
+			content:  `{"data": {"backend": "sqlite", "sqlite_file": "/tmp/app.db"}, "workers": {"max_items": 50}}`,
+			expected: func(cfg *Config) {
+				cfg.Data.Backend = "sqlite"
+				cfg.Data.SQLiteFile = "/tmp/app.db"
+				cfg.Workers.MaxItems = 50
+			},
+		},
+		{
+			name:     "Should override the file with the environment",
+			fileName: "config.yaml",
+			content:  "server:\n  addr: \":9090\"\n",
+			env: map[string]string{
+				"LISTEN_ADDR":              ":7070",
+				"USERS_URL":                "http://localhost/users",
+				"CLIENT_MAX_RETRIES":       "0",
+				"HEALTH_UPSTREAM_CRITICAL": "true",
+				"DATA_PARSE_MODE":          "lenient",
+				"USERS_SYNC_INTERVAL":      "15m",
+				"CACHE_TTL":                "30s",
+				"HTTP_CACHE_MLB_PLAYERS":   "public, max-age=60",
+				"AUTH_ENABLED":             "true",
+				"AUTH_API_KEYS_FILE":       "/etc/academy/api_keys.yaml",
+			},
+			expected: func(cfg *Config) {
+				cfg.Server.Addr = ":7070"
+				cfg.Users.URL = "http://localhost/users"
+				cfg.Client.MaxRetries = 0
+				cfg.Health.UpstreamCritical = true
+				cfg.Data.ParseMode = "lenient"
+				cfg.Users.SyncInterval = 15 * time.Minute
+				cfg.Cache.TTL = 30 * time.Second
+				cfg.HTTP.MLBPlayers = "public, max-age=60"
+				cfg.Auth.Enabled = true
+				cfg.Auth.APIKeysFile = "/etc/academy/api_keys.yaml"
+			},
+		},
+		{
+			name:          "Should return error on unknown keys",
+			fileName:      "config.yaml",
+			content:       "server:\n  port: 8080\n",
+			expectedError: "error parsing the config file:",
+		},
+		{
+			name:          "Should return error on wrong environment values",
+			env:           map[string]string{"CLIENT_TIMEOUT": "ten"},
+			expectedError: "error reading CLIENT_TIMEOUT:",
+		},
+		{
+			name:          "Should return every validation problem",
+			fileName:      "config.yaml",
+			content:       "data:\n  backend: postgres\nusers:\n  url: reqres.in\n",
+			expectedError: "invalid config: data.backend must be csv or sqlite; users.url must be an absolute http(s) URL",
+		},
+		{
+			name:          "Should return error on unknown parse mode",
+			env:           map[string]string{"DATA_PARSE_MODE": "loose"},
+			expectedError: "invalid config: data.parse_mode must be strict or lenient",
+		},
+		{
+			name:          "Should return error on negative sync jitter",
+			env:           map[string]string{"USERS_SYNC_JITTER": "-1m"},
+			expectedError: "invalid config: users.sync_jitter must not be negative",
+		},
+		{
+			name:          "Should return error on a cache without entries",
+			env:           map[string]string{"CACHE_MAX_ENTRIES": "0"},
+			expectedError: "invalid config: cache.max_entries must be positive",
+		},
+		{
+			name:          "Should return error on a cache load without timeout",
+			env:           map[string]string{"CACHE_LOAD_TIMEOUT": "0s"},
+			expectedError: "invalid config: cache.load_timeout must be positive",
+		},
+		{
+			name:          "Should return error on auth enabled without keys",
+			env:           map[string]string{"AUTH_ENABLED": "true"},
+			expectedError: "invalid config: auth.api_keys_file, auth.jwt_hs256_secret_file or auth.jwt_rs256_public_key_file is required",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for name, value := range tc.env {
+				t.Setenv(name, value)
+			}
+			filePath := ""
+
+			if tc.fileName != "" {
+				filePath = writeConfigFile(t, tc.fileName, tc.content)
+			}
+
+			cfg, err := Load(filePath)
+
+			if tc.expectedError != "" {
+				assert.Nil(t, cfg)
+				assert.Contains(t, err.Error(), tc.expectedError)
+
+				return
+			}
+			expected := Default()
+			tc.expected(&expected)
+			assert.Nil(t, err)
+			assert.Equal(t, &expected, cfg)
+		})
+	}
+}
+
+func Test_Load_ShouldReturnErrorWhenOpenFile(t *testing.T) {
+	cfg, err := Load("missing.yaml")
+
+	assert.Nil(t, cfg)
+	assert.Contains(t, err.Error(), "error reading the config file:")
+}
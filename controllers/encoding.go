@@ -0,0 +1,351 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/EloYaniel/academy-go-q42021/csvcodec"
+	e "github.com/EloYaniel/academy-go-q42021/entities"
+)
+
+const (
+	columnsBatchSize = 1024
+	flushEveryRows   = 256
+)
+
+var (
+	errUnknownFormat = errors.New("unknown format")
+	errNotAcceptable = errors.New("not acceptable")
+)
+
+// responseFormat struct has a format the rows of a response can be written in.
+type responseFormat struct {
+	Name        string
+	ContentType string
+}
+
+// responseFormats are the formats of the responses with rows, the first one is the default.
+var responseFormats = []responseFormat{
+	{Name: "json", ContentType: "application/json"},
+	{Name: "csv", ContentType: "text/csv"},
+	{Name: "ndjson", ContentType: "application/x-ndjson"},
+	{Name: "xml", ContentType: "application/xml"},
+	{Name: "columns", ContentType: "application/vnd.columns+json"},
+}
+
+// formatAliases are other media types accepted for a format.
+var formatAliases = map[string]string{
+	"text/xml":              "xml",
+	"application/jsonl":     "ndjson",
+	"application/jsonlines": "ndjson",
+}
+
+// table struct describes how the rows of an entity are written in every format.
+type table struct {
+	root    string
+	item    string
+	header  []string
+	columns []string
+	record  func(v interface{}) []string
+	values  func(v interface{}) []interface{}
+}
+
+var mlbPlayerTable = table{
+	root:    "mlb_players",
+	item:    "mlb_player",
+	header:  csvcodec.PlayerHeader,
+	columns: []string{"id", "name", "team", "position", "height_inches", "weight_lbs", "age"},
+	record:  func(v interface{}) []string { return csvcodec.PlayerRecord(v.(e.MLBPlayer)) },
+	values: func(v interface{}) []interface{} {
+		p := v.(e.MLBPlayer)
+
+		return []interface{}{p.ID, p.Name, p.Team, p.Position, p.Height, p.Weight, p.Age}
+	},
+}
+
+var userTable = table{
+	root:    "users",
+	item:    "user",
+	header:  csvcodec.UserHeader,
+	columns: []string{"id", "email", "first_name", "last_name", "avatar"},
+	record:  func(v interface{}) []string { return csvcodec.UserRecord(v.(e.User)) },
+	values: func(v interface{}) []interface{} {
+		u := v.(e.User)
+
+		return []interface{}{u.ID, u.Email, u.FirstName, u.LastName, u.Avatar}
+	},
+}
+
+// negotiateFormat picks the format of the response from the format param, or else from the Accept header.
+func negotiateFormat(r *http.Request) (responseFormat, error) {
+	if name := r.URL.Query().Get("format"); name != "" {
+		for _, format := range responseFormats {
+			if format.Name == strings.ToLower(name) {
+				return format, nil
+			}
+		}
+
+		return responseFormat{}, errUnknownFormat
+	}
+	accept := r.Header.Get("Accept")
+
+	if strings.TrimSpace(accept) == "" {
+		return responseFormats[0], nil
+	}
+	for _, mediaType := range acceptedMediaTypes(accept) {
+		if format, ok := matchFormat(mediaType); ok {
+			return format, nil
+		}
+	}
+
+	return responseFormat{}, errNotAcceptable
+}
+
+// writeFormatError writes the response of a failed negotiation, 400 for an unknown format param, 406 otherwise.
+func writeFormatError(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	names := make([]string, len(responseFormats))
+	for i, format := range responseFormats {
+		names[i] = format.Name
+	}
+
+	if err == errUnknownFormat {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMessage{
+			Message: "Invalid query params",
+			Errors:  []paramError{{Param: "format", Message: "must be one of " + strings.Join(names, ", ")}},
+		})
+
+		return
+	}
+	w.WriteHeader(http.StatusNotAcceptable)
+	json.NewEncoder(w).Encode(errorMessage{
+		Message: "Not acceptable, the supported formats are " + strings.Join(names, ", "),
+	})
+}
+
+// acceptedMediaTypes reads the media types of an Accept header ordered by quality, dropping the ones with quality 0.
+func acceptedMediaTypes(accept string) []string {
+	type accepted struct {
+		mediaType string
+		quality   float64
+	}
+	var types []accepted
+
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+		quality := 1.0
+
+		for _, param := range params[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+
+			if strings.TrimSpace(name) != "q" {
+				continue
+			}
+			q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+
+			if err != nil || q < 0 || q > 1 {
+				q = 0
+			}
+			quality = q
+		}
+
+		if mediaType != "" && quality > 0 {
+			types = append(types, accepted{mediaType: mediaType, quality: quality})
+		}
+	}
+	sort.SliceStable(types, func(i, j int) bool { return types[i].quality > types[j].quality })
+
+	mediaTypes := make([]string, len(types))
+	for i, t := range types {
+		mediaTypes[i] = t.mediaType
+	}
+
+	return mediaTypes
+}
+
+// matchFormat finds the format of a media type, wildcards match the first format they cover.
+func matchFormat(mediaType string) (responseFormat, bool) {
+	if name, ok := formatAliases[mediaType]; ok {
+		for _, format := range responseFormats {
+			if format.Name == name {
+				return format, true
+			}
+		}
+	}
+	for _, format := range responseFormats {
+		kind := strings.Split(format.ContentType, "/")[0]
+
+		if mediaType == format.ContentType || mediaType == "*/*" || mediaType == kind+"/*" {
+			return format, true
+		}
+	}
+
+	return responseFormat{}, false
+}
+
+// tableWriter struct streams rows of a table in a format. Nothing is written until the first row or Close,
+// so the handler can still answer with an error before that.
+type tableWriter struct {
+	w       http.ResponseWriter
+	format  responseFormat
+	table   table
+	started bool
+	rows    int
+	err     error
+	csv     *csv.Writer
+	xml     *xml.Encoder
+	batch   [][]interface{}
+	batches int
+}
+
+func newTableWriter(w http.ResponseWriter, format responseFormat, table table) *tableWriter {
+	return &tableWriter{w: w, format: format, table: table}
+}
+
+// Started tells if the response has been started, so errors can no longer change it.
+func (tw *tableWriter) Started() bool {
+	return tw.started
+}
+
+// Write writes a row, flushing the response every few rows so it reaches the client as it is built.
+func (tw *tableWriter) Write(v interface{}) error {
+	tw.start()
+
+	switch tw.format.Name {
+	case "json":
+		if tw.rows > 0 {
+			tw.writeString(",")
+		}
+		tw.writeJSON(v)
+	case "ndjson":
+		tw.writeJSON(v)
+		tw.writeString("\n")
+	case "csv":
+		tw.setErr(tw.csv.Write(tw.table.record(v)))
+	case "xml":
+		tw.setErr(tw.xml.EncodeElement(v, xml.StartElement{Name: xml.Name{Local: tw.table.item}}))
+	case "columns":
+		tw.batch = append(tw.batch, tw.table.values(v))
+
+		if len(tw.batch) == columnsBatchSize {
+			tw.writeBatch()
+		}
+	}
+	tw.rows++
+
+	if tw.rows%flushEveryRows == 0 {
+		tw.flush()
+	}
+
+	return tw.err
+}
+
+// Close ends the response, writing an empty table when there were no rows.
+func (tw *tableWriter) Close() error {
+	tw.start()
+
+	switch tw.format.Name {
+	case "json":
+		tw.writeString("]\n")
+	case "xml":
+		tw.setErr(tw.xml.Flush())
+		tw.writeString("\n</" + tw.table.root + ">\n")
+	case "columns":
+		tw.writeBatch()
+		tw.writeString(`],"count":` + strconv.Itoa(tw.rows) + "}\n")
+	}
+	tw.flush()
+
+	return tw.err
+}
+
+func (tw *tableWriter) start() {
+	if tw.started {
+		return
+	}
+	tw.started = true
+	tw.w.Header().Set("Content-Type", tw.format.ContentType)
+
+	switch tw.format.Name {
+	case "json":
+		tw.writeString("[")
+	case "csv":
+		tw.csv = csv.NewWriter(tw.w)
+		tw.setErr(tw.csv.Write(tw.table.header))
+	case "xml":
+		tw.writeString(xml.Header + "<" + tw.table.root + ">")
+		tw.xml = xml.NewEncoder(tw.w)
+	case "columns":
+		tw.writeString(`{"columns":`)
+		tw.writeJSON(tw.table.columns)
+		tw.writeString(`,"batches":[`)
+	}
+}
+
+// writeBatch writes the buffered rows as an object with an array per column.
+func (tw *tableWriter) writeBatch() {
+	if len(tw.batch) == 0 {
+		return
+	}
+
+	if tw.batches > 0 {
+		tw.writeString(",")
+	}
+	tw.writeString("{")
+	for i, column := range tw.table.columns {
+		values := make([]interface{}, len(tw.batch))
+		for j, row := range tw.batch {
+			values[j] = row[i]
+		}
+
+		if i > 0 {
+			tw.writeString(",")
+		}
+		tw.writeJSON(column)
+		tw.writeString(":")
+		tw.writeJSON(values)
+	}
+	tw.writeString("}")
+	tw.batch = tw.batch[:0]
+	tw.batches++
+}
+
+func (tw *tableWriter) flush() {
+	if tw.csv != nil {
+		tw.csv.Flush()
+		tw.setErr(tw.csv.Error())
+	}
+	// Writers that can not flush are fine, the response is sent when the handler returns.
+	http.NewResponseController(tw.w).Flush()
+}
+
+func (tw *tableWriter) writeJSON(v interface{}) {
+	b, err := json.Marshal(v)
+
+	if err != nil {
+		tw.setErr(err)
+
+		return
+	}
+	_, err = tw.w.Write(b)
+	tw.setErr(err)
+}
+
+func (tw *tableWriter) writeString(s string) {
+	_, err := tw.w.Write([]byte(s))
+	tw.setErr(err)
+}
+
+func (tw *tableWriter) setErr(err error) {
+	if tw.err == nil {
+		tw.err = err
+	}
+}
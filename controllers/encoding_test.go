@@ -0,0 +1,397 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	e "github.com/EloYaniel/academy-go-q42021/entities"
+	"github.com/EloYaniel/academy-go-q42021/logger"
+	"github.com/EloYaniel/academy-go-q42021/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var encodingPlayers = []e.MLBPlayer{
+	{ID: 1, Name: "Adam Donachie", Team: "BAL", Position: "Catcher", Height: 74, Weight: 180, Age: 22.99},
+	{ID: 2, Name: "Paul Bako", Team: "BAL", Position: "Catcher", Height: 74, Weight: 215, Age: 34.69},
+}
+
+func Test_NegotiateFormat_Suite(t *testing.T) {
+	testCases := []struct {
+		name     string
+		rawQuery string
+		accept   string
+		expected string
+		err      error
+	}{
+		{name: "Should default to json without Accept", expected: "json"},
+		{name: "Should read the format param", rawQuery: "format=CSV", accept: "application/xml", expected: "csv"},
+		{name: "Should reject an unknown format param", rawQuery: "format=parquet", err: errUnknownFormat},
+		{name: "Should read the Accept header", accept: "application/x-ndjson", expected: "ndjson"},
+		{name: "Should prefer the highest quality", accept: "text/csv;q=0.5, application/xml;q=0.9", expected: "xml"},
+		{name: "Should keep the order of equal qualities", accept: "application/vnd.columns+json, text/csv", expected: "columns"},
+		{name: "Should skip unsupported media types", accept: "application/parquet, text/xml", expected: "xml"},
+		{name: "Should match wildcards", accept: "text/*", expected: "csv"},
+		{name: "Should match any media type", accept: "*/*", expected: "json"},
+		{name: "Should not accept quality zero", accept: "application/json;q=0", err: errNotAcceptable},
+		{name: "Should not accept unsupported media types", accept: "image/png", err: errNotAcceptable},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/mlb-players?"+tc.rawQuery, nil)
+
+			if tc.accept != "" {
+				r.Header.Set("Accept", tc.accept)
+			}
+
+			format, err := negotiateFormat(r)
+
+			assert.Equal(t, tc.err, err)
+			assert.Equal(t, tc.expected, format.Name)
+		})
+	}
+}
+
+func Test_MLBPlayerController_GetMLBPlayers_Formats_Suite(t *testing.T) {
+	testCases := []struct {
+		name         string
+		rawQuery     string
+		accept       string
+		statusCode   int
+		contentType  string
+		expectedBody string
+	}{
+		{
+			name:         "Should return csv with the file header",
+			rawQuery:     "format=csv",
+			statusCode:   http.StatusOK,
+			contentType:  "text/csv",
+			expectedBody: "Id,Name,Team,Position,Height(inches),Weight(lbs),Age\n1,Adam Donachie,BAL,Catcher,74,180,22.99\n2,Paul Bako,BAL,Catcher,74,215,34.69\n",
+		},
+		{
+			name:         "Should return ndjson",
+			accept:       "application/x-ndjson",
+			statusCode:   http.StatusOK,
+			contentType:  "application/x-ndjson",
+			expectedBody: `{"id":1,"name":"Adam Donachie","team":"BAL","position":"Catcher","height_inches":74,"weight_lbs":180,"age":22.99}` + "\n" + `{"id":2,"name":"Paul Bako","team":"BAL","position":"Catcher","height_inches":74,"weight_lbs":215,"age":34.69}` + "\n",
+		},
+		{
+			name:         "Should return xml",
+			rawQuery:     "format=xml",
+			statusCode:   http.StatusOK,
+			contentType:  "application/xml",
+			expectedBody: `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<mlb_players><mlb_player><id>1</id><name>Adam Donachie</name><team>BAL</team><position>Catcher</position><height_inches>74</height_inches><weight_lbs>180</weight_lbs><age>22.99</age></mlb_player><mlb_player><id>2</id><name>Paul Bako</name><team>BAL</team><position>Catcher</position><height_inches>74</height_inches><weight_lbs>215</weight_lbs><age>34.69</age></mlb_player>` + "\n</mlb_players>\n",
+		},
+		{
+			name:         "Should return columns",
+			rawQuery:     "format=columns",
+			statusCode:   http.StatusOK,
+			contentType:  "application/vnd.columns+json",
+			expectedBody: `{"columns":["id","name","team","position","height_inches","weight_lbs","age"],"batches":[{"id":[1,2],"name":["Adam Donachie","Paul Bako"],"team":["BAL","BAL"],"position":["Catcher","Catcher"],"height_inches":[74,74],"weight_lbs":[180,215],"age":[22.99,34.69]}],"count":2}` + "\n",
+		},
+		{
+			name:         "Should return bad request on unknown format",
+			rawQuery:     "format=parquet",
+			statusCode:   http.StatusBadRequest,
+			contentType:  "application/json",
+			expectedBody: `{"message":"Invalid query params","errors":[{"param":"format","message":"must be one of json, csv, ndjson, xml, columns"}]}` + "\n",
+		},
+		{
+			name:         "Should return not acceptable on unsupported Accept",
+			accept:       "image/png",
+			statusCode:   http.StatusNotAcceptable,
+			contentType:  "application/json",
+			expectedBody: `{"message":"Not acceptable, the supported formats are json, csv, ndjson, xml, columns"}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/mlb-players?"+tc.rawQuery, nil)
+
+			if tc.accept != "" {
+				r.Header.Set("Accept", tc.accept)
+			}
+			m := new(mockMLBService)
+			m.On("SearchMLBPlayers", mock.Anything).Return(&e.MLBPlayerPage{Players: encodingPlayers, Total: 2, Limit: 100}, nil)
+			m.On("StreamMLBPlayers", e.MLBPlayerFilter{}, []e.SortKey(nil)).Return(encodingPlayers, nil)
+			ctr := NewMLBPlayerController(m, DesiredLimits{}, logger.Discard())
+
+			ctr.GetMLBPlayers(w, r)
+
+			assert.Equal(t, tc.statusCode, w.Code)
+			assert.Equal(t, tc.contentType, w.Result().Header.Get("Content-Type"))
+			assert.Equal(t, tc.expectedBody, w.Body.String())
+		})
+	}
+}
+
+func Test_MLBPlayerController_GetMLBPlayers_ShouldSendPageInHeaders(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/mlb-players?format=ndjson&limit=2", nil)
+	m := new(mockMLBService)
+	m.On("SearchMLBPlayers", mock.Anything).Return(&e.MLBPlayerPage{Players: encodingPlayers, Total: 5, Limit: 2}, nil)
+	ctr := NewMLBPlayerController(m, DesiredLimits{}, logger.Discard())
+
+	ctr.GetMLBPlayers(w, r)
+
+	assert.Equal(t, "5", w.Result().Header.Get("X-Total-Count"))
+	assert.Equal(t, "</mlb-players?cursor="+encodeCursor(2)+"&format=ndjson&limit=2>; rel=\"next\"", w.Result().Header.Get("Link"))
+}
+
+func Test_MLBPlayerController_GetMLBPlayers_ShouldStreamEveryMatchingPlayer(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/mlb-players?format=ndjson&team=BAL&sort=-age", nil)
+	m := new(mockMLBService)
+	m.On("StreamMLBPlayers", e.MLBPlayerFilter{Teams: []string{"BAL"}}, []e.SortKey{{Field: "age", Desc: true}}).Return(encodingPlayers, nil)
+	ctr := NewMLBPlayerController(m, DesiredLimits{}, logger.Discard())
+
+	ctr.GetMLBPlayers(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Result().Header.Get("Content-Type"))
+	assert.Equal(t, 2, strings.Count(w.Body.String(), "\n"))
+	assert.Empty(t, w.Result().Header.Get("Link"))
+	m.AssertNumberOfCalls(t, "StreamMLBPlayers", 1)
+	m.AssertNumberOfCalls(t, "SearchMLBPlayers", 0)
+}
+
+func Test_MLBPlayerController_ExportMLBPlayers_Suite(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		rawQuery             string
+		statusCode           int
+		expectedServiceCalls int
+		expectedFilter       e.MLBPlayerFilter
+		serviceResponse      []e.MLBPlayer
+		serviceError         error
+		contentType          string
+		expectedBody         string
+	}{
+		{
+			name:                 "Should stream the players",
+			rawQuery:             "format=csv&team=BAL",
+			statusCode:           http.StatusOK,
+			expectedServiceCalls: 1,
+			expectedFilter:       e.MLBPlayerFilter{Teams: []string{"BAL"}},
+			serviceResponse:      encodingPlayers,
+			contentType:          "text/csv",
+			expectedBody:         "Id,Name,Team,Position,Height(inches),Weight(lbs),Age\n1,Adam Donachie,BAL,Catcher,74,180,22.99\n2,Paul Bako,BAL,Catcher,74,215,34.69\n",
+		},
+		{
+			name:                 "Should return an empty json array without players",
+			statusCode:           http.StatusOK,
+			expectedServiceCalls: 1,
+			contentType:          "application/json",
+			expectedBody:         "[]\n",
+		},
+		{
+			name:                 "Should return bad request on wrong filter params",
+			rawQuery:             "age_min=old",
+			statusCode:           http.StatusBadRequest,
+			expectedServiceCalls: 0,
+			contentType:          "application/json",
+			expectedBody:         `{"message":"Invalid query params","errors":[{"param":"age_min","message":"must be a number"}]}` + "\n",
+		},
+		{
+			name:                 "Should return internal server error when no row was sent",
+			rawQuery:             "format=xml",
+			statusCode:           http.StatusInternalServerError,
+			expectedServiceCalls: 1,
+			serviceError:         errors.New("error opening the file"),
+			contentType:          "application/json",
+			expectedBody:         `{"message":"Internal server error"}` + "\n",
+		},
+		{
+			name:                 "Should leave the response incomplete when rows were sent",
+			statusCode:           http.StatusOK,
+			expectedServiceCalls: 1,
+			serviceResponse:      encodingPlayers[:1],
+			serviceError:         errors.New("error reading the row"),
+			contentType:          "application/json",
+			expectedBody:         `[{"id":1,"name":"Adam Donachie","team":"BAL","position":"Catcher","height_inches":74,"weight_lbs":180,"age":22.99}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/mlb-players/export?"+tc.rawQuery, nil)
+			m := new(mockMLBService)
+			m.On("ExportMLBPlayers", tc.expectedFilter).Return(tc.serviceResponse, tc.serviceError)
+			ctr := NewMLBPlayerController(m, DesiredLimits{}, logger.Discard())
+
+			ctr.ExportMLBPlayers(w, r)
+
+			assert.Equal(t, tc.statusCode, w.Code)
+			assert.Equal(t, tc.contentType, w.Result().Header.Get("Content-Type"))
+			assert.Equal(t, tc.expectedBody, w.Body.String())
+			m.AssertNumberOfCalls(t, "ExportMLBPlayers", tc.expectedServiceCalls)
+		})
+	}
+}
+
+func Test_TableWriter_ShouldWriteColumnsInBatches(t *testing.T) {
+	w := httptest.NewRecorder()
+	tw := newTableWriter(w, responseFormat{Name: "columns", ContentType: "application/vnd.columns+json"}, mlbPlayerTable)
+	total := columnsBatchSize*2 + 10
+
+	for i := 1; i <= total; i++ {
+		assert.Nil(t, tw.Write(e.MLBPlayer{ID: i}))
+	}
+	assert.Nil(t, tw.Close())
+
+	var body struct {
+		Columns []string                   `json:"columns"`
+		Batches []map[string][]interface{} `json:"batches"`
+		Count   int                        `json:"count"`
+	}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, mlbPlayerTable.columns, body.Columns)
+	assert.Equal(t, total, body.Count)
+	assert.Len(t, body.Batches, 3)
+	assert.Len(t, body.Batches[0]["id"], columnsBatchSize)
+	assert.Len(t, body.Batches[2]["name"], 10)
+	assert.Equal(t, float64(total), body.Batches[2]["id"][9])
+}
+
+func Test_UserController_Formats_Suite(t *testing.T) {
+	users := []e.User{{ID: 1, Email: "e@gmail.com", FirstName: "First", LastName: "Last", Avatar: "FL"}}
+	testCases := []struct {
+		name         string
+		export       bool
+		rawQuery     string
+		statusCode   int
+		serviceError error
+		contentType  string
+		expectedBody string
+	}{
+		{
+			name:         "Should return users as csv with the file header",
+			rawQuery:     "format=csv",
+			statusCode:   http.StatusOK,
+			contentType:  "text/csv",
+			expectedBody: "Id,Email,FirstName,LastName,Avatar\n1,e@gmail.com,First,Last,FL\n",
+		},
+		{
+			name:         "Should return internal server error when no user was sent",
+			rawQuery:     "format=csv",
+			statusCode:   http.StatusInternalServerError,
+			serviceError: errors.New("error fetching the users"),
+			contentType:  "application/json",
+			expectedBody: `{"message":"Internal server error"}` + "\n",
+		},
+		{
+			name:         "Should export users as xml",
+			export:       true,
+			rawQuery:     "format=xml",
+			statusCode:   http.StatusOK,
+			contentType:  "application/xml",
+			expectedBody: `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<users><user><id>1</id><email>e@gmail.com</email><first_name>First</first_name><last_name>Last</last_name><avatar>FL</avatar></user>` + "\n</users>\n",
+		},
+		{
+			name:         "Should return internal server error when the export fails",
+			export:       true,
+			rawQuery:     "format=ndjson",
+			statusCode:   http.StatusInternalServerError,
+			serviceError: errors.New("error opening the file"),
+			contentType:  "application/json",
+			expectedBody: `{"message":"Internal server error"}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/users?"+tc.rawQuery, nil)
+			m := new(mockUserService)
+			ctr := NewUserController(m, logger.Discard())
+
+			if tc.export && tc.serviceError != nil {
+				m.On("ExportUsers").Return(nil, tc.serviceError)
+				ctr.ExportUsers(w, r)
+			} else if tc.export {
+				m.On("ExportUsers").Return(users, nil)
+				ctr.ExportUsers(w, r)
+			} else if tc.serviceError != nil {
+				m.On("StreamUsers").Return(nil, tc.serviceError)
+				ctr.GetUsers(w, r)
+			} else {
+				m.On("StreamUsers").Return(users, nil)
+				ctr.GetUsers(w, r)
+			}
+
+			assert.Equal(t, tc.statusCode, w.Code)
+			assert.Equal(t, tc.contentType, w.Result().Header.Get("Content-Type"))
+			assert.Equal(t, tc.expectedBody, w.Body.String())
+		})
+	}
+}
+
+// failingWriter fails every write of the body after the first one.
+type failingWriter struct {
+	*httptest.ResponseRecorder
+	writes int
+}
+
+func (w *failingWriter) Write(b []byte) (int, error) {
+	w.writes++
+
+	if w.writes > 1 {
+		return 0, errors.New("connection reset")
+	}
+
+	return w.ResponseRecorder.Write(b)
+}
+
+func Test_GetMLBPlayers_ShouldFlushTheRowsThroughTheValidators(t *testing.T) {
+	players := make([]e.MLBPlayer, flushEveryRows+1)
+	for i := range players {
+		players[i] = e.MLBPlayer{ID: i + 1}
+	}
+	m := new(mockMLBService)
+	m.On("StreamMLBPlayers", mock.Anything, mock.Anything).Return(players, nil)
+	ctr := NewMLBPlayerController(m, DesiredLimits{}, logger.Discard())
+	modified := time.Now()
+	version := func(r *http.Request) (middleware.Version, bool) {
+		return middleware.Version{Tag: "v1", Modified: modified}, true
+	}
+	w := httptest.NewRecorder()
+
+	middleware.Conditional("no-cache", version)(http.HandlerFunc(ctr.GetMLBPlayers)).
+		ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/mlb-players?format=csv", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, w.Flushed)
+	assert.NotEmpty(t, w.Header().Get("ETag"))
+}
+
+func Test_GetMLBPlayers_ShouldAbortAResponseThatFailsMidway(t *testing.T) {
+	m := new(mockMLBService)
+	m.On("StreamMLBPlayers", mock.Anything, mock.Anything).Return(encodingPlayers, nil)
+	ctr := NewMLBPlayerController(m, DesiredLimits{}, logger.Discard())
+	w := &failingWriter{ResponseRecorder: httptest.NewRecorder()}
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		ctr.GetMLBPlayers(w, httptest.NewRequest(http.MethodGet, "/mlb-players?format=ndjson", nil))
+	})
+}
+
+func Test_GetUsers_ShouldAbortAResponseThatFailsMidway(t *testing.T) {
+	users := []e.User{{ID: 1}, {ID: 2}}
+	m := new(mockUserService)
+	m.On("StreamUsers").Return(users, nil)
+	ctr := NewUserController(m, logger.Discard())
+	w := &failingWriter{ResponseRecorder: httptest.NewRecorder()}
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		ctr.GetUsers(w, httptest.NewRequest(http.MethodGet, "/users?format=ndjson", nil))
+	})
+}
@@ -1,19 +1,40 @@
 package controllers
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
+
+	e "github.com/EloYaniel/academy-go-q42021/entities"
 )
 
+type healthService interface {
+	CheckReadiness(ctx context.Context) e.HealthReport
+}
+
 // HealthController struct handles api controller.
-type HealthController struct{}
+type HealthController struct {
+	service healthService
+}
 
 // NewHealthController function creates an instance of HealthController.
-func NewHealthController() *HealthController {
-	return &HealthController{}
+func NewHealthController(service healthService) *HealthController {
+	return &HealthController{service: service}
 }
 
-// CheckHealth checks API health.
-func (HealthController) CheckHealth(w http.ResponseWriter, r *http.Request) {
-	json.NewEncoder(w).Encode("API is healthy")
+// CheckLiveness tells the process is running, without checking its dependencies.
+func (ctr *HealthController) CheckLiveness(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(e.HealthReport{Status: e.HealthUp, Checks: []e.HealthCheckResult{}})
+}
+
+// CheckReadiness checks every dependency of the API, it is unavailable when a critical one fails.
+func (ctr *HealthController) CheckReadiness(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	report := ctr.service.CheckReadiness(r.Context())
+
+	if report.Status == e.HealthDown {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
 }
@@ -1,33 +1,82 @@
 package controllers
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	e "github.com/EloYaniel/academy-go-q42021/entities"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
-func Test_Health_Suite(t *testing.T) {
+type mockHealthService struct {
+	mock.Mock
+}
+
+func (m *mockHealthService) CheckReadiness(ctx context.Context) e.HealthReport {
+	args := m.Called()
+
+	return args.Get(0).(e.HealthReport)
+}
+
+func Test_Liveness_ShouldNotCheckDependencies(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/health/live", nil)
+	service := &mockHealthService{}
+
+	NewHealthController(service).CheckLiveness(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status":"up","checks":[]}`, w.Body.String())
+	service.AssertNotCalled(t, "CheckReadiness")
+}
+
+func Test_Readiness_Suite(t *testing.T) {
 	testCases := []struct {
 		name       string
+		report     e.HealthReport
 		statusCode int
 	}{
 		{
-			name:       "Should return healthy api",
+			name: "Should return ready when every check passes",
+			report: e.HealthReport{Status: e.HealthUp, Checks: []e.HealthCheckResult{
+				{Name: "mlb_players", Status: e.HealthUp, Critical: true, LatencyMs: 1.5},
+			}},
+			statusCode: http.StatusOK,
+		},
+		{
+			name: "Should return ready when only a check that is not critical fails",
+			report: e.HealthReport{Status: e.HealthDegraded, Checks: []e.HealthCheckResult{
+				{Name: "mlb_players", Status: e.HealthUp, Critical: true},
+				{Name: "users_api", Status: e.HealthDown, Error: "context deadline exceeded"},
+			}},
 			statusCode: http.StatusOK,
 		},
+		{
+			name: "Should return unavailable when a critical check fails",
+			report: e.HealthReport{Status: e.HealthDown, Checks: []e.HealthCheckResult{
+				{Name: "mlb_players", Status: e.HealthDown, Critical: true, Error: "error opening the file"},
+			}},
+			statusCode: http.StatusServiceUnavailable,
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-
+			service := &mockHealthService{}
+			service.On("CheckReadiness").Return(tc.report)
 			w := httptest.NewRecorder()
-			r := httptest.NewRequest(http.MethodGet, "/health", nil)
+			r := httptest.NewRequest(http.MethodGet, "/health/ready", nil)
 
-			NewHealthController().CheckHealth(w, r)
+			NewHealthController(service).CheckReadiness(w, r)
 
+			var report e.HealthReport
+			json.NewDecoder(w.Body).Decode(&report)
 			assert.Equal(t, tc.statusCode, w.Code)
+			assert.Equal(t, tc.report, report)
 		})
 	}
 }
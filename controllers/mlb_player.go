@@ -1,7 +1,13 @@
 package controllers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
 	"net/http"
 	"strconv"
 
@@ -11,31 +17,253 @@ import (
 
 var allowedTypeFilters = map[string]bool{"even": true, "odd": true}
 
+var statsGroups = map[string]string{"": "", "teams": "team", "positions": "position"}
+
+var defaultPercentiles = []float64{25, 50, 75, 90}
+
+var importModes = map[e.MLBPlayerImportMode]bool{e.ImportMerge: true, e.ImportReplace: true}
+
+const (
+	maxImportBytes  = 32 << 20
+	maxImportMemory = 8 << 20
+)
+
 type mlbPlayerService interface {
-	GetMLBPlayers() ([]e.MLBPlayer, error)
-	GetMLBPlayerByID(id int) (*e.MLBPlayer, error)
-	GetMLBPlayerDesired(filterType string, totalItems int, itemsPerWorker int) ([]e.MLBPlayer, error)
+	SearchMLBPlayers(ctx context.Context, query e.MLBPlayerQuery) (*e.MLBPlayerPage, error)
+	GetMLBPlayerStats(ctx context.Context, filter e.MLBPlayerFilter, groupBy string, percentiles []float64) ([]e.MLBPlayerGroupStats, error)
+	ExportMLBPlayers(ctx context.Context, filter e.MLBPlayerFilter, fn func(p e.MLBPlayer) error) error
+	StreamMLBPlayers(ctx context.Context, filter e.MLBPlayerFilter, sort []e.SortKey, fn func(p e.MLBPlayer) error) error
+	GetMLBPlayerByID(ctx context.Context, id int) (*e.MLBPlayer, error)
+	GetMLBPlayerDesired(ctx context.Context, predicate e.MLBPlayerPredicate, totalItems int, itemsPerWorker int) (*e.MLBPlayerDesiredResult, error)
+	GetMLBPlayerSample(ctx context.Context, predicate e.MLBPlayerPredicate, totalItems int, itemsPerWorker int, sampling e.MLBPlayerSampling) (*e.MLBPlayerDesiredResult, error)
+	CreateMLBPlayer(ctx context.Context, player e.MLBPlayer) (*e.MLBPlayer, error)
+	UpdateMLBPlayer(ctx context.Context, id int, player e.MLBPlayer) (*e.MLBPlayer, error)
+	PatchMLBPlayer(ctx context.Context, id int, patch e.MLBPlayerPatch) (*e.MLBPlayer, error)
+	DeleteMLBPlayer(ctx context.Context, id int) (*e.MLBPlayer, error)
+	ImportMLBPlayers(ctx context.Context, file io.Reader, mode e.MLBPlayerImportMode) (*e.MLBPlayerImportReport, error)
 }
 
 type errorMessage struct {
-	Message string `json:"message"`
+	Message string       `json:"message"`
+	Errors  []paramError `json:"errors,omitempty"`
 }
 
 // MLBPlayerController struct handles api controller.
 type MLBPlayerController struct {
 	service mlbPlayerService
+	limits  DesiredLimits
+	logger  *slog.Logger
+}
+
+// DesiredLimits struct has the upper bounds of the concurrent reads of MLB Players, zero means no bound.
+type DesiredLimits struct {
+	MaxItems   int
+	MaxWorkers int
 }
 
 // MLBPlayerController function creates an instance of NewMLBPlayerController.
-func NewMLBPlayerController(service mlbPlayerService) *MLBPlayerController {
-	return &MLBPlayerController{service: service}
+func NewMLBPlayerController(service mlbPlayerService, limits DesiredLimits, logger *slog.Logger) *MLBPlayerController {
+	return &MLBPlayerController{service: service, limits: limits, logger: logger}
 }
 
-// GetMLBPlayers handles list of MLB Players
+// GetMLBPlayers handles list of MLB Players filtered, sorted and paginated by the query params.
+// Formats other than JSON stream every matching player, unless a page is asked for with the limit, offset or cursor
+// params. Then they only have the players of the page, which is sent in the X-Total-Count and Link headers.
 func (ctr *MLBPlayerController) GetMLBPlayers(w http.ResponseWriter, r *http.Request) {
+	format, err := negotiateFormat(r)
+
+	if err != nil {
+		writeFormatError(w, err)
+
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	query, errs := parseMLBPlayerQuery(r.URL.Query())
+
+	if len(errs) > 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMessage{
+			Message: "Invalid query params",
+			Errors:  errs,
+		})
+
+		return
+	}
+	values := r.URL.Query()
+
+	if format.Name != "json" && values.Get("limit") == "" && values.Get("offset") == "" && values.Get("cursor") == "" {
+		ctr.streamMLBPlayers(w, r, format, query)
+
+		return
+	}
+	page, err := ctr.service.SearchMLBPlayers(r.Context(), query)
+	if err != nil {
+		ctr.logger.ErrorContext(r.Context(), "error searching players", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(errorMessage{
+			Message: "Internal server error",
+		})
+
+		return
+	}
+
+	if format.Name != "json" {
+		w.Header().Set("X-Total-Count", strconv.Itoa(page.Total))
+
+		if next := nextPageURL(r, page); next != "" {
+			w.Header().Set("Link", "<"+next+">; rel=\"next\"")
+		}
+		tw := newTableWriter(w, format, mlbPlayerTable)
+		for _, p := range page.Players {
+			if err = tw.Write(p); err != nil {
+				break
+			}
+		}
+
+		if err == nil {
+			err = tw.Close()
+		}
+
+		if err != nil {
+			ctr.logger.ErrorContext(r.Context(), "error writing players, the response is aborted", "error", err, "rows", tw.rows)
+			// The response was started with the validators of the whole list, so it must not look complete.
+			panic(http.ErrAbortHandler)
+		}
+
+		return
+	}
+	json.NewEncoder(w).Encode(struct {
+		Total   int           `json:"total"`
+		Limit   int           `json:"limit"`
+		Offset  int           `json:"offset"`
+		Next    string        `json:"next,omitempty"`
+		Players []e.MLBPlayer `json:"players"`
+	}{
+		page.Total,
+		page.Limit,
+		page.Offset,
+		nextPageURL(r, page),
+		page.Players,
+	})
+}
+
+// streamMLBPlayers streams every MLB Player matching query in a format other than json, row by row.
+func (ctr *MLBPlayerController) streamMLBPlayers(w http.ResponseWriter, r *http.Request, format responseFormat, query e.MLBPlayerQuery) {
+	tw := newTableWriter(w, format, mlbPlayerTable)
+	err := ctr.service.StreamMLBPlayers(r.Context(), query.Filter, query.Sort, func(p e.MLBPlayer) error {
+		return tw.Write(p)
+	})
+
+	if err == nil {
+		err = tw.Close()
+	}
+
+	if err != nil && !tw.Started() {
+		ctr.logger.ErrorContext(r.Context(), "error streaming players", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(errorMessage{
+			Message: "Internal server error",
+		})
+
+		return
+	}
+
+	if err != nil {
+		ctr.logger.ErrorContext(r.Context(), "error streaming players, the response is aborted", "error", err, "rows", tw.rows)
+		// The response was started with the validators of the whole list, so it must not look complete.
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// ExportMLBPlayers handles the export of every MLB Player matching the filter params, streamed in the negotiated format.
+func (ctr *MLBPlayerController) ExportMLBPlayers(w http.ResponseWriter, r *http.Request) {
+	format, err := negotiateFormat(r)
+
+	if err != nil {
+		writeFormatError(w, err)
+
+		return
+	}
+	filter, errs := parseMLBPlayerFilter(r.URL.Query())
+
+	if len(errs) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMessage{
+			Message: "Invalid query params",
+			Errors:  errs,
+		})
+
+		return
+	}
+	tw := newTableWriter(w, format, mlbPlayerTable)
+	err = ctr.service.ExportMLBPlayers(r.Context(), filter, func(p e.MLBPlayer) error {
+		return tw.Write(p)
+	})
+
+	if err == nil {
+		err = tw.Close()
+	}
+
+	if err != nil && !tw.Started() {
+		ctr.logger.ErrorContext(r.Context(), "error exporting players", "error", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(errorMessage{
+			Message: "Internal server error",
+		})
+
+		return
+	}
+
+	if err != nil {
+		ctr.logger.ErrorContext(r.Context(), "error exporting players, the response is incomplete", "error", err, "rows", tw.rows)
+	}
+}
+
+// GetMLBPlayerStats handles statistics of MLB Players, overall or grouped by teams or positions.
+func (ctr *MLBPlayerController) GetMLBPlayerStats(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	players, err := ctr.service.GetMLBPlayers()
+	groupBy, ok := statsGroups[mux.Vars(r)["group"]]
+
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(errorMessage{
+			Message: "Stats group not found",
+		})
+
+		return
+	}
+	filter, errs := parseMLBPlayerFilter(r.URL.Query())
+	percentiles := defaultPercentiles
+
+	if value := r.URL.Query().Get("percentiles"); value != "" {
+		percentiles = nil
+		for _, item := range splitList(value) {
+			p, err := strconv.ParseFloat(item, 64)
+
+			if err != nil || math.IsNaN(p) || p <= 0 || p >= 100 {
+				errs = append(errs, paramError{Param: "percentiles", Message: "must be numbers between 0 and 100"})
+
+				break
+			}
+			percentiles = append(percentiles, p)
+		}
+	}
+
+	if len(errs) > 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMessage{
+			Message: "Invalid query params",
+			Errors:  errs,
+		})
+
+		return
+	}
+	stats, err := ctr.service.GetMLBPlayerStats(r.Context(), filter, groupBy, percentiles)
+
 	if err != nil {
+		ctr.logger.ErrorContext(r.Context(), "error getting player stats", "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(errorMessage{
 			Message: "Internal server error",
@@ -43,7 +271,20 @@ func (ctr *MLBPlayerController) GetMLBPlayers(w http.ResponseWriter, r *http.Req
 
 		return
 	}
-	json.NewEncoder(w).Encode(players)
+
+	if groupBy == "" {
+		json.NewEncoder(w).Encode(stats[0])
+
+		return
+	}
+
+	json.NewEncoder(w).Encode(struct {
+		GroupBy string                  `json:"group_by"`
+		Groups  []e.MLBPlayerGroupStats `json:"groups"`
+	}{
+		groupBy,
+		stats,
+	})
 }
 
 // GetMLBPlayers handles MLB Players by ID.
@@ -60,9 +301,10 @@ func (ctr *MLBPlayerController) GetMLBPlayerByID(w http.ResponseWriter, r *http.
 
 		return
 	}
-	player, err := ctr.service.GetMLBPlayerByID(id)
+	player, err := ctr.service.GetMLBPlayerByID(r.Context(), id)
 
 	if err != nil {
+		ctr.logger.ErrorContext(r.Context(), "error getting player", "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(errorMessage{
 			Message: "Internal server error",
@@ -83,18 +325,38 @@ func (ctr *MLBPlayerController) GetMLBPlayerByID(w http.ResponseWriter, r *http.
 	json.NewEncoder(w).Encode(player)
 }
 
-// GetMLBPlayerDesired handles list of MLB Players by filters.
+// GetMLBPlayerDesired handles list of MLB Players read concurrently and kept by the type and filter params.
 func (ctr *MLBPlayerController) GetMLBPlayerDesired(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	filterType := r.FormValue("type")
+	predicate := e.MLBPlayerAnd{}
 
-	if v, ok := allowedTypeFilters[filterType]; !ok || !v {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(errorMessage{
-			Message: "type param value is not allowed",
-		})
+	if r.URL.Query().Has("type") {
+		filterType := r.FormValue("type")
 
-		return
+		if v, ok := allowedTypeFilters[filterType]; !ok || !v {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(errorMessage{
+				Message: "type param value is not allowed",
+			})
+
+			return
+		}
+		predicate = append(predicate, e.MLBPlayerIDParity{Even: filterType == "even"})
+	}
+
+	if expression := r.FormValue("filter"); expression != "" {
+		filter, err := parseMLBPlayerPredicate(expression)
+
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(errorMessage{
+				Message: "filter param is not valid",
+				Errors:  []paramError{{Param: "filter", Message: err.Error()}},
+			})
+
+			return
+		}
+		predicate = append(predicate, filter)
 	}
 	itemsperworkers, err := strconv.Atoi(r.FormValue("items_per_workers"))
 
@@ -126,9 +388,47 @@ func (ctr *MLBPlayerController) GetMLBPlayerDesired(w http.ResponseWriter, r *ht
 
 		return
 	}
-	players, err := ctr.service.GetMLBPlayerDesired(filterType, items, itemsperworkers)
+
+	if ctr.limits.MaxItems > 0 && items > ctr.limits.MaxItems {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMessage{
+			Message: fmt.Sprint("items param must be less or equal ", ctr.limits.MaxItems),
+		})
+
+		return
+	}
+
+	if ctr.limits.MaxWorkers > 0 && items/itemsperworkers > ctr.limits.MaxWorkers {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMessage{
+			Message: fmt.Sprint("items param divided by items_per_workers param must be less or equal ", ctr.limits.MaxWorkers),
+		})
+
+		return
+	}
+	sampling, errs := parseMLBPlayerSampling(r.URL.Query())
+
+	if len(errs) > 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMessage{
+			Message: "Invalid query params",
+			Errors:  errs,
+		})
+
+		return
+	}
+	var result *e.MLBPlayerDesiredResult
+	var seed *int64
+
+	if sampling != nil {
+		result, err = ctr.service.GetMLBPlayerSample(r.Context(), predicate, items, itemsperworkers, *sampling)
+		seed = &sampling.Seed
+	} else {
+		result, err = ctr.service.GetMLBPlayerDesired(r.Context(), predicate, items, itemsperworkers)
+	}
 
 	if err != nil {
+		ctr.logger.ErrorContext(r.Context(), "error getting desired players", "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(errorMessage{
 			Message: "Internal server error",
@@ -138,10 +438,254 @@ func (ctr *MLBPlayerController) GetMLBPlayerDesired(w http.ResponseWriter, r *ht
 	}
 
 	json.NewEncoder(w).Encode(struct {
-		Count   int           `json:"total"`
-		Players []e.MLBPlayer `json:"players"`
+		Count      int              `json:"total"`
+		StopReason e.StopReason     `json:"stop_reason"`
+		Seed       *int64           `json:"seed,omitempty"`
+		Workers    []e.WorkerReport `json:"workers"`
+		Players    []e.MLBPlayer    `json:"players"`
 	}{
-		len(players),
-		players,
+		len(result.Players),
+		result.StopReason,
+		seed,
+		result.Workers,
+		result.Players,
+	})
+}
+
+// CreateMLBPlayer handles the creation of a MLB Player.
+func (ctr *MLBPlayerController) CreateMLBPlayer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var player e.MLBPlayer
+	err := json.NewDecoder(r.Body).Decode(&player)
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMessage{
+			Message: "Request body must be a valid player",
+		})
+
+		return
+	}
+	created, err := ctr.service.CreateMLBPlayer(r.Context(), player)
+
+	if err != nil {
+		ctr.writeServiceError(w, r, err)
+
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprint("/mlb-players/", created.ID))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// ImportMLBPlayers handles the upload of a CSV file of MLB Players in the file field of a multipart form.
+// The mode param is merge, the default, or replace. The rows are reported one by one and the accepted ones
+// are saved together, 422 is returned when nothing was saved: no row was accepted, or a replace had rejected rows.
+func (ctr *MLBPlayerController) ImportMLBPlayers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+	err := r.ParseMultipartForm(maxImportMemory)
+
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+
+		if errors.As(err, &tooLarge) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			json.NewEncoder(w).Encode(errorMessage{
+				Message: fmt.Sprint("Request body must be at most ", maxImportBytes, " bytes"),
+			})
+
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMessage{
+			Message: "Request body must be a multipart form with a CSV file",
+		})
+
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+	mode := e.MLBPlayerImportMode(r.FormValue("mode"))
+
+	if mode == "" {
+		mode = e.ImportMerge
+	}
+	var errs []paramError
+
+	if !importModes[mode] {
+		errs = append(errs, paramError{Param: "mode", Message: "must be merge or replace"})
+	}
+	file, _, err := r.FormFile("file")
+
+	if err != nil {
+		errs = append(errs, paramError{Param: "file", Message: "is required"})
+	}
+
+	if len(errs) > 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMessage{
+			Message: "Invalid params",
+			Errors:  errs,
+		})
+
+		return
+	}
+	defer file.Close()
+	report, err := ctr.service.ImportMLBPlayers(r.Context(), file, mode)
+
+	if err != nil {
+		ctr.writeServiceError(w, r, err)
+
+		return
+	}
+
+	if !report.Committed {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// UpdateMLBPlayer handles the replacement of a MLB Player by ID.
+func (ctr *MLBPlayerController) UpdateMLBPlayer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMessage{
+			Message: "Player ID provided must be of type integer",
+		})
+
+		return
+	}
+	var player e.MLBPlayer
+	err = json.NewDecoder(r.Body).Decode(&player)
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMessage{
+			Message: "Request body must be a valid player",
+		})
+
+		return
+	}
+	updated, err := ctr.service.UpdateMLBPlayer(r.Context(), id, player)
+
+	if err != nil {
+		ctr.writeServiceError(w, r, err)
+
+		return
+	}
+
+	if updated == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(errorMessage{
+			Message: "Player not found",
+		})
+
+		return
+	}
+
+	json.NewEncoder(w).Encode(updated)
+}
+
+// PatchMLBPlayer handles the partial update of a MLB Player by ID.
+func (ctr *MLBPlayerController) PatchMLBPlayer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMessage{
+			Message: "Player ID provided must be of type integer",
+		})
+
+		return
+	}
+	var patch e.MLBPlayerPatch
+	err = json.NewDecoder(r.Body).Decode(&patch)
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMessage{
+			Message: "Request body must be a valid player",
+		})
+
+		return
+	}
+	patched, err := ctr.service.PatchMLBPlayer(r.Context(), id, patch)
+
+	if err != nil {
+		ctr.writeServiceError(w, r, err)
+
+		return
+	}
+
+	if patched == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(errorMessage{
+			Message: "Player not found",
+		})
+
+		return
+	}
+
+	json.NewEncoder(w).Encode(patched)
+}
+
+// DeleteMLBPlayer handles the deletion of a MLB Player by ID.
+func (ctr *MLBPlayerController) DeleteMLBPlayer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMessage{
+			Message: "Player ID provided must be of type integer",
+		})
+
+		return
+	}
+	deleted, err := ctr.service.DeleteMLBPlayer(r.Context(), id)
+
+	if err != nil {
+		ctr.logger.ErrorContext(r.Context(), "error deleting player", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(errorMessage{
+			Message: "Internal server error",
+		})
+
+		return
+	}
+
+	if deleted == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(errorMessage{
+			Message: "Player not found",
+		})
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeServiceError answers validation errors with bad request and any other error with internal server error.
+func (ctr *MLBPlayerController) writeServiceError(w http.ResponseWriter, r *http.Request, err error) {
+	var validationErr *e.ValidationError
+
+	if errors.As(err, &validationErr) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMessage{
+			Message: validationErr.Error(),
+		})
+
+		return
+	}
+	ctr.logger.ErrorContext(r.Context(), "error writing player", "error", err)
+	w.WriteHeader(http.StatusInternalServerError)
+	json.NewEncoder(w).Encode(errorMessage{
+		Message: "Internal server error",
 	})
 }
@@ -0,0 +1,247 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	e "github.com/EloYaniel/academy-go-q42021/entities"
+)
+
+const (
+	maxPredicateLength     = 1024
+	maxPredicateConditions = 32
+)
+
+// predicateOperators are the operators of a condition, the two chars ones first so they win over their prefix.
+var predicateOperators = []e.PredicateOperator{
+	e.OpNotEqual, e.OpGreaterOrEqual, e.OpLessOrEqual, e.OpEqual, e.OpGreater, e.OpLess, e.OpContains,
+}
+
+// predicateToken is a parenthesis, a keyword or a condition of a predicate expression.
+type predicateToken struct {
+	kind      string
+	pos       int
+	condition *e.MLBPlayerCondition
+}
+
+// parseMLBPlayerPredicate parses expressions like `team=NYY AND (age>30 OR position~Pitcher)`.
+// AND binds tighter than OR, keywords ignore case and values with spaces or parentheses are quoted.
+func parseMLBPlayerPredicate(expression string) (e.MLBPlayerPredicate, error) {
+	if len(expression) > maxPredicateLength {
+		return nil, errors.New(fmt.Sprint("must be at most ", maxPredicateLength, " characters long"))
+	}
+	tokens, err := tokenizePredicate(expression)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tokens) == 0 {
+		return nil, errors.New("must not be empty")
+	}
+	p := &predicateParser{tokens: tokens}
+	predicate, err := p.parseOr()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if p.i < len(p.tokens) {
+		return nil, errors.New(fmt.Sprint("unexpected ", p.tokens[p.i].kind, " at position ", p.tokens[p.i].pos))
+	}
+
+	return predicate, nil
+}
+
+// tokenizePredicate splits expression into parentheses, AND, OR and conditions.
+func tokenizePredicate(expression string) ([]predicateToken, error) {
+	var tokens []predicateToken
+	conditions := 0
+
+	for i := 0; i < len(expression); {
+		c := expression[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, predicateToken{kind: string(c), pos: i})
+			i++
+		default:
+			start := i
+			for i < len(expression) && isFieldChar(expression[i]) {
+				i++
+			}
+			word := expression[start:i]
+
+			if keyword := strings.ToUpper(word); (keyword == "AND" || keyword == "OR") && !startsOperator(expression[i:]) {
+				tokens = append(tokens, predicateToken{kind: keyword, pos: start})
+
+				continue
+			}
+
+			if word == "" {
+				return nil, errors.New(fmt.Sprint("expected a field at position ", start))
+			}
+			operator := matchOperator(expression[i:])
+
+			if operator == "" {
+				return nil, errors.New(fmt.Sprint("expected an operator after ", word, " at position ", i))
+			}
+			i += len(operator)
+			value, next, err := readPredicateValue(expression, i)
+
+			if err != nil {
+				return nil, err
+			}
+			condition, err := e.NewMLBPlayerCondition(word, operator, value)
+
+			if err != nil {
+				return nil, err
+			}
+			conditions++
+
+			if conditions > maxPredicateConditions {
+				return nil, errors.New(fmt.Sprint("must have at most ", maxPredicateConditions, " conditions"))
+			}
+			tokens = append(tokens, predicateToken{kind: "condition", pos: start, condition: condition})
+			i = next
+		}
+	}
+
+	return tokens, nil
+}
+
+func isFieldChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func startsOperator(s string) bool {
+	return matchOperator(s) != ""
+}
+
+func matchOperator(s string) e.PredicateOperator {
+	for _, operator := range predicateOperators {
+		if strings.HasPrefix(s, string(operator)) {
+			return operator
+		}
+	}
+
+	return ""
+}
+
+// readPredicateValue reads a quoted value, or a bare one up to a space or a parenthesis, and returns where it ends.
+func readPredicateValue(expression string, i int) (string, int, error) {
+	if i < len(expression) && (expression[i] == '"' || expression[i] == '\'') {
+		quote := expression[i]
+		end := strings.IndexByte(expression[i+1:], quote)
+
+		if end < 0 {
+			return "", 0, errors.New(fmt.Sprint("unterminated quote at position ", i))
+		}
+
+		return expression[i+1 : i+1+end], i + end + 2, nil
+	}
+	start := i
+	for i < len(expression) && !strings.ContainsRune(" \t\n()", rune(expression[i])) {
+		i++
+	}
+
+	if i == start {
+		return "", 0, errors.New(fmt.Sprint("expected a value at position ", start))
+	}
+
+	return expression[start:i], i, nil
+}
+
+// predicateParser builds the predicate from the tokens by recursive descent.
+type predicateParser struct {
+	tokens []predicateToken
+	i      int
+}
+
+func (p *predicateParser) accept(kind string) bool {
+	if p.i < len(p.tokens) && p.tokens[p.i].kind == kind {
+		p.i++
+
+		return true
+	}
+
+	return false
+}
+
+func (p *predicateParser) parseOr() (e.MLBPlayerPredicate, error) {
+	var or e.MLBPlayerOr
+
+	for {
+		predicate, err := p.parseAnd()
+
+		if err != nil {
+			return nil, err
+		}
+		or = append(or, predicate)
+
+		if !p.accept("OR") {
+			break
+		}
+	}
+
+	if len(or) == 1 {
+		return or[0], nil
+	}
+
+	return or, nil
+}
+
+func (p *predicateParser) parseAnd() (e.MLBPlayerPredicate, error) {
+	var and e.MLBPlayerAnd
+
+	for {
+		predicate, err := p.parseTerm()
+
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, predicate)
+
+		if !p.accept("AND") {
+			break
+		}
+	}
+
+	if len(and) == 1 {
+		return and[0], nil
+	}
+
+	return and, nil
+}
+
+func (p *predicateParser) parseTerm() (e.MLBPlayerPredicate, error) {
+	if p.i >= len(p.tokens) {
+		return nil, errors.New("unexpected end of the expression")
+	}
+	token := p.tokens[p.i]
+
+	switch token.kind {
+	case "condition":
+		p.i++
+
+		return token.condition, nil
+	case "(":
+		p.i++
+		predicate, err := p.parseOr()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !p.accept(")") {
+			return nil, errors.New(fmt.Sprint("missing ) for the ( at position ", token.pos))
+		}
+
+		return predicate, nil
+	}
+
+	return nil, errors.New(fmt.Sprint("unexpected ", token.kind, " at position ", token.pos))
+}
@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"testing"
+
+	e "github.com/EloYaniel/academy-go-q42021/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+var predicatePlayers = []e.MLBPlayer{
+	{ID: 1, Name: "Adam Donachie", Team: "BAL", Position: "Catcher", Height: 74, Weight: 180, Age: 22.99},
+	{ID: 2, Name: "Paul Bako", Team: "BAL", Position: "Catcher", Height: 74, Weight: 215, Age: 34.69},
+	{ID: 3, Name: "Derek Jeter", Team: "NYY", Position: "Shortstop", Height: 75, Weight: 195, Age: 32.68},
+	{ID: 4, Name: "Mike Mussina", Team: "NYY", Position: "Starting Pitcher", Height: 74, Weight: 185, Age: 38.22},
+}
+
+func Test_parseMLBPlayerPredicate_Suite(t *testing.T) {
+	testCases := []struct {
+		name          string
+		expression    string
+		expectedIDs   []int
+		expectedError string
+	}{
+		{
+			name:        "Should compare text ignoring case",
+			expression:  "team=nyy",
+			expectedIDs: []int{3, 4},
+		},
+		{
+			name:        "Should compare numbers",
+			expression:  "age>30",
+			expectedIDs: []int{2, 3, 4},
+		},
+		{
+			name:        "Should compare float fields with their own precision",
+			expression:  "age=22.99",
+			expectedIDs: []int{1},
+		},
+		{
+			name:        "Should search text",
+			expression:  "position~Pitcher",
+			expectedIDs: []int{4},
+		},
+		{
+			name:        "Should bind AND tighter than OR",
+			expression:  "team=NYY and age>35 or name~bako",
+			expectedIDs: []int{2, 4},
+		},
+		{
+			name:        "Should group with parentheses",
+			expression:  "team=NYY AND (age>35 OR weight_lbs<=195)",
+			expectedIDs: []int{3, 4},
+		},
+		{
+			name:        "Should read quoted values",
+			expression:  `position="Starting Pitcher" OR name!='Adam Donachie' AND id>=3`,
+			expectedIDs: []int{3, 4},
+		},
+		{
+			name:          "Should reject unknown fields",
+			expression:    "salary>10",
+			expectedError: "salary is not a field of MLB Players",
+		},
+		{
+			name:          "Should reject number operators on text fields",
+			expression:    "team<NYY",
+			expectedError: "team only supports =, != and ~",
+		},
+		{
+			name:          "Should reject text values on number fields",
+			expression:    "age>old",
+			expectedError: "age must be compared with a number",
+		},
+		{
+			name:          "Should reject numbers that are not finite",
+			expression:    "age>NaN",
+			expectedError: "age must be compared with a number",
+		},
+		{
+			name:          "Should reject missing operators",
+			expression:    "team NYY",
+			expectedError: "expected an operator after team at position 4",
+		},
+		{
+			name:          "Should reject dangling keywords",
+			expression:    "team=NYY AND",
+			expectedError: "unexpected end of the expression",
+		},
+		{
+			name:          "Should reject unbalanced parentheses",
+			expression:    "(team=NYY OR age>30",
+			expectedError: "missing ) for the ( at position 0",
+		},
+		{
+			name:          "Should reject unterminated quotes",
+			expression:    `name="Derek`,
+			expectedError: "unterminated quote at position 5",
+		},
+		{
+			name:          "Should reject empty expressions",
+			expression:    "  ",
+			expectedError: "must not be empty",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			predicate, err := parseMLBPlayerPredicate(tc.expression)
+
+			if tc.expectedError != "" {
+				assert.Nil(t, predicate)
+				assert.EqualError(t, err, tc.expectedError)
+
+				return
+			}
+			assert.Nil(t, err)
+			var ids []int
+			for _, p := range predicatePlayers {
+				if predicate.Match(p) {
+					ids = append(ids, p.ID)
+				}
+			}
+			assert.Equal(t, tc.expectedIDs, ids)
+		})
+	}
+}
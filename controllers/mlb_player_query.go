@@ -0,0 +1,223 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	e "github.com/EloYaniel/academy-go-q42021/entities"
+)
+
+const (
+	defaultPlayersLimit = 100
+	maxPlayersLimit     = 1000
+	cursorPrefix        = "offset:"
+)
+
+// paramError struct has a query param with a wrong value.
+type paramError struct {
+	Param   string `json:"param"`
+	Message string `json:"message"`
+}
+
+// parseMLBPlayerFilter reads the filter of MLB Players from the query params.
+func parseMLBPlayerFilter(values url.Values) (e.MLBPlayerFilter, []paramError) {
+	var errs []paramError
+	filter := e.MLBPlayerFilter{
+		Teams:     splitList(values.Get("team")),
+		Positions: splitList(values.Get("position")),
+		MinHeight: parseIntParam(values, "height_inches_min", &errs),
+		MaxHeight: parseIntParam(values, "height_inches_max", &errs),
+		MinWeight: parseFloatParam(values, "weight_lbs_min", &errs),
+		MaxWeight: parseFloatParam(values, "weight_lbs_max", &errs),
+		MinAge:    parseFloatParam(values, "age_min", &errs),
+		MaxAge:    parseFloatParam(values, "age_max", &errs),
+	}
+
+	if filter.MinHeight != nil && filter.MaxHeight != nil && *filter.MinHeight > *filter.MaxHeight {
+		errs = append(errs, paramError{Param: "height_inches_min", Message: "must be less or equal height_inches_max"})
+	}
+
+	if filter.MinWeight != nil && filter.MaxWeight != nil && *filter.MinWeight > *filter.MaxWeight {
+		errs = append(errs, paramError{Param: "weight_lbs_min", Message: "must be less or equal weight_lbs_max"})
+	}
+
+	if filter.MinAge != nil && filter.MaxAge != nil && *filter.MinAge > *filter.MaxAge {
+		errs = append(errs, paramError{Param: "age_min", Message: "must be less or equal age_max"})
+	}
+
+	return filter, errs
+}
+
+// parseMLBPlayerQuery reads the filter, sort and page of MLB Players from the query params.
+func parseMLBPlayerQuery(values url.Values) (e.MLBPlayerQuery, []paramError) {
+	filter, errs := parseMLBPlayerFilter(values)
+	query := e.MLBPlayerQuery{Filter: filter, Limit: defaultPlayersLimit}
+
+	for _, field := range splitList(values.Get("sort")) {
+		key := e.SortKey{Field: strings.TrimPrefix(field, "-"), Desc: strings.HasPrefix(field, "-")}
+
+		if !e.MLBPlayerSortFields[key.Field] {
+			errs = append(errs, paramError{Param: "sort", Message: "can not sort by " + key.Field})
+
+			continue
+		}
+		query.Sort = append(query.Sort, key)
+	}
+
+	if limit := parseIntParam(values, "limit", &errs); limit != nil {
+		if *limit <= 0 || *limit > maxPlayersLimit {
+			errs = append(errs, paramError{Param: "limit", Message: "must be between 1 and " + strconv.Itoa(maxPlayersLimit)})
+		} else {
+			query.Limit = *limit
+		}
+	}
+
+	if values.Get("cursor") != "" && values.Get("offset") != "" {
+		errs = append(errs, paramError{Param: "cursor", Message: "can not be used with offset"})
+	} else if cursor := values.Get("cursor"); cursor != "" {
+		offset, err := decodeCursor(cursor)
+
+		if err != nil {
+			errs = append(errs, paramError{Param: "cursor", Message: "is not valid"})
+		} else {
+			query.Offset = offset
+		}
+	} else if offset := parseIntParam(values, "offset", &errs); offset != nil {
+		if *offset < 0 {
+			errs = append(errs, paramError{Param: "offset", Message: "must be a non negative integer"})
+		} else {
+			query.Offset = *offset
+		}
+	}
+
+	return query, errs
+}
+
+// parseMLBPlayerSampling reads the mode of the concurrent reads of MLB Players, nil when they keep the first matching rows.
+// Without seed param a random one is used.
+func parseMLBPlayerSampling(values url.Values) (*e.MLBPlayerSampling, []paramError) {
+	var errs []paramError
+	mode := values.Get("mode")
+
+	if mode == "" || mode == "first" {
+		for _, name := range []string{"seed", "replacement"} {
+			if values.Get(name) != "" {
+				errs = append(errs, paramError{Param: name, Message: "requires mode sample"})
+			}
+		}
+
+		return nil, errs
+	}
+
+	if mode != "sample" {
+		return nil, []paramError{{Param: "mode", Message: "must be first or sample"}}
+	}
+	sampling := &e.MLBPlayerSampling{Seed: rand.Int63()}
+
+	if value := values.Get("seed"); value != "" {
+		seed, err := strconv.ParseInt(value, 10, 64)
+
+		if err != nil {
+			errs = append(errs, paramError{Param: "seed", Message: "must be an integer"})
+		}
+		sampling.Seed = seed
+	}
+
+	if value := values.Get("replacement"); value != "" {
+		replacement, err := strconv.ParseBool(value)
+
+		if err != nil {
+			errs = append(errs, paramError{Param: "replacement", Message: "must be true or false"})
+		}
+		sampling.Replacement = replacement
+	}
+
+	return sampling, errs
+}
+
+// nextPageURL gets the link to the page after page, empty if it is the last one.
+func nextPageURL(r *http.Request, page *e.MLBPlayerPage) string {
+	next := page.Offset + page.Limit
+
+	if page.Limit <= 0 || next >= page.Total {
+		return ""
+	}
+	values := r.URL.Query()
+	values.Del("offset")
+	values.Set("cursor", encodeCursor(next))
+	values.Set("limit", strconv.Itoa(page.Limit))
+
+	return r.URL.Path + "?" + values.Encode()
+}
+
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+
+	if err != nil || !strings.HasPrefix(string(raw), cursorPrefix) {
+		return 0, errors.New("invalid cursor")
+	}
+	offset, err := strconv.Atoi(strings.TrimPrefix(string(raw), cursorPrefix))
+
+	if err != nil || offset < 0 {
+		return 0, errors.New("invalid cursor")
+	}
+
+	return offset, nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+func parseIntParam(values url.Values, name string, errs *[]paramError) *int {
+	value := values.Get(name)
+
+	if value == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(value)
+
+	if err != nil {
+		*errs = append(*errs, paramError{Param: name, Message: "must be an integer"})
+
+		return nil
+	}
+
+	return &n
+}
+
+func parseFloatParam(values url.Values, name string, errs *[]paramError) *float32 {
+	value := values.Get(name)
+
+	if value == "" {
+		return nil
+	}
+	n, err := strconv.ParseFloat(value, 32)
+
+	// NaN and infinities would be accepted by ParseFloat and then match no player.
+	if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+		*errs = append(*errs, paramError{Param: name, Message: "must be a number"})
+
+		return nil
+	}
+	f := float32(n)
+
+	return &f
+}
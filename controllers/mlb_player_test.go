@@ -1,13 +1,21 @@
 package controllers
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"io"
 	"io/ioutil"
+	"log/slog"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	e "github.com/EloYaniel/academy-go-q42021/entities"
+	"github.com/EloYaniel/academy-go-q42021/logger"
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -17,41 +25,118 @@ type mockMLBService struct {
 	mock.Mock
 }
 
-func (m *mockMLBService) GetMLBPlayers() ([]e.MLBPlayer, error) {
+func (m *mockMLBService) SearchMLBPlayers(ctx context.Context, query e.MLBPlayerQuery) (*e.MLBPlayerPage, error) {
+	args := m.Called(query)
+
+	return args.Get(0).(*e.MLBPlayerPage), args.Error(1)
+}
+
+func (m *mockMLBService) ExportMLBPlayers(ctx context.Context, filter e.MLBPlayerFilter, fn func(p e.MLBPlayer) error) error {
+	args := m.Called(filter)
+	players, _ := args.Get(0).([]e.MLBPlayer)
+	for _, p := range players {
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+
+	return args.Error(1)
+}
+
+func (m *mockMLBService) StreamMLBPlayers(ctx context.Context, filter e.MLBPlayerFilter, sort []e.SortKey, fn func(p e.MLBPlayer) error) error {
+	args := m.Called(filter, sort)
+	players, _ := args.Get(0).([]e.MLBPlayer)
+	for _, p := range players {
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+
+	return args.Error(1)
+}
+
+func (m *mockMLBService) GetMLBPlayerByID(ctx context.Context, id int) (*e.MLBPlayer, error) {
+	args := m.Called()
+
+	return args.Get(0).(*e.MLBPlayer), args.Error(1)
+}
+
+func (m *mockMLBService) GetMLBPlayerDesired(ctx context.Context, predicate e.MLBPlayerPredicate, totalItems int, itemsPerWorker int) (*e.MLBPlayerDesiredResult, error) {
+	args := m.Called()
+
+	return args.Get(0).(*e.MLBPlayerDesiredResult), args.Error(1)
+}
+
+func (m *mockMLBService) GetMLBPlayerSample(ctx context.Context, predicate e.MLBPlayerPredicate, totalItems int, itemsPerWorker int, sampling e.MLBPlayerSampling) (*e.MLBPlayerDesiredResult, error) {
+	args := m.Called(sampling)
+
+	return args.Get(0).(*e.MLBPlayerDesiredResult), args.Error(1)
+}
+
+func (m *mockMLBService) GetMLBPlayerStats(ctx context.Context, filter e.MLBPlayerFilter, groupBy string, percentiles []float64) ([]e.MLBPlayerGroupStats, error) {
+	args := m.Called(filter, groupBy, percentiles)
+
+	return args.Get(0).([]e.MLBPlayerGroupStats), args.Error(1)
+}
+
+func (m *mockMLBService) CreateMLBPlayer(ctx context.Context, player e.MLBPlayer) (*e.MLBPlayer, error) {
 	args := m.Called()
 
-	return args.Get(0).([]e.MLBPlayer), args.Error(1)
+	return args.Get(0).(*e.MLBPlayer), args.Error(1)
 }
 
-func (m *mockMLBService) GetMLBPlayerByID(id int) (*e.MLBPlayer, error) {
+func (m *mockMLBService) UpdateMLBPlayer(ctx context.Context, id int, player e.MLBPlayer) (*e.MLBPlayer, error) {
 	args := m.Called()
 
 	return args.Get(0).(*e.MLBPlayer), args.Error(1)
 }
 
-func (m *mockMLBService) GetMLBPlayerDesired(filterType string, totalItems int, itemsPerWorker int) ([]e.MLBPlayer, error) {
+func (m *mockMLBService) PatchMLBPlayer(ctx context.Context, id int, patch e.MLBPlayerPatch) (*e.MLBPlayer, error) {
 	args := m.Called()
 
-	return args.Get(0).([]e.MLBPlayer), args.Error(1)
+	return args.Get(0).(*e.MLBPlayer), args.Error(1)
+}
+
+func (m *mockMLBService) DeleteMLBPlayer(ctx context.Context, id int) (*e.MLBPlayer, error) {
+	args := m.Called()
+
+	return args.Get(0).(*e.MLBPlayer), args.Error(1)
+}
+
+func (m *mockMLBService) ImportMLBPlayers(ctx context.Context, file io.Reader, mode e.MLBPlayerImportMode) (*e.MLBPlayerImportReport, error) {
+	content, _ := ioutil.ReadAll(file)
+	args := m.Called(string(content), mode)
+
+	return args.Get(0).(*e.MLBPlayerImportReport), args.Error(1)
+}
+
+func intParam(n int) *int {
+	return &n
+}
+
+func floatParam(n float32) *float32 {
+	return &n
 }
 
 func Test_MLBPlayerController_GetMLBPlayers_Suite(t *testing.T) {
 	testCases := []struct {
 		name                 string
+		rawQuery             string
 		statusCode           int
 		expectedServiceCalls int
-		hasError             bool
+		expectedQuery        e.MLBPlayerQuery
 		serviceError         error
-		serviceResponse      []e.MLBPlayer
-		errorMessage         string
+		serviceResponse      *e.MLBPlayerPage
+		expectedBody         []string
 	}{
 		{
 			name:                 "Should return players",
+			rawQuery:             "",
 			statusCode:           http.StatusOK,
 			expectedServiceCalls: 1,
-			hasError:             false,
-			serviceResponse: []e.MLBPlayer{
-				{
+			expectedQuery:        e.MLBPlayerQuery{Limit: 100},
+			serviceResponse: &e.MLBPlayerPage{
+				Players: []e.MLBPlayer{{
 					ID:       1,
 					Name:     "Adam Donachie",
 					Team:     "BAL",
@@ -59,38 +144,103 @@ func Test_MLBPlayerController_GetMLBPlayers_Suite(t *testing.T) {
 					Height:   74,
 					Weight:   180,
 					Age:      22.99,
+				}},
+				Total: 1,
+				Limit: 100,
+			},
+			expectedBody: []string{"\"total\":1", "\"players\":[{\"id\":1"},
+		},
+		{
+			name:                 "Should parse filters, sort and page",
+			rawQuery:             "team=BAL,NYY&position=Catcher&height_inches_min=70&height_inches_max=75&weight_lbs_min=180.5&age_max=30&sort=team,-age&limit=2&offset=4",
+			statusCode:           http.StatusOK,
+			expectedServiceCalls: 1,
+			expectedQuery: e.MLBPlayerQuery{
+				Filter: e.MLBPlayerFilter{
+					Teams:     []string{"BAL", "NYY"},
+					Positions: []string{"Catcher"},
+					MinHeight: intParam(70),
+					MaxHeight: intParam(75),
+					MinWeight: floatParam(180.5),
+					MaxAge:    floatParam(30),
 				},
+				Sort:   []e.SortKey{{Field: "team"}, {Field: "age", Desc: true}},
+				Limit:  2,
+				Offset: 4,
 			},
-			serviceError: nil,
+			serviceResponse: &e.MLBPlayerPage{Players: []e.MLBPlayer{}, Total: 10, Limit: 2, Offset: 4},
+			expectedBody:    []string{"\"total\":10", "\"next\":\"/mlb-players?age_max=30", "cursor=" + encodeCursor(6) + "\\u0026", "limit=2"},
+		},
+		{
+			name:                 "Should read offset from cursor",
+			rawQuery:             "cursor=" + encodeCursor(6) + "&limit=2",
+			statusCode:           http.StatusOK,
+			expectedServiceCalls: 1,
+			expectedQuery:        e.MLBPlayerQuery{Limit: 2, Offset: 6},
+			serviceResponse:      &e.MLBPlayerPage{Players: []e.MLBPlayer{}, Total: 8, Limit: 2, Offset: 6},
+			expectedBody:         []string{"\"offset\":6"},
+		},
+		{
+			name:                 "Should return bad request on wrong params",
+			rawQuery:             "height_inches_min=tall&age_min=40&age_max=30&sort=salary&limit=0&cursor=abc",
+			statusCode:           http.StatusBadRequest,
+			expectedServiceCalls: 0,
+			expectedBody: []string{
+				"Invalid query params",
+				"{\"param\":\"height_inches_min\",\"message\":\"must be an integer\"}",
+				"{\"param\":\"age_min\",\"message\":\"must be less or equal age_max\"}",
+				"{\"param\":\"sort\",\"message\":\"can not sort by salary\"}",
+				"{\"param\":\"limit\",\"message\":\"must be between 1 and 1000\"}",
+				"{\"param\":\"cursor\",\"message\":\"is not valid\"}",
+			},
+		},
+		{
+			name:                 "Should return bad request on numbers that are not finite",
+			rawQuery:             "age_min=NaN&weight_lbs_max=Inf&weight_lbs_min=-infinity",
+			statusCode:           http.StatusBadRequest,
+			expectedServiceCalls: 0,
+			expectedBody: []string{
+				"{\"param\":\"weight_lbs_min\",\"message\":\"must be a number\"}",
+				"{\"param\":\"weight_lbs_max\",\"message\":\"must be a number\"}",
+				"{\"param\":\"age_min\",\"message\":\"must be a number\"}",
+			},
+		},
+		{
+			name:                 "Should return bad request when cursor and offset are used",
+			rawQuery:             "cursor=" + encodeCursor(6) + "&offset=2",
+			statusCode:           http.StatusBadRequest,
+			expectedServiceCalls: 0,
+			expectedBody:         []string{"can not be used with offset"},
 		},
 		{
 			name:                 "Should return internal server on service error",
+			rawQuery:             "",
 			statusCode:           http.StatusInternalServerError,
 			expectedServiceCalls: 1,
-			hasError:             true,
+			expectedQuery:        e.MLBPlayerQuery{Limit: 100},
 			serviceResponse:      nil,
 			serviceError:         errors.New("unknown error"),
-			errorMessage:         "Internal server error",
+			expectedBody:         []string{"Internal server error"},
 		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			w := httptest.NewRecorder()
-			r := httptest.NewRequest(http.MethodGet, "/mlb-players", nil)
+			r := httptest.NewRequest(http.MethodGet, "/mlb-players?"+tc.rawQuery, nil)
 			m := new(mockMLBService)
-			m.On("GetMLBPlayers").Return(tc.serviceResponse, tc.serviceError)
-			ctr := NewMLBPlayerController(m)
+			m.On("SearchMLBPlayers", tc.expectedQuery).Return(tc.serviceResponse, tc.serviceError)
+			ctr := NewMLBPlayerController(m, DesiredLimits{}, logger.Discard())
 
 			ctr.GetMLBPlayers(w, r)
 
-			if tc.hasError {
-				assert.Contains(t, w.Body.String(), tc.errorMessage)
+			for _, body := range tc.expectedBody {
+				assert.Contains(t, w.Body.String(), body)
 			}
 
-			assert.Equal(t, w.Code, tc.statusCode)
+			assert.Equal(t, tc.statusCode, w.Code)
 			assert.Equal(t, "application/json", w.Result().Header.Get("Content-Type"))
-			m.AssertNumberOfCalls(t, "GetMLBPlayers", tc.expectedServiceCalls)
+			m.AssertNumberOfCalls(t, "SearchMLBPlayers", tc.expectedServiceCalls)
 		})
 	}
 }
@@ -162,7 +312,7 @@ func Test_MLBPlayerController_GetMLBPlayerByID_Suite(t *testing.T) {
 			r = mux.SetURLVars(r, map[string]string{"id": tc.idParam})
 			m := new(mockMLBService)
 			m.On("GetMLBPlayerByID").Return(tc.serviceResponse, tc.serviceError)
-			ctr := NewMLBPlayerController(m)
+			ctr := NewMLBPlayerController(m, DesiredLimits{}, logger.Discard())
 
 			ctr.GetMLBPlayerByID(w, r)
 			res := w.Result()
@@ -183,13 +333,15 @@ func Test_MLBPlayerController_GetMLBPlayerDesired_Suite(t *testing.T) {
 	testCases := []struct {
 		name                 string
 		typeParam            string
+		filterParam          string
 		ipwParam             string
 		itemsParam           string
+		limits               DesiredLimits
 		statusCode           int
 		expectedServiceCalls int
 		hasError             bool
 		serviceError         error
-		serviceResponse      []e.MLBPlayer
+		serviceResponse      *e.MLBPlayerDesiredResult
 		errorMessage         string
 	}{
 		{
@@ -200,8 +352,8 @@ func Test_MLBPlayerController_GetMLBPlayerDesired_Suite(t *testing.T) {
 			statusCode:           http.StatusOK,
 			expectedServiceCalls: 1,
 			hasError:             false,
-			serviceResponse: []e.MLBPlayer{
-				{
+			serviceResponse: &e.MLBPlayerDesiredResult{
+				Players: []e.MLBPlayer{{
 					ID:       1,
 					Name:     "Adam Donachie",
 					Team:     "BAL",
@@ -209,7 +361,9 @@ func Test_MLBPlayerController_GetMLBPlayerDesired_Suite(t *testing.T) {
 					Height:   74,
 					Weight:   180,
 					Age:      22.99,
-				},
+				}},
+				StopReason: e.StopReasonWorkersLimit,
+				Workers:    []e.WorkerReport{{WorkerID: 1, RowsRead: 1, Items: 1}},
 			},
 			serviceError: nil,
 		},
@@ -221,8 +375,8 @@ func Test_MLBPlayerController_GetMLBPlayerDesired_Suite(t *testing.T) {
 			statusCode:           http.StatusOK,
 			expectedServiceCalls: 1,
 			hasError:             false,
-			serviceResponse: []e.MLBPlayer{
-				{
+			serviceResponse: &e.MLBPlayerDesiredResult{
+				Players: []e.MLBPlayer{{
 					ID:       1,
 					Name:     "Adam Donachie",
 					Team:     "BAL",
@@ -230,7 +384,9 @@ func Test_MLBPlayerController_GetMLBPlayerDesired_Suite(t *testing.T) {
 					Height:   74,
 					Weight:   180,
 					Age:      22.99,
-				},
+				}},
+				StopReason: e.StopReasonWorkersLimit,
+				Workers:    []e.WorkerReport{{WorkerID: 1, RowsRead: 1, Items: 1}},
 			},
 			serviceError: nil,
 		},
@@ -258,6 +414,46 @@ func Test_MLBPlayerController_GetMLBPlayerDesired_Suite(t *testing.T) {
 			serviceError:         nil,
 			errorMessage:         "type param value is not allowed",
 		},
+		{
+			name:                 "Should return players matching the filter",
+			filterParam:          "team=BAL AND (age>30 OR position~catcher)",
+			ipwParam:             "5",
+			itemsParam:           "20",
+			statusCode:           http.StatusOK,
+			expectedServiceCalls: 1,
+			hasError:             false,
+			serviceResponse: &e.MLBPlayerDesiredResult{
+				Players:    []e.MLBPlayer{{ID: 1, Name: "Adam Donachie", Team: "BAL", Position: "Catcher", Height: 74, Weight: 180, Age: 22.99}},
+				StopReason: e.StopReasonEOF,
+				Workers:    []e.WorkerReport{{WorkerID: 1, RowsRead: 1, Items: 1}},
+			},
+			serviceError: nil,
+		},
+		{
+			name:                 "Should return every player without type nor filter",
+			ipwParam:             "5",
+			itemsParam:           "20",
+			statusCode:           http.StatusOK,
+			expectedServiceCalls: 1,
+			hasError:             false,
+			serviceResponse: &e.MLBPlayerDesiredResult{
+				StopReason: e.StopReasonEOF,
+			},
+			serviceError: nil,
+		},
+		{
+			name:                 "Should return bad request if the filter is not valid",
+			typeParam:            "odd",
+			filterParam:          "team>NYY",
+			ipwParam:             "5",
+			itemsParam:           "20",
+			statusCode:           http.StatusBadRequest,
+			expectedServiceCalls: 0,
+			hasError:             true,
+			serviceResponse:      nil,
+			serviceError:         nil,
+			errorMessage:         `{"message":"filter param is not valid","errors":[{"param":"filter","message":"team only supports =, != and ~"}]}`,
+		},
 		{
 			name:                 "Should return bad request if items params is not a number",
 			typeParam:            "even",
@@ -318,6 +514,32 @@ func Test_MLBPlayerController_GetMLBPlayerDesired_Suite(t *testing.T) {
 			serviceError:         nil,
 			errorMessage:         "items_per_workers param must be less or equal items param",
 		},
+		{
+			name:                 "Should return bad request if items params is over the limit",
+			typeParam:            "even",
+			ipwParam:             "5",
+			itemsParam:           "20",
+			limits:               DesiredLimits{MaxItems: 10},
+			statusCode:           http.StatusBadRequest,
+			expectedServiceCalls: 0,
+			hasError:             true,
+			serviceResponse:      nil,
+			serviceError:         nil,
+			errorMessage:         "items param must be less or equal 10",
+		},
+		{
+			name:                 "Should return bad request if workers are over the limit",
+			typeParam:            "even",
+			ipwParam:             "5",
+			itemsParam:           "20",
+			limits:               DesiredLimits{MaxItems: 20, MaxWorkers: 3},
+			statusCode:           http.StatusBadRequest,
+			expectedServiceCalls: 0,
+			hasError:             true,
+			serviceResponse:      nil,
+			serviceError:         nil,
+			errorMessage:         "items param divided by items_per_workers param must be less or equal 3",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -327,11 +549,17 @@ func Test_MLBPlayerController_GetMLBPlayerDesired_Suite(t *testing.T) {
 			q := r.URL.Query()
 			q.Add("items_per_workers", tc.ipwParam)
 			q.Add("items", tc.itemsParam)
-			q.Add("type", tc.typeParam)
+			if tc.typeParam != "" {
+				q.Add("type", tc.typeParam)
+			}
+
+			if tc.filterParam != "" {
+				q.Add("filter", tc.filterParam)
+			}
 			r.URL.RawQuery = q.Encode()
 			m := new(mockMLBService)
 			m.On("GetMLBPlayerDesired").Return(tc.serviceResponse, tc.serviceError)
-			ctr := NewMLBPlayerController(m)
+			ctr := NewMLBPlayerController(m, tc.limits, logger.Discard())
 
 			ctr.GetMLBPlayerDesired(w, r)
 			res := w.Result()
@@ -341,9 +569,635 @@ func Test_MLBPlayerController_GetMLBPlayerDesired_Suite(t *testing.T) {
 				assert.Contains(t, string(body), tc.errorMessage)
 			}
 
+			if tc.serviceResponse != nil {
+				assert.Contains(t, string(body), string(tc.serviceResponse.StopReason))
+			}
+
 			assert.Equal(t, tc.statusCode, res.StatusCode)
 			assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
 			m.AssertNumberOfCalls(t, "GetMLBPlayerDesired", tc.expectedServiceCalls)
 		})
 	}
 }
+
+func Test_MLBPlayerController_GetMLBPlayerSample_Suite(t *testing.T) {
+	testCases := []struct {
+		name             string
+		query            string
+		statusCode       int
+		expectedSampling *e.MLBPlayerSampling
+		expectedBody     string
+	}{
+		{
+			name:             "Should sample with the given seed",
+			query:            "mode=sample&seed=42&items=4&items_per_workers=2",
+			statusCode:       http.StatusOK,
+			expectedSampling: &e.MLBPlayerSampling{Seed: 42},
+			expectedBody:     `"seed":42`,
+		},
+		{
+			name:             "Should sample with replacement",
+			query:            "mode=sample&seed=-7&replacement=true&items=4&items_per_workers=2",
+			statusCode:       http.StatusOK,
+			expectedSampling: &e.MLBPlayerSampling{Seed: -7, Replacement: true},
+			expectedBody:     `"seed":-7`,
+		},
+		{
+			name:         "Should keep the first rows by default",
+			query:        "items=4&items_per_workers=2",
+			statusCode:   http.StatusOK,
+			expectedBody: `"stop_reason":"eof","workers"`,
+		},
+		{
+			name:         "Should return bad request on unknown modes",
+			query:        "mode=random&items=4&items_per_workers=2",
+			statusCode:   http.StatusBadRequest,
+			expectedBody: `{"param":"mode","message":"must be first or sample"}`,
+		},
+		{
+			name:         "Should return bad request on wrong seeds",
+			query:        "mode=sample&seed=abc&replacement=yes&items=4&items_per_workers=2",
+			statusCode:   http.StatusBadRequest,
+			expectedBody: `[{"param":"seed","message":"must be an integer"},{"param":"replacement","message":"must be true or false"}]`,
+		},
+		{
+			name:         "Should return bad request on sampling params without sample mode",
+			query:        "seed=1&items=4&items_per_workers=2",
+			statusCode:   http.StatusBadRequest,
+			expectedBody: `{"param":"seed","message":"requires mode sample"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/random-mlb-players?"+tc.query, nil)
+			m := new(mockMLBService)
+			result := &e.MLBPlayerDesiredResult{StopReason: e.StopReasonEOF}
+			m.On("GetMLBPlayerDesired").Return(result, nil)
+			m.On("GetMLBPlayerSample", mock.Anything).Return(result, nil)
+
+			NewMLBPlayerController(m, DesiredLimits{}, logger.Discard()).GetMLBPlayerDesired(w, r)
+
+			assert.Equal(t, tc.statusCode, w.Code)
+			assert.Contains(t, w.Body.String(), tc.expectedBody)
+
+			if tc.expectedSampling != nil {
+				m.AssertCalled(t, "GetMLBPlayerSample", *tc.expectedSampling)
+				m.AssertNotCalled(t, "GetMLBPlayerDesired")
+			} else {
+				m.AssertNotCalled(t, "GetMLBPlayerSample", mock.Anything)
+			}
+		})
+	}
+}
+
+var createdPlayer = &e.MLBPlayer{
+	ID:       100,
+	Name:     "Adam Donachie",
+	Team:     "BAL",
+	Position: "Catcher",
+	Height:   74,
+	Weight:   180,
+	Age:      22.99,
+}
+
+const playerBody = `{"name":"Adam Donachie","team":"BAL","position":"Catcher","height_inches":74,"weight_lbs":180,"age":22.99}`
+
+func Test_MLBPlayerController_CreateMLBPlayer_Suite(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		body                 string
+		statusCode           int
+		expectedServiceCalls int
+		serviceError         error
+		serviceResponse      *e.MLBPlayer
+		expectedBody         string
+		expectedLocation     string
+	}{
+		{
+			name:                 "Should create player",
+			body:                 playerBody,
+			statusCode:           http.StatusCreated,
+			expectedServiceCalls: 1,
+			serviceResponse:      createdPlayer,
+			expectedBody:         "\"id\":100",
+			expectedLocation:     "/mlb-players/100",
+		},
+		{
+			name:                 "Should return bad request on malformed body",
+			body:                 "{name",
+			statusCode:           http.StatusBadRequest,
+			expectedServiceCalls: 0,
+			expectedBody:         "Request body must be a valid player",
+		},
+		{
+			name:                 "Should return bad request on validation error",
+			body:                 playerBody,
+			statusCode:           http.StatusBadRequest,
+			expectedServiceCalls: 1,
+			serviceError:         &e.ValidationError{Field: "age", Message: "must be greater than 0 and at most 100"},
+			expectedBody:         "age must be greater than 0 and at most 100",
+		},
+		{
+			name:                 "Should return internal server on service error",
+			body:                 playerBody,
+			statusCode:           http.StatusInternalServerError,
+			expectedServiceCalls: 1,
+			serviceError:         errors.New("error writing the file"),
+			expectedBody:         "Internal server error",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/mlb-players", strings.NewReader(tc.body))
+			m := new(mockMLBService)
+			m.On("CreateMLBPlayer").Return(tc.serviceResponse, tc.serviceError)
+			ctr := NewMLBPlayerController(m, DesiredLimits{}, logger.Discard())
+
+			ctr.CreateMLBPlayer(w, r)
+
+			assert.Equal(t, tc.statusCode, w.Code)
+			assert.Contains(t, w.Body.String(), tc.expectedBody)
+			assert.Equal(t, tc.expectedLocation, w.Header().Get("Location"))
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+			m.AssertNumberOfCalls(t, "CreateMLBPlayer", tc.expectedServiceCalls)
+		})
+	}
+}
+
+func Test_MLBPlayerController_UpdateMLBPlayer_Suite(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		method               string
+		idParam              string
+		body                 string
+		statusCode           int
+		expectedServiceCalls int
+		serviceError         error
+		serviceResponse      *e.MLBPlayer
+		expectedBody         string
+	}{
+		{
+			name:                 "Should update player with PUT",
+			method:               http.MethodPut,
+			idParam:              "100",
+			body:                 playerBody,
+			statusCode:           http.StatusOK,
+			expectedServiceCalls: 1,
+			serviceResponse:      createdPlayer,
+			expectedBody:         "\"id\":100",
+		},
+		{
+			name:                 "Should update player with PATCH",
+			method:               http.MethodPatch,
+			idParam:              "100",
+			body:                 `{"team":"BAL"}`,
+			statusCode:           http.StatusOK,
+			expectedServiceCalls: 1,
+			serviceResponse:      createdPlayer,
+			expectedBody:         "\"id\":100",
+		},
+		{
+			name:                 "Should return not found with PUT",
+			method:               http.MethodPut,
+			idParam:              "100",
+			body:                 playerBody,
+			statusCode:           http.StatusNotFound,
+			expectedServiceCalls: 1,
+			expectedBody:         "Player not found",
+		},
+		{
+			name:                 "Should return not found with PATCH",
+			method:               http.MethodPatch,
+			idParam:              "100",
+			body:                 `{"team":"BAL"}`,
+			statusCode:           http.StatusNotFound,
+			expectedServiceCalls: 1,
+			expectedBody:         "Player not found",
+		},
+		{
+			name:                 "Should return bad request on wrong id",
+			method:               http.MethodPut,
+			idParam:              "1a2b",
+			body:                 playerBody,
+			statusCode:           http.StatusBadRequest,
+			expectedServiceCalls: 0,
+			expectedBody:         "Player ID provided must be of type integer",
+		},
+		{
+			name:                 "Should return bad request on malformed body",
+			method:               http.MethodPatch,
+			idParam:              "100",
+			body:                 `{"height_inches":"tall"}`,
+			statusCode:           http.StatusBadRequest,
+			expectedServiceCalls: 0,
+			expectedBody:         "Request body must be a valid player",
+		},
+		{
+			name:                 "Should return bad request on validation error",
+			method:               http.MethodPatch,
+			idParam:              "100",
+			body:                 `{"name":""}`,
+			statusCode:           http.StatusBadRequest,
+			expectedServiceCalls: 1,
+			serviceError:         &e.ValidationError{Field: "name", Message: "must not be empty"},
+			expectedBody:         "name must not be empty",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(tc.method, "/mlb-players/{id}", strings.NewReader(tc.body))
+			r = mux.SetURLVars(r, map[string]string{"id": tc.idParam})
+			m := new(mockMLBService)
+			m.On("UpdateMLBPlayer").Return(tc.serviceResponse, tc.serviceError)
+			m.On("PatchMLBPlayer").Return(tc.serviceResponse, tc.serviceError)
+			ctr := NewMLBPlayerController(m, DesiredLimits{}, logger.Discard())
+
+			serviceMethod := "UpdateMLBPlayer"
+			if tc.method == http.MethodPatch {
+				serviceMethod = "PatchMLBPlayer"
+				ctr.PatchMLBPlayer(w, r)
+			} else {
+				ctr.UpdateMLBPlayer(w, r)
+			}
+
+			assert.Equal(t, tc.statusCode, w.Code)
+			assert.Contains(t, w.Body.String(), tc.expectedBody)
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+			m.AssertNumberOfCalls(t, serviceMethod, tc.expectedServiceCalls)
+		})
+	}
+}
+
+func Test_MLBPlayerController_DeleteMLBPlayer_Suite(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		idParam              string
+		statusCode           int
+		expectedServiceCalls int
+		serviceError         error
+		serviceResponse      *e.MLBPlayer
+		expectedBody         string
+	}{
+		{
+			name:                 "Should delete player",
+			idParam:              "100",
+			statusCode:           http.StatusNoContent,
+			expectedServiceCalls: 1,
+			serviceResponse:      createdPlayer,
+		},
+		{
+			name:                 "Should return not found if no player",
+			idParam:              "100",
+			statusCode:           http.StatusNotFound,
+			expectedServiceCalls: 1,
+			expectedBody:         "Player not found",
+		},
+		{
+			name:                 "Should return bad request on wrong id",
+			idParam:              "1a2b",
+			statusCode:           http.StatusBadRequest,
+			expectedServiceCalls: 0,
+			expectedBody:         "Player ID provided must be of type integer",
+		},
+		{
+			name:                 "Should return internal server on service error",
+			idParam:              "100",
+			statusCode:           http.StatusInternalServerError,
+			expectedServiceCalls: 1,
+			serviceError:         errors.New("error writing the file"),
+			expectedBody:         "Internal server error",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodDelete, "/mlb-players/{id}", nil)
+			r = mux.SetURLVars(r, map[string]string{"id": tc.idParam})
+			m := new(mockMLBService)
+			m.On("DeleteMLBPlayer").Return(tc.serviceResponse, tc.serviceError)
+			ctr := NewMLBPlayerController(m, DesiredLimits{}, logger.Discard())
+
+			ctr.DeleteMLBPlayer(w, r)
+
+			assert.Equal(t, tc.statusCode, w.Code)
+			assert.Contains(t, w.Body.String(), tc.expectedBody)
+			m.AssertNumberOfCalls(t, "DeleteMLBPlayer", tc.expectedServiceCalls)
+		})
+	}
+}
+
+func Test_MLBPlayerController_GetMLBPlayerStats_Suite(t *testing.T) {
+	stats := []e.MLBPlayerGroupStats{{Group: "BAL", Count: 2}, {Group: "NYY", Count: 1}}
+	testCases := []struct {
+		name                 string
+		group                string
+		rawQuery             string
+		statusCode           int
+		expectedServiceCalls int
+		expectedFilter       e.MLBPlayerFilter
+		expectedGroupBy      string
+		expectedPercentiles  []float64
+		serviceError         error
+		serviceResponse      []e.MLBPlayerGroupStats
+		expectedBody         string
+	}{
+		{
+			name:                 "Should return overall stats",
+			group:                "",
+			statusCode:           http.StatusOK,
+			expectedServiceCalls: 1,
+			expectedGroupBy:      "",
+			expectedPercentiles:  []float64{25, 50, 75, 90},
+			serviceResponse:      []e.MLBPlayerGroupStats{{Count: 3}},
+			expectedBody:         "{\"count\":3,",
+		},
+		{
+			name:                 "Should return stats by team with filters and percentiles",
+			group:                "teams",
+			rawQuery:             "position=Catcher&percentiles=10,99.5",
+			statusCode:           http.StatusOK,
+			expectedServiceCalls: 1,
+			expectedFilter:       e.MLBPlayerFilter{Positions: []string{"Catcher"}},
+			expectedGroupBy:      "team",
+			expectedPercentiles:  []float64{10, 99.5},
+			serviceResponse:      stats,
+			expectedBody:         "{\"group_by\":\"team\",\"groups\":[{\"group\":\"BAL\",\"count\":2,",
+		},
+		{
+			name:                 "Should return stats by position",
+			group:                "positions",
+			statusCode:           http.StatusOK,
+			expectedServiceCalls: 1,
+			expectedGroupBy:      "position",
+			expectedPercentiles:  []float64{25, 50, 75, 90},
+			serviceResponse:      stats,
+			expectedBody:         "{\"group_by\":\"position\"",
+		},
+		{
+			name:                 "Should return not found on unknown group",
+			group:                "leagues",
+			statusCode:           http.StatusNotFound,
+			expectedServiceCalls: 0,
+			expectedBody:         "Stats group not found",
+		},
+		{
+			name:                 "Should return bad request on wrong percentiles",
+			group:                "teams",
+			rawQuery:             "percentiles=50,100",
+			statusCode:           http.StatusBadRequest,
+			expectedServiceCalls: 0,
+			expectedBody:         "{\"param\":\"percentiles\",\"message\":\"must be numbers between 0 and 100\"}",
+		},
+		{
+			name:                 "Should return bad request on NaN percentiles",
+			group:                "teams",
+			rawQuery:             "percentiles=NaN",
+			statusCode:           http.StatusBadRequest,
+			expectedServiceCalls: 0,
+			expectedBody:         "{\"param\":\"percentiles\",\"message\":\"must be numbers between 0 and 100\"}",
+		},
+		{
+			name:                 "Should return bad request on wrong filters",
+			group:                "teams",
+			rawQuery:             "age_min=old",
+			statusCode:           http.StatusBadRequest,
+			expectedServiceCalls: 0,
+			expectedBody:         "{\"param\":\"age_min\",\"message\":\"must be a number\"}",
+		},
+		{
+			name:                 "Should return internal server on service error",
+			group:                "teams",
+			statusCode:           http.StatusInternalServerError,
+			expectedServiceCalls: 1,
+			expectedGroupBy:      "team",
+			expectedPercentiles:  []float64{25, 50, 75, 90},
+			serviceError:         errors.New("unknown error"),
+			expectedBody:         "Internal server error",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/mlb-players/stats/{group}?"+tc.rawQuery, nil)
+			r = mux.SetURLVars(r, map[string]string{"group": tc.group})
+			m := new(mockMLBService)
+			m.On("GetMLBPlayerStats", tc.expectedFilter, tc.expectedGroupBy, tc.expectedPercentiles).Return(tc.serviceResponse, tc.serviceError)
+			ctr := NewMLBPlayerController(m, DesiredLimits{}, logger.Discard())
+
+			ctr.GetMLBPlayerStats(w, r)
+
+			assert.Equal(t, tc.statusCode, w.Code)
+			assert.Contains(t, w.Body.String(), tc.expectedBody)
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+			m.AssertNumberOfCalls(t, "GetMLBPlayerStats", tc.expectedServiceCalls)
+		})
+	}
+}
+
+// blockingMLBService blocks GetMLBPlayerDesired until the request context is done.
+type blockingMLBService struct {
+	mockMLBService
+	done chan error
+}
+
+func (m *blockingMLBService) GetMLBPlayerDesired(ctx context.Context, predicate e.MLBPlayerPredicate, totalItems int, itemsPerWorker int) (*e.MLBPlayerDesiredResult, error) {
+	<-ctx.Done()
+	m.done <- ctx.Err()
+
+	return nil, ctx.Err()
+}
+
+func Test_MLBPlayerController_GetMLBPlayerDesired_ShouldCancelWhenClientDisconnects(t *testing.T) {
+	m := &blockingMLBService{done: make(chan error, 1)}
+	server := httptest.NewServer(http.HandlerFunc(NewMLBPlayerController(m, DesiredLimits{}, logger.Discard()).GetMLBPlayerDesired))
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"?type=odd&items=10&items_per_workers=2", nil)
+
+	_, err := http.DefaultClient.Do(req)
+	assert.NotNil(t, err)
+
+	select {
+	case err := <-m.done:
+		assert.Equal(t, context.Canceled, err)
+	case <-time.After(time.Second):
+		t.Fatal("the service did not see the disconnection")
+	}
+}
+
+func Test_MLBPlayerController_ShouldLogInternalErrors(t *testing.T) {
+	var buf bytes.Buffer
+	m := new(mockMLBService)
+	m.On("GetMLBPlayerByID").Return((*e.MLBPlayer)(nil), errors.New("error getting player"))
+	ctr := NewMLBPlayerController(m, DesiredLimits{}, logger.New(&buf, slog.LevelInfo))
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/mlb-players/1", nil)
+	r = mux.SetURLVars(r, map[string]string{"id": "1"})
+	r = r.WithContext(logger.WithRequestID(r.Context(), "abc-123"))
+
+	ctr.GetMLBPlayerByID(w, r)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, buf.String(), `"msg":"error getting player"`)
+	assert.Contains(t, buf.String(), `"error":"error getting player"`)
+	assert.Contains(t, buf.String(), `"request_id":"abc-123"`)
+}
+
+// multipartBody builds a multipart form with the fields and a file field when content is not empty.
+func multipartBody(t *testing.T, fields map[string]string, content string) (*bytes.Buffer, string) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for name, value := range fields {
+		assert.Nil(t, writer.WriteField(name, value))
+	}
+
+	if content != "" {
+		part, err := writer.CreateFormFile("file", "players.csv")
+		assert.Nil(t, err)
+		_, err = part.Write([]byte(content))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, writer.Close())
+
+	return body, writer.FormDataContentType()
+}
+
+func Test_MLBPlayerController_ImportMLBPlayers_Suite(t *testing.T) {
+	content := "Id,Name,Team,Position,Height(inches),Weight(lbs),Age\n1,Adam Donachie,BAL,Catcher,74,180,22.99\n"
+	testCases := []struct {
+		name                 string
+		fields               map[string]string
+		content              string
+		rawBody              string
+		statusCode           int
+		expectedServiceCalls int
+		expectedMode         e.MLBPlayerImportMode
+		serviceResponse      *e.MLBPlayerImportReport
+		serviceError         error
+		expectedBody         []string
+	}{
+		{
+			name:                 "Should import in merge mode by default",
+			content:              content,
+			statusCode:           http.StatusOK,
+			expectedServiceCalls: 1,
+			expectedMode:         e.ImportMerge,
+			serviceResponse:      &e.MLBPlayerImportReport{Mode: e.ImportMerge, Committed: true, Accepted: 1, Inserted: 1, Rejected: []e.MLBPlayerImportRejection{}},
+			expectedBody:         []string{`"mode":"merge","committed":true,"accepted":1,"inserted":1,"updated":0,"removed":0,"rejected":[]`},
+		},
+		{
+			name:                 "Should import in replace mode",
+			fields:               map[string]string{"mode": "replace"},
+			content:              content,
+			statusCode:           http.StatusOK,
+			expectedServiceCalls: 1,
+			expectedMode:         e.ImportReplace,
+			serviceResponse:      &e.MLBPlayerImportReport{Mode: e.ImportReplace, Committed: true, Accepted: 1, Updated: 1, Removed: 99},
+			expectedBody:         []string{`"mode":"replace"`, `"removed":99`},
+		},
+		{
+			name:                 "Should return unprocessable entity when no row was accepted",
+			content:              content,
+			statusCode:           http.StatusUnprocessableEntity,
+			expectedServiceCalls: 1,
+			expectedMode:         e.ImportMerge,
+			serviceResponse:      &e.MLBPlayerImportReport{Mode: e.ImportMerge, Rejected: []e.MLBPlayerImportRejection{{Line: 2, Reason: "error casting Height"}}},
+			expectedBody:         []string{`"committed":false`, `"rejected":[{"line":2,"reason":"error casting Height"}]`},
+		},
+		{
+			name:                 "Should return unprocessable entity when a replace had rejected rows",
+			fields:               map[string]string{"mode": "replace"},
+			content:              content,
+			statusCode:           http.StatusUnprocessableEntity,
+			expectedServiceCalls: 1,
+			expectedMode:         e.ImportReplace,
+			serviceResponse:      &e.MLBPlayerImportReport{Mode: e.ImportReplace, Accepted: 1, Rejected: []e.MLBPlayerImportRejection{{Line: 3, Reason: "error casting Height"}}},
+			expectedBody:         []string{`"committed":false,"accepted":1`, `"rejected":[{"line":3,"reason":"error casting Height"}]`},
+		},
+		{
+			name:                 "Should return bad request on wrong mode and missing file",
+			fields:               map[string]string{"mode": "append"},
+			statusCode:           http.StatusBadRequest,
+			expectedServiceCalls: 0,
+			expectedBody: []string{
+				`{"param":"mode","message":"must be merge or replace"}`,
+				`{"param":"file","message":"is required"}`,
+			},
+		},
+		{
+			name:                 "Should return bad request when the body is not a multipart form",
+			rawBody:              "Id,Name",
+			statusCode:           http.StatusBadRequest,
+			expectedServiceCalls: 0,
+			expectedBody:         []string{"Request body must be a multipart form with a CSV file"},
+		},
+		{
+			name:                 "Should return bad request on a wrong header",
+			content:              content,
+			statusCode:           http.StatusBadRequest,
+			expectedServiceCalls: 1,
+			expectedMode:         e.ImportMerge,
+			serviceError:         &e.ValidationError{Field: "file", Message: "must start with the header"},
+			expectedBody:         []string{"file must start with the header"},
+		},
+		{
+			name:                 "Should return internal server error on service error",
+			content:              content,
+			statusCode:           http.StatusInternalServerError,
+			expectedServiceCalls: 1,
+			expectedMode:         e.ImportMerge,
+			serviceError:         errors.New("error writing the database"),
+			expectedBody:         []string{"Internal server error"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			var r *http.Request
+
+			if tc.rawBody != "" {
+				r = httptest.NewRequest(http.MethodPost, "/mlb-players/import", strings.NewReader(tc.rawBody))
+				r.Header.Set("Content-Type", "text/csv")
+			} else {
+				body, contentType := multipartBody(t, tc.fields, tc.content)
+				r = httptest.NewRequest(http.MethodPost, "/mlb-players/import", body)
+				r.Header.Set("Content-Type", contentType)
+			}
+			m := new(mockMLBService)
+			m.On("ImportMLBPlayers", tc.content, tc.expectedMode).Return(tc.serviceResponse, tc.serviceError)
+			ctr := NewMLBPlayerController(m, DesiredLimits{}, logger.Discard())
+
+			ctr.ImportMLBPlayers(w, r)
+
+			for _, body := range tc.expectedBody {
+				assert.Contains(t, w.Body.String(), body)
+			}
+			assert.Equal(t, tc.statusCode, w.Code)
+			m.AssertNumberOfCalls(t, "ImportMLBPlayers", tc.expectedServiceCalls)
+		})
+	}
+}
+
+func Test_MLBPlayerController_ImportMLBPlayers_ShouldRejectLargeBodies(t *testing.T) {
+	body, contentType := multipartBody(t, nil, strings.Repeat("x", maxImportBytes+1))
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/mlb-players/import", body)
+	r.Header.Set("Content-Type", contentType)
+	m := new(mockMLBService)
+	ctr := NewMLBPlayerController(m, DesiredLimits{}, logger.Discard())
+
+	ctr.ImportMLBPlayers(w, r)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	m.AssertNumberOfCalls(t, "ImportMLBPlayers", 0)
+}
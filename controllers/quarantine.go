@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	e "github.com/EloYaniel/academy-go-q42021/entities"
+)
+
+var quarantineSources = map[string]bool{"": true, "mlb_players": true, "users": true}
+
+type quarantineStore interface {
+	Rows(source string) []e.QuarantinedRow
+}
+
+// QuarantineController struct handles api controller.
+type QuarantineController struct {
+	store quarantineStore
+	mode  string
+}
+
+// NewQuarantineController function creates an instance of QuarantineController.
+func NewQuarantineController(store quarantineStore, mode string) *QuarantineController {
+	return &QuarantineController{store: store, mode: mode}
+}
+
+// GetQuarantinedRows handles the malformed rows skipped by the lenient reads of the data files,
+// of every file or of the one set by the source param.
+func (ctr *QuarantineController) GetQuarantinedRows(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	source := r.URL.Query().Get("source")
+
+	if !quarantineSources[source] {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorMessage{
+			Message: "Invalid query params",
+			Errors:  []paramError{{Param: "source", Message: "must be mlb_players or users"}},
+		})
+
+		return
+	}
+	rows := ctr.store.Rows(source)
+	json.NewEncoder(w).Encode(struct {
+		Mode  string             `json:"mode"`
+		Total int                `json:"total"`
+		Rows  []e.QuarantinedRow `json:"rows"`
+	}{
+		ctr.mode,
+		len(rows),
+		rows,
+	})
+}
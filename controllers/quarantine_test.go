@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	e "github.com/EloYaniel/academy-go-q42021/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockQuarantineStore struct {
+	mock.Mock
+}
+
+func (m *mockQuarantineStore) Rows(source string) []e.QuarantinedRow {
+	args := m.Called(source)
+
+	return args.Get(0).([]e.QuarantinedRow)
+}
+
+func Test_GetQuarantinedRows_Suite(t *testing.T) {
+	quarantinedAt := time.Date(2021, time.November, 2, 10, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name         string
+		url          string
+		source       string
+		rows         []e.QuarantinedRow
+		statusCode   int
+		responseBody string
+	}{
+		{
+			name:   "Should return the rows of every file",
+			url:    "/admin/quarantine",
+			source: "",
+			rows: []e.QuarantinedRow{
+				{Source: "mlb_players", Line: 3, Column: 5, Field: "Height(inches)", Value: "74abc", Reason: "error casting Height", QuarantinedAt: quarantinedAt},
+				{Source: "users", Line: 2, Value: "1,a@b.com", Reason: "wrong number of fields", QuarantinedAt: quarantinedAt},
+			},
+			statusCode: http.StatusOK,
+			responseBody: `{"mode":"lenient","total":2,"rows":[
+				{"source":"mlb_players","line":3,"column":5,"field":"Height(inches)","value":"74abc","reason":"error casting Height","quarantined_at":"2021-11-02T10:00:00Z"},
+				{"source":"users","line":2,"column":0,"value":"1,a@b.com","reason":"wrong number of fields","quarantined_at":"2021-11-02T10:00:00Z"}
+			]}`,
+		},
+		{
+			name:         "Should return the rows of the source",
+			url:          "/admin/quarantine?source=users",
+			source:       "users",
+			rows:         []e.QuarantinedRow{},
+			statusCode:   http.StatusOK,
+			responseBody: `{"mode":"lenient","total":0,"rows":[]}`,
+		},
+		{
+			name:         "Should return bad request when the source is unknown",
+			url:          "/admin/quarantine?source=teams",
+			statusCode:   http.StatusBadRequest,
+			responseBody: `{"message":"Invalid query params","errors":[{"param":"source","message":"must be mlb_players or users"}]}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := &mockQuarantineStore{}
+			store.On("Rows", tc.source).Return(tc.rows)
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tc.url, nil)
+
+			NewQuarantineController(store, "lenient").GetQuarantinedRows(w, r)
+
+			assert.Equal(t, tc.statusCode, w.Code)
+			assert.JSONEq(t, tc.responseBody, w.Body.String())
+		})
+	}
+}
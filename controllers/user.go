@@ -1,8 +1,9 @@
 package controllers
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
 
@@ -11,26 +12,45 @@ import (
 )
 
 type userService interface {
-	GetUsers() ([]e.User, error)
-	GetUserByID(id int) (*e.User, error)
+	GetUsers(ctx context.Context) ([]e.User, error)
+	ExportUsers(ctx context.Context, fn func(u e.User) error) error
+	StreamUsers(ctx context.Context, fn func(u e.User) error) error
+	GetUserByID(ctx context.Context, id int) (*e.User, error)
+	GetLastImport() *e.UserImport
+	SyncUsers(ctx context.Context) (*e.UserSync, error)
+	GetSyncStatus() e.UserSync
 }
 
 // MLBPlayerController struct handles api controller.
 type UserController struct {
 	service userService
+	logger  *slog.Logger
 }
 
 // NewUserController function creates an instance of UserController.
-func NewUserController(service userService) *UserController {
-	return &UserController{service: service}
+func NewUserController(service userService, logger *slog.Logger) *UserController {
+	return &UserController{service: service, logger: logger}
 }
 
-// GetUsers handles list of Users
+// GetUsers handles list of Users in the negotiated format, the formats other than JSON are streamed row by row.
 func (ctr *UserController) GetUsers(w http.ResponseWriter, r *http.Request) {
+	format, err := negotiateFormat(r)
+
+	if err != nil {
+		writeFormatError(w, err)
+
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	users, err := ctr.service.GetUsers()
+
+	if format.Name != "json" {
+		ctr.streamUsers(w, r, format)
+
+		return
+	}
+	users, err := ctr.service.GetUsers(r.Context())
 	if err != nil {
-		log.Println(err)
+		ctr.logger.ErrorContext(r.Context(), "error getting users", "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(errorMessage{
 			Message: "Internal server error",
@@ -38,9 +58,72 @@ func (ctr *UserController) GetUsers(w http.ResponseWriter, r *http.Request) {
 
 		return
 	}
+
 	json.NewEncoder(w).Encode(users)
 }
 
+// streamUsers streams every User in a format other than json, row by row.
+func (ctr *UserController) streamUsers(w http.ResponseWriter, r *http.Request, format responseFormat) {
+	tw := newTableWriter(w, format, userTable)
+	err := ctr.service.StreamUsers(r.Context(), func(u e.User) error {
+		return tw.Write(u)
+	})
+
+	if err == nil {
+		err = tw.Close()
+	}
+
+	if err != nil && !tw.Started() {
+		ctr.logger.ErrorContext(r.Context(), "error getting users", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(errorMessage{
+			Message: "Internal server error",
+		})
+
+		return
+	}
+
+	if err != nil {
+		ctr.logger.ErrorContext(r.Context(), "error writing users, the response is aborted", "error", err, "rows", tw.rows)
+		// The response was started with the validators of the whole list, so it must not look complete.
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// ExportUsers handles the export of the stored Users, streamed in the negotiated format.
+func (ctr *UserController) ExportUsers(w http.ResponseWriter, r *http.Request) {
+	format, err := negotiateFormat(r)
+
+	if err != nil {
+		writeFormatError(w, err)
+
+		return
+	}
+	tw := newTableWriter(w, format, userTable)
+	err = ctr.service.ExportUsers(r.Context(), func(u e.User) error {
+		return tw.Write(u)
+	})
+
+	if err == nil {
+		err = tw.Close()
+	}
+
+	if err != nil && !tw.Started() {
+		ctr.logger.ErrorContext(r.Context(), "error exporting users", "error", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(errorMessage{
+			Message: "Internal server error",
+		})
+
+		return
+	}
+
+	if err != nil {
+		ctr.logger.ErrorContext(r.Context(), "error exporting users, the response is incomplete", "error", err, "rows", tw.rows)
+	}
+}
+
 // GetUserByID handles Users by ID.
 func (ctr *UserController) GetUserByID(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -55,9 +138,10 @@ func (ctr *UserController) GetUserByID(w http.ResponseWriter, r *http.Request) {
 
 		return
 	}
-	user, err := ctr.service.GetUserByID(id)
+	user, err := ctr.service.GetUserByID(r.Context(), id)
 
 	if err != nil {
+		ctr.logger.ErrorContext(r.Context(), "error getting user", "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(errorMessage{
 			Message: "Internal server error",
@@ -77,3 +161,53 @@ func (ctr *UserController) GetUserByID(w http.ResponseWriter, r *http.Request) {
 
 	json.NewEncoder(w).Encode(user)
 }
+
+// GetLastImport handles the metadata of the last import of Users.
+func (ctr *UserController) GetLastImport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	lastImport := ctr.service.GetLastImport()
+
+	if lastImport == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(errorMessage{
+			Message: "No import found",
+		})
+
+		return
+	}
+
+	json.NewEncoder(w).Encode(lastImport)
+}
+
+// SyncUsers handles a sync of Users with the upstream API on demand.
+func (ctr *UserController) SyncUsers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	state, err := ctr.service.SyncUsers(r.Context())
+
+	if err == e.ErrUserSyncInProgress {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(errorMessage{
+			Message: "A sync of users is in progress",
+		})
+
+		return
+	}
+
+	if err != nil {
+		ctr.logger.ErrorContext(r.Context(), "error syncing users", "error", err)
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(errorMessage{
+			Message: "Error syncing users with the upstream API",
+		})
+
+		return
+	}
+
+	json.NewEncoder(w).Encode(state)
+}
+
+// GetSyncStatus handles the state of the syncs of Users.
+func (ctr *UserController) GetSyncStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ctr.service.GetSyncStatus())
+}
@@ -1,12 +1,14 @@
 package controllers
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	e "github.com/EloYaniel/academy-go-q42021/entities"
+	"github.com/EloYaniel/academy-go-q42021/logger"
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -16,18 +18,60 @@ type mockUserService struct {
 	mock.Mock
 }
 
-func (m *mockUserService) GetUsers() ([]e.User, error) {
+func (m *mockUserService) GetUsers(ctx context.Context) ([]e.User, error) {
 	args := m.Called()
 
 	return args.Get(0).([]e.User), args.Error(1)
 }
 
-func (m *mockUserService) GetUserByID(id int) (*e.User, error) {
+func (m *mockUserService) ExportUsers(ctx context.Context, fn func(u e.User) error) error {
+	args := m.Called()
+	users, _ := args.Get(0).([]e.User)
+	for _, u := range users {
+		if err := fn(u); err != nil {
+			return err
+		}
+	}
+
+	return args.Error(1)
+}
+
+func (m *mockUserService) StreamUsers(ctx context.Context, fn func(u e.User) error) error {
+	args := m.Called()
+	users, _ := args.Get(0).([]e.User)
+	for _, u := range users {
+		if err := fn(u); err != nil {
+			return err
+		}
+	}
+
+	return args.Error(1)
+}
+
+func (m *mockUserService) GetUserByID(ctx context.Context, id int) (*e.User, error) {
 	args := m.Called()
 
 	return args.Get(0).(*e.User), args.Error(1)
 }
 
+func (m *mockUserService) GetLastImport() *e.UserImport {
+	args := m.Called()
+
+	return args.Get(0).(*e.UserImport)
+}
+
+func (m *mockUserService) SyncUsers(ctx context.Context) (*e.UserSync, error) {
+	args := m.Called()
+
+	return args.Get(0).(*e.UserSync), args.Error(1)
+}
+
+func (m *mockUserService) GetSyncStatus() e.UserSync {
+	args := m.Called()
+
+	return args.Get(0).(e.UserSync)
+}
+
 func Test_UserController_GetUsers_Suite(t *testing.T) {
 	testCases := []struct {
 		name                 string
@@ -69,7 +113,7 @@ func Test_UserController_GetUsers_Suite(t *testing.T) {
 			r := httptest.NewRequest(http.MethodGet, "/users", nil)
 			m := new(mockUserService)
 			m.On("GetUsers").Return(tc.serviceResponse, tc.serviceError)
-			ctr := NewUserController(m)
+			ctr := NewUserController(m, logger.Discard())
 
 			ctr.GetUsers(w, r)
 
@@ -149,7 +193,7 @@ func Test_UserController_GetUserByID_Suite(t *testing.T) {
 			r = mux.SetURLVars(r, map[string]string{"id": tc.idParam})
 			m := new(mockUserService)
 			m.On("GetUserByID").Return(tc.serviceResponse, tc.serviceError)
-			ctr := NewUserController(m)
+			ctr := NewUserController(m, logger.Discard())
 
 			ctr.GetUserByID(w, r)
 
@@ -163,3 +207,93 @@ func Test_UserController_GetUserByID_Suite(t *testing.T) {
 		})
 	}
 }
+
+func Test_UserController_SyncUsers_Suite(t *testing.T) {
+	testCases := []struct {
+		name            string
+		statusCode      int
+		serviceResponse *e.UserSync
+		serviceError    error
+		expectedBody    string
+	}{
+		{
+			name:            "Should return the state of the sync",
+			statusCode:      http.StatusOK,
+			serviceResponse: &e.UserSync{PagesFetched: 2, PagesNotModified: 1, Updated: 1, Unchanged: 5},
+			expectedBody:    `{"running":false,"pages_fetched":2,"pages_not_modified":1,"inserted":0,"updated":1,"unchanged":5}`,
+		},
+		{
+			name:         "Should return conflict when a sync is in progress",
+			statusCode:   http.StatusConflict,
+			serviceError: e.ErrUserSyncInProgress,
+			expectedBody: `{"message":"A sync of users is in progress"}`,
+		},
+		{
+			name:         "Should return bad gateway when the sync fails",
+			statusCode:   http.StatusBadGateway,
+			serviceError: errors.New("unexpected status 500: "),
+			expectedBody: `{"message":"Error syncing users with the upstream API"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/users/sync", nil)
+			m := new(mockUserService)
+			m.On("SyncUsers").Return(tc.serviceResponse, tc.serviceError)
+			ctr := NewUserController(m, logger.Discard())
+
+			ctr.SyncUsers(w, r)
+
+			assert.Equal(t, tc.statusCode, w.Code)
+			assert.JSONEq(t, tc.expectedBody, w.Body.String())
+			m.AssertNumberOfCalls(t, "SyncUsers", 1)
+		})
+	}
+}
+
+func Test_UserController_GetLastImport_Suite(t *testing.T) {
+	testCases := []struct {
+		name            string
+		statusCode      int
+		serviceResponse *e.UserImport
+		expectedBody    string
+	}{
+		{
+			name:       "Should return last import",
+			statusCode: http.StatusOK,
+			serviceResponse: &e.UserImport{
+				PagesFetched: 2,
+				TotalPages:   2,
+				Total:        12,
+				Imported:     12,
+				Completed:    true,
+			},
+			expectedBody: "\"pages_fetched\":2",
+		},
+		{
+			name:            "Should return not found if no import",
+			statusCode:      http.StatusNotFound,
+			serviceResponse: nil,
+			expectedBody:    "No import found",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/users/import", nil)
+			m := new(mockUserService)
+			m.On("GetLastImport").Return(tc.serviceResponse)
+			ctr := NewUserController(m, logger.Discard())
+
+			ctr.GetLastImport(w, r)
+
+			assert.Contains(t, w.Body.String(), tc.expectedBody)
+			assert.Equal(t, tc.statusCode, w.Code)
+			assert.Equal(t, "application/json", w.Result().Header.Get("Content-Type"))
+			m.AssertNumberOfCalls(t, "GetLastImport", 1)
+		})
+	}
+}
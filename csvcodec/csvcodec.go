@@ -0,0 +1,111 @@
+package csvcodec
+
+import (
+	"strconv"
+	"strings"
+
+	e "github.com/EloYaniel/academy-go-q42021/entities"
+)
+
+// PlayerHeader is the header row of the MLB Players files.
+var PlayerHeader = []string{"Id", "Name", "Team", "Position", "Height(inches)", "Weight(lbs)", "Age"}
+
+// UserHeader is the header row of the Users files.
+var UserHeader = []string{"Id", "Email", "FirstName", "LastName", "Avatar"}
+
+// FieldError is returned when a row can not be parsed. Column is the 1-based index of the wrong field,
+// 0 when the row has a wrong number of fields.
+type FieldError struct {
+	Column  int
+	Field   string
+	Value   string
+	Message string
+}
+
+func (err *FieldError) Error() string {
+	return err.Message
+}
+
+// rowError reports a row with a wrong number of fields.
+func rowError(record []string) *FieldError {
+	return &FieldError{Value: strings.Join(record, ","), Message: "error reading the row: wrong number of fields"}
+}
+
+// castError reports the field at index of record, named after header, that can not be cast.
+func castError(header []string, record []string, index int, name string) *FieldError {
+	return &FieldError{Column: index + 1, Field: header[index], Value: record[index], Message: "error casting " + name}
+}
+
+// ParsePlayer reads a Player from a row of a MLB Players file.
+func ParsePlayer(record []string) (*e.MLBPlayer, error) {
+	if len(record) != len(PlayerHeader) {
+		return nil, rowError(record)
+	}
+	id, err := strconv.Atoi(record[0])
+
+	if err != nil {
+		return nil, castError(PlayerHeader, record, 0, "ID")
+	}
+	height, err := strconv.Atoi(record[4])
+
+	if err != nil {
+		return nil, castError(PlayerHeader, record, 4, "Height")
+	}
+	weight, err := strconv.ParseFloat(record[5], 32)
+	if err != nil {
+		return nil, castError(PlayerHeader, record, 5, "Weight")
+	}
+	age, err := strconv.ParseFloat(record[6], 32)
+
+	if err != nil {
+		return nil, castError(PlayerHeader, record, 6, "Age")
+	}
+
+	return &e.MLBPlayer{
+		ID:       id,
+		Name:     record[1],
+		Team:     record[2],
+		Position: record[3],
+		Height:   height,
+		Weight:   float32(weight),
+		Age:      float32(age),
+	}, nil
+}
+
+// PlayerRecord writes a Player as a row of a MLB Players file.
+func PlayerRecord(p e.MLBPlayer) []string {
+	return []string{
+		strconv.Itoa(p.ID),
+		p.Name,
+		p.Team,
+		p.Position,
+		strconv.Itoa(p.Height),
+		strconv.FormatFloat(float64(p.Weight), 'f', -1, 32),
+		strconv.FormatFloat(float64(p.Age), 'f', -1, 32),
+	}
+}
+
+// ParseUser reads a User from a row of a Users file.
+func ParseUser(record []string) (*e.User, error) {
+	if len(record) != len(UserHeader) {
+		return nil, rowError(record)
+	}
+	id, err := strconv.Atoi(record[0])
+
+	if err != nil {
+		return nil, castError(UserHeader, record, 0, "ID")
+	}
+
+	return &e.User{
+		ID:        id,
+		Email:     record[1],
+		FirstName: record[2],
+		LastName:  record[3],
+		Avatar:    record[4],
+	}, nil
+}
+
+// UserRecord writes a User as a row of a Users file.
+func UserRecord(u e.User) []string {
+	return []string{strconv.Itoa(u.ID), u.Email, u.FirstName, u.LastName, u.Avatar}
+}
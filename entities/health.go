@@ -0,0 +1,28 @@
+package entities
+
+// HealthStatus tells whether a check, or the whole application, is able to serve requests.
+type HealthStatus string
+
+const (
+	// HealthUp means every check passed.
+	HealthUp HealthStatus = "up"
+	// HealthDegraded means only checks that are not critical failed.
+	HealthDegraded HealthStatus = "degraded"
+	// HealthDown means a critical check failed.
+	HealthDown HealthStatus = "down"
+)
+
+// HealthCheckResult struct has the outcome of a single readiness check.
+type HealthCheckResult struct {
+	Name      string       `json:"name"`
+	Status    HealthStatus `json:"status"`
+	Critical  bool         `json:"critical"`
+	LatencyMs float64      `json:"latency_ms"`
+	Error     string       `json:"error,omitempty"`
+}
+
+// HealthReport struct has the outcome of every readiness check.
+type HealthReport struct {
+	Status HealthStatus        `json:"status"`
+	Checks []HealthCheckResult `json:"checks"`
+}
@@ -1,12 +1,79 @@
 package entities
 
+import (
+	"math"
+	"strings"
+)
+
 // MLBPlayer struct has MLB Player business info.
 type MLBPlayer struct {
-	ID       int     `json:"id"`
-	Name     string  `json:"name"`
-	Team     string  `json:"team"`
-	Position string  `json:"position"`
-	Height   int     `json:"height_inches"`
-	Weight   float32 `json:"weight_lbs"`
-	Age      float32 `json:"age"`
+	ID       int     `json:"id" xml:"id"`
+	Name     string  `json:"name" xml:"name"`
+	Team     string  `json:"team" xml:"team"`
+	Position string  `json:"position" xml:"position"`
+	Height   int     `json:"height_inches" xml:"height_inches"`
+	Weight   float32 `json:"weight_lbs" xml:"weight_lbs"`
+	Age      float32 `json:"age" xml:"age"`
+}
+
+// MLBPlayerPatch struct has the MLB Player fields to change, nil fields are kept.
+type MLBPlayerPatch struct {
+	Name     *string  `json:"name"`
+	Team     *string  `json:"team"`
+	Position *string  `json:"position"`
+	Height   *int     `json:"height_inches"`
+	Weight   *float32 `json:"weight_lbs"`
+	Age      *float32 `json:"age"`
+}
+
+// Apply changes the fields of player set in the patch.
+func (patch MLBPlayerPatch) Apply(player *MLBPlayer) {
+	if patch.Name != nil {
+		player.Name = *patch.Name
+	}
+
+	if patch.Team != nil {
+		player.Team = *patch.Team
+	}
+
+	if patch.Position != nil {
+		player.Position = *patch.Position
+	}
+
+	if patch.Height != nil {
+		player.Height = *patch.Height
+	}
+
+	if patch.Weight != nil {
+		player.Weight = *patch.Weight
+	}
+
+	if patch.Age != nil {
+		player.Age = *patch.Age
+	}
+}
+
+// Validate checks the MLB Player has every field with a valid value.
+func (p MLBPlayer) Validate() error {
+	switch {
+	case strings.TrimSpace(p.Name) == "":
+		return &ValidationError{Field: "name", Message: "must not be empty"}
+	case strings.TrimSpace(p.Team) == "":
+		return &ValidationError{Field: "team", Message: "must not be empty"}
+	case strings.TrimSpace(p.Position) == "":
+		return &ValidationError{Field: "position", Message: "must not be empty"}
+	case p.Height <= 0 || p.Height > 120:
+		return &ValidationError{Field: "height_inches", Message: "must be between 1 and 120"}
+	case !finite(p.Weight) || p.Weight <= 0 || p.Weight > 1000:
+		return &ValidationError{Field: "weight_lbs", Message: "must be greater than 0 and at most 1000"}
+	case !finite(p.Age) || p.Age <= 0 || p.Age > 100:
+		return &ValidationError{Field: "age", Message: "must be greater than 0 and at most 100"}
+	}
+
+	return nil
+}
+
+// finite tells if v is a number, NaN and the infinities are not.
+func finite(v float32) bool {
+	return !math.IsNaN(float64(v)) && !math.IsInf(float64(v), 0)
 }
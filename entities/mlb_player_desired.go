@@ -0,0 +1,35 @@
+package entities
+
+// StopReason tells why a concurrent read of MLB Players finished.
+type StopReason string
+
+const (
+	// StopReasonItemsReached means the requested amount of items was collected.
+	StopReasonItemsReached StopReason = "items_reached"
+	// StopReasonWorkersLimit means every worker appended its items_per_workers, or when sampling, a worker dropped
+	// matching rows to keep only its items_per_workers.
+	StopReasonWorkersLimit StopReason = "workers_limit"
+	// StopReasonEOF means the end of the file was reached.
+	StopReasonEOF StopReason = "eof"
+)
+
+// WorkerReport struct has the rows handled by a single worker.
+type WorkerReport struct {
+	WorkerID int `json:"worker_id"`
+	RowsRead int `json:"rows_read"`
+	Items    int `json:"items"`
+}
+
+// MLBPlayerDesiredResult struct has the result of a concurrent read of MLB Players.
+type MLBPlayerDesiredResult struct {
+	Players    []MLBPlayer    `json:"players"`
+	StopReason StopReason     `json:"stop_reason"`
+	Workers    []WorkerReport `json:"workers"`
+}
+
+// MLBPlayerSampling struct has the settings of a random sample of MLB Players.
+// The same seed over the same rows with the same workers always gives the same sample.
+type MLBPlayerSampling struct {
+	Seed        int64
+	Replacement bool
+}
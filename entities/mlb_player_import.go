@@ -0,0 +1,28 @@
+package entities
+
+// MLBPlayerImportMode tells what happens to the stored MLB Players when new ones are imported.
+type MLBPlayerImportMode string
+
+const (
+	// ImportMerge keeps the stored players, replacing the ones with the ID of an imported player.
+	ImportMerge MLBPlayerImportMode = "merge"
+	// ImportReplace drops the stored players, keeping only the imported ones. It is only saved when every row is accepted.
+	ImportReplace MLBPlayerImportMode = "replace"
+)
+
+// MLBPlayerImportRejection struct has a row of an import that was not accepted and why.
+type MLBPlayerImportRejection struct {
+	Line   int    `json:"line"`
+	Reason string `json:"reason"`
+}
+
+// MLBPlayerImportReport struct has the outcome of an import of MLB Players.
+type MLBPlayerImportReport struct {
+	Mode      MLBPlayerImportMode        `json:"mode"`
+	Committed bool                       `json:"committed"`
+	Accepted  int                        `json:"accepted"`
+	Inserted  int                        `json:"inserted"`
+	Updated   int                        `json:"updated"`
+	Removed   int                        `json:"removed"`
+	Rejected  []MLBPlayerImportRejection `json:"rejected"`
+}
@@ -0,0 +1,167 @@
+package entities
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// PredicateOperator compares a field of a MLB Player with a value.
+type PredicateOperator string
+
+const (
+	// OpEqual keeps the players whose field equals the value, ignoring case for text fields.
+	OpEqual PredicateOperator = "="
+	// OpNotEqual keeps the players whose field differs from the value, ignoring case for text fields.
+	OpNotEqual PredicateOperator = "!="
+	// OpGreater keeps the players whose number field is greater than the value.
+	OpGreater PredicateOperator = ">"
+	// OpGreaterOrEqual keeps the players whose number field is greater or equal the value.
+	OpGreaterOrEqual PredicateOperator = ">="
+	// OpLess keeps the players whose number field is less than the value.
+	OpLess PredicateOperator = "<"
+	// OpLessOrEqual keeps the players whose number field is less or equal the value.
+	OpLessOrEqual PredicateOperator = "<="
+	// OpContains keeps the players whose text field contains the value, ignoring case.
+	OpContains PredicateOperator = "~"
+)
+
+// MLBPlayerPredicate tells which MLB Players must be kept.
+type MLBPlayerPredicate interface {
+	Match(p MLBPlayer) bool
+}
+
+// numberField reads a number field of a player. single fields are float32, so their values are rounded the same way.
+type numberField struct {
+	get    func(p MLBPlayer) float64
+	single bool
+}
+
+var mlbPlayerTextFields = map[string]func(p MLBPlayer) string{
+	"name":     func(p MLBPlayer) string { return p.Name },
+	"team":     func(p MLBPlayer) string { return p.Team },
+	"position": func(p MLBPlayer) string { return p.Position },
+}
+
+var mlbPlayerNumberFields = map[string]numberField{
+	"id":            {get: func(p MLBPlayer) float64 { return float64(p.ID) }},
+	"height_inches": {get: func(p MLBPlayer) float64 { return float64(p.Height) }},
+	"weight_lbs":    {get: func(p MLBPlayer) float64 { return float64(p.Weight) }, single: true},
+	"age":           {get: func(p MLBPlayer) float64 { return float64(p.Age) }, single: true},
+}
+
+// MLBPlayerCondition struct compares a field of MLB Players with a value.
+type MLBPlayerCondition struct {
+	Field    string
+	Operator PredicateOperator
+	Value    string
+	text     func(p MLBPlayer) string
+	number   func(p MLBPlayer) float64
+	parsed   float64
+}
+
+// NewMLBPlayerCondition validates the field, the operator and the value of a condition.
+// Text fields support =, != and ~, number fields support every operator but ~.
+func NewMLBPlayerCondition(field string, operator PredicateOperator, value string) (*MLBPlayerCondition, error) {
+	field = strings.ToLower(field)
+	c := &MLBPlayerCondition{Field: field, Operator: operator, Value: value}
+
+	if text, ok := mlbPlayerTextFields[field]; ok {
+		if operator != OpEqual && operator != OpNotEqual && operator != OpContains {
+			return nil, &ValidationError{Field: field, Message: "only supports =, != and ~"}
+		}
+		c.text = text
+
+		return c, nil
+	}
+	number, ok := mlbPlayerNumberFields[field]
+
+	if !ok {
+		return nil, &ValidationError{Field: field, Message: "is not a field of MLB Players"}
+	}
+
+	if operator == OpContains {
+		return nil, &ValidationError{Field: field, Message: "does not support ~"}
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+
+	if err != nil || math.IsNaN(parsed) || math.IsInf(parsed, 0) {
+		return nil, &ValidationError{Field: field, Message: "must be compared with a number"}
+	}
+
+	if number.single {
+		parsed = float64(float32(parsed))
+	}
+	c.number = number.get
+	c.parsed = parsed
+
+	return c, nil
+}
+
+// Match tells if the field of the player satisfies the condition.
+func (c *MLBPlayerCondition) Match(p MLBPlayer) bool {
+	if c.text != nil {
+		switch c.Operator {
+		case OpEqual:
+			return strings.EqualFold(c.text(p), c.Value)
+		case OpNotEqual:
+			return !strings.EqualFold(c.text(p), c.Value)
+		default:
+			return strings.Contains(strings.ToLower(c.text(p)), strings.ToLower(c.Value))
+		}
+	}
+	v := c.number(p)
+
+	switch c.Operator {
+	case OpEqual:
+		return v == c.parsed
+	case OpNotEqual:
+		return v != c.parsed
+	case OpGreater:
+		return v > c.parsed
+	case OpGreaterOrEqual:
+		return v >= c.parsed
+	case OpLess:
+		return v < c.parsed
+	default:
+		return v <= c.parsed
+	}
+}
+
+// MLBPlayerAnd keeps the players matching every predicate, every player when it is empty.
+type MLBPlayerAnd []MLBPlayerPredicate
+
+// Match tells if the player matches every predicate.
+func (and MLBPlayerAnd) Match(p MLBPlayer) bool {
+	for _, predicate := range and {
+		if !predicate.Match(p) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// MLBPlayerOr keeps the players matching any predicate.
+type MLBPlayerOr []MLBPlayerPredicate
+
+// Match tells if the player matches any predicate.
+func (or MLBPlayerOr) Match(p MLBPlayer) bool {
+	for _, predicate := range or {
+		if predicate.Match(p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MLBPlayerIDParity keeps the players with an even ID, or an odd one when Even is false.
+type MLBPlayerIDParity struct {
+	Even bool
+}
+
+// Match tells if the parity of the player ID is the expected one.
+func (parity MLBPlayerIDParity) Match(p MLBPlayer) bool {
+	return (p.ID%2 == 0) == parity.Even
+}
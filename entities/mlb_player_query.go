@@ -0,0 +1,82 @@
+package entities
+
+import "strings"
+
+// MLBPlayerSortFields are the fields MLB Players can be sorted by.
+var MLBPlayerSortFields = map[string]bool{
+	"id":            true,
+	"name":          true,
+	"team":          true,
+	"position":      true,
+	"height_inches": true,
+	"weight_lbs":    true,
+	"age":           true,
+}
+
+// MLBPlayerFilter struct has the criteria MLB Players must match. Empty criteria match every player.
+type MLBPlayerFilter struct {
+	Teams     []string
+	Positions []string
+	MinHeight *int
+	MaxHeight *int
+	MinWeight *float32
+	MaxWeight *float32
+	MinAge    *float32
+	MaxAge    *float32
+}
+
+// SortKey struct has a field to sort MLB Players by.
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
+// MLBPlayerQuery struct has the filter, sort and page of a search of MLB Players.
+type MLBPlayerQuery struct {
+	Filter MLBPlayerFilter
+	Sort   []SortKey
+	Limit  int
+	Offset int
+}
+
+// MLBPlayerPage struct has a page of MLB Players and the total of players matching the query.
+type MLBPlayerPage struct {
+	Players []MLBPlayer
+	Total   int
+	Limit   int
+	Offset  int
+}
+
+// Match tells if the player matches every criteria of the filter.
+func (f MLBPlayerFilter) Match(p MLBPlayer) bool {
+	switch {
+	case len(f.Teams) > 0 && !containsFold(f.Teams, p.Team):
+		return false
+	case len(f.Positions) > 0 && !containsFold(f.Positions, p.Position):
+		return false
+	case f.MinHeight != nil && p.Height < *f.MinHeight:
+		return false
+	case f.MaxHeight != nil && p.Height > *f.MaxHeight:
+		return false
+	case f.MinWeight != nil && p.Weight < *f.MinWeight:
+		return false
+	case f.MaxWeight != nil && p.Weight > *f.MaxWeight:
+		return false
+	case f.MinAge != nil && p.Age < *f.MinAge:
+		return false
+	case f.MaxAge != nil && p.Age > *f.MaxAge:
+		return false
+	}
+
+	return true
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,19 @@
+package entities
+
+// Stats struct has the summary statistics of a numeric field.
+type Stats struct {
+	Mean        float64            `json:"mean"`
+	Median      float64            `json:"median"`
+	Min         float64            `json:"min"`
+	Max         float64            `json:"max"`
+	Percentiles map[string]float64 `json:"percentiles"`
+}
+
+// MLBPlayerGroupStats struct has the statistics of a group of MLB Players.
+type MLBPlayerGroupStats struct {
+	Group  string `json:"group,omitempty"`
+	Count  int    `json:"count"`
+	Height Stats  `json:"height_inches"`
+	Weight Stats  `json:"weight_lbs"`
+	Age    Stats  `json:"age"`
+}
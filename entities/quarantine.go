@@ -0,0 +1,15 @@
+package entities
+
+import "time"
+
+// QuarantinedRow struct has a malformed row skipped while reading a file in lenient mode.
+// Column is the 1-based index of the wrong field, 0 when the whole row is wrong.
+type QuarantinedRow struct {
+	Source        string    `json:"source"`
+	Line          int       `json:"line"`
+	Column        int       `json:"column"`
+	Field         string    `json:"field,omitempty"`
+	Value         string    `json:"value"`
+	Reason        string    `json:"reason"`
+	QuarantinedAt time.Time `json:"quarantined_at"`
+}
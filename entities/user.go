@@ -2,9 +2,9 @@ package entities
 
 // User struct has User business info.
 type User struct {
-	ID        int    `json:"id"`
-	Email     string `json:"email"`
-	FirstName string `json:"first_name"`
-	LastName  string `json:"last_name"`
-	Avatar    string `json:"avatar"`
+	ID        int    `json:"id" xml:"id"`
+	Email     string `json:"email" xml:"email"`
+	FirstName string `json:"first_name" xml:"first_name"`
+	LastName  string `json:"last_name" xml:"last_name"`
+	Avatar    string `json:"avatar" xml:"avatar"`
 }
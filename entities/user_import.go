@@ -0,0 +1,26 @@
+package entities
+
+import "time"
+
+// UserImport struct has the metadata of an import of Users from the upstream API.
+type UserImport struct {
+	PagesFetched int        `json:"pages_fetched"`
+	TotalPages   int        `json:"total_pages"`
+	Total        int        `json:"total"`
+	Imported     int        `json:"imported"`
+	Inserted     int        `json:"inserted"`
+	Updated      int        `json:"updated"`
+	Unchanged    int        `json:"unchanged"`
+	NextPage     int        `json:"next_page,omitempty"`
+	Completed    bool       `json:"completed"`
+	LastError    string     `json:"last_error,omitempty"`
+	StartedAt    time.Time  `json:"started_at"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+}
+
+// UserSaveReport struct has the outcome of saving Users, matched to the stored ones by ID.
+type UserSaveReport struct {
+	Inserted  int `json:"inserted"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+}
@@ -0,0 +1,25 @@
+package entities
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrUserSyncInProgress is returned when a sync of Users is asked for while another one is running.
+var ErrUserSyncInProgress = errors.New("a sync of users is in progress")
+
+// UserSync struct has the state of the syncs of Users with the upstream API.
+// The counts are the ones of the last sync that succeeded.
+type UserSync struct {
+	Running          bool       `json:"running"`
+	LastStartedAt    *time.Time `json:"last_started_at,omitempty"`
+	LastSuccessAt    *time.Time `json:"last_success_at,omitempty"`
+	LastErrorAt      *time.Time `json:"last_error_at,omitempty"`
+	LastError        string     `json:"last_error,omitempty"`
+	NextSyncAt       *time.Time `json:"next_sync_at,omitempty"`
+	PagesFetched     int        `json:"pages_fetched"`
+	PagesNotModified int        `json:"pages_not_modified"`
+	Inserted         int        `json:"inserted"`
+	Updated          int        `json:"updated"`
+	Unchanged        int        `json:"unchanged"`
+}
@@ -0,0 +1,11 @@
+package entities
+
+// ValidationError is returned when a field of an entity has a wrong value.
+type ValidationError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (err *ValidationError) Error() string {
+	return err.Field + " " + err.Message
+}
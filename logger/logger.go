@@ -0,0 +1,88 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+type requestIDKey struct{}
+
+type callerKey struct{}
+
+// contextHandler adds the request ID and the caller found in the context to every record.
+type contextHandler struct {
+	slog.Handler
+}
+
+// Handle adds the request_id attribute when the record is logged with a request context.
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+
+	if caller := Caller(ctx); caller != "" {
+		record.AddAttrs(slog.String("caller", caller))
+	}
+
+	return h.Handler.Handle(ctx, record)
+}
+
+// WithAttrs keeps the request ID handling on the derived handler.
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup keeps the request ID handling on the derived handler.
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// New creates a JSON logger writing to w the records at level or above.
+// Records logged with a context carrying a request ID or a caller include them as request_id and caller.
+func New(w io.Writer, level slog.Level) *slog.Logger {
+	return slog.New(contextHandler{slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})})
+}
+
+// Discard creates a logger that drops every record.
+func Discard() *slog.Logger {
+	return slog.New(slog.DiscardHandler)
+}
+
+// ParseLevel converts debug, info, warn or error into its slog.Level.
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+
+	if err := level.UnmarshalText([]byte(strings.ToUpper(name))); err != nil {
+		return level, errors.New(fmt.Sprint("unknown log level: ", name))
+	}
+
+	return level, nil
+}
+
+// WithRequestID returns a copy of ctx carrying the request ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID gets the request ID carried by ctx, empty if there is none.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+
+	return id
+}
+
+// WithCaller returns a copy of ctx carrying the name of the authenticated caller.
+func WithCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// Caller gets the name of the authenticated caller carried by ctx, empty if there is none.
+func Caller(ctx context.Context) string {
+	caller, _ := ctx.Value(callerKey{}).(string)
+
+	return caller
+}
@@ -0,0 +1,90 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_New_ShouldAddTheRequestID(t *testing.T) {
+	testCases := []struct {
+		name              string
+		ctx               context.Context
+		expectedRequestID interface{}
+		expectedCaller    interface{}
+	}{
+		{
+			name:              "Should add the request ID carried by the context",
+			ctx:               WithRequestID(context.Background(), "abc-123"),
+			expectedRequestID: "abc-123",
+		},
+		{
+			name:              "Should add the caller carried by the context",
+			ctx:               WithCaller(WithRequestID(context.Background(), "abc-123"), "reporting"),
+			expectedRequestID: "abc-123",
+			expectedCaller:    "reporting",
+		},
+		{
+			name:              "Should not add a request ID without one in the context",
+			ctx:               context.Background(),
+			expectedRequestID: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := New(&buf, slog.LevelInfo).With("component", "test")
+
+			logger.ErrorContext(tc.ctx, "error getting players", "error", "boom")
+
+			var line map[string]interface{}
+			assert.Nil(t, json.Unmarshal(buf.Bytes(), &line))
+			assert.Equal(t, "ERROR", line["level"])
+			assert.Equal(t, "error getting players", line["msg"])
+			assert.Equal(t, "boom", line["error"])
+			assert.Equal(t, "test", line["component"])
+			assert.Equal(t, tc.expectedRequestID, line["request_id"])
+			assert.Equal(t, tc.expectedCaller, line["caller"])
+		})
+	}
+}
+
+func Test_New_ShouldSkipRecordsBelowLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelWarn)
+
+	logger.Info("request")
+
+	assert.Empty(t, buf.String())
+}
+
+func Test_ParseLevel_Suite(t *testing.T) {
+	testCases := []struct {
+		name          string
+		level         string
+		expected      slog.Level
+		expectedError bool
+	}{
+		{name: "Should parse debug", level: "debug", expected: slog.LevelDebug},
+		{name: "Should parse upper case", level: "WARN", expected: slog.LevelWarn},
+		{name: "Should parse error", level: "error", expected: slog.LevelError},
+		{name: "Should return error on unknown level", level: "verbose", expectedError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			level, err := ParseLevel(tc.level)
+
+			assert.Equal(t, tc.expectedError, err != nil)
+
+			if !tc.expectedError {
+				assert.Equal(t, tc.expected, level)
+			}
+		})
+	}
+}
@@ -1,13 +1,55 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
-	"net/http"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
 
 	app "github.com/EloYaniel/academy-go-q42021/app"
+	"github.com/EloYaniel/academy-go-q42021/config"
+	"github.com/EloYaniel/academy-go-q42021/logger"
 )
 
 func main() {
-	r := app.InitApp()
-	log.Fatal(http.ListenAndServe(":8080", r))
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML or JSON config file")
+	flag.Parse()
+	cfg, err := config.Load(*configFile)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+	level, _ := logger.ParseLevel(cfg.Log.Level)
+	appLogger := logger.New(os.Stderr, level)
+	slog.SetDefault(appLogger)
+	lc := app.NewLifecycle()
+	r, err := app.InitApp(*cfg, lc, appLogger)
+
+	if err != nil {
+		lc.Stop(context.Background())
+		fatal(appLogger, "error starting the application", err)
+	}
+	listener, err := net.Listen("tcp", cfg.Server.Addr)
+
+	if err != nil {
+		lc.Stop(context.Background())
+		fatal(appLogger, "error listening", err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err = app.Serve(ctx, app.NewServer(cfg.Server, r, appLogger), listener, lc, cfg.Server.ShutdownTimeout, appLogger)
+
+	if err != nil {
+		fatal(appLogger, "error stopping the server", err)
+	}
+	appLogger.Info("server stopped")
+}
+
+func fatal(log *slog.Logger, message string, err error) {
+	log.Error(message, "error", err)
+	os.Exit(1)
 }
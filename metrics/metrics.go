@@ -0,0 +1,320 @@
+package metrics
+
+import (
+	"bufio"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets are the default upper bounds, in seconds, of the latency histograms.
+var DefBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// collector is a metric family that can write itself in the Prometheus text format.
+type collector interface {
+	name() string
+	write(w *bufio.Writer)
+}
+
+// Registry struct keeps the metric families exposed by Handler.
+type Registry struct {
+	mu         sync.Mutex
+	collectors map[string]collector
+}
+
+// NewRegistry function creates a new instance of type Registry.
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]collector)}
+}
+
+func (reg *Registry) register(c collector) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	if _, ok := reg.collectors[c.name()]; ok {
+		panic("metrics: duplicate metric " + c.name())
+	}
+	reg.collectors[c.name()] = c
+}
+
+// Handler serves every registered metric in the Prometheus text exposition format.
+func (reg *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		reg.mu.Lock()
+		collectors := make([]collector, 0, len(reg.collectors))
+		for _, c := range reg.collectors {
+			collectors = append(collectors, c)
+		}
+		reg.mu.Unlock()
+		sort.Slice(collectors, func(i, j int) bool { return collectors[i].name() < collectors[j].name() })
+
+		bw := bufio.NewWriter(w)
+		for _, c := range collectors {
+			c.write(bw)
+		}
+		bw.Flush()
+	})
+}
+
+// family struct has what every metric family shares: its description and its children by label values.
+type family struct {
+	mu       sync.Mutex
+	fullName string
+	help     string
+	kind     string
+	labels   []string
+	children map[string][]string
+}
+
+func newFamily(name string, help string, kind string, labels []string) family {
+	return family{fullName: name, help: help, kind: kind, labels: labels, children: make(map[string][]string)}
+}
+
+func (f *family) name() string {
+	return f.fullName
+}
+
+// key checks the label values and returns the key of their child, storing the values the first time.
+func (f *family) key(values []string) string {
+	if len(values) != len(f.labels) {
+		panic("metrics: " + f.fullName + " expects " + strconv.Itoa(len(f.labels)) + " label values")
+	}
+	key := strings.Join(values, "\xff")
+
+	if _, ok := f.children[key]; !ok {
+		f.children[key] = append([]string(nil), values...)
+	}
+
+	return key
+}
+
+// sortedKeys returns the children keys in a stable order.
+func (f *family) sortedKeys() []string {
+	keys := make([]string, 0, len(f.children))
+	for key := range f.children {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func (f *family) writeHeader(w *bufio.Writer) {
+	w.WriteString("# HELP " + f.fullName + " " + escapeHelp(f.help) + "\n")
+	w.WriteString("# TYPE " + f.fullName + " " + f.kind + "\n")
+}
+
+// CounterVec struct is a counter partitioned by labels.
+type CounterVec struct {
+	family
+	values map[string]float64
+}
+
+// NewCounterVec registers a counter partitioned by the given labels.
+func (reg *Registry) NewCounterVec(name string, help string, labels ...string) *CounterVec {
+	c := &CounterVec{family: newFamily(name, help, "counter", labels), values: make(map[string]float64)}
+	reg.register(c)
+
+	return c
+}
+
+// Add increases the counter of the label values by v, which must not be negative.
+func (c *CounterVec) Add(v float64, values ...string) {
+	if v < 0 {
+		panic("metrics: counter " + c.fullName + " cannot decrease")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[c.key(values)] += v
+}
+
+// Inc increases the counter of the label values by one.
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Value gets the counter of the label values.
+func (c *CounterVec) Value(values ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.values[strings.Join(values, "\xff")]
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeHeader(w)
+	for _, key := range c.sortedKeys() {
+		writeSample(w, c.fullName, c.labels, c.children[key], "", "", c.values[key])
+	}
+}
+
+// GaugeVec struct is a gauge partitioned by labels.
+type GaugeVec struct {
+	family
+	values map[string]float64
+}
+
+// NewGaugeVec registers a gauge partitioned by the given labels.
+func (reg *Registry) NewGaugeVec(name string, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{family: newFamily(name, help, "gauge", labels), values: make(map[string]float64)}
+	reg.register(g)
+
+	return g
+}
+
+// Add changes the gauge of the label values by v.
+func (g *GaugeVec) Add(v float64, values ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.values[g.key(values)] += v
+}
+
+// Set replaces the gauge of the label values with v.
+func (g *GaugeVec) Set(v float64, values ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.values[g.key(values)] = v
+}
+
+// Value gets the gauge of the label values.
+func (g *GaugeVec) Value(values ...string) float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.values[strings.Join(values, "\xff")]
+}
+
+func (g *GaugeVec) write(w *bufio.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.writeHeader(w)
+	for _, key := range g.sortedKeys() {
+		writeSample(w, g.fullName, g.labels, g.children[key], "", "", g.values[key])
+	}
+}
+
+// histogram struct has the observations of a single child of a HistogramVec.
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// HistogramVec struct is a histogram partitioned by labels.
+type HistogramVec struct {
+	family
+	buckets []float64
+	values  map[string]*histogram
+}
+
+// NewHistogramVec registers a histogram with the given bucket upper bounds partitioned by the given labels.
+func (reg *Registry) NewHistogramVec(name string, help string, buckets []float64, labels ...string) *HistogramVec {
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	h := &HistogramVec{family: newFamily(name, help, "histogram", labels), buckets: sorted, values: make(map[string]*histogram)}
+	reg.register(h)
+
+	return h
+}
+
+// Observe adds v to the histogram of the label values.
+func (h *HistogramVec) Observe(v float64, values ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := h.key(values)
+	child, ok := h.values[key]
+
+	if !ok {
+		child = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = child
+	}
+	for i, bound := range h.buckets {
+		if v <= bound {
+			child.counts[i]++
+		}
+	}
+	child.count++
+	child.sum += v
+}
+
+// Count gets the number of observations of the label values.
+func (h *HistogramVec) Count(values ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if child, ok := h.values[strings.Join(values, "\xff")]; ok {
+		return child.count
+	}
+
+	return 0
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.writeHeader(w)
+	for _, key := range h.sortedKeys() {
+		child := h.values[key]
+		values := h.children[key]
+		for i, bound := range h.buckets {
+			writeSample(w, h.fullName+"_bucket", h.labels, values, "le", formatFloat(bound), float64(child.counts[i]))
+		}
+		writeSample(w, h.fullName+"_bucket", h.labels, values, "le", "+Inf", float64(child.count))
+		writeSample(w, h.fullName+"_sum", h.labels, values, "", "", child.sum)
+		writeSample(w, h.fullName+"_count", h.labels, values, "", "", float64(child.count))
+	}
+}
+
+// writeSample writes a sample line, extraName and extraValue add a label like le when not empty.
+func writeSample(w *bufio.Writer, name string, labels []string, values []string, extraName string, extraValue string, v float64) {
+	w.WriteString(name)
+
+	if len(labels) > 0 || extraName != "" {
+		w.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(label + `="` + escapeLabel(values[i]) + `"`)
+		}
+
+		if extraName != "" {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(extraName + `="` + extraValue + `"`)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteString(" " + formatFloat(v) + "\n")
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func scrape(reg *Registry) (string, string) {
+	w := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := ioutil.ReadAll(w.Body)
+
+	return w.Header().Get("Content-Type"), string(body)
+}
+
+func Test_Registry_Suite(t *testing.T) {
+	testCases := []struct {
+		name     string
+		record   func(reg *Registry)
+		expected string
+	}{
+		{
+			name: "Should write counters by label values",
+			record: func(reg *Registry) {
+				c := reg.NewCounterVec("requests_total", "Requests.", "method")
+				c.Inc("GET")
+				c.Add(2, "POST")
+				c.Inc("GET")
+			},
+			expected: "# HELP requests_total Requests.\n" +
+				"# TYPE requests_total counter\n" +
+				"requests_total{method=\"GET\"} 2\n" +
+				"requests_total{method=\"POST\"} 2\n",
+		},
+		{
+			name: "Should write gauges without labels",
+			record: func(reg *Registry) {
+				g := reg.NewGaugeVec("workers", "Workers.")
+				g.Add(3)
+				g.Add(-1)
+			},
+			expected: "# HELP workers Workers.\n" +
+				"# TYPE workers gauge\n" +
+				"workers 2\n",
+		},
+		{
+			name: "Should write cumulative histogram buckets",
+			record: func(reg *Registry) {
+				h := reg.NewHistogramVec("duration_seconds", "Duration.", []float64{1, 0.1}, "route")
+				h.Observe(0.05, "/a")
+				h.Observe(0.5, "/a")
+				h.Observe(5, "/a")
+			},
+			expected: "# HELP duration_seconds Duration.\n" +
+				"# TYPE duration_seconds histogram\n" +
+				"duration_seconds_bucket{route=\"/a\",le=\"0.1\"} 1\n" +
+				"duration_seconds_bucket{route=\"/a\",le=\"1\"} 2\n" +
+				"duration_seconds_bucket{route=\"/a\",le=\"+Inf\"} 3\n" +
+				"duration_seconds_sum{route=\"/a\"} 5.55\n" +
+				"duration_seconds_count{route=\"/a\"} 3\n",
+		},
+		{
+			name: "Should escape label values and help",
+			record: func(reg *Registry) {
+				reg.NewCounterVec("escaped_total", "Line\\one\nline two.", "path").Inc("a\"b\\c\nd")
+			},
+			expected: "# HELP escaped_total Line\\\\one\\nline two.\n" +
+				"# TYPE escaped_total counter\n" +
+				"escaped_total{path=\"a\\\"b\\\\c\\nd\"} 1\n",
+		},
+		{
+			name: "Should sort the families by name",
+			record: func(reg *Registry) {
+				reg.NewGaugeVec("b", "B.").Set(1)
+				reg.NewGaugeVec("a", "A.").Set(2)
+			},
+			expected: "# HELP a A.\n# TYPE a gauge\na 2\n# HELP b B.\n# TYPE b gauge\nb 1\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reg := NewRegistry()
+			tc.record(reg)
+
+			contentType, body := scrape(reg)
+
+			assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", contentType)
+			assert.Equal(t, tc.expected, body)
+		})
+	}
+}
+
+func Test_Registry_ShouldPanicOnMisuse(t *testing.T) {
+	reg := NewRegistry()
+	c := reg.NewCounterVec("requests_total", "Requests.", "method")
+
+	assert.Panics(t, func() { reg.NewGaugeVec("requests_total", "Requests.") })
+	assert.Panics(t, func() { c.Inc() })
+	assert.Panics(t, func() { c.Add(-1, "GET") })
+}
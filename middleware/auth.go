@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/EloYaniel/academy-go-q42021/auth"
+	"github.com/EloYaniel/academy-go-q42021/logger"
+)
+
+// Authenticator identifies the caller of a request.
+type Authenticator interface {
+	Authenticate(r *http.Request) (auth.Identity, error)
+}
+
+type authError struct {
+	Message string `json:"message"`
+}
+
+// Authorize answers with 401 the requests whose caller can not be identified by authenticator and with 403 the ones
+// whose caller lacks the role required by the route, as told by role. Routes that require no role are public.
+// The identity of the caller is carried by the context of the request, see auth.FromContext.
+func Authorize(authenticator Authenticator, role func(r *http.Request) (auth.Role, bool)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			required, ok := role(r)
+
+			if !ok {
+				next.ServeHTTP(w, r)
+
+				return
+			}
+			id, err := authenticator.Authenticate(r)
+
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="academy-go", ApiKey realm="academy-go"`)
+				writeAuthError(w, http.StatusUnauthorized, "Authentication required: "+err.Error())
+
+				return
+			}
+
+			if !id.Allows(required) {
+				writeAuthError(w, http.StatusForbidden, "The "+string(required)+" role is required")
+
+				return
+			}
+			ctx := logger.WithCaller(auth.WithIdentity(r.Context(), id), id.Subject)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func writeAuthError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(authError{Message: message})
+}
@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/EloYaniel/academy-go-q42021/auth"
+	"github.com/EloYaniel/academy-go-q42021/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockAuthenticator struct {
+	id  auth.Identity
+	err error
+}
+
+func (m mockAuthenticator) Authenticate(r *http.Request) (auth.Identity, error) {
+	return m.id, m.err
+}
+
+func Test_Authorize_Suite(t *testing.T) {
+	reader := auth.Identity{Subject: "reporting", Method: "api_key", Roles: []auth.Role{auth.RoleRead}}
+	testCases := []struct {
+		name           string
+		authenticator  mockAuthenticator
+		role           auth.Role
+		public         bool
+		expectedStatus int
+		expectedBody   string
+		expectedCaller string
+	}{
+		{
+			name:           "Should pass the identity of an allowed caller",
+			authenticator:  mockAuthenticator{id: reader},
+			role:           auth.RoleRead,
+			expectedStatus: http.StatusOK,
+			expectedBody:   "reporting",
+			expectedCaller: "reporting",
+		},
+		{
+			name:           "Should return forbidden when the caller lacks the role",
+			authenticator:  mockAuthenticator{id: reader},
+			role:           auth.RoleWrite,
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"message":"The write role is required"}` + "\n",
+		},
+		{
+			name:           "Should return unauthorized without credentials",
+			authenticator:  mockAuthenticator{err: auth.ErrMissingCredentials},
+			role:           auth.RoleRead,
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"message":"Authentication required: missing credentials"}` + "\n",
+		},
+		{
+			name:           "Should return unauthorized on invalid credentials",
+			authenticator:  mockAuthenticator{err: auth.ErrInvalidCredentials},
+			role:           auth.RoleRead,
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"message":"Authentication required: invalid credentials"}` + "\n",
+		},
+		{
+			name:           "Should not authenticate public routes",
+			authenticator:  mockAuthenticator{err: auth.ErrMissingCredentials},
+			public:         true,
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var caller string
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				caller = logger.Caller(r.Context())
+
+				if id, ok := auth.FromContext(r.Context()); ok {
+					w.Write([]byte(id.Subject))
+				}
+			})
+			role := func(r *http.Request) (auth.Role, bool) { return tc.role, !tc.public }
+			w := httptest.NewRecorder()
+
+			Authorize(tc.authenticator, role)(handler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.Equal(t, tc.expectedBody, w.Body.String())
+			assert.Equal(t, tc.expectedCaller, caller)
+
+			if tc.expectedStatus == http.StatusUnauthorized {
+				assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+			}
+
+			if tc.expectedStatus != http.StatusOK {
+				assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
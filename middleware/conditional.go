@@ -0,0 +1,217 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxBufferedBody bounds the responses buffered to compute their ETag, larger ones are streamed without it.
+const maxBufferedBody = 1 << 20
+
+// Version identifies the version of the data a response is built from. Tag must change whenever the data does,
+// Modified is the time it changed.
+type Version struct {
+	Tag      string
+	Modified time.Time
+}
+
+// conditionalResponse validates the successful response written by the next handler as it starts.
+// When the version of the data is the same as when the request started the validators are computed from it and
+// the response is streamed. Otherwise the response is buffered, up to maxBufferedBody, to compute its ETag from
+// the content; larger ones are streamed without it.
+type conditionalResponse struct {
+	w            http.ResponseWriter
+	r            *http.Request
+	cacheControl string
+	version      func(r *http.Request) (Version, bool)
+	before       Version
+	known        bool
+	status       int
+	buffering    bool
+	notModified  bool
+	body         bytes.Buffer
+}
+
+func (cr *conditionalResponse) Header() http.Header {
+	return cr.w.Header()
+}
+
+func (cr *conditionalResponse) WriteHeader(status int) {
+	if cr.status != 0 {
+		return
+	}
+	cr.status = status
+
+	if status != http.StatusOK {
+		cr.w.WriteHeader(status)
+
+		return
+	}
+	after, known := cr.version(cr.r)
+
+	if !cr.known || !known || after != cr.before {
+		cr.buffering = true
+
+		return
+	}
+	etag := setValidators(cr.w, cr.cacheControl, versionETag(cr.r, cr.Header().Get("Content-Type"), after.Tag))
+	modified := after.Modified.UTC().Truncate(time.Second)
+	cr.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+
+	if notModified(cr.r, etag, modified, true) {
+		cr.notModified = true
+		cr.Header().Del("Content-Type")
+		cr.Header().Del("Content-Length")
+		status = http.StatusNotModified
+	}
+	cr.w.WriteHeader(status)
+}
+
+func (cr *conditionalResponse) Write(b []byte) (int, error) {
+	if cr.status == 0 {
+		cr.WriteHeader(http.StatusOK)
+	}
+
+	if cr.notModified {
+		return len(b), nil
+	}
+
+	if !cr.buffering {
+		return cr.w.Write(b)
+	}
+
+	if cr.body.Len()+len(b) <= maxBufferedBody {
+		return cr.body.Write(b)
+	}
+	cr.stream()
+
+	return cr.w.Write(b)
+}
+
+// stream gives up on buffering, sending the status and the buffered body without ETag.
+func (cr *conditionalResponse) stream() {
+	cr.buffering = false
+
+	if cr.cacheControl != "" {
+		cr.w.Header().Set("Cache-Control", cr.cacheControl)
+	}
+	cr.w.WriteHeader(cr.status)
+	cr.w.Write(cr.body.Bytes())
+	cr.body.Reset()
+}
+
+// finish sends the buffered response, if any, once the next handler returns.
+func (cr *conditionalResponse) finish() {
+	if cr.status == 0 {
+		cr.WriteHeader(http.StatusOK)
+	}
+
+	if !cr.buffering {
+		return
+	}
+	etag := setValidators(cr.w, cr.cacheControl, contentETag(cr.Header().Get("Content-Type"), cr.body.Bytes()))
+
+	if notModified(cr.r, etag, time.Time{}, false) {
+		cr.Header().Del("Content-Type")
+		cr.Header().Del("Content-Length")
+		cr.w.WriteHeader(http.StatusNotModified)
+
+		return
+	}
+	cr.w.WriteHeader(http.StatusOK)
+	cr.w.Write(cr.body.Bytes())
+}
+
+// FlushError flushes the response unless it is being buffered, then there is nothing to send yet.
+func (cr *conditionalResponse) FlushError() error {
+	if cr.buffering {
+		return nil
+	}
+
+	return http.NewResponseController(cr.w).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the original writer.
+func (cr *conditionalResponse) Unwrap() http.ResponseWriter {
+	return cr.w
+}
+
+// Conditional sets a strong ETag, the Last-Modified time of the version given by version, if any, and cacheControl,
+// when it is not empty, on the successful GET responses of next. Requests whose If-None-Match, or else
+// If-Modified-Since, header matches the response are answered with 304 and no body.
+// The ETag is computed from the version when it did not change while the response was built, so the response is
+// streamed, otherwise from the content. A handler that fails after starting a response must abort it, so the
+// partial body is not taken for the version it was sent with.
+func Conditional(cacheControl string, version func(r *http.Request) (Version, bool)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				next.ServeHTTP(w, r)
+
+				return
+			}
+			before, known := version(r)
+			cr := &conditionalResponse{w: w, r: r, cacheControl: cacheControl, version: version, before: before, known: known}
+
+			next.ServeHTTP(cr, r)
+			cr.finish()
+		})
+	}
+}
+
+// setValidators sets etag and cacheControl, when it is not empty, on a successful response and returns etag.
+func setValidators(w http.ResponseWriter, cacheControl string, etag string) string {
+	w.Header().Set("ETag", etag)
+	w.Header().Add("Vary", "Accept")
+
+	if cacheControl != "" {
+		w.Header().Set("Cache-Control", cacheControl)
+	}
+
+	return etag
+}
+
+// contentETag computes a strong ETag from the content type and body of a response.
+func contentETag(contentType string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(contentType))
+	h.Write([]byte{0})
+	h.Write(body)
+
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// versionETag computes a strong ETag from the request, the content type of its response and the tag of the
+// version of the data.
+func versionETag(r *http.Request, contentType string, tag string) string {
+	h := sha256.New()
+	h.Write([]byte(r.URL.RequestURI()))
+	h.Write([]byte{0})
+	h.Write([]byte(contentType))
+	h.Write([]byte{0})
+	h.Write([]byte(tag))
+
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since when there is no If-None-Match, against a response.
+func notModified(r *http.Request, etag string, modified time.Time, known bool) bool {
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		for _, tag := range strings.Split(ifNoneMatch, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+
+			if tag == "*" || tag == etag {
+				return true
+			}
+		}
+
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+
+	return known && err == nil && !modified.After(since)
+}
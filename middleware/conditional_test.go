@@ -0,0 +1,234 @@
+package middleware
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Conditional_Suite(t *testing.T) {
+	modified := time.Date(2021, time.November, 2, 10, 0, 0, 500, time.UTC)
+	body := `[{"id":1}]`
+	etag := contentETag("application/json", []byte(body))
+	versioned := versionETag(httptest.NewRequest(http.MethodGet, "/items", nil), "application/json", "v1")
+	testCases := []struct {
+		name           string
+		method         string
+		headers        map[string]string
+		status         int
+		lastModified   bool
+		expectedStatus int
+		expectedBody   string
+		expectedETag   string
+		expectedLast   string
+	}{
+		{
+			name:           "Should set the validators on a full response",
+			expectedStatus: http.StatusOK,
+			lastModified:   true,
+			expectedBody:   body,
+			expectedETag:   versioned,
+			expectedLast:   "Tue, 02 Nov 2021 10:00:00 GMT",
+		},
+		{
+			name:           "Should return not modified when the ETag matches",
+			headers:        map[string]string{"If-None-Match": `"other", ` + etag},
+			expectedStatus: http.StatusNotModified,
+			expectedETag:   etag,
+		},
+		{
+			name:           "Should match weak ETags",
+			headers:        map[string]string{"If-None-Match": "W/" + etag},
+			expectedStatus: http.StatusNotModified,
+			expectedETag:   etag,
+		},
+		{
+			name: "Should return the full response when the ETag changed, even if it was not modified since",
+			headers: map[string]string{
+				"If-None-Match":     `"other"`,
+				"If-Modified-Since": "Tue, 02 Nov 2021 10:00:00 GMT",
+			},
+			lastModified:   true,
+			expectedStatus: http.StatusOK,
+			expectedBody:   body,
+			expectedETag:   versioned,
+			expectedLast:   "Tue, 02 Nov 2021 10:00:00 GMT",
+		},
+		{
+			name:           "Should return not modified when the ETag of the version matches",
+			headers:        map[string]string{"If-None-Match": versioned},
+			lastModified:   true,
+			expectedStatus: http.StatusNotModified,
+			expectedETag:   versioned,
+			expectedLast:   "Tue, 02 Nov 2021 10:00:00 GMT",
+		},
+		{
+			name:           "Should return not modified when it was not modified since",
+			headers:        map[string]string{"If-Modified-Since": "Tue, 02 Nov 2021 10:00:00 GMT"},
+			lastModified:   true,
+			expectedStatus: http.StatusNotModified,
+			expectedETag:   versioned,
+			expectedLast:   "Tue, 02 Nov 2021 10:00:00 GMT",
+		},
+		{
+			name:           "Should return the full response when it was modified since",
+			headers:        map[string]string{"If-Modified-Since": "Tue, 02 Nov 2021 09:59:59 GMT"},
+			lastModified:   true,
+			expectedStatus: http.StatusOK,
+			expectedBody:   body,
+			expectedETag:   versioned,
+			expectedLast:   "Tue, 02 Nov 2021 10:00:00 GMT",
+		},
+		{
+			name:           "Should ignore If-Modified-Since when the modification time is unknown",
+			headers:        map[string]string{"If-Modified-Since": "Tue, 02 Nov 2021 10:00:00 GMT"},
+			expectedStatus: http.StatusOK,
+			expectedBody:   body,
+			expectedETag:   etag,
+		},
+		{
+			name:           "Should not validate failed responses",
+			headers:        map[string]string{"If-None-Match": "*"},
+			status:         http.StatusNotFound,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   body,
+		},
+		{
+			name:           "Should not validate failed responses of a known version",
+			headers:        map[string]string{"If-None-Match": "*"},
+			status:         http.StatusNotFound,
+			lastModified:   true,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   body,
+		},
+		{
+			name:           "Should not validate other methods",
+			method:         http.MethodPost,
+			headers:        map[string]string{"If-None-Match": "*"},
+			expectedStatus: http.StatusOK,
+			expectedBody:   body,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+
+				if tc.status != 0 {
+					w.WriteHeader(tc.status)
+				}
+				w.Write([]byte(body))
+			})
+			version := func(r *http.Request) (Version, bool) { return Version{Tag: "v1", Modified: modified}, tc.lastModified }
+			method := tc.method
+
+			if method == "" {
+				method = http.MethodGet
+			}
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(method, "/items", nil)
+			for name, value := range tc.headers {
+				r.Header.Set(name, value)
+			}
+
+			Conditional("private, no-cache", version)(handler).ServeHTTP(w, r)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.Equal(t, tc.expectedBody, w.Body.String())
+			assert.Equal(t, tc.expectedETag, w.Header().Get("ETag"))
+			assert.Equal(t, tc.expectedLast, w.Header().Get("Last-Modified"))
+
+			if tc.expectedETag != "" {
+				assert.Equal(t, "private, no-cache", w.Header().Get("Cache-Control"))
+				assert.Equal(t, "Accept", w.Header().Get("Vary"))
+			}
+		})
+	}
+}
+
+func Test_Conditional_ShouldChangeTheETagWithTheContentType(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/items", nil)
+
+	assert.NotEqual(t, contentETag("application/json", []byte("[]")), contentETag("text/csv", []byte("[]")))
+	assert.NotEqual(t, versionETag(r, "application/json", "v1"), versionETag(r, "text/csv", "v1"))
+	assert.NotEqual(t, versionETag(r, "text/csv", "v1"), versionETag(httptest.NewRequest(http.MethodGet, "/items?page=2", nil), "text/csv", "v1"))
+	assert.NotEqual(t, versionETag(r, "text/csv", "v1"), versionETag(r, "text/csv", "v2"))
+}
+
+func Test_Conditional_ShouldUseTheContentWhenTheVersionChangesDuringTheRequest(t *testing.T) {
+	body := `[{"id":1}]`
+	versions := 0
+	version := func(r *http.Request) (Version, bool) {
+		versions++
+
+		return Version{Tag: strconv.Itoa(versions), Modified: time.Now()}, true
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	})
+	w := httptest.NewRecorder()
+
+	Conditional("no-cache", version)(handler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, body, w.Body.String())
+	assert.Equal(t, contentETag("application/json", []byte(body)), w.Header().Get("ETag"))
+	assert.Empty(t, w.Header().Get("Last-Modified"))
+}
+
+func Test_Conditional_ShouldStreamTheResponses(t *testing.T) {
+	testCases := []struct {
+		name         string
+		lastModified bool
+		firstRow     int
+		expectedETag bool
+	}{
+		{
+			name:         "Should stream as it is written when the modification time is known",
+			lastModified: true,
+			firstRow:     10,
+			expectedETag: true,
+		},
+		{
+			name:     "Should stream without ETag past the buffered size when the modification time is unknown",
+			firstRow: maxBufferedBody + 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			release := make(chan struct{})
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/csv")
+				w.Write([]byte(strings.Repeat("a", tc.firstRow-1) + "\n"))
+				http.NewResponseController(w).Flush()
+				<-release
+				w.Write([]byte("last\n"))
+			})
+			modified := time.Now()
+			version := func(r *http.Request) (Version, bool) { return Version{Tag: "v1", Modified: modified}, tc.lastModified }
+			server := httptest.NewServer(Conditional("no-cache", version)(handler))
+			defer server.Close()
+			defer close(release)
+
+			res, err := http.Get(server.URL)
+			assert.Nil(t, err)
+			defer res.Body.Close()
+			line, err := bufio.NewReader(res.Body).ReadString('\n')
+
+			assert.Nil(t, err)
+			assert.Len(t, line, tc.firstRow)
+			assert.Equal(t, http.StatusOK, res.StatusCode)
+			assert.Equal(t, tc.expectedETag, res.Header.Get("ETag") != "")
+			assert.Equal(t, "no-cache", res.Header.Get("Cache-Control"))
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+)
+
+// RequestObserver is notified of every request served, to expose it as metrics.
+type RequestObserver interface {
+	ObserveRequest(method string, route string, status int, d time.Duration)
+}
+
+// Metrics notifies observer of the method, route, status and latency of every request.
+// route names the route that serves a request, so the paths of the same route are observed together.
+func Metrics(observer RequestObserver, route func(r *http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			started := time.Now()
+			rec := &statusRecorder{ResponseWriter: w}
+
+			next.ServeHTTP(rec, r)
+
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+			observer.ObserveRequest(r.Method, route(r), rec.status, time.Since(started))
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// requestObservation has the arguments of a call to ObserveRequest.
+type requestObservation struct {
+	method string
+	route  string
+	status int
+}
+
+type recordingObserver struct {
+	observed []requestObservation
+}
+
+func (o *recordingObserver) ObserveRequest(method string, route string, status int, d time.Duration) {
+	o.observed = append(o.observed, requestObservation{method: method, route: route, status: status})
+}
+
+func Test_Metrics_Suite(t *testing.T) {
+	testCases := []struct {
+		name     string
+		handler  http.HandlerFunc
+		expected requestObservation
+	}{
+		{
+			name:     "Should observe the status written",
+			handler:  func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNotFound) },
+			expected: requestObservation{method: http.MethodGet, route: "/items/{id}", status: http.StatusNotFound},
+		},
+		{
+			name:     "Should observe an implicit OK",
+			handler:  func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("body")) },
+			expected: requestObservation{method: http.MethodGet, route: "/items/{id}", status: http.StatusOK},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			observer := &recordingObserver{}
+			route := func(r *http.Request) string { return "/items/{id}" }
+			w := httptest.NewRecorder()
+
+			Metrics(observer, route)(tc.handler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items/1", nil))
+
+			assert.Equal(t, []requestObservation{tc.expected}, observer.observed)
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/EloYaniel/academy-go-q42021/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RequestLogger_Suite(t *testing.T) {
+	testCases := []struct {
+		name              string
+		requestID         string
+		status            int
+		expectedRequestID string
+		expectedLevel     string
+	}{
+		{
+			name:              "Should propagate the request ID",
+			requestID:         "abc-123",
+			status:            http.StatusCreated,
+			expectedRequestID: "abc-123",
+			expectedLevel:     "INFO",
+		},
+		{
+			name:          "Should assign a request ID when there is none",
+			status:        http.StatusOK,
+			expectedLevel: "INFO",
+		},
+		{
+			name:          "Should replace an invalid request ID",
+			requestID:     "bad id",
+			status:        http.StatusOK,
+			expectedLevel: "INFO",
+		},
+		{
+			name:          "Should log server errors as errors",
+			requestID:     strings.Repeat("a", 129),
+			status:        http.StatusInternalServerError,
+			expectedLevel: "ERROR",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			log := logger.New(&buf, slog.LevelInfo)
+			var handlerRequestID string
+			handler := RequestLogger(log)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handlerRequestID = logger.RequestID(r.Context())
+				w.WriteHeader(tc.status)
+				w.Write([]byte("body"))
+			}))
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/mlb-players?limit=1", nil)
+
+			if tc.requestID != "" {
+				r.Header.Set(RequestIDHeader, tc.requestID)
+			}
+
+			handler.ServeHTTP(w, r)
+
+			requestID := w.Header().Get(RequestIDHeader)
+
+			if tc.expectedRequestID != "" {
+				assert.Equal(t, tc.expectedRequestID, requestID)
+			} else {
+				assert.Len(t, requestID, 32)
+			}
+			assert.Equal(t, requestID, handlerRequestID)
+
+			var line map[string]interface{}
+			assert.Nil(t, json.Unmarshal(buf.Bytes(), &line))
+			assert.Equal(t, tc.expectedLevel, line["level"])
+			assert.Equal(t, "request", line["msg"])
+			assert.Equal(t, requestID, line["request_id"])
+			assert.Equal(t, http.MethodPost, line["method"])
+			assert.Equal(t, "/mlb-players", line["path"])
+			assert.Equal(t, float64(tc.status), line["status"])
+			assert.Equal(t, float64(4), line["bytes"])
+			assert.Contains(t, line, "latency_ms")
+		})
+	}
+}
+
+func Test_RequestLogger_ShouldDefaultToOK(t *testing.T) {
+	var buf bytes.Buffer
+	handler := RequestLogger(logger.New(&buf, slog.LevelInfo))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	assert.Contains(t, buf.String(), `"status":200`)
+}
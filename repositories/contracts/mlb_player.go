@@ -1,14 +1,37 @@
 package repositories
 
-import e "github.com/EloYaniel/academy-go-q42021/entities"
+import (
+	"context"
+
+	e "github.com/EloYaniel/academy-go-q42021/entities"
+)
 
 type MLBPlayerRepository interface {
 	// GetMLBPlayers gets all MLB Players.
-	GetMLBPlayers() ([]e.MLBPlayer, error)
+	GetMLBPlayers(ctx context.Context) ([]e.MLBPlayer, error)
+
+	// EachMLBPlayer streams every MLB Player in order, stopping at the first error of fn.
+	EachMLBPlayer(ctx context.Context, fn func(p e.MLBPlayer) error) error
 
 	// GetMLBPlayerByID get a Player by its ID
-	GetMLBPlayerByID(id int) (*e.MLBPlayer, error)
+	GetMLBPlayerByID(ctx context.Context, id int) (*e.MLBPlayer, error)
 
 	// GetMLBPlayerDesired gets MLB Players and filetered by its params.
-	GetMLBPlayerDesired(filterType string, totalItems int, itemsPerWorker int) ([]e.MLBPlayer, error)
+	GetMLBPlayerDesired(ctx context.Context, predicate e.MLBPlayerPredicate, totalItems int, itemsPerWorker int) (*e.MLBPlayerDesiredResult, error)
+
+	// GetMLBPlayerSample gets a random sample of the MLB Players matching predicate read concurrently.
+	GetMLBPlayerSample(ctx context.Context, predicate e.MLBPlayerPredicate, totalItems int, itemsPerWorker int, sampling e.MLBPlayerSampling) (*e.MLBPlayerDesiredResult, error)
+
+	// CreateMLBPlayer saves a new Player allocating its ID.
+	CreateMLBPlayer(ctx context.Context, player e.MLBPlayer) (*e.MLBPlayer, error)
+
+	// UpdateMLBPlayer replaces the Player with the same ID, nil if it does not exist.
+	UpdateMLBPlayer(ctx context.Context, player e.MLBPlayer) (*e.MLBPlayer, error)
+
+	// DeleteMLBPlayer deletes a Player by its ID, nil if it does not exist.
+	DeleteMLBPlayer(ctx context.Context, id int) (*e.MLBPlayer, error)
+
+	// ImportMLBPlayers saves players in a single commit, merged with the stored ones by ID or replacing them.
+	// The report has the inserted, updated and removed counts.
+	ImportMLBPlayers(ctx context.Context, players []e.MLBPlayer, mode e.MLBPlayerImportMode) (*e.MLBPlayerImportReport, error)
 }
@@ -1,14 +1,29 @@
 package repositories
 
-import e "github.com/EloYaniel/academy-go-q42021/entities"
+import (
+	"context"
+
+	e "github.com/EloYaniel/academy-go-q42021/entities"
+)
 
 type UserRepository interface {
-	// SaveUsers saves all users
-	SaveUsers(users []e.User) error
+	// SaveUsers upserts users by ID, keeping the stored ones that are not in users
+	SaveUsers(ctx context.Context, users []e.User) (*e.UserSaveReport, error)
 
 	// GetUsers gets all Users
-	GetUsers() ([]e.User, error)
+	GetUsers(ctx context.Context) ([]e.User, error)
+
+	// EachUser streams every User in order, stopping at the first error of fn.
+	EachUser(ctx context.Context, fn func(u e.User) error) error
 
 	// GetUserByID get a User by its ID
-	GetUserByID(id int) (*e.User, error)
+	GetUserByID(ctx context.Context, id int) (*e.User, error)
+}
+
+type UserImportRepository interface {
+	// GetUserImport gets the state of the last import of Users, nil if there was none
+	GetUserImport(ctx context.Context) (*e.UserImport, error)
+
+	// SaveUserImport replaces the state of the last import of Users
+	SaveUserImport(ctx context.Context, userImport e.UserImport) error
 }
@@ -0,0 +1,73 @@
+package repositories
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/EloYaniel/academy-go-q42021/cache"
+	e "github.com/EloYaniel/academy-go-q42021/entities"
+	r "github.com/EloYaniel/academy-go-q42021/repositories/contracts"
+)
+
+// CachedUserRepository struct implements UserRepository interface keeping the reads of another one in a cache.
+// Saving Users purges the cache, and when a read fails the last Users read are served while they are not too old.
+type CachedUserRepository struct {
+	source r.UserRepository
+	cache  *cache.Cache
+}
+
+// NewCachedUserRepository function creates a new instance of type CachedUserRepository.
+func NewCachedUserRepository(source r.UserRepository, c *cache.Cache) *CachedUserRepository {
+	return &CachedUserRepository{source: source, cache: c}
+}
+
+// SaveUsers upserts users by ID in the source and purges the cache.
+func (repo *CachedUserRepository) SaveUsers(ctx context.Context, users []e.User) (*e.UserSaveReport, error) {
+	defer repo.cache.Purge()
+
+	return repo.source.SaveUsers(ctx, users)
+}
+
+// GetUsers gets all Users through the cache.
+func (repo *CachedUserRepository) GetUsers(ctx context.Context) ([]e.User, error) {
+	value, err := repo.cache.GetOrLoad(ctx, "users", func(ctx context.Context) (interface{}, error) {
+		return repo.source.GetUsers(ctx)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	cached := value.([]e.User)
+
+	if cached == nil {
+		return nil, nil
+	}
+	users := make([]e.User, len(cached))
+	copy(users, cached)
+
+	return users, nil
+}
+
+// EachUser streams the Users of the source, which are not cached.
+func (repo *CachedUserRepository) EachUser(ctx context.Context, fn func(u e.User) error) error {
+	return repo.source.EachUser(ctx, fn)
+}
+
+// GetUserByID get a User by its ID through the cache, Users that do not exist are cached too.
+func (repo *CachedUserRepository) GetUserByID(ctx context.Context, id int) (*e.User, error) {
+	value, err := repo.cache.GetOrLoad(ctx, "user:"+strconv.Itoa(id), func(ctx context.Context) (interface{}, error) {
+		return repo.source.GetUserByID(ctx, id)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	cached := value.(*e.User)
+
+	if cached == nil {
+		return nil, nil
+	}
+	user := *cached
+
+	return &user, nil
+}
@@ -0,0 +1,67 @@
+package repositories
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/EloYaniel/academy-go-q42021/cache"
+	e "github.com/EloYaniel/academy-go-q42021/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CachedUserRepository_ShouldReadThroughTheCache(t *testing.T) {
+	filePath := copyTestFile(t, "../../data/test/users-test.csv")
+	repo := NewCachedUserRepository(NewCSVUserRepository(filePath), cache.New("users", 10, time.Minute, 0))
+
+	users, err := repo.GetUsers(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, []e.User{user1, user2}, users)
+	user, err := repo.GetUserByID(context.Background(), 3)
+	assert.Nil(t, err)
+	assert.Nil(t, user)
+
+	touchTestFile(t, filePath, "\n3,emma.wong@reqres.in,Emma,Wong,")
+	users[0].Email = "changed@reqres.in"
+
+	users, err = repo.GetUsers(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, []e.User{user1, user2}, users)
+	user, err = repo.GetUserByID(context.Background(), 3)
+	assert.Nil(t, err)
+	assert.Nil(t, user)
+
+	user4 := e.User{ID: 4, Email: "eve.holt@reqres.in", FirstName: "Eve", LastName: "Holt"}
+	report, err := repo.SaveUsers(context.Background(), []e.User{user4})
+	assert.Nil(t, err)
+	assert.Equal(t, &e.UserSaveReport{Inserted: 1}, report)
+
+	users, err = repo.GetUsers(context.Background())
+	assert.Nil(t, err)
+	assert.Len(t, users, 4)
+	user, err = repo.GetUserByID(context.Background(), 3)
+	assert.Nil(t, err)
+	assert.Equal(t, "emma.wong@reqres.in", user.Email)
+}
+
+func Test_CachedUserRepository_ShouldServeStaleUsersOnError(t *testing.T) {
+	filePath := copyTestFile(t, "../../data/test/users-test.csv")
+	repo := NewCachedUserRepository(NewCSVUserRepository(filePath), cache.New("users", 10, time.Nanosecond, time.Minute))
+
+	_, err := repo.GetUsers(context.Background())
+	assert.Nil(t, err)
+	_, err = repo.GetUserByID(context.Background(), 1)
+	assert.Nil(t, err)
+	assert.Nil(t, os.Remove(filePath))
+
+	users, err := repo.GetUsers(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, []e.User{user1, user2}, users)
+	user, err := repo.GetUserByID(context.Background(), 1)
+	assert.Nil(t, err)
+	assert.Equal(t, &user1, user)
+	user, err = repo.GetUserByID(context.Background(), 2)
+	assert.EqualError(t, err, "error getting user")
+	assert.Nil(t, user)
+}
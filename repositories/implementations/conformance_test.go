@@ -0,0 +1,412 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	e "github.com/EloYaniel/academy-go-q42021/entities"
+	contracts "github.com/EloYaniel/academy-go-q42021/repositories/contracts"
+	"github.com/stretchr/testify/assert"
+)
+
+// backend builds fresh repositories seeded with the given files.
+type backend struct {
+	name    string
+	players func(t *testing.T, playersFilePath string) contracts.MLBPlayerRepository
+	users   func(t *testing.T, usersFilePath string) contracts.UserRepository
+}
+
+var backends = []backend{
+	{
+		name: "CSV",
+		players: func(t *testing.T, playersFilePath string) contracts.MLBPlayerRepository {
+			return NewCSVMLBPlayerRepository(copyTestFile(t, playersFilePath))
+		},
+		users: func(t *testing.T, usersFilePath string) contracts.UserRepository {
+			return NewCSVUserRepository(copyTestFile(t, usersFilePath))
+		},
+	},
+	{
+		name: "Indexed",
+		players: func(t *testing.T, playersFilePath string) contracts.MLBPlayerRepository {
+			return NewIndexedMLBPlayerRepository(copyTestFile(t, playersFilePath))
+		},
+		users: func(t *testing.T, usersFilePath string) contracts.UserRepository {
+			return NewIndexedUserRepository(copyTestFile(t, usersFilePath))
+		},
+	},
+	{
+		name: "SQLite",
+		players: func(t *testing.T, playersFilePath string) contracts.MLBPlayerRepository {
+			return NewSQLiteMLBPlayerRepository(newTestDB(t, playersFilePath, "../../data/test/users-test.csv"))
+		},
+		users: func(t *testing.T, usersFilePath string) contracts.UserRepository {
+			return NewSQLiteUserRepository(newTestDB(t, "../../data/test/players-test.csv", usersFilePath))
+		},
+	},
+}
+
+func newTestDB(t *testing.T, playersFilePath string, usersFilePath string) *sql.DB {
+	db, err := OpenSQLite(filepath.Join(t.TempDir(), "test.db"))
+	assert.Nil(t, err)
+	t.Cleanup(func() { db.Close() })
+	assert.Nil(t, ImportCSVIntoSQLite(context.Background(), db, playersFilePath, usersFilePath, nil))
+
+	return db
+}
+
+func Test_Conformance_GetMLBPlayers(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			repo := b.players(t, "../../data/test/players-test.csv")
+
+			players, err := repo.GetMLBPlayers(context.Background())
+
+			assert.Nil(t, err)
+			assert.Equal(t, []e.MLBPlayer{player1, player2}, players)
+		})
+	}
+}
+
+func Test_Conformance_EachMLBPlayer(t *testing.T) {
+	stop := errors.New("stop")
+
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			repo := b.players(t, "../../data/test/players-test.csv")
+			var players []e.MLBPlayer
+
+			err := repo.EachMLBPlayer(context.Background(), func(p e.MLBPlayer) error {
+				players = append(players, p)
+
+				return nil
+			})
+			assert.Nil(t, err)
+			assert.Equal(t, []e.MLBPlayer{player1, player2}, players)
+
+			calls := 0
+			err = repo.EachMLBPlayer(context.Background(), func(p e.MLBPlayer) error {
+				calls++
+
+				return stop
+			})
+			assert.Equal(t, stop, err)
+			assert.Equal(t, 1, calls)
+		})
+	}
+}
+
+func Test_Conformance_GetMLBPlayerByID(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			repo := b.players(t, "../../data/test/players-test.csv")
+
+			player, err := repo.GetMLBPlayerByID(context.Background(), 2)
+			assert.Nil(t, err)
+			assert.Equal(t, &player2, player)
+
+			player, err = repo.GetMLBPlayerByID(context.Background(), 3)
+			assert.Nil(t, err)
+			assert.Nil(t, player)
+		})
+	}
+}
+
+func Test_Conformance_GetMLBPlayerDesired(t *testing.T) {
+	expected, err := NewCSVMLBPlayerRepository("../../data/mlb_players.csv").GetMLBPlayerDesired(context.Background(), e.MLBPlayerIDParity{}, 40, 7)
+	assert.Nil(t, err)
+
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			repo := b.players(t, "../../data/mlb_players.csv")
+
+			result, err := repo.GetMLBPlayerDesired(context.Background(), e.MLBPlayerIDParity{}, 40, 7)
+
+			assert.Nil(t, err)
+			assert.Equal(t, expected.Players, result.Players)
+			assert.Equal(t, expected.StopReason, result.StopReason)
+		})
+	}
+}
+
+func Test_Conformance_GetMLBPlayerSample(t *testing.T) {
+	sampling := e.MLBPlayerSampling{Seed: 7}
+	expected, err := NewCSVMLBPlayerRepository("../../data/mlb_players.csv").GetMLBPlayerSample(context.Background(), e.MLBPlayerIDParity{}, 12, 4, sampling)
+	assert.Nil(t, err)
+
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			repo := b.players(t, "../../data/mlb_players.csv")
+
+			result, err := repo.GetMLBPlayerSample(context.Background(), e.MLBPlayerIDParity{}, 12, 4, sampling)
+
+			assert.Nil(t, err)
+			assert.Equal(t, expected.Players, result.Players)
+		})
+	}
+}
+
+func Test_Conformance_CreateMLBPlayer(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			repo := b.players(t, "../../data/test/players-test.csv")
+			player := player3
+			player.ID = 0
+
+			created, err := repo.CreateMLBPlayer(context.Background(), player)
+
+			assert.Nil(t, err)
+			assert.Equal(t, &player3, created)
+
+			players, err := repo.GetMLBPlayers(context.Background())
+			assert.Nil(t, err)
+			assert.Equal(t, []e.MLBPlayer{player1, player2, player3}, players)
+		})
+	}
+}
+
+func Test_Conformance_UpdateMLBPlayer(t *testing.T) {
+	updated := player2
+	updated.Team = "NYY"
+	updated.Age = 35.5
+
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			repo := b.players(t, "../../data/test/players-test.csv")
+
+			player, err := repo.UpdateMLBPlayer(context.Background(), updated)
+			assert.Nil(t, err)
+			assert.Equal(t, &updated, player)
+
+			player, err = repo.UpdateMLBPlayer(context.Background(), player3)
+			assert.Nil(t, err)
+			assert.Nil(t, player)
+
+			players, err := repo.GetMLBPlayers(context.Background())
+			assert.Nil(t, err)
+			assert.Equal(t, []e.MLBPlayer{player1, updated}, players)
+		})
+	}
+}
+
+func Test_Conformance_DeleteMLBPlayer(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			repo := b.players(t, "../../data/test/players-test.csv")
+
+			player, err := repo.DeleteMLBPlayer(context.Background(), 1)
+			assert.Nil(t, err)
+			assert.Equal(t, &player1, player)
+
+			player, err = repo.DeleteMLBPlayer(context.Background(), 1)
+			assert.Nil(t, err)
+			assert.Nil(t, player)
+
+			players, err := repo.GetMLBPlayers(context.Background())
+			assert.Nil(t, err)
+			assert.Equal(t, []e.MLBPlayer{player2}, players)
+		})
+	}
+}
+
+func Test_Conformance_ImportMLBPlayers(t *testing.T) {
+	changed := player2
+	changed.Age = 35.5
+
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			repo := b.players(t, "../../data/test/players-test.csv")
+
+			report, err := repo.ImportMLBPlayers(context.Background(), []e.MLBPlayer{player3, changed}, e.ImportMerge)
+			assert.Nil(t, err)
+			assert.Equal(t, &e.MLBPlayerImportReport{Mode: e.ImportMerge, Inserted: 1, Updated: 1}, report)
+
+			players, err := repo.GetMLBPlayers(context.Background())
+			assert.Nil(t, err)
+			assert.Equal(t, []e.MLBPlayer{player1, changed, player3}, players)
+
+			report, err = repo.ImportMLBPlayers(context.Background(), []e.MLBPlayer{player4, player3}, e.ImportReplace)
+			assert.Nil(t, err)
+			assert.Equal(t, &e.MLBPlayerImportReport{Mode: e.ImportReplace, Inserted: 1, Updated: 1, Removed: 2}, report)
+
+			players, err = repo.GetMLBPlayers(context.Background())
+			assert.Nil(t, err)
+			assert.ElementsMatch(t, []e.MLBPlayer{player3, player4}, players)
+		})
+	}
+}
+
+func Test_Conformance_Users(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			repo := b.users(t, "../../data/test/users-test.csv")
+
+			users, err := repo.GetUsers(context.Background())
+			assert.Nil(t, err)
+			assert.Equal(t, []e.User{user1, user2}, users)
+
+			user, err := repo.GetUserByID(context.Background(), 3)
+			assert.Nil(t, err)
+			assert.Nil(t, user)
+
+			renamed := user2
+			renamed.LastName = "Bluth"
+			user3 := e.User{ID: 3, Email: "emma.wong@reqres.in", FirstName: "Emma", LastName: "Wong"}
+
+			report, err := repo.SaveUsers(context.Background(), []e.User{user3, renamed, user1})
+			assert.Nil(t, err)
+			assert.Equal(t, &e.UserSaveReport{Inserted: 1, Updated: 1, Unchanged: 1}, report)
+
+			users, err = repo.GetUsers(context.Background())
+			assert.Nil(t, err)
+			assert.Equal(t, []e.User{user1, renamed, user3}, users)
+
+			user, err = repo.GetUserByID(context.Background(), 2)
+			assert.Nil(t, err)
+			assert.Equal(t, &renamed, user)
+
+			report, err = repo.SaveUsers(context.Background(), []e.User{user3})
+			assert.Nil(t, err)
+			assert.Equal(t, &e.UserSaveReport{Unchanged: 1}, report)
+		})
+	}
+}
+
+func Test_Conformance_EachUser(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			repo := b.users(t, "../../data/test/users-test.csv")
+			var users []e.User
+
+			err := repo.EachUser(context.Background(), func(u e.User) error {
+				users = append(users, u)
+
+				return nil
+			})
+
+			assert.Nil(t, err)
+			assert.Equal(t, []e.User{user1, user2}, users)
+		})
+	}
+}
+
+func Test_Conformance_ShouldReturnContextError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			repo := b.players(t, "../../data/test/players-test.csv")
+
+			players, err := repo.GetMLBPlayers(ctx)
+
+			assert.Nil(t, players)
+			assert.Equal(t, context.Canceled, err)
+
+			player, err := repo.GetMLBPlayerByID(ctx, 1)
+
+			assert.Nil(t, player)
+			assert.Equal(t, context.Canceled, err)
+		})
+	}
+}
+
+// readableChecker is implemented by the repositories that can check their store.
+type readableChecker interface {
+	CheckReadable(ctx context.Context) error
+}
+
+// writableChecker is implemented by the repositories that can check their store accepts writes.
+type writableChecker interface {
+	CheckWritable(ctx context.Context) error
+}
+
+func Test_Conformance_HealthChecks(t *testing.T) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			players := b.players(t, "../../data/test/players-test.csv")
+			users := b.users(t, "../../data/test/users-test.csv")
+
+			assert.Nil(t, players.(readableChecker).CheckReadable(context.Background()))
+			assert.Nil(t, users.(readableChecker).CheckReadable(context.Background()))
+			assert.Nil(t, users.(writableChecker).CheckWritable(context.Background()))
+		})
+	}
+}
+
+func Test_CSV_HealthChecks_Suite(t *testing.T) {
+	testCases := []struct {
+		name          string
+		check         func(t *testing.T) error
+		expectedError string
+	}{
+		{
+			name: "Should not parse the rows of the players file",
+			check: func(t *testing.T) error {
+				return NewCSVMLBPlayerRepository(copyTestFile(t, "../../data/test/players-with-wrong-age-test.csv")).CheckReadable(context.Background())
+			},
+		},
+		{
+			name: "Should fail when the players file has another header",
+			check: func(t *testing.T) error {
+				return NewCSVMLBPlayerRepository(copyTestFile(t, "../../data/test/users-test.csv")).CheckReadable(context.Background())
+			},
+			expectedError: "error reading the file header",
+		},
+		{
+			name: "Should fail when the players file is missing",
+			check: func(t *testing.T) error {
+				return NewCSVMLBPlayerRepository(filepath.Join(t.TempDir(), "missing.csv")).CheckReadable(context.Background())
+			},
+			expectedError: "error opening the file",
+		},
+		{
+			name: "Should not parse the rows of the users file",
+			check: func(t *testing.T) error {
+				return NewCSVUserRepository(copyTestFile(t, "../../data/test/users-with-wrong-id-test.csv")).CheckReadable(context.Background())
+			},
+		},
+		{
+			name: "Should fail when the users file has another header",
+			check: func(t *testing.T) error {
+				return NewCSVUserRepository(copyTestFile(t, "../../data/test/players-test.csv")).CheckReadable(context.Background())
+			},
+			expectedError: "error reading the file header",
+		},
+		{
+			name: "Should read a users file not imported yet",
+			check: func(t *testing.T) error {
+				return NewCSVUserRepository(filepath.Join(t.TempDir(), "users.csv")).CheckReadable(context.Background())
+			},
+		},
+		{
+			name: "Should write a users file not imported yet",
+			check: func(t *testing.T) error {
+				return NewCSVUserRepository(filepath.Join(t.TempDir(), "users.csv")).CheckWritable(context.Background())
+			},
+		},
+		{
+			name: "Should fail when the users directory does not exist",
+			check: func(t *testing.T) error {
+				return NewCSVUserRepository(filepath.Join(t.TempDir(), "missing", "users.csv")).CheckWritable(context.Background())
+			},
+			expectedError: "error opening the file for writing",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.check(t)
+
+			if tc.expectedError == "" {
+				assert.Nil(t, err)
+
+				return
+			}
+			assert.EqualError(t, err, tc.expectedError)
+		})
+	}
+}
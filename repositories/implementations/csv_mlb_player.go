@@ -1,62 +1,69 @@
 package repositories
 
 import (
-	"encoding/csv"
+	"context"
 	"errors"
-	"fmt"
 	"io"
-	"math"
 	"os"
-	"strconv"
 	"sync"
+	"time"
 
+	"github.com/EloYaniel/academy-go-q42021/csvcodec"
 	e "github.com/EloYaniel/academy-go-q42021/entities"
 )
 
 // CSVMLBPlayerRepository struct implements MLBPlayerRepository interface
 type CSVMLBPlayerRepository struct {
-	filePath string
+	filePath   string
+	m          sync.Mutex
+	observer   Observer
+	quarantine *Quarantine
 }
 
 // NewCSVMLBPlayerRepository function creates a new instance of type CSVMLBPlayerRepository.
 func NewCSVMLBPlayerRepository(filePath string) *CSVMLBPlayerRepository {
-	return &CSVMLBPlayerRepository{filePath: filePath}
+	return &CSVMLBPlayerRepository{filePath: filePath, observer: nopObserver{}}
 }
 
-// GetMLBPlayers gets all MLB Players from the file.
-func (repo *CSVMLBPlayerRepository) GetMLBPlayers() ([]e.MLBPlayer, error) {
-	f, err := os.Open(repo.filePath)
+// WithObserver sets the observer notified of the reads of the file.
+func (repo *CSVMLBPlayerRepository) WithObserver(observer Observer) *CSVMLBPlayerRepository {
+	repo.observer = observer
 
-	if err != nil {
-		return nil, errors.New("error opening the file")
-	}
-	defer f.Close()
-	data, err := csv.NewReader(f).ReadAll()
+	return repo
+}
 
-	if err != nil {
-		return nil, errors.New("error reading the file")
-	}
+// WithQuarantine makes the reads lenient, skipping the malformed rows into quarantine instead of failing.
+func (repo *CSVMLBPlayerRepository) WithQuarantine(quarantine *Quarantine) *CSVMLBPlayerRepository {
+	repo.quarantine = quarantine
+
+	return repo
+}
+
+// GetMLBPlayers gets all MLB Players from the file.
+func (repo *CSVMLBPlayerRepository) GetMLBPlayers(ctx context.Context) ([]e.MLBPlayer, error) {
 	var players []e.MLBPlayer
-	for i, line := range data {
-		if i != 0 {
-			player, err := parsePlayer(line)
+	err := repo.eachPlayer(ctx, func(p e.MLBPlayer) error {
+		players = append(players, p)
 
-			if err != nil {
-				return nil, err
-			}
+		return nil
+	})
 
-			players = append(players, *player)
-		}
+	if err != nil {
+		return nil, err
 	}
 
 	return players, nil
 }
 
 // GetMLBPlayerByID get a Player by its ID
-func (repo *CSVMLBPlayerRepository) GetMLBPlayerByID(id int) (*e.MLBPlayer, error) {
-	players, err := repo.GetMLBPlayers()
+func (repo *CSVMLBPlayerRepository) GetMLBPlayerByID(ctx context.Context, id int) (*e.MLBPlayer, error) {
+	players, err := repo.GetMLBPlayers(ctx)
 
 	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
 		return nil, errors.New("error getting player")
 	}
 
@@ -70,130 +77,301 @@ func (repo *CSVMLBPlayerRepository) GetMLBPlayerByID(id int) (*e.MLBPlayer, erro
 }
 
 // GetMLBPlayerDesired gets MLB Players from the file concurrently and filetered by its params.
-func (repo *CSVMLBPlayerRepository) GetMLBPlayerDesired(filterType string, totalItems int, itemsPerWorker int) ([]e.MLBPlayer, error) {
-	f, err := os.Open(repo.filePath)
+func (repo *CSVMLBPlayerRepository) GetMLBPlayerDesired(ctx context.Context, predicate e.MLBPlayerPredicate, totalItems int, itemsPerWorker int) (*e.MLBPlayerDesiredResult, error) {
+	next, done, err := repo.desiredRows()
 
 	if err != nil {
-		return nil, errors.New("error opening the file")
+		return nil, err
 	}
-	defer f.Close()
+	defer done()
 
-	reader := csv.NewReader(f)
-	reader.Read()
-	m := new(sync.Mutex)
-	jobs := make(chan int)
-	intermediateChan := make(chan int)
-	workersCount := totalItems / itemsPerWorker
-	done := make(chan struct{})
-	wg := new(sync.WaitGroup)
-	totalItemsCount := 1
+	return runDesiredPool(ctx, next, predicate, totalItems, itemsPerWorker, repo.observer)
+}
 
-	go func() {
+// GetMLBPlayerSample gets a random sample of the MLB Players matching predicate reading the whole file concurrently.
+func (repo *CSVMLBPlayerRepository) GetMLBPlayerSample(ctx context.Context, predicate e.MLBPlayerPredicate, totalItems int, itemsPerWorker int, sampling e.MLBPlayerSampling) (*e.MLBPlayerDesiredResult, error) {
+	next, done, err := repo.desiredRows()
+
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	return runSamplePool(ctx, next, predicate, totalItems, itemsPerWorker, sampling, repo.observer)
+}
+
+// desiredRows opens the file past its header for a concurrent read, done closes it.
+func (repo *CSVMLBPlayerRepository) desiredRows() (next func() ([]string, error), done func() error, err error) {
+	version := repo.quarantine.begin("mlb_players", repo.filePath)
+	f, err := os.Open(repo.filePath)
+
+	if err != nil {
+		return nil, nil, errors.New("error opening the file")
+	}
+	var parse func(record []string) (interface{}, error)
+
+	// In strict mode the workers parse the rows, in lenient mode they are parsed here to quarantine the malformed ones.
+	if repo.quarantine != nil {
+		parse = parsePlayerRow
+	}
+	rows := newCSVRows("mlb_players", version, repo.quarantine, f, parse)
+	next = func() ([]string, error) {
 		for {
-			select {
-			case ev := <-intermediateChan:
-				jobs <- ev
-			case <-done:
-				close(jobs)
-				return
+			row, err := rows.next()
+
+			if err != nil {
+				return nil, err
+			}
+
+			if parse == nil || row.value != nil {
+				return row.record, nil
 			}
 		}
-	}()
+	}
 
-	wg.Add(workersCount)
-	var players []e.MLBPlayer
+	return next, f.Close, nil
+}
 
-	for i := 0; i < workersCount; i++ {
-		go func(workerID int, wg *sync.WaitGroup) {
-			defer wg.Done()
-			itemsCount := 0
-			for j := range jobs {
-				if totalItemsCount > totalItems {
-					return
-				}
-
-				p, err := job(j, filterType, m, reader)
-
-				if err != nil {
-					done <- struct{}{}
-				}
-
-				if p != nil {
-					players = append(players, *p)
-					totalItemsCount++
-					itemsCount++
-				}
-
-				if itemsCount == itemsPerWorker {
-					return
-				}
-			}
-		}(i, wg)
+// CreateMLBPlayer saves a new Player to the file allocating its ID.
+func (repo *CSVMLBPlayerRepository) CreateMLBPlayer(ctx context.Context, player e.MLBPlayer) (*e.MLBPlayer, error) {
+	repo.m.Lock()
+	defer repo.m.Unlock()
+	rows, err := repo.readRows(ctx)
+
+	if err != nil {
+		return nil, err
 	}
+	player.ID = nextRowID(rows)
+	err = repo.writePlayers(append(rows, playerRow(player)))
 
-	go func() {
-		for i := 1; i <= totalItems; i++ {
-			intermediateChan <- i
-		}
-	}()
-	wg.Wait()
+	if err != nil {
+		return nil, err
+	}
 
-	return players, nil
+	return &player, nil
 }
 
-func job(jobID int, filter string, m *sync.Mutex, reader *csv.Reader) (*e.MLBPlayer, error) {
-	m.Lock()
-	data, err := reader.Read()
-	m.Unlock()
-	if err == io.EOF {
-		return nil, errors.New(fmt.Sprint("JOB", jobID, "reached end of file"))
-	}
+// UpdateMLBPlayer replaces the Player with the same ID in the file, nil if it does not exist.
+func (repo *CSVMLBPlayerRepository) UpdateMLBPlayer(ctx context.Context, player e.MLBPlayer) (*e.MLBPlayer, error) {
+	repo.m.Lock()
+	defer repo.m.Unlock()
+	rows, err := repo.readRows(ctx)
 
-	id, err := strconv.Atoi(data[0])
 	if err != nil {
-		return nil, errors.New("error casting ID")
+		return nil, err
 	}
 
-	if (math.Remainder(float64(id), 2) == 0) != (filter == "even") {
-		m.Lock()
-		data, err = reader.Read()
-		m.Unlock()
-		if err == io.EOF {
-			return nil, errors.New(fmt.Sprint("JOB", jobID, "reached end of file"))
+	for i, row := range rows {
+		if row.value != nil && row.id == player.ID {
+			rows[i] = playerRow(player)
+			err = repo.writePlayers(rows)
+
+			if err != nil {
+				return nil, err
+			}
+
+			return &player, nil
 		}
 	}
 
-	return parsePlayer(data)
+	return nil, nil
 }
 
-func parsePlayer(line []string) (*e.MLBPlayer, error) {
-	id, err := strconv.Atoi(line[0])
+// DeleteMLBPlayer deletes a Player by its ID from the file, nil if it does not exist.
+func (repo *CSVMLBPlayerRepository) DeleteMLBPlayer(ctx context.Context, id int) (*e.MLBPlayer, error) {
+	repo.m.Lock()
+	defer repo.m.Unlock()
+	rows, err := repo.readRows(ctx)
 
 	if err != nil {
-		return nil, errors.New("error casting ID")
+		return nil, err
 	}
-	height, err := strconv.Atoi(line[4])
 
-	if err != nil {
-		return nil, errors.New("error casting Height")
+	for i, row := range rows {
+		if row.value != nil && row.id == id {
+			err = repo.writePlayers(append(rows[:i:i], rows[i+1:]...))
+
+			if err != nil {
+				return nil, err
+			}
+
+			return row.value.(*e.MLBPlayer), nil
+		}
 	}
-	weight, err := strconv.ParseFloat(line[5], 32)
-	if err != nil {
-		return nil, errors.New("error casting Weight")
+
+	return nil, nil
+}
+
+// ImportMLBPlayers writes players to the file in a single atomic replace. In merge mode the stored players
+// keep their order and the new ones are appended, a player with the ID of a quarantined row takes its place.
+// A replace drops the quarantined rows too and does not need the stored file to be readable.
+func (repo *CSVMLBPlayerRepository) ImportMLBPlayers(ctx context.Context, players []e.MLBPlayer, mode e.MLBPlayerImportMode) (*e.MLBPlayerImportReport, error) {
+	repo.m.Lock()
+	defer repo.m.Unlock()
+	rows, err := repo.readRows(ctx)
+
+	if err != nil && (mode != e.ImportReplace || ctx.Err() != nil) {
+		return nil, err
+	}
+	var stored []e.MLBPlayer
+	for _, row := range rows {
+		if row.value != nil {
+			stored = append(stored, *row.value.(*e.MLBPlayer))
+		}
+	}
+	_, report := mergeImportedPlayers(stored, players, mode)
+	imported := make([]csvRow, 0, len(players))
+	for _, p := range players {
+		imported = append(imported, playerRow(p))
 	}
-	age, err := strconv.ParseFloat(line[6], 32)
+
+	if mode == e.ImportReplace {
+		report.Removed += len(rows) - len(stored)
+		rows = nil
+	}
+
+	if err = repo.writePlayers(upsertRows(rows, imported)); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// CheckReadable checks the file can be opened and has the header of the players, without parsing the rows.
+func (repo *CSVMLBPlayerRepository) CheckReadable(ctx context.Context) error {
+	return checkCSVHeader(repo.filePath, csvcodec.PlayerHeader)
+}
+
+// Close waits for the write in progress, if any, to finish.
+func (repo *CSVMLBPlayerRepository) Close(ctx context.Context) error {
+	return waitForWrites(ctx, &repo.m)
+}
+
+// mergeImportedPlayers builds the players stored after an import and counts the inserted, updated and removed ones.
+func mergeImportedPlayers(stored []e.MLBPlayer, imported []e.MLBPlayer, mode e.MLBPlayerImportMode) ([]e.MLBPlayer, *e.MLBPlayerImportReport) {
+	report := &e.MLBPlayerImportReport{Mode: mode}
+	byID := make(map[int]int, len(stored))
+	for i, p := range stored {
+		byID[p.ID] = i
+	}
+
+	if mode == e.ImportReplace {
+		seen := make(map[int]bool, len(imported))
+		for _, p := range imported {
+			seen[p.ID] = true
+
+			if _, ok := byID[p.ID]; ok {
+				report.Updated++
+			} else {
+				report.Inserted++
+			}
+		}
+		for _, p := range stored {
+			if !seen[p.ID] {
+				report.Removed++
+			}
+		}
+
+		return imported, report
+	}
+	merged := append([]e.MLBPlayer(nil), stored...)
+	for _, p := range imported {
+		if i, ok := byID[p.ID]; ok {
+			merged[i] = p
+			report.Updated++
+
+			continue
+		}
+		byID[p.ID] = len(merged)
+		merged = append(merged, p)
+		report.Inserted++
+	}
+
+	return merged, report
+}
+
+// readRows reads every row of the file in order, the quarantined ones are kept as they are in the file.
+func (repo *CSVMLBPlayerRepository) readRows(ctx context.Context) (rows []csvRow, err error) {
+	defer func(started time.Time) {
+		repo.observer.ObserveRead("mlb_players", time.Since(started), err)
+	}(time.Now())
+
+	return readCSVRows(ctx, "mlb_players", repo.filePath, repo.quarantine, parsePlayerRow)
+}
+
+// writePlayers replaces the file with rows. The rows are written to a temporary file
+// that is renamed over the original one, so readers never see a half written file.
+func (repo *CSVMLBPlayerRepository) writePlayers(rows []csvRow) error {
+	return writeFileAtomically(repo.filePath, csvcodec.PlayerHeader, rows, func(value interface{}) []string {
+		return csvcodec.PlayerRecord(*value.(*e.MLBPlayer))
+	})
+}
+
+func parsePlayerRow(record []string) (interface{}, error) {
+	return csvcodec.ParsePlayer(record)
+}
+
+func playerRow(p e.MLBPlayer) csvRow {
+	return csvRow{id: p.ID, value: &p}
+}
+
+// EachMLBPlayer streams the MLB Players of the file in order, stopping at the first error of fn.
+func (repo *CSVMLBPlayerRepository) EachMLBPlayer(ctx context.Context, fn func(p e.MLBPlayer) error) error {
+	return repo.eachPlayer(ctx, fn)
+}
+
+// eachPlayer streams the file row by row calling fn for every parsed player until ctx is done or fn fails.
+func (repo *CSVMLBPlayerRepository) eachPlayer(ctx context.Context, fn func(p e.MLBPlayer) error) (err error) {
+	defer func(started time.Time) {
+		repo.observer.ObserveRead("mlb_players", time.Since(started), err)
+	}(time.Now())
+	version := repo.quarantine.begin("mlb_players", repo.filePath)
+	f, err := os.Open(repo.filePath)
 
 	if err != nil {
-		return nil, errors.New("error casting Age")
-	}
-
-	return &e.MLBPlayer{
-		ID:       id,
-		Name:     line[1],
-		Team:     line[2],
-		Position: line[3],
-		Height:   height,
-		Weight:   float32(weight),
-		Age:      float32(age),
-	}, nil
+		return errors.New("error opening the file")
+	}
+	defer f.Close()
+	rows := newCSVRows("mlb_players", version, repo.quarantine, f, parsePlayerRow)
+
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		row, err := rows.next()
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if row.value == nil {
+			continue
+		}
+
+		if err = fn(*row.value.(*e.MLBPlayer)); err != nil {
+			return err
+		}
+	}
+}
+
+// waitForWrites blocks until m is free or ctx is done.
+func waitForWrites(ctx context.Context, m *sync.Mutex) error {
+	done := make(chan struct{})
+
+	go func() {
+		m.Lock()
+		defer m.Unlock()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
@@ -1,8 +1,11 @@
 package repositories
 
 import (
+	"context"
 	"errors"
+	"sync"
 	"testing"
+	"time"
 
 	e "github.com/EloYaniel/academy-go-q42021/entities"
 	"github.com/stretchr/testify/assert"
@@ -91,7 +94,7 @@ func Test_GetMLBPlayers_Suite(t *testing.T) {
 
 			repo := NewCSVMLBPlayerRepository(tc.filePath)
 
-			users, err := repo.GetMLBPlayers()
+			users, err := repo.GetMLBPlayers(context.Background())
 
 			assert.Equal(t, tc.expectedResponse, users)
 			assert.Equal(t, tc.expectedError, err)
@@ -142,7 +145,7 @@ func Test_GetPlayerByID_Suite(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			repo := NewCSVMLBPlayerRepository(tc.filePath)
 
-			player, err := repo.GetMLBPlayerByID(tc.playerID)
+			player, err := repo.GetMLBPlayerByID(context.Background(), tc.playerID)
 
 			assert.Equal(t, tc.expectedResponse, player)
 			assert.Equal(t, tc.expectedError, err)
@@ -150,79 +153,117 @@ func Test_GetPlayerByID_Suite(t *testing.T) {
 	}
 }
 
+var player3 = e.MLBPlayer{
+	ID:       3,
+	Name:     "Ramon Hernandez",
+	Team:     "BAL",
+	Position: "Catcher",
+	Height:   72,
+	Weight:   210,
+	Age:      30.78,
+}
+
+var player4 = e.MLBPlayer{
+	ID:       4,
+	Name:     "Kevin Millar",
+	Team:     "BAL",
+	Position: "First Baseman",
+	Height:   72,
+	Weight:   210,
+	Age:      35.43,
+}
+
+func mustCondition(t *testing.T, field string, operator e.PredicateOperator, value string) *e.MLBPlayerCondition {
+	condition, err := e.NewMLBPlayerCondition(field, operator, value)
+	assert.Nil(t, err)
+
+	return condition
+}
+
 func Test_GetMLBPlayerDesired_Suite(t *testing.T) {
 	testCases := []struct {
-		name             string
-		filter           string
-		totalItems       int
-		itemsPerWorker   int
-		filePath         string
-		expectedError    error
-		expectedResponse []e.MLBPlayer
+		name               string
+		filter             e.MLBPlayerPredicate
+		totalItems         int
+		itemsPerWorker     int
+		filePath           string
+		expectedError      error
+		expectedPlayers    []e.MLBPlayer
+		expectedStopReason e.StopReason
+		expectedWorkers    int
 	}{
 		{
-			name:           "Should return players with odd ID",
-			filePath:       "../../data/mlb_players.csv",
-			filter:         "odd",
-			itemsPerWorker: 1,
-			totalItems:     2,
-			expectedResponse: []e.MLBPlayer{
-				{
-					ID:       1,
-					Name:     "Adam Donachie",
-					Team:     "BAL",
-					Position: "Catcher",
-					Height:   74,
-					Weight:   180,
-					Age:      22.99,
-				},
-				{
-
-					ID:       3,
-					Name:     "Ramon Hernandez",
-					Team:     "BAL",
-					Position: "Catcher",
-					Height:   72,
-					Weight:   210,
-					Age:      30.78,
-				},
-			},
-			expectedError: nil,
-		},
-		{
-			name:           "Should return players with even ID",
-			filePath:       "../../data/mlb_players.csv",
-			filter:         "even",
-			itemsPerWorker: 1,
-			totalItems:     2,
-			expectedResponse: []e.MLBPlayer{
-				{
-					ID:       2,
-					Name:     "Paul Bako",
-					Team:     "BAL",
-					Position: "Catcher",
-					Height:   74,
-					Weight:   215,
-					Age:      34.69,
-				},
-				{
-
-					ID:       4,
-					Name:     "Kevin Millar",
-					Team:     "BAL",
-					Position: "First Baseman",
-					Height:   72,
-					Weight:   210,
-					Age:      35.43,
-				},
+			name:               "Should return players with odd ID",
+			filePath:           "../../data/mlb_players.csv",
+			filter:             e.MLBPlayerIDParity{},
+			itemsPerWorker:     1,
+			totalItems:         2,
+			expectedPlayers:    []e.MLBPlayer{player1, player3},
+			expectedStopReason: e.StopReasonItemsReached,
+			expectedWorkers:    2,
+			expectedError:      nil,
+		},
+		{
+			name:               "Should return players with even ID",
+			filePath:           "../../data/mlb_players.csv",
+			filter:             e.MLBPlayerIDParity{Even: true},
+			itemsPerWorker:     1,
+			totalItems:         2,
+			expectedPlayers:    []e.MLBPlayer{player2, player4},
+			expectedStopReason: e.StopReasonItemsReached,
+			expectedWorkers:    2,
+			expectedError:      nil,
+		},
+		{
+			name:     "Should return players matching the predicate",
+			filePath: "../../data/mlb_players.csv",
+			filter: e.MLBPlayerOr{
+				e.MLBPlayerAnd{mustCondition(t, "team", e.OpEqual, "BAL"), mustCondition(t, "age", e.OpGreater, "35")},
+				mustCondition(t, "name", e.OpContains, "donachie"),
 			},
-			expectedError: nil,
+			itemsPerWorker:     1,
+			totalItems:         2,
+			expectedPlayers:    []e.MLBPlayer{player1, player4},
+			expectedStopReason: e.StopReasonItemsReached,
+			expectedWorkers:    2,
+			expectedError:      nil,
 		},
 		{
-			name:             "Should return error when open file",
-			filePath:         "",
-			expectedResponse: nil,
-			expectedError:    errors.New("error opening the file"),
+			name:               "Should stop when workers reached the limit",
+			filePath:           "../../data/mlb_players.csv",
+			filter:             e.MLBPlayerIDParity{Even: true},
+			itemsPerWorker:     2,
+			totalItems:         5,
+			expectedPlayers:    []e.MLBPlayer{player2, player4, {ID: 6, Name: "Brian Roberts", Team: "BAL", Position: "Second Baseman", Height: 69, Weight: 176, Age: 29.39}, {ID: 8, Name: "Melvin Mora", Team: "BAL", Position: "Third Baseman", Height: 71, Weight: 200, Age: 35.07}},
+			expectedStopReason: e.StopReasonWorkersLimit,
+			expectedWorkers:    2,
+			expectedError:      nil,
+		},
+		{
+			name:               "Should stop at end of file",
+			filePath:           "../../data/test/players-test.csv",
+			filter:             e.MLBPlayerIDParity{},
+			itemsPerWorker:     1,
+			totalItems:         5,
+			expectedPlayers:    []e.MLBPlayer{player1},
+			expectedStopReason: e.StopReasonEOF,
+			expectedWorkers:    5,
+			expectedError:      nil,
+		},
+		{
+			name:            "Should return error when casting ID",
+			filePath:        "../../data/test/players-with-wrong-id-test.csv",
+			filter:          e.MLBPlayerIDParity{},
+			itemsPerWorker:  1,
+			totalItems:      2,
+			expectedPlayers: nil,
+			expectedError:   errors.New("error casting ID"),
+		},
+		{
+			name:            "Should return error when open file",
+			filePath:        "",
+			expectedPlayers: nil,
+			expectedError:   errors.New("error opening the file"),
 		},
 	}
 
@@ -231,10 +272,200 @@ func Test_GetMLBPlayerDesired_Suite(t *testing.T) {
 
 			repo := NewCSVMLBPlayerRepository(tc.filePath)
 
-			users, err := repo.GetMLBPlayerDesired(tc.filter, tc.totalItems, tc.itemsPerWorker)
+			result, err := repo.GetMLBPlayerDesired(context.Background(), tc.filter, tc.totalItems, tc.itemsPerWorker)
 
-			assert.Equal(t, tc.expectedResponse, users)
 			assert.Equal(t, tc.expectedError, err)
+
+			if tc.expectedError != nil {
+				assert.Nil(t, result)
+
+				return
+			}
+			assert.Equal(t, tc.expectedPlayers, result.Players)
+			assert.Equal(t, tc.expectedStopReason, result.StopReason)
+			assert.Len(t, result.Workers, tc.expectedWorkers)
+
+			items := 0
+			for _, w := range result.Workers {
+				assert.LessOrEqual(t, w.Items, tc.itemsPerWorker)
+				assert.LessOrEqual(t, w.Items, w.RowsRead)
+				items += w.Items
+			}
+			assert.Equal(t, len(result.Players), items)
+		})
+	}
+}
+
+func Test_GetMLBPlayerDesired_ShouldBeDeterministic(t *testing.T) {
+	repo := NewCSVMLBPlayerRepository("../../data/mlb_players.csv")
+
+	expected, err := repo.GetMLBPlayerDesired(context.Background(), e.MLBPlayerIDParity{}, 40, 7)
+	assert.Nil(t, err)
+
+	for i := 0; i < 20; i++ {
+		result, err := repo.GetMLBPlayerDesired(context.Background(), e.MLBPlayerIDParity{}, 40, 7)
+
+		assert.Nil(t, err)
+		assert.Equal(t, expected.Players, result.Players)
+		assert.Equal(t, expected.StopReason, result.StopReason)
+	}
+	assert.Equal(t, e.StopReasonWorkersLimit, expected.StopReason)
+	assert.Len(t, expected.Players, 35)
+}
+
+func Test_CreateMLBPlayer_ShouldAllocateID(t *testing.T) {
+	filePath := copyTestFile(t, "../../data/test/players-test.csv")
+	repo := NewCSVMLBPlayerRepository(filePath)
+	player := player3
+	player.ID = 0
+
+	created, err := repo.CreateMLBPlayer(context.Background(), player)
+
+	assert.Nil(t, err)
+	assert.Equal(t, &player3, created)
+
+	players, err := repo.GetMLBPlayers(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, []e.MLBPlayer{player1, player2, player3}, players)
+}
+
+func Test_CreateMLBPlayer_ShouldReturnErrorWhenOpenFile(t *testing.T) {
+	repo := NewCSVMLBPlayerRepository("")
+
+	created, err := repo.CreateMLBPlayer(context.Background(), player1)
+
+	assert.Nil(t, created)
+	assert.Equal(t, errors.New("error opening the file"), err)
+}
+
+func Test_UpdateMLBPlayer_Suite(t *testing.T) {
+	updated := player2
+	updated.Team = "NYY"
+	updated.Age = 35.5
+	testCases := []struct {
+		name             string
+		player           e.MLBPlayer
+		expectedResponse *e.MLBPlayer
+		expectedPlayers  []e.MLBPlayer
+	}{
+		{
+			name:             "Should update the player",
+			player:           updated,
+			expectedResponse: &updated,
+			expectedPlayers:  []e.MLBPlayer{player1, updated},
+		},
+		{
+			name:             "Should return no player if not found",
+			player:           player3,
+			expectedResponse: nil,
+			expectedPlayers:  []e.MLBPlayer{player1, player2},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := NewCSVMLBPlayerRepository(copyTestFile(t, "../../data/test/players-test.csv"))
+
+			player, err := repo.UpdateMLBPlayer(context.Background(), tc.player)
+
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedResponse, player)
+
+			players, err := repo.GetMLBPlayers(context.Background())
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedPlayers, players)
+		})
+	}
+}
+
+func Test_DeleteMLBPlayer_Suite(t *testing.T) {
+	testCases := []struct {
+		name             string
+		playerID         int
+		expectedResponse *e.MLBPlayer
+		expectedPlayers  []e.MLBPlayer
+	}{
+		{
+			name:             "Should delete the player",
+			playerID:         1,
+			expectedResponse: &player1,
+			expectedPlayers:  []e.MLBPlayer{player2},
+		},
+		{
+			name:             "Should return no player if not found",
+			playerID:         3,
+			expectedResponse: nil,
+			expectedPlayers:  []e.MLBPlayer{player1, player2},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := NewCSVMLBPlayerRepository(copyTestFile(t, "../../data/test/players-test.csv"))
+
+			player, err := repo.DeleteMLBPlayer(context.Background(), tc.playerID)
+
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedResponse, player)
+
+			players, err := repo.GetMLBPlayers(context.Background())
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedPlayers, players)
 		})
 	}
 }
+
+func Test_CSVMLBPlayerRepository_ConcurrentWritesShouldNotCorruptReads(t *testing.T) {
+	repo := NewCSVMLBPlayerRepository(copyTestFile(t, "../../data/test/players-test.csv"))
+	wg := new(sync.WaitGroup)
+	player := player3
+	player.ID = 0
+
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := repo.CreateMLBPlayer(context.Background(), player)
+			assert.Nil(t, err)
+		}()
+		go func() {
+			defer wg.Done()
+			players, err := repo.GetMLBPlayers(context.Background())
+			assert.Nil(t, err)
+			assert.GreaterOrEqual(t, len(players), 2)
+		}()
+	}
+	wg.Wait()
+
+	players, err := repo.GetMLBPlayers(context.Background())
+	assert.Nil(t, err)
+	assert.Len(t, players, 12)
+	assert.Equal(t, 12, players[11].ID)
+}
+
+func Test_CSVMLBPlayerRepository_CloseShouldWaitForWrites(t *testing.T) {
+	repo := NewCSVMLBPlayerRepository(copyTestFile(t, "../../data/test/players-test.csv"))
+	repo.m.Lock()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	assert.Equal(t, context.DeadlineExceeded, repo.Close(ctx))
+
+	repo.m.Unlock()
+	assert.Nil(t, repo.Close(context.Background()))
+}
+
+func Test_ImportMLBPlayers_ShouldReplaceUnreadableFile(t *testing.T) {
+	repo := NewCSVMLBPlayerRepository(copyTestFile(t, "../../data/test/players-with-wrong-height-test.csv"))
+
+	report, err := repo.ImportMLBPlayers(context.Background(), []e.MLBPlayer{player1}, e.ImportReplace)
+	assert.Nil(t, err)
+	assert.Equal(t, &e.MLBPlayerImportReport{Mode: e.ImportReplace, Inserted: 1}, report)
+
+	_, err = repo.ImportMLBPlayers(context.Background(), []e.MLBPlayer{player2}, e.ImportMerge)
+	assert.Nil(t, err)
+
+	players, err := repo.GetMLBPlayers(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, []e.MLBPlayer{player1, player2}, players)
+}
@@ -0,0 +1,279 @@
+package repositories
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// csvRow struct is a data row of a CSV file. A quarantined row has no value and keeps the bytes read from the file,
+// so rewriting the file does not lose it. id is the ID in the first field, 0 when it has none.
+type csvRow struct {
+	id     int
+	record []string
+	value  interface{}
+	raw    []byte
+}
+
+// csvRows struct reads the data rows of a CSV file, skipping its header: the record that starts at line 1.
+// In lenient mode the malformed rows are quarantined, otherwise the first one fails the read.
+type csvRows struct {
+	source     string
+	version    fileVersion
+	quarantine *Quarantine
+	reader     *csv.Reader
+	parse      func(record []string) (interface{}, error)
+	content    []byte
+}
+
+// newCSVRows creates a reader of the rows of source read from r. When parse is nil the records are not parsed.
+func newCSVRows(source string, version fileVersion, quarantine *Quarantine, r io.Reader, parse func(record []string) (interface{}, error)) *csvRows {
+	return &csvRows{source: source, version: version, quarantine: quarantine, reader: csv.NewReader(r), parse: parse}
+}
+
+// next reads the next data row, io.EOF after the last one. Quarantined rows are returned without value,
+// with their raw bytes when the content of the file was given.
+func (rows *csvRows) next() (csvRow, error) {
+	for {
+		start := rows.reader.InputOffset()
+		record, err := rows.reader.Read()
+
+		if err == io.EOF {
+			return csvRow{}, err
+		}
+		var parseErr *csv.ParseError
+		line := 0
+
+		if errors.As(err, &parseErr) {
+			line = parseErr.StartLine
+		} else if err == nil {
+			line, _ = rows.reader.FieldPos(0)
+		}
+
+		if err != nil && !rows.quarantine.addReadError(rows.source, rows.version, record, err) {
+			return csvRow{}, errors.New("error reading the file")
+		}
+
+		if line == 1 {
+			if err != nil {
+				// The fields of a malformed header do not tell the number of fields of the rows.
+				rows.reader.FieldsPerRecord = 0
+			}
+
+			continue
+		}
+		row := csvRow{id: rowID(record), record: record}
+
+		if err == nil && rows.parse != nil {
+			row.value, err = rows.parse(record)
+
+			if err != nil {
+				row.value = nil
+
+				if !rows.quarantine.addParseError(rows.source, rows.version, line, err) {
+					return csvRow{}, plainError(err)
+				}
+			}
+		}
+
+		if err != nil && rows.content != nil {
+			row.raw = rows.content[start:rows.reader.InputOffset()]
+		}
+
+		return row, nil
+	}
+}
+
+// readCSVRows reads every data row of the file of source in order, keeping the raw bytes of the quarantined ones.
+func readCSVRows(ctx context.Context, source string, filePath string, quarantine *Quarantine, parse func(record []string) (interface{}, error)) ([]csvRow, error) {
+	version := quarantine.begin(source, filePath)
+	content, err := os.ReadFile(filePath)
+
+	if err != nil {
+		return nil, errors.New("error opening the file")
+	}
+	rows := newCSVRows(source, version, quarantine, bytes.NewReader(content), parse)
+	rows.content = content
+	var all []csvRow
+
+	for {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		row, err := rows.next()
+
+		if err == io.EOF {
+			return all, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, row)
+	}
+}
+
+// checkCSVHeader opens the file and checks its first record is header, without reading the rows. An empty file has no header to check.
+func checkCSVHeader(filePath string, header []string) error {
+	f, err := os.Open(filePath)
+
+	if err != nil {
+		return errors.New("error opening the file")
+	}
+	defer f.Close()
+	record, err := csv.NewReader(f).Read()
+
+	if err == io.EOF {
+		return nil
+	}
+
+	if err != nil || len(record) != len(header) {
+		return errors.New("error reading the file header")
+	}
+
+	for i, field := range record {
+		if !strings.EqualFold(strings.TrimSpace(field), header[i]) {
+			return errors.New("error reading the file header")
+		}
+	}
+
+	return nil
+}
+
+// rowID reads the ID in the first field of record, 0 when it is not a number.
+func rowID(record []string) int {
+	if len(record) == 0 {
+		return 0
+	}
+	id, err := strconv.Atoi(strings.TrimSpace(record[0]))
+
+	if err != nil {
+		return 0
+	}
+
+	return id
+}
+
+// nextRowID allocates the ID following the greatest ID of rows, quarantined rows included.
+func nextRowID(rows []csvRow) int {
+	id := 1
+	for _, row := range rows {
+		if row.id >= id {
+			id = row.id + 1
+		}
+	}
+
+	return id
+}
+
+// upsertRows replaces the row with the ID of every row of updates, a good row or else a quarantined one,
+// and appends the ones with a new ID. The rows keep their order.
+func upsertRows(rows []csvRow, updates []csvRow) []csvRow {
+	rows = append([]csvRow(nil), rows...)
+	good := make(map[int]int, len(rows))
+	quarantined := make(map[int]int)
+	for i, row := range rows {
+		if row.value != nil {
+			good[row.id] = i
+		} else if _, ok := quarantined[row.id]; !ok && row.id != 0 {
+			quarantined[row.id] = i
+		}
+	}
+
+	for _, update := range updates {
+		i, ok := good[update.id]
+
+		if !ok {
+			i, ok = quarantined[update.id]
+			delete(quarantined, update.id)
+		}
+
+		if !ok {
+			i = len(rows)
+			rows = append(rows, update)
+		}
+		rows[i] = update
+		good[update.id] = i
+	}
+
+	return rows
+}
+
+// writeFileAtomically writes header and rows to a temporary file next to filePath and renames it over filePath.
+// The good rows are written with encode and the quarantined ones as they were read.
+func writeFileAtomically(filePath string, header []string, rows []csvRow, encode func(value interface{}) []string) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(filePath), filepath.Base(filePath)+".*.tmp")
+
+	if err != nil {
+		return errors.New("error creating the file")
+	}
+	defer os.Remove(tmp.Name())
+	mode := os.FileMode(0644)
+
+	if info, err := os.Stat(filePath); err == nil {
+		mode = info.Mode().Perm()
+	}
+	writer := csv.NewWriter(tmp)
+	err = writer.Write(header)
+
+	for _, row := range rows {
+		if err != nil {
+			break
+		}
+
+		if row.value != nil {
+			err = writer.Write(encode(row.value))
+
+			continue
+		}
+		writer.Flush()
+
+		if err = writer.Error(); err == nil {
+			_, err = tmp.Write(withNewline(row.raw))
+		}
+	}
+	writer.Flush()
+
+	if err == nil {
+		err = writer.Error()
+	}
+
+	if err == nil {
+		err = tmp.Chmod(mode)
+	}
+
+	if err == nil {
+		err = tmp.Sync()
+	}
+
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return errors.New("error writing the file")
+	}
+
+	if err := os.Rename(tmp.Name(), filePath); err != nil {
+		return errors.New("error writing the file")
+	}
+	countFileWrite(filePath)
+
+	return nil
+}
+
+// withNewline ends raw with a line break, the last row of a file may not have one.
+func withNewline(raw []byte) []byte {
+	if len(raw) > 0 && raw[len(raw)-1] == '\n' {
+		return raw
+	}
+
+	return append(append([]byte(nil), raw...), '\n')
+}
@@ -1,98 +1,148 @@
 package repositories
 
 import (
-	"encoding/csv"
+	"context"
 	"errors"
-	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
-	"strconv"
+	"path/filepath"
+	"sync"
+	"time"
 
+	"github.com/EloYaniel/academy-go-q42021/csvcodec"
 	e "github.com/EloYaniel/academy-go-q42021/entities"
 )
 
 // CSVUserRepository struct implements UserRepository interface
 type CSVUserRepository struct {
-	filePath string
+	filePath   string
+	m          sync.Mutex
+	observer   Observer
+	quarantine *Quarantine
 }
 
 // NewCSVUserRepository function creates a new instance of type CSVUserRepository.
 func NewCSVUserRepository(filePath string) *CSVUserRepository {
-	return &CSVUserRepository{filePath: filePath}
+	return &CSVUserRepository{filePath: filePath, observer: nopObserver{}}
 }
 
-// SaveUsers saves all users to the file.
-func (repo *CSVUserRepository) SaveUsers(users []e.User) error {
-	csvFile, err := os.Create(repo.filePath)
+// WithObserver sets the observer notified of the reads of the file.
+func (repo *CSVUserRepository) WithObserver(observer Observer) *CSVUserRepository {
+	repo.observer = observer
 
-	if err != nil {
-		return errors.New("error opening o creating the file")
-	}
-	defer csvFile.Close()
-	csvwriter := csv.NewWriter(csvFile)
-	defer csvwriter.Flush()
+	return repo
+}
 
-	fileinfo, _ := csvFile.Stat()
+// WithQuarantine makes the reads lenient, skipping the malformed rows into quarantine instead of failing.
+func (repo *CSVUserRepository) WithQuarantine(quarantine *Quarantine) *CSVUserRepository {
+	repo.quarantine = quarantine
 
-	if fileinfo.Size() == 0 {
-		err = csvwriter.Write([]string{"Id", "Email", "FirstName", "LastName", "Avatar"})
+	return repo
+}
 
-		if err != nil {
-			return errors.New((fmt.Sprint("error writing user to file:", err.Error())))
-		}
+// SaveUsers upserts users by ID, keeping the stored Users that are not in users, in a single atomic replace
+// of the file. The stored Users keep their order and the new ones are appended, a missing file has no Users yet.
+// The quarantined rows are written back as they were, unless a User with their ID replaces them.
+func (repo *CSVUserRepository) SaveUsers(ctx context.Context, users []e.User) (*e.UserSaveReport, error) {
+	repo.m.Lock()
+	defer repo.m.Unlock()
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
 	}
+	var rows []csvRow
 
-	for _, user := range users {
-		row := []string{strconv.Itoa(user.ID), user.Email, user.FirstName, user.LastName, user.Avatar}
-		err = csvwriter.Write(row)
+	if _, err := os.Stat(repo.filePath); !os.IsNotExist(err) {
+		rows, err = repo.readRows(ctx)
 
 		if err != nil {
-			return errors.New((fmt.Sprint("error writing user to file:", err.Error(), "User", row)))
+			return nil, err
 		}
 	}
+	var stored []e.User
+	for _, row := range rows {
+		if row.value != nil {
+			stored = append(stored, *row.value.(*e.User))
+		}
+	}
+	_, report := mergeUsers(stored, users)
 
-	return nil
+	if report.Inserted == 0 && report.Updated == 0 {
+		return report, nil
+	}
+	saved := make([]csvRow, 0, len(users))
+	for _, u := range users {
+		saved = append(saved, userRow(u))
+	}
+	rows = upsertRows(rows, saved)
+
+	if err := repo.writeUsers(rows); err != nil {
+		return nil, err
+	}
+
+	return report, nil
 }
 
-// GetUsers gets all Users from the file.
-func (repo *CSVUserRepository) GetUsers() ([]e.User, error) {
-	f, err := os.Open(repo.filePath)
+// CheckReadable checks the file can be opened and has the header of the Users, without parsing the rows.
+// A missing file has no Users yet.
+func (repo *CSVUserRepository) CheckReadable(ctx context.Context) error {
+	if _, err := os.Stat(repo.filePath); os.IsNotExist(err) {
+		return nil
+	}
 
-	if err != nil {
-		return nil, errors.New("error opening the file")
+	return checkCSVHeader(repo.filePath, csvcodec.UserHeader)
+}
+
+// CheckWritable checks the file, or its directory when it does not exist yet, can be written.
+func (repo *CSVUserRepository) CheckWritable(ctx context.Context) error {
+	f, err := os.OpenFile(repo.filePath, os.O_WRONLY, 0)
+
+	if os.IsNotExist(err) {
+		f, err = ioutil.TempFile(filepath.Dir(repo.filePath), filepath.Base(repo.filePath)+".*.tmp")
+
+		if err == nil {
+			defer os.Remove(f.Name())
+		}
 	}
-	defer f.Close()
-	data, err := csv.NewReader(f).ReadAll()
 
 	if err != nil {
-		return nil, errors.New("error reading the file")
+		return errors.New("error opening the file for writing")
 	}
+
+	return f.Close()
+}
+
+// Close waits for the save in progress, if any, to finish.
+func (repo *CSVUserRepository) Close(ctx context.Context) error {
+	return waitForWrites(ctx, &repo.m)
+}
+
+// GetUsers gets all Users from the file.
+func (repo *CSVUserRepository) GetUsers(ctx context.Context) ([]e.User, error) {
 	var users []e.User
-	for i, line := range data {
-		if i != 0 {
-			id, err := strconv.Atoi(line[0])
-
-			if err != nil {
-				return nil, errors.New("error casting ID")
-			}
-			users = append(users, e.User{
-				ID:        id,
-				Email:     line[1],
-				FirstName: line[2],
-				LastName:  line[3],
-				Avatar:    line[4],
-			})
-		}
+	err := repo.eachUser(ctx, func(u e.User) error {
+		users = append(users, u)
+
+		return nil
+	})
 
+	if err != nil {
+		return nil, err
 	}
 
 	return users, nil
 }
 
 // GetUserByID get a User by its ID.
-func (repo *CSVUserRepository) GetUserByID(id int) (*e.User, error) {
-	users, err := repo.GetUsers()
+func (repo *CSVUserRepository) GetUserByID(ctx context.Context, id int) (*e.User, error) {
+	users, err := repo.GetUsers(ctx)
 
 	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
 		return nil, errors.New("error getting user")
 	}
 
@@ -104,3 +154,99 @@ func (repo *CSVUserRepository) GetUserByID(id int) (*e.User, error) {
 
 	return nil, nil
 }
+
+// EachUser streams the Users of the file in order, stopping at the first error of fn.
+func (repo *CSVUserRepository) EachUser(ctx context.Context, fn func(u e.User) error) error {
+	return repo.eachUser(ctx, fn)
+}
+
+// eachUser streams the file row by row calling fn for every parsed user until ctx is done or fn fails.
+func (repo *CSVUserRepository) eachUser(ctx context.Context, fn func(u e.User) error) (err error) {
+	defer func(started time.Time) {
+		repo.observer.ObserveRead("users", time.Since(started), err)
+	}(time.Now())
+	version := repo.quarantine.begin("users", repo.filePath)
+	f, err := os.Open(repo.filePath)
+
+	if err != nil {
+		return errors.New("error opening the file")
+	}
+	defer f.Close()
+	rows := newCSVRows("users", version, repo.quarantine, f, parseUserRow)
+
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		row, err := rows.next()
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if row.value == nil {
+			continue
+		}
+
+		if err = fn(*row.value.(*e.User)); err != nil {
+			return err
+		}
+	}
+}
+
+// readRows reads every row of the file, keeping the quarantined ones so they are written back.
+func (repo *CSVUserRepository) readRows(ctx context.Context) (rows []csvRow, err error) {
+	defer func(started time.Time) {
+		repo.observer.ObserveRead("users", time.Since(started), err)
+	}(time.Now())
+
+	return readCSVRows(ctx, "users", repo.filePath, repo.quarantine, parseUserRow)
+}
+
+// writeUsers replaces the file with rows in a single atomic replace.
+func (repo *CSVUserRepository) writeUsers(rows []csvRow) error {
+	return writeFileAtomically(repo.filePath, csvcodec.UserHeader, rows, func(value interface{}) []string {
+		return csvcodec.UserRecord(*value.(*e.User))
+	})
+}
+
+func parseUserRow(record []string) (interface{}, error) {
+	return csvcodec.ParseUser(record)
+}
+
+func userRow(u e.User) csvRow {
+	return csvRow{id: u.ID, value: &u}
+}
+
+// mergeUsers builds the Users stored after saving users and counts the inserted, updated and unchanged ones.
+// When users has the same ID more than once the last one wins.
+func mergeUsers(stored []e.User, users []e.User) ([]e.User, *e.UserSaveReport) {
+	report := &e.UserSaveReport{}
+	merged := append([]e.User(nil), stored...)
+	byID := make(map[int]int, len(stored))
+	for i, u := range merged {
+		byID[u.ID] = i
+	}
+
+	for _, u := range users {
+		i, ok := byID[u.ID]
+
+		switch {
+		case !ok:
+			byID[u.ID] = len(merged)
+			merged = append(merged, u)
+			report.Inserted++
+		case merged[i] != u:
+			merged[i] = u
+			report.Updated++
+		default:
+			report.Unchanged++
+		}
+	}
+
+	return merged, report
+}
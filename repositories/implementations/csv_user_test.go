@@ -1,8 +1,9 @@
 package repositories
 
 import (
+	"context"
 	"errors"
-	"os"
+	"path/filepath"
 	"testing"
 
 	e "github.com/EloYaniel/academy-go-q42021/entities"
@@ -33,53 +34,86 @@ func Test_NewCSVUserRepository_ShouldReturnDiffInstances(t *testing.T) {
 	assert.NotSame(t, instance, instance2)
 }
 
-func Test_SaveUsers_ShouldSaveUsers(t *testing.T) {
-	users := []e.User{user1, user2}
+func Test_SaveUsers_Suite(t *testing.T) {
+	renamed := user2
+	renamed.LastName = "Bluth"
+	user3 := e.User{ID: 3, Email: "emma.wong@reqres.in", FirstName: "Emma", LastName: "Wong"}
 	testCases := []struct {
-		name          string
-		filePath      string
-		users         []e.User
-		createdFile   bool
-		expectedError error
+		name           string
+		filePath       string
+		users          []e.User
+		expectedReport *e.UserSaveReport
+		expectedUsers  []e.User
+		expectedError  error
 	}{
 		{
 			name:          "Should return error when can't create file",
 			filePath:      "",
-			users:         users,
-			createdFile:   false,
-			expectedError: errors.New("error opening o creating the file"),
+			users:         []e.User{user1, user2},
+			expectedError: errors.New("error writing the file"),
 		},
 		{
-			name:          "Should save users",
-			filePath:      "../../data/test/saved-users-test.csv",
-			users:         users,
-			createdFile:   true,
-			expectedError: nil,
+			name:          "Should return error when the stored users can't be read",
+			filePath:      "../../data/test/users-with-wrong-id-test.csv",
+			users:         []e.User{user1},
+			expectedError: errors.New("error casting ID"),
+		},
+		{
+			name:           "Should create the file when it does not exist",
+			filePath:       filepath.Join(t.TempDir(), "saved-users-test.csv"),
+			users:          []e.User{user1, user2},
+			expectedReport: &e.UserSaveReport{Inserted: 2},
+			expectedUsers:  []e.User{user1, user2},
+		},
+		{
+			name:           "Should upsert users by ID keeping the stored ones",
+			filePath:       copyTestFile(t, "../../data/test/users-test.csv"),
+			users:          []e.User{user3, renamed},
+			expectedReport: &e.UserSaveReport{Inserted: 1, Updated: 1},
+			expectedUsers:  []e.User{user1, renamed, user3},
+		},
+		{
+			name:           "Should keep the last user when an ID is repeated",
+			filePath:       copyTestFile(t, "../../data/test/users-test.csv"),
+			users:          []e.User{renamed, user2, user1},
+			expectedReport: &e.UserSaveReport{Updated: 2, Unchanged: 1},
+			expectedUsers:  []e.User{user1, user2},
 		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-
 			repo := NewCSVUserRepository(tc.filePath)
 
-			err := repo.SaveUsers(tc.users)
+			report, err := repo.SaveUsers(context.Background(), tc.users)
 
 			assert.Equal(t, tc.expectedError, err)
+			assert.Equal(t, tc.expectedReport, report)
 
-			if tc.createdFile {
-				file, err := os.Open(repo.filePath)
-				assert.Nil(t, err)
-				defer file.Close()
-				st, err := file.Stat()
+			if tc.expectedError == nil {
+				users, err := repo.GetUsers(context.Background())
 				assert.Nil(t, err)
-				assert.Greater(t, st.Size(), int64(0))
-				os.Remove(repo.filePath)
+				assert.Equal(t, tc.expectedUsers, users)
 			}
 		})
 	}
 }
 
+func Test_SaveUsers_ShouldNotWriteUnchangedUsers(t *testing.T) {
+	filePath := copyTestFile(t, "../../data/test/users-test.csv")
+	before, err := statFileVersion(filePath)
+	assert.Nil(t, err)
+	repo := NewCSVUserRepository(filePath)
+
+	report, err := repo.SaveUsers(context.Background(), []e.User{user2, user1})
+
+	assert.Nil(t, err)
+	assert.Equal(t, &e.UserSaveReport{Unchanged: 2}, report)
+	after, err := statFileVersion(filePath)
+	assert.Nil(t, err)
+	assert.Equal(t, before, after)
+}
+
 func Test_GetUsers_Suite(t *testing.T) {
 	users := []e.User{
 		user1,
@@ -116,7 +150,7 @@ func Test_GetUsers_Suite(t *testing.T) {
 
 			repo := NewCSVUserRepository(tc.filePath)
 
-			users, err := repo.GetUsers()
+			users, err := repo.GetUsers(context.Background())
 
 			assert.Equal(t, tc.expectedResponse, users)
 			assert.Equal(t, tc.expectedError, err)
@@ -167,7 +201,7 @@ func Test_GetUserByID_Suite(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			repo := NewCSVUserRepository(tc.filePath)
 
-			user, err := repo.GetUserByID(tc.userID)
+			user, err := repo.GetUserByID(context.Background(), tc.userID)
 
 			assert.Equal(t, tc.expectedResponse, user)
 			assert.Equal(t, tc.expectedError, err)
@@ -0,0 +1,198 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/EloYaniel/academy-go-q42021/csvcodec"
+	e "github.com/EloYaniel/academy-go-q42021/entities"
+)
+
+// desiredRow is a raw row waiting to be handled by a worker.
+type desiredRow struct {
+	seq    int
+	record []string
+	err    error
+}
+
+// desiredMatch is a player accepted by a worker, tagged with its position in the source.
+type desiredMatch struct {
+	seq    int
+	player e.MLBPlayer
+}
+
+// desiredFailure is the first error found by a worker.
+type desiredFailure struct {
+	seq int
+	err error
+}
+
+// runDesiredPool reads rows from next and hands them to a pool of totalItems/itemsPerWorker workers
+// that keep the players matching predicate.
+// A worker only pulls a new row while it has appended less than itemsPerWorker players, so the rows
+// handled are always the same prefix of the source and the result does not depend on scheduling.
+// next must return io.EOF when there are no more rows. When ctx is done the reader and the workers stop and ctx.Err() is returned.
+// observer is notified of the workers running and of the rows they handle.
+func runDesiredPool(ctx context.Context, next func() ([]string, error), predicate e.MLBPlayerPredicate, totalItems int, itemsPerWorker int, observer Observer) (*e.MLBPlayerDesiredResult, error) {
+	workersCount := totalItems / itemsPerWorker
+	stop := make(chan struct{})
+	rows, readerDone := readDesiredRows(ctx, next, stop)
+
+	matches := make([][]desiredMatch, workersCount)
+	reports := make([]e.WorkerReport, workersCount)
+	failures := make([]*desiredFailure, workersCount)
+	failed := make(chan struct{})
+	abort := new(sync.Once)
+	wg := new(sync.WaitGroup)
+	wg.Add(workersCount)
+
+	for i := 0; i < workersCount; i++ {
+		go func(workerID int) {
+			defer wg.Done()
+			observer.ObserveWorkers(1)
+			defer observer.ObserveWorkers(-1)
+			report := &reports[workerID]
+			report.WorkerID = workerID + 1
+
+			for report.Items < itemsPerWorker {
+				var row desiredRow
+				var ok bool
+
+				select {
+				case row, ok = <-rows:
+				case <-failed:
+					return
+				case <-ctx.Done():
+					return
+				}
+
+				if !ok {
+					return
+				}
+				report.RowsRead++
+				player, err := parseDesiredRow(row)
+
+				if err != nil {
+					failures[workerID] = &desiredFailure{seq: row.seq, err: err}
+					abort.Do(func() { close(failed) })
+
+					return
+				}
+
+				rejected := !predicate.Match(*player)
+				observer.ObserveRow(rejected)
+
+				if rejected {
+					continue
+				}
+				matches[workerID] = append(matches[workerID], desiredMatch{seq: row.seq, player: *player})
+				report.Items++
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(stop)
+	<-readerDone
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	if err := firstFailure(failures); err != nil {
+		return nil, err
+	}
+
+	return buildDesiredResult(matches, reports, totalItems, workersCount*itemsPerWorker), nil
+}
+
+// readDesiredRows sends the rows from next, numbered in order, until EOF, the first read error,
+// stop being closed or ctx being done. The rows channel is closed when it finishes, then done.
+func readDesiredRows(ctx context.Context, next func() ([]string, error), stop <-chan struct{}) (<-chan desiredRow, <-chan struct{}) {
+	rows := make(chan desiredRow)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		defer close(rows)
+		for seq := 0; ; seq++ {
+			record, err := next()
+
+			if err == io.EOF {
+				return
+			}
+
+			select {
+			case rows <- desiredRow{seq: seq, record: record, err: err}:
+			case <-stop:
+				return
+			case <-ctx.Done():
+				return
+			}
+
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	return rows, done
+}
+
+func parseDesiredRow(row desiredRow) (*e.MLBPlayer, error) {
+	if row.err != nil {
+		return nil, errors.New("error reading the file")
+	}
+
+	player, err := csvcodec.ParsePlayer(row.record)
+
+	if err != nil {
+		return nil, plainError(err)
+	}
+
+	return player, nil
+}
+
+func firstFailure(failures []*desiredFailure) error {
+	var first *desiredFailure
+	for _, f := range failures {
+		if f != nil && (first == nil || f.seq < first.seq) {
+			first = f
+		}
+	}
+
+	if first == nil {
+		return nil
+	}
+
+	return first.err
+}
+
+func buildDesiredResult(matches [][]desiredMatch, reports []e.WorkerReport, totalItems int, capacity int) *e.MLBPlayerDesiredResult {
+	var all []desiredMatch
+	for _, m := range matches {
+		all = append(all, m...)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].seq < all[j].seq })
+
+	players := make([]e.MLBPlayer, 0, len(all))
+	for _, m := range all {
+		players = append(players, m.player)
+	}
+
+	reason := e.StopReasonEOF
+	switch len(players) {
+	case totalItems:
+		reason = e.StopReasonItemsReached
+	case capacity:
+		reason = e.StopReasonWorkersLimit
+	}
+
+	return &e.MLBPlayerDesiredResult{
+		Players:    players,
+		StopReason: reason,
+		Workers:    reports,
+	}
+}
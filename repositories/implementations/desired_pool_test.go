@@ -0,0 +1,152 @@
+package repositories
+
+import (
+	"context"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	e "github.com/EloYaniel/academy-go-q42021/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+// endlessOddRows returns a next function producing odd players forever, so an even read never fills up.
+func endlessOddRows() func() ([]string, error) {
+	id := -1
+
+	return func() ([]string, error) {
+		id += 2
+		time.Sleep(time.Millisecond)
+
+		return []string{strconv.Itoa(id), "Adam Donachie", "BAL", "Catcher", "74", "180", "22.99"}, nil
+	}
+}
+
+// assertNoGoroutineLeak waits for the goroutines started after before was taken to finish.
+func assertNoGoroutineLeak(t *testing.T, before int) {
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), before)
+}
+
+func Test_runDesiredPool_ShouldStopWhenContextIsDone(t *testing.T) {
+	testCases := []struct {
+		name          string
+		ctx           func() (context.Context, context.CancelFunc)
+		expectedError error
+	}{
+		{
+			name: "Should stop on cancel",
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(20*time.Millisecond, cancel)
+
+				return ctx, cancel
+			},
+			expectedError: context.Canceled,
+		},
+		{
+			name: "Should stop on deadline",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 20*time.Millisecond)
+			},
+			expectedError: context.DeadlineExceeded,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			before := runtime.NumGoroutine()
+			ctx, cancel := tc.ctx()
+			defer cancel()
+			started := time.Now()
+
+			result, err := runDesiredPool(ctx, endlessOddRows(), e.MLBPlayerIDParity{Even: true}, 10, 2, nopObserver{})
+
+			assert.Nil(t, result)
+			assert.Equal(t, tc.expectedError, err)
+			assert.Less(t, time.Since(started), time.Second)
+			assertNoGoroutineLeak(t, before)
+		})
+	}
+}
+
+func Test_runDesiredPool_ShouldNotLeakGoroutines(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	result, err := NewCSVMLBPlayerRepository("../../data/mlb_players.csv").GetMLBPlayerDesired(context.Background(), e.MLBPlayerIDParity{}, 10, 2)
+
+	assert.Nil(t, err)
+	assert.Len(t, result.Players, 10)
+	assertNoGoroutineLeak(t, before)
+}
+
+// recordingObserver counts the notifications of the repositories.
+type recordingObserver struct {
+	m           sync.Mutex
+	reads       []string
+	workers     int
+	maxWorkers  int
+	rows        int
+	rejected    int
+	quarantined int
+}
+
+func (o *recordingObserver) ObserveRead(source string, d time.Duration, err error) {
+	o.m.Lock()
+	defer o.m.Unlock()
+	o.reads = append(o.reads, source)
+}
+
+func (o *recordingObserver) ObserveWorkers(delta int) {
+	o.m.Lock()
+	defer o.m.Unlock()
+	o.workers += delta
+
+	if o.workers > o.maxWorkers {
+		o.maxWorkers = o.workers
+	}
+}
+
+func (o *recordingObserver) ObserveRow(rejected bool) {
+	o.m.Lock()
+	defer o.m.Unlock()
+	o.rows++
+
+	if rejected {
+		o.rejected++
+	}
+}
+
+func (o *recordingObserver) ObserveQuarantined(source string, added int, total int) {
+	o.m.Lock()
+	defer o.m.Unlock()
+	o.quarantined = total
+}
+
+func Test_GetMLBPlayerDesired_ShouldNotifyObserver(t *testing.T) {
+	observer := &recordingObserver{}
+	repo := NewCSVMLBPlayerRepository("../../data/mlb_players.csv").WithObserver(observer)
+
+	result, err := repo.GetMLBPlayerDesired(context.Background(), e.MLBPlayerIDParity{}, 10, 2)
+
+	assert.Nil(t, err)
+	assert.Len(t, result.Players, 10)
+	assert.Equal(t, 0, observer.workers)
+	assert.Greater(t, observer.maxWorkers, 0)
+	assert.GreaterOrEqual(t, observer.rows, 10)
+	rowsRead := 0
+	for _, worker := range result.Workers {
+		rowsRead += worker.RowsRead
+	}
+	assert.Equal(t, rowsRead, observer.rows)
+
+	_, err = repo.GetMLBPlayers(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"mlb_players"}, observer.reads)
+}
@@ -0,0 +1,64 @@
+package repositories
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// fileVersion identifies the content of a file by its modification time and size, and by the number of times
+// this process rewrote it, since a rewrite may leave both as they were.
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+	writes  uint64
+}
+
+var (
+	fileWritesMu sync.Mutex
+	fileWrites   = make(map[string]uint64)
+)
+
+// countFileWrite records a rewrite of the file, it must be called once the new content is in place.
+func countFileWrite(filePath string) {
+	fileWritesMu.Lock()
+	defer fileWritesMu.Unlock()
+	fileWrites[fileWritesKey(filePath)]++
+}
+
+func fileWritesKey(filePath string) string {
+	if abs, err := filepath.Abs(filePath); err == nil {
+		return abs
+	}
+
+	return filepath.Clean(filePath)
+}
+
+// statFileVersion gets the current version of the file.
+func statFileVersion(filePath string) (fileVersion, error) {
+	fileWritesMu.Lock()
+	writes := fileWrites[fileWritesKey(filePath)]
+	fileWritesMu.Unlock()
+	info, err := os.Stat(filePath)
+
+	if err != nil {
+		return fileVersion{}, err
+	}
+
+	return fileVersion{modTime: info.ModTime(), size: info.Size(), writes: writes}, nil
+}
+
+// FileVersion gets a tag that changes with every version of the file written by this process, or by others
+// when they change its modification time or size, and the time it was modified.
+func FileVersion(filePath string) (string, time.Time, error) {
+	version, err := statFileVersion(filePath)
+
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	tag := strconv.FormatInt(version.modTime.UnixNano(), 10) + "-" + strconv.FormatInt(version.size, 10) + "-" + strconv.FormatUint(version.writes, 10)
+
+	return tag, version.modTime, nil
+}
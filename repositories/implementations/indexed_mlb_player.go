@@ -0,0 +1,203 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	e "github.com/EloYaniel/academy-go-q42021/entities"
+)
+
+// IndexedMLBPlayerRepository struct implements MLBPlayerRepository interface keeping the file indexed by ID in memory.
+// The index is built once from the file and rebuilt when the file changes.
+type IndexedMLBPlayerRepository struct {
+	source  *CSVMLBPlayerRepository
+	mu      sync.RWMutex
+	loaded  bool
+	version fileVersion
+	players []e.MLBPlayer
+	byID    map[int]int
+}
+
+// NewIndexedMLBPlayerRepository function creates a new instance of type IndexedMLBPlayerRepository.
+func NewIndexedMLBPlayerRepository(filePath string) *IndexedMLBPlayerRepository {
+	return &IndexedMLBPlayerRepository{source: NewCSVMLBPlayerRepository(filePath)}
+}
+
+// GetMLBPlayers gets all MLB Players from the index.
+func (repo *IndexedMLBPlayerRepository) GetMLBPlayers(ctx context.Context) ([]e.MLBPlayer, error) {
+	err := repo.refresh(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	if len(repo.players) == 0 {
+		return nil, nil
+	}
+	players := make([]e.MLBPlayer, len(repo.players))
+	copy(players, repo.players)
+
+	return players, nil
+}
+
+// EachMLBPlayer streams the MLB Players of the index in order, stopping at the first error of fn.
+func (repo *IndexedMLBPlayerRepository) EachMLBPlayer(ctx context.Context, fn func(p e.MLBPlayer) error) error {
+	err := repo.refresh(ctx)
+
+	if err != nil {
+		return err
+	}
+	// A refresh replaces the slice instead of changing it, so it can be read without holding the lock.
+	repo.mu.RLock()
+	players := repo.players
+	repo.mu.RUnlock()
+
+	for _, p := range players {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err = fn(p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetMLBPlayerByID get a Player by its ID from the index.
+func (repo *IndexedMLBPlayerRepository) GetMLBPlayerByID(ctx context.Context, id int) (*e.MLBPlayer, error) {
+	err := repo.refresh(ctx)
+
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		return nil, errors.New("error getting player")
+	}
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	i, ok := repo.byID[id]
+
+	if !ok {
+		return nil, nil
+	}
+	player := repo.players[i]
+
+	return &player, nil
+}
+
+// GetMLBPlayerDesired gets MLB Players from the file concurrently and filetered by its params.
+func (repo *IndexedMLBPlayerRepository) GetMLBPlayerDesired(ctx context.Context, predicate e.MLBPlayerPredicate, totalItems int, itemsPerWorker int) (*e.MLBPlayerDesiredResult, error) {
+	return repo.source.GetMLBPlayerDesired(ctx, predicate, totalItems, itemsPerWorker)
+}
+
+// GetMLBPlayerSample gets a random sample of the MLB Players matching predicate reading the whole file concurrently.
+func (repo *IndexedMLBPlayerRepository) GetMLBPlayerSample(ctx context.Context, predicate e.MLBPlayerPredicate, totalItems int, itemsPerWorker int, sampling e.MLBPlayerSampling) (*e.MLBPlayerDesiredResult, error) {
+	return repo.source.GetMLBPlayerSample(ctx, predicate, totalItems, itemsPerWorker, sampling)
+}
+
+// CreateMLBPlayer saves a new Player to the file allocating its ID.
+func (repo *IndexedMLBPlayerRepository) CreateMLBPlayer(ctx context.Context, player e.MLBPlayer) (*e.MLBPlayer, error) {
+	defer repo.invalidate()
+
+	return repo.source.CreateMLBPlayer(ctx, player)
+}
+
+// UpdateMLBPlayer replaces the Player with the same ID in the file, nil if it does not exist.
+func (repo *IndexedMLBPlayerRepository) UpdateMLBPlayer(ctx context.Context, player e.MLBPlayer) (*e.MLBPlayer, error) {
+	defer repo.invalidate()
+
+	return repo.source.UpdateMLBPlayer(ctx, player)
+}
+
+// DeleteMLBPlayer deletes a Player by its ID from the file, nil if it does not exist.
+func (repo *IndexedMLBPlayerRepository) DeleteMLBPlayer(ctx context.Context, id int) (*e.MLBPlayer, error) {
+	defer repo.invalidate()
+
+	return repo.source.DeleteMLBPlayer(ctx, id)
+}
+
+// ImportMLBPlayers writes players to the file in a single atomic replace, merged by ID or replacing them.
+func (repo *IndexedMLBPlayerRepository) ImportMLBPlayers(ctx context.Context, players []e.MLBPlayer, mode e.MLBPlayerImportMode) (*e.MLBPlayerImportReport, error) {
+	defer repo.invalidate()
+
+	return repo.source.ImportMLBPlayers(ctx, players, mode)
+}
+
+// WithObserver sets the observer notified of the reads of the file.
+func (repo *IndexedMLBPlayerRepository) WithObserver(observer Observer) *IndexedMLBPlayerRepository {
+	repo.source.WithObserver(observer)
+
+	return repo
+}
+
+// WithQuarantine makes the reads of the file lenient, skipping the malformed rows into quarantine instead of failing.
+func (repo *IndexedMLBPlayerRepository) WithQuarantine(quarantine *Quarantine) *IndexedMLBPlayerRepository {
+	repo.source.WithQuarantine(quarantine)
+
+	return repo
+}
+
+// CheckReadable checks the file can be opened and has the header of the players.
+func (repo *IndexedMLBPlayerRepository) CheckReadable(ctx context.Context) error {
+	return repo.source.CheckReadable(ctx)
+}
+
+// Close waits for the write in progress in the file, if any, to finish.
+func (repo *IndexedMLBPlayerRepository) Close(ctx context.Context) error {
+	return repo.source.Close(ctx)
+}
+
+// invalidate drops the index so the next read rebuilds it, even if the file version looks the same.
+func (repo *IndexedMLBPlayerRepository) invalidate() {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.loaded = false
+}
+
+// refresh rebuilds the index when the file changed since it was loaded.
+func (repo *IndexedMLBPlayerRepository) refresh(ctx context.Context) error {
+	version, err := statFileVersion(repo.source.filePath)
+
+	if err != nil {
+		return errors.New("error opening the file")
+	}
+	repo.mu.RLock()
+	upToDate := repo.loaded && repo.version == version
+	repo.mu.RUnlock()
+
+	if upToDate {
+		return nil
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if repo.loaded && repo.version == version {
+		return nil
+	}
+	var players []e.MLBPlayer
+	byID := make(map[int]int)
+	err = repo.source.eachPlayer(ctx, func(p e.MLBPlayer) error {
+		if _, ok := byID[p.ID]; !ok {
+			byID[p.ID] = len(players)
+		}
+		players = append(players, p)
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+	repo.players = players
+	repo.byID = byID
+	repo.version = version
+	repo.loaded = true
+
+	return nil
+}
@@ -0,0 +1,182 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	e "github.com/EloYaniel/academy-go-q42021/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+func copyTestFile(t *testing.T, src string) string {
+	data, err := ioutil.ReadFile(src)
+	assert.Nil(t, err)
+	dst := filepath.Join(t.TempDir(), filepath.Base(src))
+	assert.Nil(t, ioutil.WriteFile(dst, data, 0644))
+
+	return dst
+}
+
+func touchTestFile(t *testing.T, filePath string, content string) {
+	f, err := os.OpenFile(filePath, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.Nil(t, err)
+	_, err = f.WriteString(content)
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+	later := time.Now().Add(time.Minute)
+	assert.Nil(t, os.Chtimes(filePath, later, later))
+}
+
+func Test_IndexedMLBPlayerRepository_GetMLBPlayers_Suite(t *testing.T) {
+	testCases := []struct {
+		name             string
+		filePath         string
+		expectedError    error
+		expectedResponse []e.MLBPlayer
+	}{
+		{
+			name:             "Should return the players",
+			filePath:         "../../data/test/players-test.csv",
+			expectedResponse: []e.MLBPlayer{player1, player2},
+			expectedError:    nil,
+		},
+		{
+			name:             "Should return error when open file",
+			filePath:         "",
+			expectedResponse: nil,
+			expectedError:    errors.New("error opening the file"),
+		},
+		{
+			name:             "Should return error when casting Height",
+			filePath:         "../../data/test/players-with-wrong-height-test.csv",
+			expectedResponse: nil,
+			expectedError:    errors.New("error casting Height"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := NewIndexedMLBPlayerRepository(tc.filePath)
+
+			players, err := repo.GetMLBPlayers(context.Background())
+
+			assert.Equal(t, tc.expectedResponse, players)
+			assert.Equal(t, tc.expectedError, err)
+		})
+	}
+}
+
+func Test_IndexedMLBPlayerRepository_GetMLBPlayerByID_Suite(t *testing.T) {
+	testCases := []struct {
+		name             string
+		filePath         string
+		playerID         int
+		expectedError    error
+		expectedResponse *e.MLBPlayer
+	}{
+		{
+			name:             "Should return the player",
+			filePath:         "../../data/test/players-test.csv",
+			playerID:         2,
+			expectedResponse: &player2,
+			expectedError:    nil,
+		},
+		{
+			name:             "Should return no player and no error",
+			filePath:         "../../data/test/players-test.csv",
+			playerID:         3,
+			expectedResponse: nil,
+			expectedError:    nil,
+		},
+		{
+			name:             "Should return no player and error",
+			filePath:         "",
+			playerID:         1,
+			expectedResponse: nil,
+			expectedError:    errors.New("error getting player"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := NewIndexedMLBPlayerRepository(tc.filePath)
+
+			player, err := repo.GetMLBPlayerByID(context.Background(), tc.playerID)
+
+			assert.Equal(t, tc.expectedResponse, player)
+			assert.Equal(t, tc.expectedError, err)
+		})
+	}
+}
+
+func Test_IndexedMLBPlayerRepository_ShouldReloadWhenFileChanges(t *testing.T) {
+	filePath := copyTestFile(t, "../../data/test/players-test.csv")
+	repo := NewIndexedMLBPlayerRepository(filePath)
+
+	player, err := repo.GetMLBPlayerByID(context.Background(), 3)
+	assert.Nil(t, err)
+	assert.Nil(t, player)
+
+	touchTestFile(t, filePath, "\n3,\"Ramon Hernandez\",\"BAL\",\"Catcher\",72,210,30.78\n")
+
+	player, err = repo.GetMLBPlayerByID(context.Background(), 3)
+	assert.Nil(t, err)
+	assert.Equal(t, &player3, player)
+
+	players, err := repo.GetMLBPlayers(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, []e.MLBPlayer{player1, player2, player3}, players)
+}
+
+func Test_IndexedMLBPlayerRepository_ShouldSeeItsOwnWrites(t *testing.T) {
+	repo := NewIndexedMLBPlayerRepository(copyTestFile(t, "../../data/test/players-test.csv"))
+	player := player3
+	player.ID = 0
+
+	_, err := repo.GetMLBPlayers(context.Background())
+	assert.Nil(t, err)
+	created, err := repo.CreateMLBPlayer(context.Background(), player)
+	assert.Nil(t, err)
+
+	found, err := repo.GetMLBPlayerByID(context.Background(), created.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, &player3, found)
+
+	deleted, err := repo.DeleteMLBPlayer(context.Background(), 1)
+	assert.Nil(t, err)
+	assert.Equal(t, &player1, deleted)
+
+	found, err = repo.GetMLBPlayerByID(context.Background(), 1)
+	assert.Nil(t, err)
+	assert.Nil(t, found)
+}
+
+func Test_IndexedMLBPlayerRepository_ShouldSeeWritesThatKeepSizeAndModTime(t *testing.T) {
+	filePath := copyTestFile(t, "../../data/test/players-test.csv")
+	repo := NewIndexedMLBPlayerRepository(filePath)
+	writer := NewCSVMLBPlayerRepository(filePath)
+	_, err := writer.UpdateMLBPlayer(context.Background(), player1)
+	assert.Nil(t, err)
+	info, err := os.Stat(filePath)
+	assert.Nil(t, err)
+
+	_, err = repo.GetMLBPlayers(context.Background())
+	assert.Nil(t, err)
+	updated := player1
+	updated.Age = 22.98
+	_, err = writer.UpdateMLBPlayer(context.Background(), updated)
+	assert.Nil(t, err)
+	assert.Nil(t, os.Chtimes(filePath, info.ModTime(), info.ModTime()))
+	after, err := os.Stat(filePath)
+	assert.Nil(t, err)
+	assert.Equal(t, info.Size(), after.Size())
+
+	player, err := repo.GetMLBPlayerByID(context.Background(), 1)
+	assert.Nil(t, err)
+	assert.Equal(t, &updated, player)
+}
@@ -0,0 +1,172 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	e "github.com/EloYaniel/academy-go-q42021/entities"
+)
+
+// IndexedUserRepository struct implements UserRepository interface keeping the file indexed by ID in memory.
+// The index is built once from the file and rebuilt when the file changes.
+type IndexedUserRepository struct {
+	source  *CSVUserRepository
+	mu      sync.RWMutex
+	loaded  bool
+	version fileVersion
+	users   []e.User
+	byID    map[int]int
+}
+
+// NewIndexedUserRepository function creates a new instance of type IndexedUserRepository.
+func NewIndexedUserRepository(filePath string) *IndexedUserRepository {
+	return &IndexedUserRepository{source: NewCSVUserRepository(filePath)}
+}
+
+// SaveUsers upserts users by ID in the file and drops the index.
+func (repo *IndexedUserRepository) SaveUsers(ctx context.Context, users []e.User) (*e.UserSaveReport, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.loaded = false
+
+	return repo.source.SaveUsers(ctx, users)
+}
+
+// GetUsers gets all Users from the index.
+func (repo *IndexedUserRepository) GetUsers(ctx context.Context) ([]e.User, error) {
+	err := repo.refresh(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	if len(repo.users) == 0 {
+		return nil, nil
+	}
+	users := make([]e.User, len(repo.users))
+	copy(users, repo.users)
+
+	return users, nil
+}
+
+// EachUser streams the Users of the index in order, stopping at the first error of fn.
+func (repo *IndexedUserRepository) EachUser(ctx context.Context, fn func(u e.User) error) error {
+	err := repo.refresh(ctx)
+
+	if err != nil {
+		return err
+	}
+	// A refresh replaces the slice instead of changing it, so it can be read without holding the lock.
+	repo.mu.RLock()
+	users := repo.users
+	repo.mu.RUnlock()
+
+	for _, u := range users {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err = fn(u); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetUserByID get a User by its ID from the index.
+func (repo *IndexedUserRepository) GetUserByID(ctx context.Context, id int) (*e.User, error) {
+	err := repo.refresh(ctx)
+
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		return nil, errors.New("error getting user")
+	}
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	i, ok := repo.byID[id]
+
+	if !ok {
+		return nil, nil
+	}
+	user := repo.users[i]
+
+	return &user, nil
+}
+
+// WithObserver sets the observer notified of the reads of the file.
+func (repo *IndexedUserRepository) WithObserver(observer Observer) *IndexedUserRepository {
+	repo.source.WithObserver(observer)
+
+	return repo
+}
+
+// WithQuarantine makes the reads of the file lenient, skipping the malformed rows into quarantine instead of failing.
+func (repo *IndexedUserRepository) WithQuarantine(quarantine *Quarantine) *IndexedUserRepository {
+	repo.source.WithQuarantine(quarantine)
+
+	return repo
+}
+
+// CheckReadable checks the file can be opened and has the header of the Users, a missing file has no Users yet.
+func (repo *IndexedUserRepository) CheckReadable(ctx context.Context) error {
+	return repo.source.CheckReadable(ctx)
+}
+
+// CheckWritable checks the file, or its directory when it does not exist yet, can be written.
+func (repo *IndexedUserRepository) CheckWritable(ctx context.Context) error {
+	return repo.source.CheckWritable(ctx)
+}
+
+// Close waits for the write in progress in the file, if any, to finish.
+func (repo *IndexedUserRepository) Close(ctx context.Context) error {
+	return repo.source.Close(ctx)
+}
+
+// refresh rebuilds the index when the file changed since it was loaded.
+func (repo *IndexedUserRepository) refresh(ctx context.Context) error {
+	version, err := statFileVersion(repo.source.filePath)
+
+	if err != nil {
+		return errors.New("error opening the file")
+	}
+	repo.mu.RLock()
+	upToDate := repo.loaded && repo.version == version
+	repo.mu.RUnlock()
+
+	if upToDate {
+		return nil
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if repo.loaded && repo.version == version {
+		return nil
+	}
+	var users []e.User
+	byID := make(map[int]int)
+	err = repo.source.eachUser(ctx, func(u e.User) error {
+		if _, ok := byID[u.ID]; !ok {
+			byID[u.ID] = len(users)
+		}
+		users = append(users, u)
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+	repo.users = users
+	repo.byID = byID
+	repo.version = version
+	repo.loaded = true
+
+	return nil
+}
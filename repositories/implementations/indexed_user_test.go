@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	e "github.com/EloYaniel/academy-go-q42021/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_IndexedUserRepository_GetUserByID_Suite(t *testing.T) {
+	testCases := []struct {
+		name             string
+		filePath         string
+		userID           int
+		expectedError    error
+		expectedResponse *e.User
+	}{
+		{
+			name:             "Should return the user",
+			filePath:         "../../data/test/users-test.csv",
+			userID:           2,
+			expectedResponse: &user2,
+			expectedError:    nil,
+		},
+		{
+			name:             "Should return no user and no error",
+			filePath:         "../../data/test/users-test.csv",
+			userID:           3,
+			expectedResponse: nil,
+			expectedError:    nil,
+		},
+		{
+			name:             "Should return no user and error",
+			filePath:         "",
+			userID:           1,
+			expectedResponse: nil,
+			expectedError:    errors.New("error getting user"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := NewIndexedUserRepository(tc.filePath)
+
+			user, err := repo.GetUserByID(context.Background(), tc.userID)
+
+			assert.Equal(t, tc.expectedResponse, user)
+			assert.Equal(t, tc.expectedError, err)
+		})
+	}
+}
+
+func Test_IndexedUserRepository_ShouldReloadAfterSave(t *testing.T) {
+	filePath := copyTestFile(t, "../../data/test/users-test.csv")
+	repo := NewIndexedUserRepository(filePath)
+
+	users, err := repo.GetUsers(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, []e.User{user1, user2}, users)
+
+	renamed := user1
+	renamed.Email = "george@reqres.in"
+	_, err = repo.SaveUsers(context.Background(), []e.User{renamed})
+	assert.Nil(t, err)
+
+	users, err = repo.GetUsers(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, []e.User{renamed, user2}, users)
+
+	user, err := repo.GetUserByID(context.Background(), 1)
+	assert.Nil(t, err)
+	assert.Equal(t, &renamed, user)
+}
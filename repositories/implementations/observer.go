@@ -0,0 +1,29 @@
+package repositories
+
+import "time"
+
+// Observer is notified of the reads done by the repositories, to expose them as metrics.
+type Observer interface {
+	// ObserveRead is called after a full read of the named source.
+	ObserveRead(source string, d time.Duration, err error)
+
+	// ObserveWorkers is called with +1 when a GetMLBPlayerDesired worker starts and -1 when it stops.
+	ObserveWorkers(delta int)
+
+	// ObserveRow is called for every row handled by a GetMLBPlayerDesired worker, rejected when the predicate dropped it.
+	ObserveRow(rejected bool)
+
+	// ObserveQuarantined is called when rows of source are quarantined, with the added count and the current total.
+	ObserveQuarantined(source string, added int, total int)
+}
+
+// nopObserver ignores every notification.
+type nopObserver struct{}
+
+func (nopObserver) ObserveRead(source string, d time.Duration, err error) {}
+
+func (nopObserver) ObserveWorkers(delta int) {}
+
+func (nopObserver) ObserveRow(rejected bool) {}
+
+func (nopObserver) ObserveQuarantined(source string, added int, total int) {}
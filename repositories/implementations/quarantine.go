@@ -0,0 +1,150 @@
+package repositories
+
+import (
+	"encoding/csv"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/EloYaniel/academy-go-q42021/csvcodec"
+	e "github.com/EloYaniel/academy-go-q42021/entities"
+)
+
+// Quarantine keeps the malformed rows skipped by the CSV repositories in lenient mode.
+// The rows of a source are dropped when its file changes, so they always describe the current file.
+// A nil Quarantine is the strict mode, where the first malformed row fails the read.
+type Quarantine struct {
+	mu       sync.Mutex
+	sources  map[string]*quarantinedSource
+	observer Observer
+}
+
+// quarantinedSource struct has the malformed rows of a version of a file by line.
+type quarantinedSource struct {
+	version fileVersion
+	rows    map[int]e.QuarantinedRow
+}
+
+// NewQuarantine function creates an empty Quarantine.
+func NewQuarantine() *Quarantine {
+	return &Quarantine{sources: make(map[string]*quarantinedSource), observer: nopObserver{}}
+}
+
+// WithObserver sets the observer notified when rows are quarantined or dropped.
+func (q *Quarantine) WithObserver(observer Observer) *Quarantine {
+	q.observer = observer
+
+	return q
+}
+
+// Rows gets the quarantined rows of source, or of every source when it is empty, sorted by source and line.
+func (q *Quarantine) Rows(source string) []e.QuarantinedRow {
+	rows := []e.QuarantinedRow{}
+
+	if q == nil {
+		return rows
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for name, s := range q.sources {
+		if source != "" && name != source {
+			continue
+		}
+		for _, row := range s.rows {
+			rows = append(rows, row)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Source != rows[j].Source {
+			return rows[i].Source < rows[j].Source
+		}
+
+		return rows[i].Line < rows[j].Line
+	})
+
+	return rows
+}
+
+// begin starts a read of the file of source, dropping its rows when the file changed since they were found.
+func (q *Quarantine) begin(source string, filePath string) fileVersion {
+	if q == nil {
+		return fileVersion{}
+	}
+	version, _ := statFileVersion(filePath)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	s, ok := q.sources[source]
+
+	if ok && s.version == version {
+		return version
+	}
+	q.sources[source] = &quarantinedSource{version: version, rows: make(map[int]e.QuarantinedRow)}
+
+	if ok && len(s.rows) > 0 {
+		q.observer.ObserveQuarantined(source, 0, 0)
+	}
+
+	return version
+}
+
+// addReadError quarantines a row the CSV reader could not read, false in strict mode or when the file itself failed.
+func (q *Quarantine) addReadError(source string, version fileVersion, record []string, err error) bool {
+	var parseErr *csv.ParseError
+
+	if q == nil || !errors.As(err, &parseErr) {
+		return false
+	}
+	q.add(source, version, e.QuarantinedRow{
+		Line:   parseErr.StartLine,
+		Value:  strings.Join(record, ","),
+		Reason: parseErr.Err.Error(),
+	})
+
+	return true
+}
+
+// addParseError quarantines a row read at line that could not be parsed, false in strict mode.
+func (q *Quarantine) addParseError(source string, version fileVersion, line int, err error) bool {
+	if q == nil {
+		return false
+	}
+	row := e.QuarantinedRow{Line: line, Reason: err.Error()}
+	var fieldErr *csvcodec.FieldError
+
+	if errors.As(err, &fieldErr) {
+		row.Column = fieldErr.Column
+		row.Field = fieldErr.Field
+		row.Value = fieldErr.Value
+		row.Reason = fieldErr.Message
+	}
+	q.add(source, version, row)
+
+	return true
+}
+
+func (q *Quarantine) add(source string, version fileVersion, row e.QuarantinedRow) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	s := q.sources[source]
+
+	// A read of a newer version of the file already replaced the rows of this one.
+	if s == nil || s.version != version {
+		return
+	}
+
+	if _, ok := s.rows[row.Line]; ok {
+		return
+	}
+	row.Source = source
+	row.QuarantinedAt = time.Now()
+	s.rows[row.Line] = row
+	q.observer.ObserveQuarantined(source, 1, len(s.rows))
+}
+
+// plainError drops the details of a parse error, the strict reads report the message only.
+func plainError(err error) error {
+	return errors.New(err.Error())
+}
@@ -0,0 +1,242 @@
+package repositories
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	e "github.com/EloYaniel/academy-go-q42021/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+const malformedPlayers = `Id,Name,Team,Position,Height(inches),Weight(lbs),Age
+1,Adam Donachie,BAL,Catcher,74,180,22.99
+2,Paul Bako,BAL,Catcher,74abc,215,34.69
+3,Ramon Hernandez,BAL,Catcher,72,210
+4,"Kevin "Millar,BAL,First Baseman,72,210,35.43
+3,Ramon Hernandez,BAL,Catcher,72,210,30.78
+`
+
+var malformedPlayersRows = []e.QuarantinedRow{
+	{Source: "mlb_players", Line: 3, Column: 5, Field: "Height(inches)", Value: "74abc", Reason: "error casting Height"},
+	{Source: "mlb_players", Line: 4, Value: "3,Ramon Hernandez,BAL,Catcher,72,210", Reason: "wrong number of fields"},
+	{Source: "mlb_players", Line: 5, Value: "4", Reason: "extraneous or missing \" in quoted-field"},
+}
+
+func writeMalformedFile(t *testing.T, content string) string {
+	filePath := filepath.Join(t.TempDir(), "malformed.csv")
+	assert.Nil(t, ioutil.WriteFile(filePath, []byte(content), 0644))
+
+	return filePath
+}
+
+// quarantinedRows gets the rows of q without the time they were found.
+func quarantinedRows(q *Quarantine, source string) []e.QuarantinedRow {
+	rows := q.Rows(source)
+	for i := range rows {
+		rows[i].QuarantinedAt = time.Time{}
+	}
+
+	return rows
+}
+
+func Test_Quarantine_ShouldSkipMalformedRows(t *testing.T) {
+	observer := &recordingObserver{}
+	quarantine := NewQuarantine().WithObserver(observer)
+	repo := NewCSVMLBPlayerRepository(writeMalformedFile(t, malformedPlayers)).WithQuarantine(quarantine)
+
+	for i := 0; i < 2; i++ {
+		players, err := repo.GetMLBPlayers(context.Background())
+
+		assert.Nil(t, err)
+		assert.Equal(t, []e.MLBPlayer{player1, player3}, players)
+		assert.Equal(t, malformedPlayersRows, quarantinedRows(quarantine, ""))
+		assert.Equal(t, 3, observer.quarantined)
+	}
+}
+
+func Test_Quarantine_ShouldDropRowsWhenTheFileChanges(t *testing.T) {
+	observer := &recordingObserver{}
+	quarantine := NewQuarantine().WithObserver(observer)
+	filePath := writeMalformedFile(t, malformedPlayers)
+	repo := NewIndexedMLBPlayerRepository(filePath).WithQuarantine(quarantine)
+
+	_, err := repo.GetMLBPlayers(context.Background())
+	assert.Nil(t, err)
+	assert.Len(t, quarantine.Rows("mlb_players"), 3)
+
+	assert.Nil(t, ioutil.WriteFile(filePath, []byte("Id,Name,Team,Position,Height(inches),Weight(lbs),Age\n1,Adam Donachie,BAL,Catcher,74,180,22.99\n"), 0644))
+	players, err := repo.GetMLBPlayers(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, []e.MLBPlayer{player1}, players)
+	assert.Empty(t, quarantine.Rows("mlb_players"))
+	assert.Equal(t, 0, observer.quarantined)
+}
+
+func Test_Quarantine_ShouldKeepQuarantinedRowsOnWrites(t *testing.T) {
+	quarantine := NewQuarantine()
+	filePath := writeMalformedFile(t, malformedPlayers)
+	repo := NewIndexedMLBPlayerRepository(filePath).WithQuarantine(quarantine)
+	player := e.MLBPlayer{Name: "Brian Roberts", Team: "BAL", Position: "Second Baseman", Height: 69, Weight: 176, Age: 29.39}
+
+	created, err := repo.CreateMLBPlayer(context.Background(), player)
+	assert.Nil(t, err)
+	assert.Equal(t, 5, created.ID)
+
+	updated := player1
+	updated.Age = 23.5
+	_, err = repo.UpdateMLBPlayer(context.Background(), updated)
+	assert.Nil(t, err)
+
+	deleted, err := repo.DeleteMLBPlayer(context.Background(), 3)
+	assert.Nil(t, err)
+	assert.Equal(t, &player3, deleted)
+
+	content, err := ioutil.ReadFile(filePath)
+	assert.Nil(t, err)
+	assert.Equal(t, `Id,Name,Team,Position,Height(inches),Weight(lbs),Age
+1,Adam Donachie,BAL,Catcher,74,180,23.5
+2,Paul Bako,BAL,Catcher,74abc,215,34.69
+3,Ramon Hernandez,BAL,Catcher,72,210
+4,"Kevin "Millar,BAL,First Baseman,72,210,35.43
+5,Brian Roberts,BAL,Second Baseman,69,176,29.39
+`, string(content))
+
+	players, err := repo.GetMLBPlayers(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, []e.MLBPlayer{updated, *created}, players)
+	assert.Equal(t, malformedPlayersRows, quarantinedRows(quarantine, "mlb_players"))
+}
+
+func Test_Quarantine_ShouldNotReuseTheIDOfAQuarantinedRow(t *testing.T) {
+	content := "Id,Name,Team,Position,Height(inches),Weight(lbs),Age\n1,Adam Donachie,BAL,Catcher,74,180,22.99\n2,Paul Bako,BAL,Catcher,xx,215,34.69\n"
+	filePath := writeMalformedFile(t, content)
+	repo := NewCSVMLBPlayerRepository(filePath).WithQuarantine(NewQuarantine())
+	player := player3
+	player.ID = 0
+
+	created, err := repo.CreateMLBPlayer(context.Background(), player)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, created.ID)
+	written, err := ioutil.ReadFile(filePath)
+	assert.Nil(t, err)
+	assert.Equal(t, content+"3,Ramon Hernandez,BAL,Catcher,72,210,30.78\n", string(written))
+}
+
+func Test_Quarantine_ShouldReplaceQuarantinedRowsOnImports(t *testing.T) {
+	testCases := []struct {
+		name            string
+		mode            e.MLBPlayerImportMode
+		expectedReport  *e.MLBPlayerImportReport
+		expectedContent string
+	}{
+		{
+			name:           "Should replace the quarantined row with the same ID in merge mode",
+			mode:           e.ImportMerge,
+			expectedReport: &e.MLBPlayerImportReport{Mode: e.ImportMerge, Inserted: 1},
+			expectedContent: `Id,Name,Team,Position,Height(inches),Weight(lbs),Age
+1,Adam Donachie,BAL,Catcher,74,180,22.99
+2,Paul Bako,BAL,Catcher,74,215,34.69
+3,Ramon Hernandez,BAL,Catcher,72,210
+4,"Kevin "Millar,BAL,First Baseman,72,210,35.43
+3,Ramon Hernandez,BAL,Catcher,72,210,30.78
+`,
+		},
+		{
+			name:           "Should drop the quarantined rows in replace mode",
+			mode:           e.ImportReplace,
+			expectedReport: &e.MLBPlayerImportReport{Mode: e.ImportReplace, Inserted: 1, Removed: 5},
+			expectedContent: `Id,Name,Team,Position,Height(inches),Weight(lbs),Age
+2,Paul Bako,BAL,Catcher,74,215,34.69
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			filePath := writeMalformedFile(t, malformedPlayers)
+			repo := NewCSVMLBPlayerRepository(filePath).WithQuarantine(NewQuarantine())
+
+			report, err := repo.ImportMLBPlayers(context.Background(), []e.MLBPlayer{player2}, tc.mode)
+
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedReport, report)
+			content, err := ioutil.ReadFile(filePath)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedContent, string(content))
+		})
+	}
+}
+
+func Test_Quarantine_ShouldReadTheRowsAfterAMalformedHeader(t *testing.T) {
+	quarantine := NewQuarantine()
+	content := "Id,Na\"me,Team,Position,Height(inches),Weight(lbs),Age\n1,Adam Donachie,BAL,Catcher,74,180,22.99\n"
+	repo := NewCSVMLBPlayerRepository(writeMalformedFile(t, content)).WithQuarantine(quarantine)
+
+	players, err := repo.GetMLBPlayers(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, []e.MLBPlayer{player1}, players)
+	assert.Equal(t, []e.QuarantinedRow{
+		{Source: "mlb_players", Line: 1, Value: "Id", Reason: "bare \" in non-quoted-field"},
+	}, quarantinedRows(quarantine, "mlb_players"))
+}
+
+func Test_Quarantine_ShouldSkipMalformedRowsOfConcurrentReads(t *testing.T) {
+	quarantine := NewQuarantine()
+	repo := NewCSVMLBPlayerRepository(writeMalformedFile(t, malformedPlayers)).WithQuarantine(quarantine)
+
+	result, err := repo.GetMLBPlayerDesired(context.Background(), e.MLBPlayerAnd{}, 10, 1)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []e.MLBPlayer{player1, player3}, result.Players)
+	assert.Equal(t, malformedPlayersRows, quarantinedRows(quarantine, "mlb_players"))
+}
+
+func Test_Quarantine_ShouldSkipMalformedUsers(t *testing.T) {
+	quarantine := NewQuarantine()
+	repo := NewCSVUserRepository("../../data/test/users-with-wrong-id-test.csv").WithQuarantine(quarantine)
+
+	users, err := repo.GetUsers(context.Background())
+
+	assert.Nil(t, err)
+	assert.Empty(t, users)
+	assert.Equal(t, []e.QuarantinedRow{
+		{Source: "users", Line: 2, Column: 1, Field: "Id", Value: "Id", Reason: "error casting ID"},
+		{Source: "users", Line: 3, Column: 1, Field: "Id", Value: "1abc", Reason: "error casting ID"},
+		{Source: "users", Line: 4, Column: 1, Field: "Id", Value: "abc2", Reason: "error casting ID"},
+	}, quarantinedRows(quarantine, "users"))
+	assert.Empty(t, quarantine.Rows("mlb_players"))
+}
+
+func Test_Quarantine_ShouldKeepQuarantinedUsersOnSaves(t *testing.T) {
+	content := `Id,Email,FirstName,LastName,Avatar
+1,george.bluth@reqres.in,George,Bluth,https://reqres.in/img/faces/1-image.jpg
+2x,janet.weaver@reqres.in,Janet,Weaver,https://reqres.in/img/faces/2-image.jpg
+`
+	filePath := writeMalformedFile(t, content)
+	repo := NewCSVUserRepository(filePath).WithQuarantine(NewQuarantine())
+	user3 := e.User{ID: 3, Email: "emma.wong@reqres.in", FirstName: "Emma", LastName: "Wong"}
+
+	report, err := repo.SaveUsers(context.Background(), []e.User{user1, user3})
+
+	assert.Nil(t, err)
+	assert.Equal(t, &e.UserSaveReport{Inserted: 1, Unchanged: 1}, report)
+	written, err := ioutil.ReadFile(filePath)
+	assert.Nil(t, err)
+	assert.Equal(t, content+"3,emma.wong@reqres.in,Emma,Wong,\n", string(written))
+}
+
+func Test_Quarantine_ShouldBeEmptyInStrictMode(t *testing.T) {
+	var quarantine *Quarantine
+	repo := NewCSVMLBPlayerRepository(writeMalformedFile(t, malformedPlayers)).WithQuarantine(quarantine)
+
+	players, err := repo.GetMLBPlayers(context.Background())
+
+	assert.Nil(t, players)
+	assert.EqualError(t, err, "error casting Height")
+	assert.Equal(t, []e.QuarantinedRow{}, quarantine.Rows(""))
+}
@@ -0,0 +1,241 @@
+package repositories
+
+import (
+	"container/heap"
+	"context"
+	"math/rand"
+	"sort"
+	"sync"
+
+	e "github.com/EloYaniel/academy-go-q42021/entities"
+)
+
+// sampledRow is a player kept by a sampling worker with the random key that ranks it.
+type sampledRow struct {
+	key    uint64
+	seq    int
+	worker int
+	player e.MLBPlayer
+}
+
+func (row sampledRow) less(other sampledRow) bool {
+	return row.key < other.key || (row.key == other.key && row.seq < other.seq)
+}
+
+// sampleHeap keeps the rows with the smallest keys, the largest one on top so it is the first replaced.
+type sampleHeap []sampledRow
+
+func (h sampleHeap) Len() int {
+	return len(h)
+}
+
+func (h sampleHeap) Less(i, j int) bool {
+	return h[j].less(h[i])
+}
+
+func (h sampleHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *sampleHeap) Push(x interface{}) {
+	*h = append(*h, x.(sampledRow))
+}
+
+func (h *sampleHeap) Pop() interface{} {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+
+	return last
+}
+
+// runSamplePool reads every row from next with a pool of totalItems/itemsPerWorker workers and returns
+// a uniformly random sample of up to totalItems players matching predicate, made of at most itemsPerWorker
+// distinct players kept by every worker.
+// Every row gets a random key derived from the seed and its position in the source, which also deals it to a worker.
+// Each worker keeps the itemsPerWorker matching rows with the smallest keys it is dealt and the sample is made of
+// the rows of all the workers, so it only depends on the seed, the rows and the number of workers, not on scheduling.
+func runSamplePool(ctx context.Context, next func() ([]string, error), predicate e.MLBPlayerPredicate, totalItems int, itemsPerWorker int, sampling e.MLBPlayerSampling, observer Observer) (*e.MLBPlayerDesiredResult, error) {
+	workersCount := totalItems / itemsPerWorker
+	stop := make(chan struct{})
+	rows, readerDone := readDesiredRows(ctx, next, stop)
+
+	kept := make([]sampleHeap, workersCount)
+	matched := make([]int, workersCount)
+	reports := make([]e.WorkerReport, workersCount)
+	failures := make([]*desiredFailure, workersCount)
+	failed := make(chan struct{})
+	abort := new(sync.Once)
+	seedKey := mix64(uint64(sampling.Seed))
+	dealt, dealerDone := dealSampleRows(ctx, rows, workersCount, seedKey, failed)
+	wg := new(sync.WaitGroup)
+	wg.Add(workersCount)
+
+	for i := 0; i < workersCount; i++ {
+		go func(workerID int) {
+			defer wg.Done()
+			observer.ObserveWorkers(1)
+			defer observer.ObserveWorkers(-1)
+			reports[workerID].WorkerID = workerID + 1
+
+			for {
+				var row desiredRow
+				var ok bool
+
+				select {
+				case row, ok = <-dealt[workerID]:
+				case <-failed:
+					return
+				case <-ctx.Done():
+					return
+				}
+
+				if !ok {
+					return
+				}
+				reports[workerID].RowsRead++
+				player, err := parseDesiredRow(row)
+
+				if err != nil {
+					failures[workerID] = &desiredFailure{seq: row.seq, err: err}
+					abort.Do(func() { close(failed) })
+
+					return
+				}
+
+				rejected := !predicate.Match(*player)
+				observer.ObserveRow(rejected)
+
+				if rejected {
+					continue
+				}
+				matched[workerID]++
+				candidate := sampledRow{key: sampleKey(seedKey, row.seq), seq: row.seq, worker: workerID, player: *player}
+				h := &kept[workerID]
+
+				if h.Len() < itemsPerWorker {
+					heap.Push(h, candidate)
+				} else if candidate.less((*h)[0]) {
+					(*h)[0] = candidate
+					heap.Fix(h, 0)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(stop)
+	<-readerDone
+	<-dealerDone
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	if err := firstFailure(failures); err != nil {
+		return nil, err
+	}
+	total := 0
+	for _, n := range matched {
+		total += n
+	}
+
+	return buildSampleResult(kept, reports, total, totalItems, workersCount*itemsPerWorker, sampling), nil
+}
+
+// dealSampleRows sends every row from rows to the worker picked by its key, until rows is closed, failed is closed
+// or ctx is done. The channels of the workers are closed when it finishes, then done.
+func dealSampleRows(ctx context.Context, rows <-chan desiredRow, workersCount int, seedKey uint64, failed <-chan struct{}) ([]chan desiredRow, <-chan struct{}) {
+	dealt := make([]chan desiredRow, workersCount)
+	for i := range dealt {
+		dealt[i] = make(chan desiredRow)
+	}
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		defer func() {
+			for _, ch := range dealt {
+				close(ch)
+			}
+		}()
+		for row := range rows {
+			worker := mix64(sampleKey(seedKey, row.seq)) % uint64(workersCount)
+
+			select {
+			case dealt[worker] <- row:
+			case <-failed:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return dealt, done
+}
+
+// buildSampleResult merges the rows kept by the workers. Sorted by key they are a uniformly random ordered subset
+// of the total matching rows. With replacement, up to totalItems positions are drawn from the total rows and each
+// distinct position takes the next row of that subset, which gives independent uniform draws until the subset
+// runs out, which only happens when a worker had to drop rows to keep its items per worker.
+func buildSampleResult(kept []sampleHeap, reports []e.WorkerReport, total int, totalItems int, capacity int, sampling e.MLBPlayerSampling) *e.MLBPlayerDesiredResult {
+	var all []sampledRow
+	for _, h := range kept {
+		all = append(all, h...)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].less(all[j]) })
+	sample := all
+
+	if sampling.Replacement && total > 0 {
+		draws := rand.New(rand.NewSource(sampling.Seed))
+		assigned := make(map[int]int)
+		sample = make([]sampledRow, 0, capacity)
+		for len(sample) < capacity {
+			position := draws.Intn(total)
+			j, ok := assigned[position]
+
+			if !ok {
+				j = len(assigned)
+				assigned[position] = j
+			}
+
+			if j == len(all) {
+				break
+			}
+			sample = append(sample, all[j])
+		}
+	}
+
+	players := make([]e.MLBPlayer, 0, len(sample))
+	for _, row := range sample {
+		players = append(players, row.player)
+		reports[row.worker].Items++
+	}
+
+	reason := e.StopReasonEOF
+	switch {
+	case len(players) == totalItems:
+		reason = e.StopReasonItemsReached
+	case len(players) == capacity || len(all) < total:
+		reason = e.StopReasonWorkersLimit
+	}
+
+	return &e.MLBPlayerDesiredResult{
+		Players:    players,
+		StopReason: reason,
+		Workers:    reports,
+	}
+}
+
+// sampleKey is the random key of the row at seq, uniform over uint64 for every seed.
+func sampleKey(seedKey uint64, seq int) uint64 {
+	return mix64(seedKey + uint64(seq+1)*0x9e3779b97f4a7c15)
+}
+
+// mix64 is the splitmix64 finalizer.
+func mix64(z uint64) uint64 {
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+
+	return z ^ (z >> 31)
+}
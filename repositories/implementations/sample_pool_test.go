@@ -0,0 +1,290 @@
+package repositories
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"testing"
+
+	e "github.com/EloYaniel/academy-go-q42021/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+// chiSquareCritical19 is the 0.999 quantile of the chi-square distribution with 19 degrees of freedom,
+// so a uniform sampler fails a check once every thousand seeds sets at most.
+const chiSquareCritical19 = 43.82
+
+// numberedRows returns a next function producing n players with IDs 1 to n.
+func numberedRows(n int) func() ([]string, error) {
+	id := 0
+
+	return func() ([]string, error) {
+		if id == n {
+			return nil, io.EOF
+		}
+		id++
+
+		return []string{strconv.Itoa(id), "Adam Donachie", "BAL", "Catcher", "74", "180", "22.99"}, nil
+	}
+}
+
+func chiSquare(counts map[int]int, categories int, expected float64) float64 {
+	statistic := 0.0
+	for id := 1; id <= categories; id++ {
+		d := float64(counts[id]) - expected
+		statistic += d * d / expected
+	}
+
+	return statistic
+}
+
+func sampleIDs(t *testing.T, rows int, items int, itemsPerWorker int, sampling e.MLBPlayerSampling) []int {
+	result, err := runSamplePool(context.Background(), numberedRows(rows), e.MLBPlayerAnd{}, items, itemsPerWorker, sampling, nopObserver{})
+	assert.Nil(t, err)
+
+	ids := make([]int, 0, len(result.Players))
+	for _, p := range result.Players {
+		ids = append(ids, p.ID)
+	}
+
+	return ids
+}
+
+func Test_runSamplePool_ShouldBeUniformWithoutReplacement(t *testing.T) {
+	const rows, items, seeds = 20, 5, 4000
+	included := make(map[int]int)
+	first := make(map[int]int)
+
+	for seed := int64(0); seed < seeds; seed++ {
+		ids := sampleIDs(t, rows, items, items, e.MLBPlayerSampling{Seed: seed})
+
+		assert.Len(t, ids, items)
+		distinct := make(map[int]bool)
+		for _, id := range ids {
+			distinct[id] = true
+			included[id]++
+		}
+		assert.Len(t, distinct, items)
+		first[ids[0]]++
+	}
+
+	assert.Less(t, chiSquare(included, rows, float64(seeds*items)/rows), chiSquareCritical19)
+	assert.Less(t, chiSquare(first, rows, float64(seeds)/rows), chiSquareCritical19)
+}
+
+func Test_runSamplePool_ShouldBeUniformWithReplacement(t *testing.T) {
+	const rows, items, seeds = 20, 5, 4000
+	drawn := make(map[int]int)
+	withDuplicates := 0
+
+	for seed := int64(0); seed < seeds; seed++ {
+		ids := sampleIDs(t, rows, items, items, e.MLBPlayerSampling{Seed: seed, Replacement: true})
+
+		assert.Len(t, ids, items)
+		distinct := make(map[int]bool)
+		for _, id := range ids {
+			distinct[id] = true
+			drawn[id]++
+		}
+
+		if len(distinct) < items {
+			withDuplicates++
+		}
+	}
+
+	// 5 independent draws out of 20 repeat a player with probability 1 - 20*19*18*17*16/20^5.
+	assert.InDelta(t, 0.4186, float64(withDuplicates)/seeds, 0.04)
+	assert.Less(t, chiSquare(drawn, rows, float64(seeds*items)/rows), chiSquareCritical19)
+}
+
+func Test_runSamplePool_ShouldBeUniformAcrossTheWorkers(t *testing.T) {
+	const rows, items, seeds = 20, 6, 4000
+	included := make(map[int]int)
+	sampled := 0
+
+	for seed := int64(0); seed < seeds; seed++ {
+		ids := sampleIDs(t, rows, items, 2, e.MLBPlayerSampling{Seed: seed})
+
+		assert.LessOrEqual(t, len(ids), items)
+		for _, id := range ids {
+			included[id]++
+		}
+		sampled += len(ids)
+	}
+
+	assert.Less(t, chiSquare(included, rows, float64(sampled)/rows), chiSquareCritical19)
+}
+
+func Test_runSamplePool_ShouldOnlyDependOnTheSeed(t *testing.T) {
+	for _, sampling := range []e.MLBPlayerSampling{{Seed: 42}, {Seed: 42, Replacement: true}} {
+		for _, itemsPerWorker := range []int{12, 3, 1} {
+			expected := sampleIDs(t, 100, 12, itemsPerWorker, sampling)
+
+			assert.Equal(t, expected, sampleIDs(t, 100, 12, itemsPerWorker, sampling))
+		}
+	}
+
+	assert.NotEqual(t, sampleIDs(t, 100, 12, 3, e.MLBPlayerSampling{Seed: 1}), sampleIDs(t, 100, 12, 3, e.MLBPlayerSampling{Seed: 2}))
+}
+
+func Test_runSamplePool_ShouldKeepItemsPerWorker(t *testing.T) {
+	testCases := []struct {
+		name           string
+		rows           int
+		totalItems     int
+		itemsPerWorker int
+		sampling       e.MLBPlayerSampling
+		expectedItems  int
+		expectedReason e.StopReason
+	}{
+		{
+			name:           "Should stop when the items are reached",
+			rows:           100,
+			totalItems:     6,
+			itemsPerWorker: 2,
+			expectedItems:  6,
+			expectedReason: e.StopReasonItemsReached,
+		},
+		{
+			name:           "Should stop at the limit of the workers when it is less than the items",
+			rows:           100,
+			totalItems:     7,
+			itemsPerWorker: 2,
+			expectedItems:  6,
+			expectedReason: e.StopReasonWorkersLimit,
+		},
+		{
+			name:           "Should stop at the limit of the workers with replacement",
+			rows:           100,
+			totalItems:     7,
+			itemsPerWorker: 2,
+			sampling:       e.MLBPlayerSampling{Replacement: true},
+			expectedItems:  6,
+			expectedReason: e.StopReasonWorkersLimit,
+		},
+		{
+			name:           "Should stop at the end of the rows when there are less than the items",
+			rows:           3,
+			totalItems:     30,
+			itemsPerWorker: 10,
+			expectedItems:  3,
+			expectedReason: e.StopReasonEOF,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for seed := int64(0); seed < 100; seed++ {
+				tc.sampling.Seed = seed
+				result, err := runSamplePool(context.Background(), numberedRows(tc.rows), e.MLBPlayerAnd{}, tc.totalItems, tc.itemsPerWorker, tc.sampling, nopObserver{})
+
+				assert.Nil(t, err)
+				assert.Len(t, result.Players, tc.expectedItems)
+				assert.Equal(t, tc.expectedReason, result.StopReason)
+				assert.Len(t, result.Workers, tc.totalItems/tc.itemsPerWorker)
+				distinct := make(map[int]bool)
+				for _, p := range result.Players {
+					distinct[p.ID] = true
+				}
+				assert.LessOrEqual(t, len(distinct), len(result.Workers)*tc.itemsPerWorker)
+
+				// With replacement a kept player may be drawn more than once.
+				if !tc.sampling.Replacement {
+					for _, w := range result.Workers {
+						assert.LessOrEqual(t, w.Items, tc.itemsPerWorker)
+					}
+				}
+			}
+		})
+	}
+}
+
+func Test_runSamplePool_ShouldReportTheWorkersLimitWhenAWorkerDropsRows(t *testing.T) {
+	// 4 rows dealt to 3 workers that keep 1 player each always drop a row, a worker may be dealt none.
+	uneven := 0
+	for seed := int64(0); seed < 100; seed++ {
+		result, err := runSamplePool(context.Background(), numberedRows(4), e.MLBPlayerAnd{}, 3, 1, e.MLBPlayerSampling{Seed: seed}, nopObserver{})
+		assert.Nil(t, err)
+
+		if len(result.Players) == 3 {
+			assert.Equal(t, e.StopReasonItemsReached, result.StopReason)
+
+			continue
+		}
+		uneven++
+		assert.Equal(t, e.StopReasonWorkersLimit, result.StopReason)
+	}
+
+	assert.NotZero(t, uneven)
+}
+
+func Test_GetMLBPlayerSample_Suite(t *testing.T) {
+	testCases := []struct {
+		name            string
+		predicate       e.MLBPlayerPredicate
+		totalItems      int
+		sampling        e.MLBPlayerSampling
+		expectedPlayers int
+		expectedIDs     []int
+		expectedReason  e.StopReason
+	}{
+		{
+			name:            "Should only sample matching players",
+			predicate:       e.MLBPlayerIDParity{Even: true},
+			totalItems:      10,
+			expectedPlayers: 10,
+			expectedReason:  e.StopReasonItemsReached,
+		},
+		{
+			name:            "Should return every matching player when there are less than items",
+			predicate:       mustCondition(t, "id", e.OpLessOrEqual, "3"),
+			totalItems:      10,
+			expectedPlayers: 3,
+			expectedIDs:     []int{1, 2, 3},
+			expectedReason:  e.StopReasonEOF,
+		},
+		{
+			name:            "Should return items players with replacement when there are less",
+			predicate:       mustCondition(t, "id", e.OpLessOrEqual, "3"),
+			totalItems:      10,
+			sampling:        e.MLBPlayerSampling{Replacement: true},
+			expectedPlayers: 10,
+			expectedIDs:     []int{1, 2, 3},
+			expectedReason:  e.StopReasonItemsReached,
+		},
+		{
+			name:            "Should return nothing without matching players",
+			predicate:       mustCondition(t, "team", e.OpEqual, "NYY"),
+			totalItems:      10,
+			sampling:        e.MLBPlayerSampling{Replacement: true},
+			expectedPlayers: 0,
+			expectedReason:  e.StopReasonEOF,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := NewCSVMLBPlayerRepository("../../data/mlb_players.csv")
+
+			result, err := repo.GetMLBPlayerSample(context.Background(), tc.predicate, tc.totalItems, 2, tc.sampling)
+
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedReason, result.StopReason)
+			assert.Len(t, result.Players, tc.expectedPlayers)
+			items, rowsRead := 0, 0
+			for _, w := range result.Workers {
+				items += w.Items
+				rowsRead += w.RowsRead
+			}
+			assert.Equal(t, tc.expectedPlayers, items)
+			assert.Equal(t, 100, rowsRead)
+			for _, p := range result.Players {
+				assert.True(t, tc.predicate.Match(p))
+
+				if tc.expectedIDs != nil {
+					assert.Contains(t, tc.expectedIDs, p.ID)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+
+	e "github.com/EloYaniel/academy-go-q42021/entities"
+
+	// registers the pure Go "sqlite" driver.
+	_ "modernc.org/sqlite"
+)
+
+// sqliteMigrations are the schema changes applied in order. Append new ones, never edit applied ones.
+var sqliteMigrations = []string{
+	`CREATE TABLE mlb_players (
+		id INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		team TEXT NOT NULL,
+		position TEXT NOT NULL,
+		height_inches INTEGER NOT NULL,
+		weight_lbs REAL NOT NULL,
+		age REAL NOT NULL
+	)`,
+	`CREATE TABLE users (
+		id INTEGER PRIMARY KEY,
+		email TEXT NOT NULL,
+		first_name TEXT NOT NULL,
+		last_name TEXT NOT NULL,
+		avatar TEXT NOT NULL
+	)`,
+	`CREATE TABLE csv_imports (
+		source TEXT PRIMARY KEY,
+		imported_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`,
+	// The databases created before csv_imports already imported the files of the tables with rows.
+	`INSERT INTO csv_imports (source) SELECT 'mlb_players' WHERE EXISTS (SELECT 1 FROM mlb_players)`,
+	`INSERT INTO csv_imports (source) SELECT 'users' WHERE EXISTS (SELECT 1 FROM users)`,
+}
+
+// OpenSQLite opens the database file and applies the pending migrations.
+func OpenSQLite(filePath string) (*sql.DB, error) {
+	dsn := fmt.Sprint("file:", url.PathEscape(filePath), "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	db, err := sql.Open("sqlite", dsn)
+
+	if err != nil {
+		return nil, errors.New(fmt.Sprint("error opening the database:", err.Error()))
+	}
+
+	if err = migrateSQLite(db); err != nil {
+		db.Close()
+
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// migrateSQLite applies the migrations not registered in schema_migrations.
+func migrateSQLite(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+
+	if err != nil {
+		return errors.New(fmt.Sprint("error migrating the database:", err.Error()))
+	}
+	var current int
+	err = db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current)
+
+	if err != nil {
+		return errors.New(fmt.Sprint("error migrating the database:", err.Error()))
+	}
+
+	for i := current; i < len(sqliteMigrations); i++ {
+		tx, err := db.Begin()
+
+		if err != nil {
+			return errors.New(fmt.Sprint("error migrating the database:", err.Error()))
+		}
+		_, err = tx.Exec(sqliteMigrations[i])
+
+		if err == nil {
+			_, err = tx.Exec("INSERT INTO schema_migrations (version) VALUES (?)", i+1)
+		}
+
+		if err == nil {
+			err = tx.Commit()
+		} else {
+			tx.Rollback()
+		}
+
+		if err != nil {
+			return errors.New(fmt.Sprint("error migrating the database to version ", i+1, ":", err.Error()))
+		}
+	}
+
+	return nil
+}
+
+// ImportCSVIntoSQLite copies the MLB Players and Users files into the database the first time it runs.
+// Each file is imported once, as recorded in csv_imports, so it is safe to run on every start and the rows
+// deleted afterwards do not come back. With a quarantine the malformed rows of the files are skipped into it
+// instead of failing the import.
+func ImportCSVIntoSQLite(ctx context.Context, db *sql.DB, playersFilePath string, usersFilePath string, quarantine *Quarantine) error {
+	imported, err := isCSVImported(db, "mlb_players")
+
+	if err != nil {
+		return err
+	}
+
+	if !imported {
+		players, err := NewCSVMLBPlayerRepository(playersFilePath).WithQuarantine(quarantine).GetMLBPlayers(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		if err = insertPlayers(ctx, db, players); err != nil {
+			return err
+		}
+	}
+	imported, err = isCSVImported(db, "users")
+
+	if err != nil || imported {
+		return err
+	}
+	users, err := NewCSVUserRepository(usersFilePath).WithQuarantine(quarantine).GetUsers(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err = NewSQLiteUserRepository(db).SaveUsers(ctx, users); err != nil {
+		return err
+	}
+
+	return recordCSVImport(ctx, db, "users")
+}
+
+func isCSVImported(db *sql.DB, source string) (bool, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM csv_imports WHERE source = ?", source).Scan(&count)
+
+	if err != nil {
+		return false, errors.New(fmt.Sprint("error reading the database:", err.Error()))
+	}
+
+	return count > 0, nil
+}
+
+// recordCSVImport records the file of source was imported. The users are saved with upserts,
+// so importing them again when the record fails does not duplicate them.
+func recordCSVImport(ctx context.Context, db execer, source string) error {
+	if _, err := db.ExecContext(ctx, "INSERT OR IGNORE INTO csv_imports (source) VALUES (?)", source); err != nil {
+		return errors.New(fmt.Sprint("error writing the database:", err.Error()))
+	}
+
+	return nil
+}
+
+// execer is a database or a transaction.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func insertPlayers(ctx context.Context, db *sql.DB, players []e.MLBPlayer) error {
+	tx, err := db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return errors.New(fmt.Sprint("error writing the database:", err.Error()))
+	}
+	defer tx.Rollback()
+
+	for _, p := range players {
+		_, err = tx.ExecContext(
+			ctx,
+			"INSERT INTO mlb_players (id, name, team, position, height_inches, weight_lbs, age) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			p.ID, p.Name, p.Team, p.Position, p.Height, p.Weight, p.Age,
+		)
+
+		if err != nil {
+			return errors.New(fmt.Sprint("error writing the database:", err.Error()))
+		}
+	}
+
+	if err = recordCSVImport(ctx, tx, "mlb_players"); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return errors.New(fmt.Sprint("error writing the database:", err.Error()))
+	}
+
+	return nil
+}
+
+// checkTableReadable counts the rows of table to check it can be queried.
+func checkTableReadable(ctx context.Context, db *sql.DB, table string) error {
+	var count int
+
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+table).Scan(&count); err != nil {
+		return dbError(ctx, "error reading the database")
+	}
+
+	return nil
+}
+
+// dbError returns ctx.Err() when ctx is done, so callers can tell a cancellation from a database failure.
+func dbError(ctx context.Context, message string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return errors.New(message)
+}
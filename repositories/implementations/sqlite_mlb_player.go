@@ -0,0 +1,307 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+
+	"github.com/EloYaniel/academy-go-q42021/csvcodec"
+	e "github.com/EloYaniel/academy-go-q42021/entities"
+)
+
+const selectPlayers = "SELECT id, name, team, position, height_inches, weight_lbs, age FROM mlb_players"
+
+// SQLiteMLBPlayerRepository struct implements MLBPlayerRepository interface
+type SQLiteMLBPlayerRepository struct {
+	db       *sql.DB
+	observer Observer
+}
+
+// NewSQLiteMLBPlayerRepository function creates a new instance of type SQLiteMLBPlayerRepository.
+func NewSQLiteMLBPlayerRepository(db *sql.DB) *SQLiteMLBPlayerRepository {
+	return &SQLiteMLBPlayerRepository{db: db, observer: nopObserver{}}
+}
+
+// WithObserver sets the observer notified of the work done by GetMLBPlayerDesired.
+func (repo *SQLiteMLBPlayerRepository) WithObserver(observer Observer) *SQLiteMLBPlayerRepository {
+	repo.observer = observer
+
+	return repo
+}
+
+// GetMLBPlayers gets all MLB Players from the database.
+func (repo *SQLiteMLBPlayerRepository) GetMLBPlayers(ctx context.Context) ([]e.MLBPlayer, error) {
+	rows, err := repo.db.QueryContext(ctx, selectPlayers+" ORDER BY id")
+
+	if err != nil {
+		return nil, dbError(ctx, "error reading the database")
+	}
+	defer rows.Close()
+	var players []e.MLBPlayer
+
+	for rows.Next() {
+		player, err := scanPlayer(rows)
+
+		if err != nil {
+			return nil, err
+		}
+		players = append(players, *player)
+	}
+
+	if rows.Err() != nil {
+		return nil, dbError(ctx, "error reading the database")
+	}
+
+	return players, nil
+}
+
+// EachMLBPlayer streams the MLB Players of the database in ID order, stopping at the first error of fn.
+func (repo *SQLiteMLBPlayerRepository) EachMLBPlayer(ctx context.Context, fn func(p e.MLBPlayer) error) error {
+	rows, err := repo.db.QueryContext(ctx, selectPlayers+" ORDER BY id")
+
+	if err != nil {
+		return dbError(ctx, "error reading the database")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		player, err := scanPlayer(rows)
+
+		if err != nil {
+			return err
+		}
+
+		if err = fn(*player); err != nil {
+			return err
+		}
+	}
+
+	if rows.Err() != nil {
+		return dbError(ctx, "error reading the database")
+	}
+
+	return nil
+}
+
+// GetMLBPlayerByID get a Player by its ID from the database.
+func (repo *SQLiteMLBPlayerRepository) GetMLBPlayerByID(ctx context.Context, id int) (*e.MLBPlayer, error) {
+	player, err := scanPlayer(repo.db.QueryRowContext(ctx, selectPlayers+" WHERE id = ?", id))
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, dbError(ctx, "error getting player")
+	}
+
+	return player, nil
+}
+
+// GetMLBPlayerDesired gets MLB Players from the database concurrently and filetered by its params.
+func (repo *SQLiteMLBPlayerRepository) GetMLBPlayerDesired(ctx context.Context, predicate e.MLBPlayerPredicate, totalItems int, itemsPerWorker int) (*e.MLBPlayerDesiredResult, error) {
+	next, done, err := repo.desiredRows(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	return runDesiredPool(ctx, next, predicate, totalItems, itemsPerWorker, repo.observer)
+}
+
+// GetMLBPlayerSample gets a random sample of the MLB Players matching predicate reading every row concurrently.
+func (repo *SQLiteMLBPlayerRepository) GetMLBPlayerSample(ctx context.Context, predicate e.MLBPlayerPredicate, totalItems int, itemsPerWorker int, sampling e.MLBPlayerSampling) (*e.MLBPlayerDesiredResult, error) {
+	next, done, err := repo.desiredRows(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	return runSamplePool(ctx, next, predicate, totalItems, itemsPerWorker, sampling, repo.observer)
+}
+
+// desiredRows queries the players in ID order for a concurrent read, done closes the rows.
+func (repo *SQLiteMLBPlayerRepository) desiredRows(ctx context.Context) (next func() ([]string, error), done func() error, err error) {
+	rows, err := repo.db.QueryContext(ctx, selectPlayers+" ORDER BY id")
+
+	if err != nil {
+		return nil, nil, dbError(ctx, "error reading the database")
+	}
+	next = func() ([]string, error) {
+		if !rows.Next() {
+			if rows.Err() != nil {
+				return nil, rows.Err()
+			}
+
+			return nil, io.EOF
+		}
+		player, err := scanPlayer(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return csvcodec.PlayerRecord(*player), nil
+	}
+
+	return next, rows.Close, nil
+}
+
+// CreateMLBPlayer saves a new Player to the database allocating its ID.
+func (repo *SQLiteMLBPlayerRepository) CreateMLBPlayer(ctx context.Context, player e.MLBPlayer) (*e.MLBPlayer, error) {
+	result, err := repo.db.ExecContext(
+		ctx,
+		"INSERT INTO mlb_players (name, team, position, height_inches, weight_lbs, age) VALUES (?, ?, ?, ?, ?, ?)",
+		player.Name, player.Team, player.Position, player.Height, player.Weight, player.Age,
+	)
+
+	if err != nil {
+		return nil, dbError(ctx, "error writing the database")
+	}
+	id, err := result.LastInsertId()
+
+	if err != nil {
+		return nil, dbError(ctx, "error writing the database")
+	}
+	player.ID = int(id)
+
+	return &player, nil
+}
+
+// UpdateMLBPlayer replaces the Player with the same ID in the database, nil if it does not exist.
+func (repo *SQLiteMLBPlayerRepository) UpdateMLBPlayer(ctx context.Context, player e.MLBPlayer) (*e.MLBPlayer, error) {
+	result, err := repo.db.ExecContext(
+		ctx,
+		"UPDATE mlb_players SET name = ?, team = ?, position = ?, height_inches = ?, weight_lbs = ?, age = ? WHERE id = ?",
+		player.Name, player.Team, player.Position, player.Height, player.Weight, player.Age, player.ID,
+	)
+
+	if err != nil {
+		return nil, dbError(ctx, "error writing the database")
+	}
+	affected, err := result.RowsAffected()
+
+	if err != nil {
+		return nil, dbError(ctx, "error writing the database")
+	}
+
+	if affected == 0 {
+		return nil, nil
+	}
+
+	return &player, nil
+}
+
+// DeleteMLBPlayer deletes a Player by its ID from the database, nil if it does not exist.
+func (repo *SQLiteMLBPlayerRepository) DeleteMLBPlayer(ctx context.Context, id int) (*e.MLBPlayer, error) {
+	tx, err := repo.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, dbError(ctx, "error writing the database")
+	}
+	defer tx.Rollback()
+	player, err := scanPlayer(tx.QueryRowContext(ctx, selectPlayers+" WHERE id = ?", id))
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, dbError(ctx, "error getting player")
+	}
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM mlb_players WHERE id = ?", id); err != nil {
+		return nil, dbError(ctx, "error writing the database")
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, dbError(ctx, "error writing the database")
+	}
+
+	return player, nil
+}
+
+// ImportMLBPlayers saves players in a single transaction, merged by ID with the stored ones or replacing them.
+func (repo *SQLiteMLBPlayerRepository) ImportMLBPlayers(ctx context.Context, players []e.MLBPlayer, mode e.MLBPlayerImportMode) (*e.MLBPlayerImportReport, error) {
+	tx, err := repo.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, dbError(ctx, "error writing the database")
+	}
+	defer tx.Rollback()
+	rows, err := tx.QueryContext(ctx, "SELECT id FROM mlb_players")
+
+	if err != nil {
+		return nil, dbError(ctx, "error getting players")
+	}
+	var stored []e.MLBPlayer
+	for rows.Next() {
+		var p e.MLBPlayer
+
+		if err = rows.Scan(&p.ID); err != nil {
+			rows.Close()
+
+			return nil, dbError(ctx, "error getting players")
+		}
+		stored = append(stored, p)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return nil, dbError(ctx, "error getting players")
+	}
+	_, report := mergeImportedPlayers(stored, players, mode)
+
+	if mode == e.ImportReplace {
+		if _, err = tx.ExecContext(ctx, "DELETE FROM mlb_players"); err != nil {
+			return nil, dbError(ctx, "error writing the database")
+		}
+	}
+	for _, p := range players {
+		_, err = tx.ExecContext(
+			ctx,
+			"INSERT INTO mlb_players (id, name, team, position, height_inches, weight_lbs, age) VALUES (?, ?, ?, ?, ?, ?, ?) "+
+				"ON CONFLICT (id) DO UPDATE SET name = excluded.name, team = excluded.team, position = excluded.position, "+
+				"height_inches = excluded.height_inches, weight_lbs = excluded.weight_lbs, age = excluded.age",
+			p.ID, p.Name, p.Team, p.Position, p.Height, p.Weight, p.Age,
+		)
+
+		if err != nil {
+			return nil, dbError(ctx, "error writing the database")
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, dbError(ctx, "error writing the database")
+	}
+
+	return report, nil
+}
+
+// CheckReadable checks the players table can be queried.
+func (repo *SQLiteMLBPlayerRepository) CheckReadable(ctx context.Context) error {
+	return checkTableReadable(ctx, repo.db, "mlb_players")
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPlayer(row rowScanner) (*e.MLBPlayer, error) {
+	var p e.MLBPlayer
+	err := row.Scan(&p.ID, &p.Name, &p.Team, &p.Position, &p.Height, &p.Weight, &p.Age)
+
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+
+	if err != nil {
+		return nil, errors.New("error reading the database")
+	}
+
+	return &p, nil
+}
@@ -0,0 +1,40 @@
+package repositories
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ImportCSVIntoSQLite_ShouldNotImportTheDeletedRowsAgain(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "test.db")
+	db, err := OpenSQLite(filePath)
+	assert.Nil(t, err)
+	assert.Nil(t, ImportCSVIntoSQLite(context.Background(), db, "../../data/test/players-test.csv", "../../data/test/users-test.csv", nil))
+	players := NewSQLiteMLBPlayerRepository(db)
+	stored, err := players.GetMLBPlayers(context.Background())
+	assert.Nil(t, err)
+	assert.NotEmpty(t, stored)
+
+	for _, p := range stored {
+		_, err = players.DeleteMLBPlayer(context.Background(), p.ID)
+		assert.Nil(t, err)
+	}
+	_, err = db.Exec("DELETE FROM users")
+	assert.Nil(t, err)
+	assert.Nil(t, db.Close())
+
+	db, err = OpenSQLite(filePath)
+	assert.Nil(t, err)
+	defer db.Close()
+	assert.Nil(t, ImportCSVIntoSQLite(context.Background(), db, "../../data/test/players-test.csv", "../../data/test/users-test.csv", nil))
+
+	stored, err = NewSQLiteMLBPlayerRepository(db).GetMLBPlayers(context.Background())
+	assert.Nil(t, err)
+	assert.Empty(t, stored)
+	users, err := NewSQLiteUserRepository(db).GetUsers(context.Background())
+	assert.Nil(t, err)
+	assert.Empty(t, users)
+}
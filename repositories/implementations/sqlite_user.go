@@ -0,0 +1,171 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	e "github.com/EloYaniel/academy-go-q42021/entities"
+)
+
+const selectUsers = "SELECT id, email, first_name, last_name, avatar FROM users"
+
+// SQLiteUserRepository struct implements UserRepository interface
+type SQLiteUserRepository struct {
+	db *sql.DB
+}
+
+// NewSQLiteUserRepository function creates a new instance of type SQLiteUserRepository.
+func NewSQLiteUserRepository(db *sql.DB) *SQLiteUserRepository {
+	return &SQLiteUserRepository{db: db}
+}
+
+// SaveUsers upserts users by ID in a single transaction, keeping the stored Users that are not in users.
+func (repo *SQLiteUserRepository) SaveUsers(ctx context.Context, users []e.User) (*e.UserSaveReport, error) {
+	tx, err := repo.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, dbError(ctx, "error writing the database")
+	}
+	defer tx.Rollback()
+	report := &e.UserSaveReport{}
+
+	for _, u := range users {
+		stored, err := scanUser(tx.QueryRowContext(ctx, selectUsers+" WHERE id = ?", u.ID))
+
+		switch {
+		case err == sql.ErrNoRows:
+			_, err = tx.ExecContext(
+				ctx,
+				"INSERT INTO users (id, email, first_name, last_name, avatar) VALUES (?, ?, ?, ?, ?)",
+				u.ID, u.Email, u.FirstName, u.LastName, u.Avatar,
+			)
+			report.Inserted++
+		case err != nil:
+		case *stored != u:
+			_, err = tx.ExecContext(
+				ctx,
+				"UPDATE users SET email = ?, first_name = ?, last_name = ?, avatar = ? WHERE id = ?",
+				u.Email, u.FirstName, u.LastName, u.Avatar, u.ID,
+			)
+			report.Updated++
+		default:
+			report.Unchanged++
+		}
+
+		if err != nil {
+			return nil, dbError(ctx, "error writing the database")
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, dbError(ctx, "error writing the database")
+	}
+
+	return report, nil
+}
+
+// GetUsers gets all Users from the database.
+func (repo *SQLiteUserRepository) GetUsers(ctx context.Context) ([]e.User, error) {
+	rows, err := repo.db.QueryContext(ctx, selectUsers+" ORDER BY id")
+
+	if err != nil {
+		return nil, dbError(ctx, "error reading the database")
+	}
+	defer rows.Close()
+	var users []e.User
+
+	for rows.Next() {
+		user, err := scanUser(rows)
+
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *user)
+	}
+
+	if rows.Err() != nil {
+		return nil, dbError(ctx, "error reading the database")
+	}
+
+	return users, nil
+}
+
+// EachUser streams the Users of the database in ID order, stopping at the first error of fn.
+func (repo *SQLiteUserRepository) EachUser(ctx context.Context, fn func(u e.User) error) error {
+	rows, err := repo.db.QueryContext(ctx, selectUsers+" ORDER BY id")
+
+	if err != nil {
+		return dbError(ctx, "error reading the database")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		user, err := scanUser(rows)
+
+		if err != nil {
+			return err
+		}
+
+		if err = fn(*user); err != nil {
+			return err
+		}
+	}
+
+	if rows.Err() != nil {
+		return dbError(ctx, "error reading the database")
+	}
+
+	return nil
+}
+
+// GetUserByID get a User by its ID from the database.
+func (repo *SQLiteUserRepository) GetUserByID(ctx context.Context, id int) (*e.User, error) {
+	user, err := scanUser(repo.db.QueryRowContext(ctx, selectUsers+" WHERE id = ?", id))
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, dbError(ctx, "error getting user")
+	}
+
+	return user, nil
+}
+
+// CheckReadable checks the users table can be queried.
+func (repo *SQLiteUserRepository) CheckReadable(ctx context.Context) error {
+	return checkTableReadable(ctx, repo.db, "users")
+}
+
+// CheckWritable checks a write transaction on the users table can be started, rolling it back.
+func (repo *SQLiteUserRepository) CheckWritable(ctx context.Context) error {
+	tx, err := repo.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return dbError(ctx, "error writing the database")
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM users WHERE 0"); err != nil {
+		return dbError(ctx, "error writing the database")
+	}
+
+	return nil
+}
+
+func scanUser(row rowScanner) (*e.User, error) {
+	var u e.User
+	err := row.Scan(&u.ID, &u.Email, &u.FirstName, &u.LastName, &u.Avatar)
+
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+
+	if err != nil {
+		return nil, errors.New("error reading the database")
+	}
+
+	return &u, nil
+}
@@ -0,0 +1,86 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	e "github.com/EloYaniel/academy-go-q42021/entities"
+)
+
+// FileUserImportRepository struct implements UserImportRepository interface, keeping the state in a JSON file.
+type FileUserImportRepository struct {
+	filePath string
+	m        sync.Mutex
+}
+
+// NewFileUserImportRepository function creates a new instance of type FileUserImportRepository.
+func NewFileUserImportRepository(filePath string) *FileUserImportRepository {
+	return &FileUserImportRepository{filePath: filePath}
+}
+
+// GetUserImport reads the state of the last import of Users, a missing file has none.
+func (repo *FileUserImportRepository) GetUserImport(ctx context.Context) (*e.UserImport, error) {
+	repo.m.Lock()
+	defer repo.m.Unlock()
+	content, err := ioutil.ReadFile(repo.filePath)
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, errors.New("error opening the file")
+	}
+	userImport := &e.UserImport{}
+
+	if err = json.Unmarshal(content, userImport); err != nil {
+		return nil, errors.New("error reading the file")
+	}
+
+	return userImport, nil
+}
+
+// SaveUserImport replaces the state of the last import of Users in a single atomic replace of the file.
+func (repo *FileUserImportRepository) SaveUserImport(ctx context.Context, userImport e.UserImport) error {
+	repo.m.Lock()
+	defer repo.m.Unlock()
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	content, err := json.Marshal(userImport)
+
+	if err != nil {
+		return errors.New("error writing the file")
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(repo.filePath), filepath.Base(repo.filePath)+".*.tmp")
+
+	if err != nil {
+		return errors.New("error creating the file")
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(content)
+
+	if err == nil {
+		err = tmp.Sync()
+	}
+
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return errors.New("error writing the file")
+	}
+
+	if err := os.Rename(tmp.Name(), repo.filePath); err != nil {
+		return errors.New("error writing the file")
+	}
+
+	return nil
+}
@@ -0,0 +1,33 @@
+package repositories
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	e "github.com/EloYaniel/academy-go-q42021/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_FileUserImportRepository_ShouldKeepTheStateInTheFile(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "users.csv.import.json")
+	repo := NewFileUserImportRepository(filePath)
+
+	userImport, err := repo.GetUserImport(context.Background())
+	assert.Nil(t, err)
+	assert.Nil(t, userImport)
+
+	state := e.UserImport{PagesFetched: 1, TotalPages: 3, Total: 6, Imported: 2, Inserted: 2, NextPage: 2, StartedAt: time.Date(2021, time.November, 2, 10, 0, 0, 0, time.UTC)}
+	assert.Nil(t, repo.SaveUserImport(context.Background(), state))
+
+	userImport, err = NewFileUserImportRepository(filePath).GetUserImport(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, &state, userImport)
+
+	assert.Nil(t, os.WriteFile(filePath, []byte("{"), 0644))
+	userImport, err = repo.GetUserImport(context.Background())
+	assert.Equal(t, "error reading the file", err.Error())
+	assert.Nil(t, userImport)
+}
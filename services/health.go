@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	e "github.com/EloYaniel/academy-go-q42021/entities"
+)
+
+// HealthCheck checks a dependency of the application, nil when it is healthy.
+type HealthCheck func(ctx context.Context) error
+
+// namedHealthCheck is a HealthCheck as registered.
+type namedHealthCheck struct {
+	name     string
+	critical bool
+	check    HealthCheck
+}
+
+// HealthService struct runs the readiness checks registered by the application.
+type HealthService struct {
+	timeout time.Duration
+	logger  *slog.Logger
+	mu      sync.Mutex
+	checks  []namedHealthCheck
+}
+
+// NewHealthService function creates an instance of HealthService, every check is given up after timeout.
+func NewHealthService(timeout time.Duration, logger *slog.Logger) *HealthService {
+	return &HealthService{timeout: timeout, logger: logger}
+}
+
+// Register adds a readiness check, the application is down when a critical check fails.
+func (s *HealthService) Register(name string, critical bool, check HealthCheck) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checks = append(s.checks, namedHealthCheck{name: name, critical: critical, check: check})
+}
+
+// CheckReadiness runs every registered check concurrently and reports their outcome in the registration order.
+func (s *HealthService) CheckReadiness(ctx context.Context) e.HealthReport {
+	s.mu.Lock()
+	checks := make([]namedHealthCheck, len(s.checks))
+	copy(checks, s.checks)
+	s.mu.Unlock()
+
+	results := make([]e.HealthCheckResult, len(checks))
+	wg := sync.WaitGroup{}
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c namedHealthCheck) {
+			defer wg.Done()
+			results[i] = s.run(ctx, c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	report := e.HealthReport{Status: e.HealthUp, Checks: results}
+	for _, result := range results {
+		if result.Status == e.HealthUp {
+			continue
+		}
+		s.logger.WarnContext(ctx, "health check failed", "check", result.Name, "critical", result.Critical, "error", result.Error)
+
+		if result.Critical {
+			report.Status = e.HealthDown
+		} else if report.Status == e.HealthUp {
+			report.Status = e.HealthDegraded
+		}
+	}
+
+	return report
+}
+
+// run runs a single check, giving it up when the timeout expires even if it ignores its context.
+func (s *HealthService) run(ctx context.Context, c namedHealthCheck) e.HealthCheckResult {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	started := time.Now()
+	done := make(chan error, 1)
+
+	go func() {
+		done <- c.check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	result := e.HealthCheckResult{
+		Name:      c.name,
+		Status:    e.HealthUp,
+		Critical:  c.critical,
+		LatencyMs: float64(time.Since(started).Microseconds()) / 1000,
+	}
+
+	if err != nil {
+		result.Status = e.HealthDown
+		result.Error = err.Error()
+	}
+
+	return result
+}
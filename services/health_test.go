@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	e "github.com/EloYaniel/academy-go-q42021/entities"
+	"github.com/EloYaniel/academy-go-q42021/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func passingCheck(ctx context.Context) error {
+	return nil
+}
+
+func failingCheck(ctx context.Context) error {
+	return errors.New("error opening the file")
+}
+
+// hangingCheck ignores its context, like a dependency that never answers.
+func hangingCheck(ctx context.Context) error {
+	time.Sleep(time.Second)
+
+	return nil
+}
+
+func Test_CheckReadiness_Suite(t *testing.T) {
+	type check struct {
+		name     string
+		critical bool
+		check    HealthCheck
+	}
+	testCases := []struct {
+		name             string
+		checks           []check
+		expectedStatus   e.HealthStatus
+		expectedStatuses []e.HealthStatus
+		expectedErrors   []string
+	}{
+		{
+			name:           "Should be up without checks",
+			expectedStatus: e.HealthUp,
+		},
+		{
+			name: "Should be up when every check passes",
+			checks: []check{
+				{name: "mlb_players", critical: true, check: passingCheck},
+				{name: "users_api", check: passingCheck},
+			},
+			expectedStatus:   e.HealthUp,
+			expectedStatuses: []e.HealthStatus{e.HealthUp, e.HealthUp},
+			expectedErrors:   []string{"", ""},
+		},
+		{
+			name: "Should be degraded when a check that is not critical fails",
+			checks: []check{
+				{name: "mlb_players", critical: true, check: passingCheck},
+				{name: "users_api", check: failingCheck},
+			},
+			expectedStatus:   e.HealthDegraded,
+			expectedStatuses: []e.HealthStatus{e.HealthUp, e.HealthDown},
+			expectedErrors:   []string{"", "error opening the file"},
+		},
+		{
+			name: "Should be down when a critical check fails",
+			checks: []check{
+				{name: "mlb_players", critical: true, check: failingCheck},
+				{name: "users_api", check: failingCheck},
+			},
+			expectedStatus:   e.HealthDown,
+			expectedStatuses: []e.HealthStatus{e.HealthDown, e.HealthDown},
+			expectedErrors:   []string{"error opening the file", "error opening the file"},
+		},
+		{
+			name: "Should give up a check after the timeout",
+			checks: []check{
+				{name: "users_writable", critical: true, check: hangingCheck},
+			},
+			expectedStatus:   e.HealthDown,
+			expectedStatuses: []e.HealthStatus{e.HealthDown},
+			expectedErrors:   []string{context.DeadlineExceeded.Error()},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			service := NewHealthService(50*time.Millisecond, logger.Discard())
+			for _, c := range tc.checks {
+				service.Register(c.name, c.critical, c.check)
+			}
+			started := time.Now()
+
+			report := service.CheckReadiness(context.Background())
+
+			assert.Less(t, time.Since(started), 500*time.Millisecond)
+			assert.Equal(t, tc.expectedStatus, report.Status)
+			assert.Len(t, report.Checks, len(tc.checks))
+			for i, result := range report.Checks {
+				assert.Equal(t, tc.checks[i].name, result.Name)
+				assert.Equal(t, tc.checks[i].critical, result.Critical)
+				assert.Equal(t, tc.expectedStatuses[i], result.Status)
+				assert.Equal(t, tc.expectedErrors[i], result.Error)
+			}
+		})
+	}
+}
@@ -1,8 +1,16 @@
 package services
 
 import (
-	"log"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strings"
 
+	"github.com/EloYaniel/academy-go-q42021/csvcodec"
 	e "github.com/EloYaniel/academy-go-q42021/entities"
 	r "github.com/EloYaniel/academy-go-q42021/repositories/contracts"
 )
@@ -10,42 +18,310 @@ import (
 // MLBPlayerService struct handles MLB Players business logic.
 type MLBPlayerService struct {
 	repository r.MLBPlayerRepository
+	logger     *slog.Logger
 }
 
 // NewMLBPlayerService function return an instance of MLBPlayerService
-func NewMLBPlayerService(r r.MLBPlayerRepository) *MLBPlayerService {
-	return &MLBPlayerService{repository: r}
+func NewMLBPlayerService(r r.MLBPlayerRepository, logger *slog.Logger) *MLBPlayerService {
+	return &MLBPlayerService{repository: r, logger: logger}
 }
 
 // GetMLBPlayers gets all MLB Players.
-func (s *MLBPlayerService) GetMLBPlayers() ([]e.MLBPlayer, error) {
-	players, err := s.repository.GetMLBPlayers()
+func (s *MLBPlayerService) GetMLBPlayers(ctx context.Context) ([]e.MLBPlayer, error) {
+	players, err := s.repository.GetMLBPlayers(ctx)
 
 	if err != nil {
-		log.Println(err)
+		s.logger.ErrorContext(ctx, "error getting players", "error", err)
 	}
 
 	return players, err
 }
 
+// ExportMLBPlayers streams the MLB Players matching filter to fn in the order they are stored.
+func (s *MLBPlayerService) ExportMLBPlayers(ctx context.Context, filter e.MLBPlayerFilter, fn func(p e.MLBPlayer) error) error {
+	err := s.repository.EachMLBPlayer(ctx, func(p e.MLBPlayer) error {
+		if !filter.Match(p) {
+			return nil
+		}
+
+		return fn(p)
+	})
+
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error exporting players", "error", err)
+	}
+
+	return err
+}
+
 // GetMLBPlayerByID get a Player by its ID
-func (s *MLBPlayerService) GetMLBPlayerByID(id int) (*e.MLBPlayer, error) {
-	player, err := s.repository.GetMLBPlayerByID(id)
+func (s *MLBPlayerService) GetMLBPlayerByID(ctx context.Context, id int) (*e.MLBPlayer, error) {
+	player, err := s.repository.GetMLBPlayerByID(ctx, id)
 
 	if err != nil {
-		log.Println(err)
+		s.logger.ErrorContext(ctx, "error getting player", "error", err, "id", id)
 	}
 
 	return player, err
 }
 
 // GetMLBPlayerDesired gets MLB Players and filetered by its params.
-func (s *MLBPlayerService) GetMLBPlayerDesired(filterType string, totalItems int, itemsPerWorker int) ([]e.MLBPlayer, error) {
-	players, err := s.repository.GetMLBPlayerDesired(filterType, totalItems, itemsPerWorker)
+func (s *MLBPlayerService) GetMLBPlayerDesired(ctx context.Context, predicate e.MLBPlayerPredicate, totalItems int, itemsPerWorker int) (*e.MLBPlayerDesiredResult, error) {
+	result, err := s.repository.GetMLBPlayerDesired(ctx, predicate, totalItems, itemsPerWorker)
 
 	if err != nil {
-		log.Println(err)
+		s.logger.ErrorContext(ctx, "error getting desired players", "error", err)
 	}
 
-	return players, err
+	return result, err
+}
+
+// GetMLBPlayerSample gets a random sample of the MLB Players matching predicate.
+func (s *MLBPlayerService) GetMLBPlayerSample(ctx context.Context, predicate e.MLBPlayerPredicate, totalItems int, itemsPerWorker int, sampling e.MLBPlayerSampling) (*e.MLBPlayerDesiredResult, error) {
+	result, err := s.repository.GetMLBPlayerSample(ctx, predicate, totalItems, itemsPerWorker, sampling)
+
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error sampling players", "error", err, "seed", sampling.Seed)
+	}
+
+	return result, err
+}
+
+// CreateMLBPlayer validates and saves a new Player.
+func (s *MLBPlayerService) CreateMLBPlayer(ctx context.Context, player e.MLBPlayer) (*e.MLBPlayer, error) {
+	err := player.Validate()
+
+	if err != nil {
+		return nil, err
+	}
+	created, err := s.repository.CreateMLBPlayer(ctx, player)
+
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error creating player", "error", err)
+	}
+
+	return created, err
+}
+
+// UpdateMLBPlayer validates and replaces the Player with the given ID, nil if it does not exist.
+func (s *MLBPlayerService) UpdateMLBPlayer(ctx context.Context, id int, player e.MLBPlayer) (*e.MLBPlayer, error) {
+	player.ID = id
+	err := player.Validate()
+
+	if err != nil {
+		return nil, err
+	}
+	updated, err := s.repository.UpdateMLBPlayer(ctx, player)
+
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error updating player", "error", err, "id", id)
+	}
+
+	return updated, err
+}
+
+// PatchMLBPlayer changes the fields set in patch of the Player with the given ID, nil if it does not exist.
+func (s *MLBPlayerService) PatchMLBPlayer(ctx context.Context, id int, patch e.MLBPlayerPatch) (*e.MLBPlayer, error) {
+	player, err := s.repository.GetMLBPlayerByID(ctx, id)
+
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error getting player", "error", err, "id", id)
+
+		return nil, err
+	}
+
+	if player == nil {
+		return nil, nil
+	}
+	patch.Apply(player)
+
+	return s.UpdateMLBPlayer(ctx, id, *player)
+}
+
+// DeleteMLBPlayer deletes a Player by its ID, nil if it does not exist.
+func (s *MLBPlayerService) DeleteMLBPlayer(ctx context.Context, id int) (*e.MLBPlayer, error) {
+	player, err := s.repository.DeleteMLBPlayer(ctx, id)
+
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error deleting player", "error", err, "id", id)
+	}
+
+	return player, err
+}
+
+// ImportMLBPlayers reads a CSV file of MLB Players and saves the rows that parse and validate in a single commit.
+// The other rows are reported with their line and reason, nothing is saved when no row is accepted. A replace
+// is not saved when any row is rejected either, since it would drop the stored players the rejected rows stand for.
+func (s *MLBPlayerService) ImportMLBPlayers(ctx context.Context, file io.Reader, mode e.MLBPlayerImportMode) (*e.MLBPlayerImportReport, error) {
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+
+	if err != nil || !isPlayerHeader(header) {
+		return nil, &e.ValidationError{Field: "file", Message: "must start with the header " + strings.Join(csvcodec.PlayerHeader, ",")}
+	}
+	var players []e.MLBPlayer
+	var rejected []e.MLBPlayerImportRejection
+	lines := make(map[int]int)
+
+	for {
+		record, err := reader.Read()
+
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+
+		if errors.As(err, &parseErr) {
+			rejected = append(rejected, e.MLBPlayerImportRejection{Line: parseErr.StartLine, Reason: parseErr.Err.Error()})
+
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		player, err := csvcodec.ParsePlayer(record)
+
+		if err == nil {
+			err = validateImportedPlayer(*player)
+		}
+
+		if err == nil {
+			if first, ok := lines[player.ID]; ok {
+				err = errors.New(fmt.Sprint("duplicated id, first seen at line ", first))
+			}
+		}
+
+		if err != nil {
+			rejected = append(rejected, e.MLBPlayerImportRejection{Line: line, Reason: err.Error()})
+
+			continue
+		}
+		lines[player.ID] = line
+		players = append(players, *player)
+	}
+	report := &e.MLBPlayerImportReport{Mode: mode}
+
+	if len(players) > 0 && (mode != e.ImportReplace || len(rejected) == 0) {
+		report, err = s.repository.ImportMLBPlayers(ctx, players, mode)
+
+		if err != nil {
+			s.logger.ErrorContext(ctx, "error importing players", "error", err, "mode", mode)
+
+			return nil, err
+		}
+		report.Committed = true
+	}
+	report.Accepted = len(players)
+	report.Rejected = rejected
+
+	if report.Rejected == nil {
+		report.Rejected = []e.MLBPlayerImportRejection{}
+	}
+	s.logger.InfoContext(ctx, "players imported", "mode", mode, "accepted", report.Accepted, "rejected", len(rejected))
+
+	return report, nil
+}
+
+// isPlayerHeader tells if header is the one of the MLB Players files, ignoring case, spaces and a byte order mark.
+func isPlayerHeader(header []string) bool {
+	if len(header) != len(csvcodec.PlayerHeader) {
+		return false
+	}
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+
+		if !strings.EqualFold(strings.TrimSpace(name), csvcodec.PlayerHeader[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// validateImportedPlayer checks an imported player has the fields of a created one and an ID of its own.
+func validateImportedPlayer(player e.MLBPlayer) error {
+	if player.ID <= 0 {
+		return &e.ValidationError{Field: "id", Message: "must be greater than 0"}
+	}
+
+	return player.Validate()
+}
+
+// SearchMLBPlayers gets the page of MLB Players matching the query.
+func (s *MLBPlayerService) SearchMLBPlayers(ctx context.Context, query e.MLBPlayerQuery) (*e.MLBPlayerPage, error) {
+	players, err := s.repository.GetMLBPlayers(ctx)
+
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error searching players", "error", err)
+
+		return nil, err
+	}
+	matched := filterMLBPlayers(players, query.Filter)
+	sortMLBPlayers(matched, query.Sort)
+
+	return &e.MLBPlayerPage{
+		Players: pageMLBPlayers(matched, query.Limit, query.Offset),
+		Total:   len(matched),
+		Limit:   query.Limit,
+		Offset:  query.Offset,
+	}, nil
+}
+
+// StreamMLBPlayers streams every MLB Player matching filter to fn, in the order of sort or else in the order they are stored.
+// Without sort they are read one by one from the repository, sorting them needs them all in memory first.
+func (s *MLBPlayerService) StreamMLBPlayers(ctx context.Context, filter e.MLBPlayerFilter, sort []e.SortKey, fn func(p e.MLBPlayer) error) error {
+	if len(sort) == 0 {
+		return s.ExportMLBPlayers(ctx, filter, fn)
+	}
+	players, err := s.repository.GetMLBPlayers(ctx)
+
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error streaming players", "error", err)
+
+		return err
+	}
+	matched := filterMLBPlayers(players, filter)
+	sortMLBPlayers(matched, sort)
+
+	for _, p := range matched {
+		if err = fn(p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetMLBPlayerStats gets the statistics of the MLB Players matching filter, grouped by team or position
+// or in a single group when groupBy is empty.
+func (s *MLBPlayerService) GetMLBPlayerStats(ctx context.Context, filter e.MLBPlayerFilter, groupBy string, percentiles []float64) ([]e.MLBPlayerGroupStats, error) {
+	players, err := s.repository.GetMLBPlayers(ctx)
+
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error getting player stats", "error", err)
+
+		return nil, err
+	}
+	groups := groupMLBPlayers(filterMLBPlayers(players, filter), groupBy)
+
+	if groupBy == "" && len(groups) == 0 {
+		return []e.MLBPlayerGroupStats{mlbPlayerGroupStats("", nil, percentiles)}, nil
+	}
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	stats := make([]e.MLBPlayerGroupStats, 0, len(names))
+	for _, name := range names {
+		stats = append(stats, mlbPlayerGroupStats(name, groups[name], percentiles))
+	}
+
+	return stats, nil
 }
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"sort"
+	"strings"
+
+	e "github.com/EloYaniel/academy-go-q42021/entities"
+)
+
+// filterMLBPlayers gets the players matching filter keeping their order.
+func filterMLBPlayers(players []e.MLBPlayer, filter e.MLBPlayerFilter) []e.MLBPlayer {
+	matched := make([]e.MLBPlayer, 0, len(players))
+	for _, p := range players {
+		if filter.Match(p) {
+			matched = append(matched, p)
+		}
+	}
+
+	return matched
+}
+
+// sortMLBPlayers sorts players by keys, players equal on every key keep their order.
+func sortMLBPlayers(players []e.MLBPlayer, keys []e.SortKey) {
+	if len(keys) == 0 {
+		return
+	}
+
+	sort.SliceStable(players, func(i, j int) bool {
+		for _, key := range keys {
+			c := compareMLBPlayers(players[i], players[j], key.Field)
+
+			if c == 0 {
+				continue
+			}
+
+			if key.Desc {
+				return c > 0
+			}
+
+			return c < 0
+		}
+
+		return false
+	})
+}
+
+func compareMLBPlayers(a e.MLBPlayer, b e.MLBPlayer, field string) int {
+	switch field {
+	case "id":
+		return compareFloats(float64(a.ID), float64(b.ID))
+	case "name":
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	case "team":
+		return strings.Compare(strings.ToLower(a.Team), strings.ToLower(b.Team))
+	case "position":
+		return strings.Compare(strings.ToLower(a.Position), strings.ToLower(b.Position))
+	case "height_inches":
+		return compareFloats(float64(a.Height), float64(b.Height))
+	case "weight_lbs":
+		return compareFloats(float64(a.Weight), float64(b.Weight))
+	case "age":
+		return compareFloats(float64(a.Age), float64(b.Age))
+	}
+
+	return 0
+}
+
+func compareFloats(a float64, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+
+	return 0
+}
+
+// pageMLBPlayers gets the players between offset and offset+limit.
+func pageMLBPlayers(players []e.MLBPlayer, limit int, offset int) []e.MLBPlayer {
+	if offset >= len(players) {
+		return []e.MLBPlayer{}
+	}
+	end := len(players)
+
+	if limit > 0 && offset+limit < end {
+		end = offset + limit
+	}
+
+	return players[offset:end]
+}
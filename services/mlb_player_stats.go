@@ -0,0 +1,83 @@
+package services
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	e "github.com/EloYaniel/academy-go-q42021/entities"
+)
+
+// groupMLBPlayers splits players by team or position, every player in a single group when groupBy is empty.
+func groupMLBPlayers(players []e.MLBPlayer, groupBy string) map[string][]e.MLBPlayer {
+	groups := map[string][]e.MLBPlayer{}
+	for _, p := range players {
+		key := ""
+		switch groupBy {
+		case "team":
+			key = p.Team
+		case "position":
+			key = p.Position
+		}
+		groups[key] = append(groups[key], p)
+	}
+
+	return groups
+}
+
+func mlbPlayerGroupStats(group string, players []e.MLBPlayer, percentiles []float64) e.MLBPlayerGroupStats {
+	heights := make([]float64, len(players))
+	weights := make([]float64, len(players))
+	ages := make([]float64, len(players))
+	for i, p := range players {
+		heights[i] = float64(p.Height)
+		weights[i] = roundStat(float64(p.Weight))
+		ages[i] = roundStat(float64(p.Age))
+	}
+
+	return e.MLBPlayerGroupStats{
+		Group:  group,
+		Count:  len(players),
+		Height: computeStats(heights, percentiles),
+		Weight: computeStats(weights, percentiles),
+		Age:    computeStats(ages, percentiles),
+	}
+}
+
+// computeStats gets the statistics of values, percentiles are between 0 and 100.
+func computeStats(values []float64, percentiles []float64) e.Stats {
+	stats := e.Stats{Percentiles: map[string]float64{}}
+
+	if len(values) == 0 {
+		return stats
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	sum := 0.0
+	for _, v := range sorted {
+		sum += v
+	}
+	stats.Mean = roundStat(sum / float64(len(sorted)))
+	stats.Median = percentile(sorted, 50)
+	stats.Min = sorted[0]
+	stats.Max = sorted[len(sorted)-1]
+	for _, p := range percentiles {
+		stats.Percentiles["p"+strconv.FormatFloat(p, 'f', -1, 64)] = percentile(sorted, p)
+	}
+
+	return stats
+}
+
+// percentile gets the p percentile of sorted values interpolating between the closest ranks.
+func percentile(sorted []float64, p float64) float64 {
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+
+	return roundStat(sorted[lower] + (rank-float64(lower))*(sorted[upper]-sorted[lower]))
+}
+
+// roundStat rounds to 4 decimals to hide float32 noise from the data.
+func roundStat(v float64) float64 {
+	return math.Round(v*10000) / 10000
+}
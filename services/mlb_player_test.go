@@ -1,10 +1,13 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"strings"
 	"testing"
 
 	e "github.com/EloYaniel/academy-go-q42021/entities"
+	"github.com/EloYaniel/academy-go-q42021/logger"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -13,27 +16,69 @@ type mockMLBPlayerRepository struct {
 	mock.Mock
 }
 
-func (m *mockMLBPlayerRepository) GetMLBPlayers() ([]e.MLBPlayer, error) {
+func (m *mockMLBPlayerRepository) GetMLBPlayers(ctx context.Context) ([]e.MLBPlayer, error) {
 	args := m.Called()
 
 	return args.Get(0).([]e.MLBPlayer), args.Error(1)
 }
 
-func (m *mockMLBPlayerRepository) GetMLBPlayerByID(id int) (*e.MLBPlayer, error) {
+func (m *mockMLBPlayerRepository) EachMLBPlayer(ctx context.Context, fn func(p e.MLBPlayer) error) error {
+	args := m.Called()
+	players, _ := args.Get(0).([]e.MLBPlayer)
+	for _, p := range players {
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+
+	return args.Error(1)
+}
+
+func (m *mockMLBPlayerRepository) GetMLBPlayerByID(ctx context.Context, id int) (*e.MLBPlayer, error) {
 	args := m.Called()
 
 	return args.Get(0).(*e.MLBPlayer), args.Error(1)
 }
 
-func (m *mockMLBPlayerRepository) GetMLBPlayerDesired(filterType string, totalItems int, itemsPerWorker int) ([]e.MLBPlayer, error) {
+func (m *mockMLBPlayerRepository) GetMLBPlayerDesired(ctx context.Context, predicate e.MLBPlayerPredicate, totalItems int, itemsPerWorker int) (*e.MLBPlayerDesiredResult, error) {
 	args := m.Called()
 
-	return args.Get(0).([]e.MLBPlayer), args.Error(1)
+	return args.Get(0).(*e.MLBPlayerDesiredResult), args.Error(1)
+}
+
+func (m *mockMLBPlayerRepository) GetMLBPlayerSample(ctx context.Context, predicate e.MLBPlayerPredicate, totalItems int, itemsPerWorker int, sampling e.MLBPlayerSampling) (*e.MLBPlayerDesiredResult, error) {
+	args := m.Called(sampling)
+
+	return args.Get(0).(*e.MLBPlayerDesiredResult), args.Error(1)
+}
+
+func (m *mockMLBPlayerRepository) CreateMLBPlayer(ctx context.Context, player e.MLBPlayer) (*e.MLBPlayer, error) {
+	args := m.Called(player)
+
+	return args.Get(0).(*e.MLBPlayer), args.Error(1)
+}
+
+func (m *mockMLBPlayerRepository) UpdateMLBPlayer(ctx context.Context, player e.MLBPlayer) (*e.MLBPlayer, error) {
+	args := m.Called(player)
+
+	return args.Get(0).(*e.MLBPlayer), args.Error(1)
+}
+
+func (m *mockMLBPlayerRepository) DeleteMLBPlayer(ctx context.Context, id int) (*e.MLBPlayer, error) {
+	args := m.Called()
+
+	return args.Get(0).(*e.MLBPlayer), args.Error(1)
+}
+
+func (m *mockMLBPlayerRepository) ImportMLBPlayers(ctx context.Context, players []e.MLBPlayer, mode e.MLBPlayerImportMode) (*e.MLBPlayerImportReport, error) {
+	args := m.Called(players, mode)
+
+	return args.Get(0).(*e.MLBPlayerImportReport), args.Error(1)
 }
 
 func Test_NewMLBPlayerService_ShouldReturnInstance(t *testing.T) {
-	instance := NewMLBPlayerService(&mockMLBPlayerRepository{})
-	instance2 := NewMLBPlayerService(&mockMLBPlayerRepository{})
+	instance := NewMLBPlayerService(&mockMLBPlayerRepository{}, logger.Discard())
+	instance2 := NewMLBPlayerService(&mockMLBPlayerRepository{}, logger.Discard())
 
 	assert.NotNil(t, instance)
 	assert.NotSame(t, instance, instance2)
@@ -79,9 +124,9 @@ func Test_GetMLBPlayers_Suite(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			repoMock := new(mockMLBPlayerRepository)
 			repoMock.On("GetMLBPlayers").Return(tc.response, tc.err)
-			service := NewMLBPlayerService(repoMock)
+			service := NewMLBPlayerService(repoMock, logger.Discard())
 
-			resp, err := service.GetMLBPlayers()
+			resp, err := service.GetMLBPlayers(context.Background())
 
 			if err != nil {
 				assert.Equal(t, tc.err, err)
@@ -130,9 +175,9 @@ func Test_GetMLBPlayerByID_Suite(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			repoMock := new(mockMLBPlayerRepository)
 			repoMock.On("GetMLBPlayerByID").Return(tc.response, tc.err)
-			service := NewMLBPlayerService(repoMock)
+			service := NewMLBPlayerService(repoMock, logger.Discard())
 
-			resp, err := service.GetMLBPlayerByID(1)
+			resp, err := service.GetMLBPlayerByID(context.Background(), 1)
 
 			if err != nil {
 				assert.Equal(t, tc.err, err)
@@ -148,7 +193,7 @@ func Test_GetMLBPlayerByID_Suite(t *testing.T) {
 func Test_GetMLBPlayerDesired_Suite(t *testing.T) {
 	testCases := []struct {
 		name     string
-		response []e.MLBPlayer
+		response *e.MLBPlayerDesiredResult
 		err      error
 	}{
 		{
@@ -158,8 +203,8 @@ func Test_GetMLBPlayerDesired_Suite(t *testing.T) {
 		},
 		{
 			name: "Should return players",
-			response: []e.MLBPlayer{
-				{
+			response: &e.MLBPlayerDesiredResult{
+				Players: []e.MLBPlayer{{
 					ID:       1,
 					Name:     "Adam Donachie",
 					Team:     "BAL",
@@ -167,8 +212,7 @@ func Test_GetMLBPlayerDesired_Suite(t *testing.T) {
 					Height:   74,
 					Weight:   180,
 					Age:      22.99,
-				},
-				{
+				}, {
 					ID:       2,
 					Name:     "Paul Bako",
 					Team:     "BAL",
@@ -176,7 +220,9 @@ func Test_GetMLBPlayerDesired_Suite(t *testing.T) {
 					Height:   74,
 					Weight:   215,
 					Age:      34.69,
-				},
+				}},
+				StopReason: e.StopReasonEOF,
+				Workers:    []e.WorkerReport{{WorkerID: 1, RowsRead: 2, Items: 2}},
 			},
 			err: nil,
 		},
@@ -186,9 +232,9 @@ func Test_GetMLBPlayerDesired_Suite(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			repoMock := new(mockMLBPlayerRepository)
 			repoMock.On("GetMLBPlayerDesired").Return(tc.response, tc.err)
-			service := NewMLBPlayerService(repoMock)
+			service := NewMLBPlayerService(repoMock, logger.Discard())
 
-			resp, err := service.GetMLBPlayerDesired("even", 20, 5)
+			resp, err := service.GetMLBPlayerDesired(context.Background(), e.MLBPlayerIDParity{Even: true}, 20, 5)
 
 			if err != nil {
 				assert.Equal(t, tc.err, err)
@@ -201,3 +247,520 @@ func Test_GetMLBPlayerDesired_Suite(t *testing.T) {
 		})
 	}
 }
+
+var validPlayer = e.MLBPlayer{
+	ID:       1,
+	Name:     "Adam Donachie",
+	Team:     "BAL",
+	Position: "Catcher",
+	Height:   74,
+	Weight:   180,
+	Age:      22.99,
+}
+
+func Test_CreateMLBPlayer_Suite(t *testing.T) {
+	invalidPlayer := validPlayer
+	invalidPlayer.Height = 0
+	testCases := []struct {
+		name              string
+		player            e.MLBPlayer
+		repoResponse      *e.MLBPlayer
+		repoErr           error
+		expectedError     error
+		expectedRepoCalls int
+	}{
+		{
+			name:              "Should create the player",
+			player:            validPlayer,
+			repoResponse:      &validPlayer,
+			expectedRepoCalls: 1,
+		},
+		{
+			name:              "Should return validation error",
+			player:            invalidPlayer,
+			expectedError:     &e.ValidationError{Field: "height_inches", Message: "must be between 1 and 120"},
+			expectedRepoCalls: 0,
+		},
+		{
+			name:              "Should return error when repo has error",
+			player:            validPlayer,
+			repoErr:           errors.New("error writing the file"),
+			expectedError:     errors.New("error writing the file"),
+			expectedRepoCalls: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repoMock := new(mockMLBPlayerRepository)
+			repoMock.On("CreateMLBPlayer", tc.player).Return(tc.repoResponse, tc.repoErr)
+			service := NewMLBPlayerService(repoMock, logger.Discard())
+
+			resp, err := service.CreateMLBPlayer(context.Background(), tc.player)
+
+			assert.Equal(t, tc.expectedError, err)
+			assert.Equal(t, tc.repoResponse, resp)
+			repoMock.AssertNumberOfCalls(t, "CreateMLBPlayer", tc.expectedRepoCalls)
+		})
+	}
+}
+
+func Test_UpdateMLBPlayer_ShouldUseIDParam(t *testing.T) {
+	player := validPlayer
+	player.ID = 10
+	expected := validPlayer
+	expected.ID = 3
+	repoMock := new(mockMLBPlayerRepository)
+	repoMock.On("UpdateMLBPlayer", expected).Return(&expected, nil)
+	service := NewMLBPlayerService(repoMock, logger.Discard())
+
+	resp, err := service.UpdateMLBPlayer(context.Background(), 3, player)
+
+	assert.Nil(t, err)
+	assert.Equal(t, &expected, resp)
+	repoMock.AssertNumberOfCalls(t, "UpdateMLBPlayer", 1)
+}
+
+func Test_PatchMLBPlayer_Suite(t *testing.T) {
+	team := "NYY"
+	emptyName := " "
+	patched := validPlayer
+	patched.Team = team
+	testCases := []struct {
+		name                string
+		patch               e.MLBPlayerPatch
+		getResponse         *e.MLBPlayer
+		getErr              error
+		expectedResponse    *e.MLBPlayer
+		expectedError       error
+		expectedUpdateCalls int
+	}{
+		{
+			name:                "Should patch the player",
+			patch:               e.MLBPlayerPatch{Team: &team},
+			getResponse:         &e.MLBPlayer{ID: 1, Name: "Adam Donachie", Team: "BAL", Position: "Catcher", Height: 74, Weight: 180, Age: 22.99},
+			expectedResponse:    &patched,
+			expectedUpdateCalls: 1,
+		},
+		{
+			name:                "Should return no player if not found",
+			patch:               e.MLBPlayerPatch{Team: &team},
+			getResponse:         nil,
+			expectedResponse:    nil,
+			expectedUpdateCalls: 0,
+		},
+		{
+			name:                "Should return validation error",
+			patch:               e.MLBPlayerPatch{Name: &emptyName},
+			getResponse:         &e.MLBPlayer{ID: 1, Name: "Adam Donachie", Team: "BAL", Position: "Catcher", Height: 74, Weight: 180, Age: 22.99},
+			expectedError:       &e.ValidationError{Field: "name", Message: "must not be empty"},
+			expectedUpdateCalls: 0,
+		},
+		{
+			name:                "Should return error when repo has error",
+			patch:               e.MLBPlayerPatch{Team: &team},
+			getErr:              errors.New("error getting player"),
+			expectedError:       errors.New("error getting player"),
+			expectedUpdateCalls: 0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repoMock := new(mockMLBPlayerRepository)
+			repoMock.On("GetMLBPlayerByID").Return(tc.getResponse, tc.getErr)
+			repoMock.On("UpdateMLBPlayer", patched).Return(&patched, nil)
+			service := NewMLBPlayerService(repoMock, logger.Discard())
+
+			resp, err := service.PatchMLBPlayer(context.Background(), 1, tc.patch)
+
+			assert.Equal(t, tc.expectedError, err)
+			assert.Equal(t, tc.expectedResponse, resp)
+			repoMock.AssertNumberOfCalls(t, "UpdateMLBPlayer", tc.expectedUpdateCalls)
+		})
+	}
+}
+
+func Test_DeleteMLBPlayer_Suite(t *testing.T) {
+	testCases := []struct {
+		name     string
+		response *e.MLBPlayer
+		err      error
+	}{
+		{
+			name:     "Should delete the player",
+			response: &validPlayer,
+		},
+		{
+			name:     "Should return no player if not found",
+			response: nil,
+		},
+		{
+			name: "Should return error when repo has error",
+			err:  errors.New("error writing the file"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repoMock := new(mockMLBPlayerRepository)
+			repoMock.On("DeleteMLBPlayer").Return(tc.response, tc.err)
+			service := NewMLBPlayerService(repoMock, logger.Discard())
+
+			resp, err := service.DeleteMLBPlayer(context.Background(), 1)
+
+			assert.Equal(t, tc.err, err)
+			assert.Equal(t, tc.response, resp)
+		})
+	}
+}
+
+func Test_SearchMLBPlayers_Suite(t *testing.T) {
+	players := []e.MLBPlayer{
+		{ID: 1, Name: "Adam Donachie", Team: "BAL", Position: "Catcher", Height: 74, Weight: 180, Age: 22.99},
+		{ID: 2, Name: "Paul Bako", Team: "BAL", Position: "Catcher", Height: 74, Weight: 215, Age: 34.69},
+		{ID: 3, Name: "Derek Jeter", Team: "NYY", Position: "Shortstop", Height: 75, Weight: 195, Age: 32.68},
+		{ID: 4, Name: "Jorge Posada", Team: "NYY", Position: "Catcher", Height: 74, Weight: 205, Age: 35.4},
+		{ID: 5, Name: "Toby Hall", Team: "CWS", Position: "Catcher", Height: 75, Weight: 240, Age: 31.36},
+	}
+	minHeight := 75
+	maxAge := float32(33)
+	testCases := []struct {
+		name          string
+		query         e.MLBPlayerQuery
+		expectedIDs   []int
+		expectedTotal int
+	}{
+		{
+			name:          "Should return every player",
+			query:         e.MLBPlayerQuery{},
+			expectedIDs:   []int{1, 2, 3, 4, 5},
+			expectedTotal: 5,
+		},
+		{
+			name:          "Should filter by team ignoring case",
+			query:         e.MLBPlayerQuery{Filter: e.MLBPlayerFilter{Teams: []string{"nyy", "CWS"}}},
+			expectedIDs:   []int{3, 4, 5},
+			expectedTotal: 3,
+		},
+		{
+			name:          "Should filter by position and ranges",
+			query:         e.MLBPlayerQuery{Filter: e.MLBPlayerFilter{Positions: []string{"Catcher"}, MinHeight: &minHeight, MaxAge: &maxAge}},
+			expectedIDs:   []int{5},
+			expectedTotal: 1,
+		},
+		{
+			name:          "Should sort by several keys",
+			query:         e.MLBPlayerQuery{Sort: []e.SortKey{{Field: "height_inches", Desc: true}, {Field: "age"}}},
+			expectedIDs:   []int{5, 3, 1, 2, 4},
+			expectedTotal: 5,
+		},
+		{
+			name:          "Should page the sorted players",
+			query:         e.MLBPlayerQuery{Sort: []e.SortKey{{Field: "name"}}, Limit: 2, Offset: 1},
+			expectedIDs:   []int{3, 4},
+			expectedTotal: 5,
+		},
+		{
+			name:          "Should return empty page after the last player",
+			query:         e.MLBPlayerQuery{Limit: 2, Offset: 10},
+			expectedIDs:   []int{},
+			expectedTotal: 5,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repoMock := new(mockMLBPlayerRepository)
+			repoMock.On("GetMLBPlayers").Return(append([]e.MLBPlayer(nil), players...), nil)
+			service := NewMLBPlayerService(repoMock, logger.Discard())
+
+			page, err := service.SearchMLBPlayers(context.Background(), tc.query)
+
+			assert.Nil(t, err)
+			ids := []int{}
+			for _, p := range page.Players {
+				ids = append(ids, p.ID)
+			}
+			assert.Equal(t, tc.expectedIDs, ids)
+			assert.Equal(t, tc.expectedTotal, page.Total)
+		})
+	}
+}
+
+func Test_SearchMLBPlayers_ShouldReturnRepoError(t *testing.T) {
+	repoMock := new(mockMLBPlayerRepository)
+	repoMock.On("GetMLBPlayers").Return([]e.MLBPlayer(nil), errors.New("error opening the file"))
+	service := NewMLBPlayerService(repoMock, logger.Discard())
+
+	page, err := service.SearchMLBPlayers(context.Background(), e.MLBPlayerQuery{})
+
+	assert.Nil(t, page)
+	assert.Equal(t, errors.New("error opening the file"), err)
+}
+
+func Test_StreamMLBPlayers_Suite(t *testing.T) {
+	players := []e.MLBPlayer{
+		{ID: 1, Team: "BAL", Age: 22.99},
+		{ID: 2, Team: "BAL", Age: 34.69},
+		{ID: 3, Team: "NYY", Age: 32.68},
+	}
+	testCases := []struct {
+		name              string
+		sort              []e.SortKey
+		expectedIDs       []int
+		expectedEachCalls int
+		expectedGetCalls  int
+	}{
+		{
+			name:              "Should stream the players one by one without sort",
+			expectedIDs:       []int{1, 2},
+			expectedEachCalls: 1,
+		},
+		{
+			name:             "Should sort the players",
+			sort:             []e.SortKey{{Field: "age", Desc: true}},
+			expectedIDs:      []int{2, 1},
+			expectedGetCalls: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repoMock := new(mockMLBPlayerRepository)
+			repoMock.On("EachMLBPlayer").Return(players, nil)
+			repoMock.On("GetMLBPlayers").Return(players, nil)
+			service := NewMLBPlayerService(repoMock, logger.Discard())
+			var ids []int
+
+			err := service.StreamMLBPlayers(context.Background(), e.MLBPlayerFilter{Teams: []string{"BAL"}}, tc.sort, func(p e.MLBPlayer) error {
+				ids = append(ids, p.ID)
+
+				return nil
+			})
+
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedIDs, ids)
+			repoMock.AssertNumberOfCalls(t, "EachMLBPlayer", tc.expectedEachCalls)
+			repoMock.AssertNumberOfCalls(t, "GetMLBPlayers", tc.expectedGetCalls)
+		})
+	}
+}
+
+func Test_GetMLBPlayerStats_Suite(t *testing.T) {
+	players := []e.MLBPlayer{
+		{ID: 1, Name: "Adam Donachie", Team: "BAL", Position: "Catcher", Height: 74, Weight: 180, Age: 22.99},
+		{ID: 2, Name: "Paul Bako", Team: "BAL", Position: "Catcher", Height: 74, Weight: 215, Age: 34.69},
+		{ID: 3, Name: "Derek Jeter", Team: "NYY", Position: "Shortstop", Height: 75, Weight: 195, Age: 32.68},
+		{ID: 4, Name: "Jorge Posada", Team: "NYY", Position: "Catcher", Height: 72, Weight: 205, Age: 35.4},
+	}
+	testCases := []struct {
+		name          string
+		filter        e.MLBPlayerFilter
+		groupBy       string
+		percentiles   []float64
+		expectedStats []e.MLBPlayerGroupStats
+	}{
+		{
+			name:        "Should compute overall stats",
+			groupBy:     "",
+			percentiles: []float64{25, 90},
+			expectedStats: []e.MLBPlayerGroupStats{{
+				Count:  4,
+				Height: e.Stats{Mean: 73.75, Median: 74, Min: 72, Max: 75, Percentiles: map[string]float64{"p25": 73.5, "p90": 74.7}},
+				Weight: e.Stats{Mean: 198.75, Median: 200, Min: 180, Max: 215, Percentiles: map[string]float64{"p25": 191.25, "p90": 212}},
+				Age:    e.Stats{Mean: 31.44, Median: 33.685, Min: 22.99, Max: 35.4, Percentiles: map[string]float64{"p25": 30.2575, "p90": 35.187}},
+			}},
+		},
+		{
+			name:        "Should group by team",
+			groupBy:     "team",
+			percentiles: nil,
+			expectedStats: []e.MLBPlayerGroupStats{
+				{
+					Group:  "BAL",
+					Count:  2,
+					Height: e.Stats{Mean: 74, Median: 74, Min: 74, Max: 74, Percentiles: map[string]float64{}},
+					Weight: e.Stats{Mean: 197.5, Median: 197.5, Min: 180, Max: 215, Percentiles: map[string]float64{}},
+					Age:    e.Stats{Mean: 28.84, Median: 28.84, Min: 22.99, Max: 34.69, Percentiles: map[string]float64{}},
+				},
+				{
+					Group:  "NYY",
+					Count:  2,
+					Height: e.Stats{Mean: 73.5, Median: 73.5, Min: 72, Max: 75, Percentiles: map[string]float64{}},
+					Weight: e.Stats{Mean: 200, Median: 200, Min: 195, Max: 205, Percentiles: map[string]float64{}},
+					Age:    e.Stats{Mean: 34.04, Median: 34.04, Min: 32.68, Max: 35.4, Percentiles: map[string]float64{}},
+				},
+			},
+		},
+		{
+			name:        "Should group filtered players by position",
+			filter:      e.MLBPlayerFilter{Teams: []string{"NYY"}},
+			groupBy:     "position",
+			percentiles: nil,
+			expectedStats: []e.MLBPlayerGroupStats{
+				{
+					Group:  "Catcher",
+					Count:  1,
+					Height: e.Stats{Mean: 72, Median: 72, Min: 72, Max: 72, Percentiles: map[string]float64{}},
+					Weight: e.Stats{Mean: 205, Median: 205, Min: 205, Max: 205, Percentiles: map[string]float64{}},
+					Age:    e.Stats{Mean: 35.4, Median: 35.4, Min: 35.4, Max: 35.4, Percentiles: map[string]float64{}},
+				},
+				{
+					Group:  "Shortstop",
+					Count:  1,
+					Height: e.Stats{Mean: 75, Median: 75, Min: 75, Max: 75, Percentiles: map[string]float64{}},
+					Weight: e.Stats{Mean: 195, Median: 195, Min: 195, Max: 195, Percentiles: map[string]float64{}},
+					Age:    e.Stats{Mean: 32.68, Median: 32.68, Min: 32.68, Max: 32.68, Percentiles: map[string]float64{}},
+				},
+			},
+		},
+		{
+			name:        "Should return empty overall stats when no player matches",
+			filter:      e.MLBPlayerFilter{Teams: []string{"BOS"}},
+			groupBy:     "",
+			percentiles: []float64{50},
+			expectedStats: []e.MLBPlayerGroupStats{{
+				Height: e.Stats{Percentiles: map[string]float64{}},
+				Weight: e.Stats{Percentiles: map[string]float64{}},
+				Age:    e.Stats{Percentiles: map[string]float64{}},
+			}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repoMock := new(mockMLBPlayerRepository)
+			repoMock.On("GetMLBPlayers").Return(players, nil)
+			service := NewMLBPlayerService(repoMock, logger.Discard())
+
+			stats, err := service.GetMLBPlayerStats(context.Background(), tc.filter, tc.groupBy, tc.percentiles)
+
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedStats, stats)
+		})
+	}
+}
+
+func Test_ImportMLBPlayers_Suite(t *testing.T) {
+	header := "Id,Name,Team,Position,Height(inches),Weight(lbs),Age\n"
+	adam := e.MLBPlayer{ID: 1, Name: "Adam Donachie", Team: "BAL", Position: "Catcher", Height: 74, Weight: 180, Age: 22.99}
+	paul := e.MLBPlayer{ID: 2, Name: "Paul Bako", Team: "BAL", Position: "Catcher", Height: 74, Weight: 215, Age: 34.69}
+	testCases := []struct {
+		name            string
+		content         string
+		mode            e.MLBPlayerImportMode
+		expectedPlayers []e.MLBPlayer
+		repoResponse    *e.MLBPlayerImportReport
+		repoError       error
+		expectedReport  *e.MLBPlayerImportReport
+		expectedError   error
+	}{
+		{
+			name:            "Should import every valid row",
+			content:         "\ufeffid, name ,Team,Position,Height(inches),Weight(lbs),Age\n1,Adam Donachie,BAL,Catcher,74,180,22.99\n2,Paul Bako,BAL,Catcher,74,215,34.69\n",
+			mode:            e.ImportMerge,
+			expectedPlayers: []e.MLBPlayer{adam, paul},
+			repoResponse:    &e.MLBPlayerImportReport{Mode: e.ImportMerge, Inserted: 1, Updated: 1},
+			expectedReport:  &e.MLBPlayerImportReport{Mode: e.ImportMerge, Committed: true, Accepted: 2, Inserted: 1, Updated: 1, Rejected: []e.MLBPlayerImportRejection{}},
+		},
+		{
+			name: "Should report the rejected rows with their line",
+			content: header +
+				"1,Adam Donachie,BAL,Catcher,74,180,22.99\n" +
+				"2,Paul Bako,BAL,Catcher,tall,215,34.69\n" +
+				"\n" +
+				"3,Ramon Hernandez,BAL,Catcher,72,210\n" +
+				"4,\"Kevin \"Millar,BAL,First Baseman,72,210,35.43\n" +
+				"5,,BAL,First Baseman,73,188,35.71\n" +
+				"0,Brian Roberts,BAL,Second Baseman,69,176,29.39\n" +
+				"1,Miguel Tejada,BAL,Shortstop,69,209,30.77\n" +
+				"2,Paul Bako,BAL,Catcher,74,215,34.69\n",
+			mode:            e.ImportMerge,
+			expectedPlayers: []e.MLBPlayer{adam, paul},
+			repoResponse:    &e.MLBPlayerImportReport{Mode: e.ImportMerge, Updated: 2},
+			expectedReport: &e.MLBPlayerImportReport{
+				Mode:      e.ImportMerge,
+				Committed: true,
+				Accepted:  2,
+				Updated:   2,
+				Rejected: []e.MLBPlayerImportRejection{
+					{Line: 3, Reason: "error casting Height"},
+					{Line: 5, Reason: "error reading the row: wrong number of fields"},
+					{Line: 6, Reason: "extraneous or missing \" in quoted-field"},
+					{Line: 7, Reason: "name must not be empty"},
+					{Line: 8, Reason: "id must be greater than 0"},
+					{Line: 9, Reason: "duplicated id, first seen at line 2"},
+				},
+			},
+		},
+		{
+			name:    "Should not commit a replace with rejected rows",
+			content: header + "1,Adam Donachie,BAL,Catcher,74,180,22.99\n2,Paul Bako,BAL,Catcher,tall,215,34.69\n",
+			mode:    e.ImportReplace,
+			expectedReport: &e.MLBPlayerImportReport{
+				Mode:     e.ImportReplace,
+				Accepted: 1,
+				Rejected: []e.MLBPlayerImportRejection{{Line: 3, Reason: "error casting Height"}},
+			},
+		},
+		{
+			name:    "Should reject the rows with numbers that are not finite",
+			content: header + "1,A,B,C,70,NaN,NaN\n2,A,B,C,70,180,Inf\n3,A,B,C,70,-Inf,22.99\n",
+			mode:    e.ImportReplace,
+			expectedReport: &e.MLBPlayerImportReport{
+				Mode: e.ImportReplace,
+				Rejected: []e.MLBPlayerImportRejection{
+					{Line: 2, Reason: "weight_lbs must be greater than 0 and at most 1000"},
+					{Line: 3, Reason: "age must be greater than 0 and at most 100"},
+					{Line: 4, Reason: "weight_lbs must be greater than 0 and at most 1000"},
+				},
+			},
+		},
+		{
+			name:            "Should commit a replace without rejected rows",
+			content:         header + "1,Adam Donachie,BAL,Catcher,74,180,22.99\n",
+			mode:            e.ImportReplace,
+			expectedPlayers: []e.MLBPlayer{adam},
+			repoResponse:    &e.MLBPlayerImportReport{Mode: e.ImportReplace, Updated: 1, Removed: 99},
+			expectedReport:  &e.MLBPlayerImportReport{Mode: e.ImportReplace, Committed: true, Accepted: 1, Updated: 1, Removed: 99, Rejected: []e.MLBPlayerImportRejection{}},
+		},
+		{
+			name:           "Should not commit when no row is accepted",
+			content:        header + "1,Adam Donachie,BAL,Catcher,74,heavy,22.99\n",
+			mode:           e.ImportMerge,
+			expectedReport: &e.MLBPlayerImportReport{Mode: e.ImportMerge, Rejected: []e.MLBPlayerImportRejection{{Line: 2, Reason: "error casting Weight"}}},
+		},
+		{
+			name:          "Should return validation error on a wrong header",
+			content:       "Id,Name\n1,Adam Donachie\n",
+			mode:          e.ImportMerge,
+			expectedError: &e.ValidationError{Field: "file", Message: "must start with the header Id,Name,Team,Position,Height(inches),Weight(lbs),Age"},
+		},
+		{
+			name:            "Should return repository error",
+			content:         header + "1,Adam Donachie,BAL,Catcher,74,180,22.99\n",
+			mode:            e.ImportMerge,
+			expectedPlayers: []e.MLBPlayer{adam},
+			repoError:       errors.New("error writing the database"),
+			expectedError:   errors.New("error writing the database"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repoMock := new(mockMLBPlayerRepository)
+			repoMock.On("ImportMLBPlayers", tc.expectedPlayers, tc.mode).Return(tc.repoResponse, tc.repoError)
+			service := NewMLBPlayerService(repoMock, logger.Discard())
+
+			report, err := service.ImportMLBPlayers(context.Background(), strings.NewReader(tc.content), tc.mode)
+
+			assert.Equal(t, tc.expectedError, err)
+			assert.Equal(t, tc.expectedReport, report)
+
+			if tc.expectedPlayers == nil {
+				repoMock.AssertNotCalled(t, "ImportMLBPlayers", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
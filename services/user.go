@@ -1,57 +1,289 @@
 package services
 
 import (
-	"log"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/EloYaniel/academy-go-q42021/apiclient"
+	"github.com/EloYaniel/academy-go-q42021/cache"
 	e "github.com/EloYaniel/academy-go-q42021/entities"
 	repo "github.com/EloYaniel/academy-go-q42021/repositories/contracts"
 )
 
+// errStopUsers stops reading the stored Users once the first one tells there are some.
+var errStopUsers = errors.New("stop reading users")
+
+// usersPage struct has a page of Users as returned by the upstream API.
+type usersPage struct {
+	Page       int      `json:"page"`
+	PerPage    int      `json:"per_page"`
+	Total      int      `json:"total"`
+	TotalPages int      `json:"total_pages"`
+	Data       []e.User `json:"data"`
+}
+
+// userResponse struct has a single User as returned by the upstream API.
+type userResponse struct {
+	Data e.User `json:"data"`
+}
+
 // UserService struct handles Users business logic.
 type UserService struct {
-	repo      repo.UserRepository
-	apiClient apiclient.ApiClient
-	userURL   string
+	repo         repo.UserRepository
+	apiClient    apiclient.ApiClient
+	lookupClient apiclient.ApiClient
+	probeClient  apiclient.ApiClient
+	userURL      string
+	logger       *slog.Logger
+	imports      repo.UserImportRepository
+	importMu     sync.Mutex
+	imported     []e.User
+	mu           sync.Mutex
+	lastImport   *e.UserImport
+	syncMu       sync.Mutex
+	sync         e.UserSync
+	validators   map[int]apiclient.Validators
+	totalPages   int
 }
 
 // NewUserService function return an instance of UserService
-func NewUserService(repo repo.UserRepository, client apiclient.ApiClient, userURL string) *UserService {
-	return &UserService{repo: repo, apiClient: client, userURL: userURL}
+func NewUserService(repo repo.UserRepository, client apiclient.ApiClient, userURL string, logger *slog.Logger) *UserService {
+	return &UserService{repo: repo, apiClient: client, lookupClient: client, probeClient: client, userURL: userURL, logger: logger}
 }
 
-// GetUsers gets all Users
-func (s *UserService) GetUsers() ([]e.User, error) {
-	users, err := s.repo.GetUsers()
+// GetUsers gets all Users, importing them from the upstream API when there are none
+// or when a previous import did not finish.
+func (s *UserService) GetUsers(ctx context.Context) ([]e.User, error) {
+	users, err := s.repo.GetUsers(ctx)
 
 	if err != nil {
-		log.Println(err)
+		s.logger.ErrorContext(ctx, "error getting users", "error", err)
 	}
+	lastImport := s.currentImport()
 
-	if len(users) > 0 {
+	if len(users) > 0 && (lastImport == nil || lastImport.Completed) {
 		return users, nil
 	}
+	s.importMu.Lock()
+	defer s.importMu.Unlock()
+
+	// Another call may have finished an import while this one waited for it.
+	if current := s.currentImport(); current != lastImport && current.Completed {
+		return s.repo.GetUsers(ctx)
+	}
+
+	return s.importUsers(ctx, len(users) > 0)
+}
+
+// GetUserByID get a User by its ID, fetching it from the upstream API and saving it when it is not stored.
+func (s *UserService) GetUserByID(ctx context.Context, id int) (*e.User, error) {
+	user, err := s.repo.GetUserByID(ctx, id)
+
+	if err != nil || user != nil {
+		return user, err
+	}
+
+	return s.fetchUser(ctx, id)
+}
 
-	resp := struct {
-		Data []e.User
-	}{}
-	err = s.apiClient.Get(s.userURL, nil, &resp)
+// WithLookupCache keeps the Users fetched by ID from the upstream API in c, including the ones it does not have.
+func (s *UserService) WithLookupCache(c *cache.Cache) *UserService {
+	s.lookupClient = apiclient.NewCachedApiClient(s.apiClient, c)
+
+	return s
+}
+
+// WithImportRepository keeps the state of the imports in imports, resuming the one that did not finish before a restart.
+func (s *UserService) WithImportRepository(imports repo.UserImportRepository) *UserService {
+	s.imports = imports
+	lastImport, err := imports.GetUserImport(context.Background())
 
 	if err != nil {
-		log.Println(err)
+		s.logger.Error("error getting the last users import", "error", err)
+	}
+	s.lastImport = lastImport
+
+	return s
+}
+
+// fetchUser fetches a User that is not stored from the upstream API and saves it, nil if the API does not have it either.
+func (s *UserService) fetchUser(ctx context.Context, id int) (*e.User, error) {
+	body := userResponse{}
+	err := s.lookupClient.Get(ctx, strings.TrimSuffix(s.userURL, "/")+"/"+strconv.Itoa(id), nil, &body)
+	var statusErr *apiclient.StatusError
+
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error fetching user", "id", id, "error", err)
+
 		return nil, err
 	}
-	users = resp.Data
-	err = s.repo.SaveUsers(users)
+
+	if _, err = s.repo.SaveUsers(ctx, []e.User{body.Data}); err != nil {
+		s.logger.ErrorContext(ctx, "error saving user", "id", id, "error", err)
+	}
+
+	return &body.Data, nil
+}
+
+// ExportUsers streams the stored Users to fn in the order they are stored, without importing them.
+func (s *UserService) ExportUsers(ctx context.Context, fn func(u e.User) error) error {
+	err := s.repo.EachUser(ctx, fn)
 
 	if err != nil {
-		log.Println(err)
+		s.logger.ErrorContext(ctx, "error exporting users", "error", err)
+	}
+
+	return err
+}
+
+// StreamUsers streams every User to fn in the order they are stored, importing them first like GetUsers does.
+func (s *UserService) StreamUsers(ctx context.Context, fn func(u e.User) error) error {
+	lastImport := s.currentImport()
+	stored := false
+	err := s.repo.EachUser(ctx, func(u e.User) error {
+		stored = true
+
+		return errStopUsers
+	})
+
+	if err != nil && err != errStopUsers {
+		s.logger.ErrorContext(ctx, "error getting users", "error", err)
+	}
+
+	if !stored || (lastImport != nil && !lastImport.Completed) {
+		if _, err = s.GetUsers(ctx); err != nil {
+			return err
+		}
+	}
+
+	return s.ExportUsers(ctx, fn)
+}
+
+// WithProbeClient checks the upstream API with client, so the health checks do not retry
+// nor count in the circuit breaker of the requests made for the Users.
+func (s *UserService) WithProbeClient(client apiclient.ApiClient) *UserService {
+	s.probeClient = client
+
+	return s
+}
+
+// CheckUpstream checks the first page of Users can be fetched from the upstream API.
+func (s *UserService) CheckUpstream(ctx context.Context) error {
+	return s.probeClient.Get(ctx, s.userURL, map[string]interface{}{"page": 1}, &usersPage{})
+}
+
+// GetLastImport gets the metadata of the last import of Users, nil if there was none.
+func (s *UserService) GetLastImport() *e.UserImport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lastImport == nil {
+		return nil
+	}
+	lastImport := *s.lastImport
+
+	return &lastImport
+}
+
+// currentImport gets the state of the last import, nil if there was none. It is replaced, never changed, by a commit.
+func (s *UserService) currentImport() *e.UserImport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.lastImport
+}
+
+// importUsers fetches every page of Users upserting them as they arrive, resuming the last import when it did not
+// finish and some Users are stored. When a page can not be fetched or saved the import stops and the next call
+// resumes from that page. The pages are fetched without holding s.mu, which is only taken to commit the state.
+func (s *UserService) importUsers(ctx context.Context, resume bool) ([]e.User, error) {
+	s.mu.Lock()
+
+	if s.lastImport == nil || s.lastImport.Completed || !resume {
+		s.lastImport = &e.UserImport{StartedAt: time.Now(), NextPage: 1}
+		s.imported = nil
+	}
+	state := *s.lastImport
+	s.mu.Unlock()
+
+	for {
+		page := usersPage{}
+		err := s.apiClient.Get(ctx, s.userURL, map[string]interface{}{"page": state.NextPage}, &page)
+
+		if err != nil {
+			s.logger.ErrorContext(ctx, "error fetching users page", "page", state.NextPage, "error", err)
+			state.LastError = err.Error()
+			s.commitImport(ctx, state)
+
+			return nil, err
+		}
+		saved, err := s.repo.SaveUsers(ctx, page.Data)
+
+		if err != nil {
+			s.logger.ErrorContext(ctx, "error saving users page", "page", state.NextPage, "error", err)
+			state.LastError = err.Error()
+			s.commitImport(ctx, state)
+
+			return nil, err
+		}
+		s.imported = append(s.imported, page.Data...)
+		state.Inserted += saved.Inserted
+		state.Updated += saved.Updated
+		state.Unchanged += saved.Unchanged
+		state.PagesFetched++
+		state.TotalPages = page.TotalPages
+		state.Total = page.Total
+		state.Imported += len(page.Data)
+		state.LastError = ""
+
+		if state.NextPage >= page.TotalPages {
+			break
+		}
+		state.NextPage++
+		s.commitImport(ctx, state)
+	}
+	finishedAt := time.Now()
+	state.FinishedAt = &finishedAt
+	state.Completed = true
+	state.NextPage = 0
+	s.commitImport(ctx, state)
+	s.logger.InfoContext(
+		ctx, "users imported",
+		"pages", state.PagesFetched, "users", state.Imported,
+		"inserted", state.Inserted, "updated", state.Updated, "unchanged", state.Unchanged,
+	)
+	users := s.imported
+	s.imported = nil
+
+	// The pages fetched before a restart are only in the repository.
+	if len(users) != state.Imported {
+		return s.repo.GetUsers(ctx)
 	}
 
 	return users, nil
 }
 
-// GetUserByID get a User by its ID
-func (s *UserService) GetUserByID(id int) (*e.User, error) {
-	return s.repo.GetUserByID(id)
+// commitImport makes state the last import and saves it, so an import that does not finish resumes after a restart.
+func (s *UserService) commitImport(ctx context.Context, state e.UserImport) {
+	s.mu.Lock()
+	s.lastImport = &state
+	s.mu.Unlock()
+
+	if s.imports == nil {
+		return
+	}
+
+	if err := s.imports.SaveUserImport(ctx, state); err != nil {
+		s.logger.ErrorContext(ctx, "error saving the users import", "error", err)
+	}
 }
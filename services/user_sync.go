@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/EloYaniel/academy-go-q42021/apiclient"
+	e "github.com/EloYaniel/academy-go-q42021/entities"
+)
+
+// SyncUsers fetches every page of Users from the upstream API and upserts the ones of the pages that changed.
+// Pages are requested with the validators of the previous sync, so the ones answered with 304 are not saved again.
+// The validators are dropped when there are no stored Users, so a lost file is synced again in full.
+func (s *UserService) SyncUsers(ctx context.Context) (*e.UserSync, error) {
+	s.syncMu.Lock()
+
+	if s.sync.Running {
+		s.syncMu.Unlock()
+
+		return nil, e.ErrUserSyncInProgress
+	}
+	startedAt := time.Now()
+	s.sync.Running = true
+	s.sync.LastStartedAt = &startedAt
+	s.syncMu.Unlock()
+
+	if users, _ := s.repo.GetUsers(ctx); len(users) == 0 {
+		s.validators = nil
+	}
+
+	if s.validators == nil {
+		s.validators = map[int]apiclient.Validators{}
+	}
+	result, err := s.syncPages(ctx)
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+	finishedAt := time.Now()
+	s.sync.Running = false
+
+	if err != nil && ctx.Err() != nil {
+		s.logger.InfoContext(ctx, "users sync canceled", "error", err)
+
+		return nil, err
+	}
+
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error syncing users", "error", err)
+		s.sync.LastErrorAt = &finishedAt
+		s.sync.LastError = err.Error()
+
+		return nil, err
+	}
+	s.sync.LastSuccessAt = &finishedAt
+	s.sync.PagesFetched = result.PagesFetched
+	s.sync.PagesNotModified = result.PagesNotModified
+	s.sync.Inserted = result.Inserted
+	s.sync.Updated = result.Updated
+	s.sync.Unchanged = result.Unchanged
+	s.logger.InfoContext(
+		ctx, "users synced",
+		"pages", result.PagesFetched, "not_modified", result.PagesNotModified,
+		"inserted", result.Inserted, "updated", result.Updated, "unchanged", result.Unchanged,
+	)
+	state := s.sync
+
+	return &state, nil
+}
+
+// GetSyncStatus gets the state of the syncs of Users.
+func (s *UserService) GetSyncStatus() e.UserSync {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	return s.sync
+}
+
+// setNextSync records when the next scheduled sync runs, nil when none is scheduled.
+func (s *UserService) setNextSync(at *time.Time) {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+	s.sync.NextSyncAt = at
+}
+
+// syncPages fetches the pages of Users, keeping the validators of every page that is saved.
+// The number of pages is taken from every modified page, since page 1 may not change when pages are added.
+// Only one sync runs at a time, so the validators are not guarded by a lock.
+func (s *UserService) syncPages(ctx context.Context) (*e.UserSync, error) {
+	result := &e.UserSync{}
+
+	for page := 1; ; page++ {
+		body := usersPage{}
+		validators, modified, err := s.apiClient.GetIfModified(ctx, s.userURL, map[string]interface{}{"page": page}, s.validators[page], &body)
+
+		if err != nil {
+			return nil, err
+		}
+		result.PagesFetched++
+
+		if modified {
+			saved, err := s.repo.SaveUsers(ctx, body.Data)
+
+			if err != nil {
+				return nil, err
+			}
+			result.Inserted += saved.Inserted
+			result.Updated += saved.Updated
+			result.Unchanged += saved.Unchanged
+			s.totalPages = body.TotalPages
+		} else {
+			result.PagesNotModified++
+		}
+		s.validators[page] = validators
+
+		if page >= s.totalPages {
+			return result, nil
+		}
+	}
+}
+
+// UserSyncer struct runs the sync of Users in the background, every interval plus a random jitter.
+type UserSyncer struct {
+	service  *UserService
+	interval time.Duration
+	jitter   time.Duration
+	random   func(n int64) int64
+	cancel   context.CancelFunc
+	done     chan struct{}
+	once     sync.Once
+}
+
+// NewUserSyncer function creates a new instance of type UserSyncer.
+func NewUserSyncer(service *UserService, interval time.Duration, jitter time.Duration) *UserSyncer {
+	return &UserSyncer{service: service, interval: interval, jitter: jitter, random: rand.Int63n}
+}
+
+// Start schedules the syncs until Stop is called, the first one an interval after Start.
+func (syncer *UserSyncer) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	syncer.cancel = cancel
+	syncer.done = make(chan struct{})
+
+	go syncer.run(ctx)
+}
+
+// Stop cancels the sync in progress, if any, and waits for the syncer to finish or ctx to be done.
+func (syncer *UserSyncer) Stop(ctx context.Context) error {
+	if syncer.done == nil {
+		return nil
+	}
+	syncer.once.Do(syncer.cancel)
+
+	select {
+	case <-syncer.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (syncer *UserSyncer) run(ctx context.Context) {
+	defer close(syncer.done)
+	defer syncer.service.setNextSync(nil)
+
+	for {
+		delay := syncer.interval
+
+		if syncer.jitter > 0 {
+			delay += time.Duration(syncer.random(int64(syncer.jitter)))
+		}
+		next := time.Now().Add(delay)
+		syncer.service.setNextSync(&next)
+		timer := time.NewTimer(delay)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return
+		case <-timer.C:
+		}
+		// Errors are recorded in the sync state, a sync in progress on demand just skips this one.
+		syncer.service.SyncUsers(ctx)
+	}
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	e "github.com/EloYaniel/academy-go-q42021/entities"
+	"github.com/EloYaniel/academy-go-q42021/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+// versionedUsersServer serves pages of users with an ETag per page, answering 304 when it is sent back.
+type versionedUsersServer struct {
+	mu       sync.Mutex
+	pages    [][]e.User
+	versions []int
+	fail     bool
+}
+
+func newVersionedUsersServer(pages [][]e.User) (*versionedUsersServer, *httptest.Server) {
+	vs := &versionedUsersServer{pages: pages, versions: make([]int, len(pages))}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vs.mu.Lock()
+		defer vs.mu.Unlock()
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+
+		if vs.fail || page < 1 || page > len(vs.pages) {
+			w.WriteHeader(http.StatusInternalServerError)
+
+			return
+		}
+		etag := `"` + strconv.Itoa(page) + "-" + strconv.Itoa(vs.versions[page-1]) + `"`
+
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+
+			return
+		}
+		w.Header().Set("ETag", etag)
+		json.NewEncoder(w).Encode(usersPage{
+			Page:       page,
+			TotalPages: len(vs.pages),
+			Data:       vs.pages[page-1],
+		})
+	}))
+
+	return vs, server
+}
+
+func (vs *versionedUsersServer) setUser(page int, i int, u e.User) {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	vs.pages[page-1][i] = u
+	vs.versions[page-1]++
+}
+
+// addPage appends a page of users, changing only the version of the page that was the last one.
+func (vs *versionedUsersServer) addPage(users []e.User) {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	vs.versions[len(vs.pages)-1]++
+	vs.pages = append(vs.pages, users)
+	vs.versions = append(vs.versions, 0)
+}
+
+var syncedUsers = [][]e.User{
+	{{ID: 1, Email: "1@reqres.in"}, {ID: 2, Email: "2@reqres.in"}},
+	{{ID: 3, Email: "3@reqres.in"}, {ID: 4, Email: "4@reqres.in"}},
+}
+
+func Test_SyncUsers_ShouldOnlySaveModifiedPages(t *testing.T) {
+	upstream, server := newVersionedUsersServer([][]e.User{
+		append([]e.User(nil), syncedUsers[0]...),
+		append([]e.User(nil), syncedUsers[1]...),
+	})
+	defer server.Close()
+	repo := &memoryUserRepository{}
+	service := NewUserService(repo, newImportApiClient(), server.URL+"/api/users", logger.Discard())
+
+	result, err := service.SyncUsers(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, result.PagesFetched)
+	assert.Equal(t, 0, result.PagesNotModified)
+	assert.Equal(t, 4, result.Inserted)
+	assert.NotNil(t, result.LastSuccessAt)
+	assert.False(t, result.Running)
+
+	result, err = service.SyncUsers(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, result.PagesNotModified)
+	assert.Equal(t, 0, result.Inserted+result.Updated+result.Unchanged)
+	assert.Equal(t, 2, repo.saves)
+
+	upstream.setUser(2, 1, e.User{ID: 4, Email: "four@reqres.in"})
+	result, err = service.SyncUsers(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, result.PagesNotModified)
+	assert.Equal(t, 1, result.Updated)
+	assert.Equal(t, 1, result.Unchanged)
+	assert.Equal(t, []e.User{syncedUsers[0][0], syncedUsers[0][1], syncedUsers[1][0], {ID: 4, Email: "four@reqres.in"}}, repo.users)
+}
+
+func Test_SyncUsers_ShouldFetchThePagesAddedWhenTheFirstOneIsNotModified(t *testing.T) {
+	upstream, server := newVersionedUsersServer([][]e.User{
+		append([]e.User(nil), syncedUsers[0]...),
+		append([]e.User(nil), syncedUsers[1]...),
+	})
+	defer server.Close()
+	repo := &memoryUserRepository{}
+	service := NewUserService(repo, newImportApiClient(), server.URL+"/api/users", logger.Discard())
+
+	_, err := service.SyncUsers(context.Background())
+	assert.Nil(t, err)
+
+	upstream.addPage([]e.User{{ID: 5, Email: "5@reqres.in"}})
+	result, err := service.SyncUsers(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 3, result.PagesFetched)
+	assert.Equal(t, 1, result.PagesNotModified)
+	assert.Equal(t, 1, result.Inserted)
+	assert.Equal(t, e.User{ID: 5, Email: "5@reqres.in"}, repo.users[len(repo.users)-1])
+}
+
+func Test_SyncUsers_ShouldSyncEveryPageWhenThereAreNoUsers(t *testing.T) {
+	_, server := newVersionedUsersServer(syncedUsers)
+	defer server.Close()
+	repo := &memoryUserRepository{}
+	service := NewUserService(repo, newImportApiClient(), server.URL+"/api/users", logger.Discard())
+
+	_, err := service.SyncUsers(context.Background())
+	assert.Nil(t, err)
+	repo.users = nil
+
+	result, err := service.SyncUsers(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 0, result.PagesNotModified)
+	assert.Equal(t, 4, result.Inserted)
+	assert.Len(t, repo.users, 4)
+}
+
+func Test_SyncUsers_ShouldRecordTheLastError(t *testing.T) {
+	upstream, server := newVersionedUsersServer(syncedUsers)
+	defer server.Close()
+	service := NewUserService(&memoryUserRepository{}, newImportApiClient(), server.URL+"/api/users", logger.Discard())
+
+	_, err := service.SyncUsers(context.Background())
+	assert.Nil(t, err)
+	upstream.fail = true
+
+	result, err := service.SyncUsers(context.Background())
+	assert.NotNil(t, err)
+	assert.Nil(t, result)
+
+	state := service.GetSyncStatus()
+	assert.False(t, state.Running)
+	assert.Equal(t, "unexpected status 500: ", state.LastError)
+	assert.NotNil(t, state.LastErrorAt)
+	assert.NotNil(t, state.LastSuccessAt)
+	assert.Equal(t, 4, state.Inserted)
+}
+
+func Test_SyncUsers_ShouldNotRunTwiceAtOnce(t *testing.T) {
+	service := NewUserService(&memoryUserRepository{}, new(mockApiClient), "http://user.com", logger.Discard())
+	service.sync.Running = true
+
+	result, err := service.SyncUsers(context.Background())
+
+	assert.Nil(t, result)
+	assert.Equal(t, e.ErrUserSyncInProgress, err)
+}
+
+func Test_UserSyncer_ShouldSyncOnScheduleUntilStopped(t *testing.T) {
+	_, server := newVersionedUsersServer(syncedUsers)
+	defer server.Close()
+	repo := &memoryUserRepository{}
+	service := NewUserService(repo, newImportApiClient(), server.URL+"/api/users", logger.Discard())
+	syncer := NewUserSyncer(service, 10*time.Millisecond, 5*time.Millisecond)
+
+	syncer.Start()
+	assert.Eventually(t, func() bool {
+		return service.GetSyncStatus().LastSuccessAt != nil
+	}, time.Second, 5*time.Millisecond)
+	assert.Nil(t, syncer.Stop(context.Background()))
+	assert.Nil(t, syncer.Stop(context.Background()))
+
+	state := service.GetSyncStatus()
+	assert.Nil(t, state.NextSyncAt)
+	assert.Empty(t, state.LastError)
+
+	users, err := repo.GetUsers(context.Background())
+	assert.Nil(t, err)
+	assert.Len(t, users, 4)
+}
@@ -1,10 +1,21 @@
 package services
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/EloYaniel/academy-go-q42021/apiclient"
+	"github.com/EloYaniel/academy-go-q42021/cache"
 	e "github.com/EloYaniel/academy-go-q42021/entities"
+	"github.com/EloYaniel/academy-go-q42021/logger"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -13,7 +24,37 @@ type mockApiClient struct {
 	mock.Mock
 }
 
-func (m *mockApiClient) Get(url string, params map[string]interface{}, response interface{}) error {
+func (m *mockApiClient) Get(ctx context.Context, url string, params map[string]interface{}, response interface{}) error {
+	args := m.Called()
+
+	return args.Error(0)
+}
+
+func (m *mockApiClient) GetIfModified(ctx context.Context, url string, params map[string]interface{}, validators apiclient.Validators, response interface{}) (apiclient.Validators, bool, error) {
+	args := m.Called()
+
+	return args.Get(0).(apiclient.Validators), args.Bool(1), args.Error(2)
+}
+
+func (m *mockApiClient) Post(ctx context.Context, url string, body interface{}, headers map[string]string, response interface{}) error {
+	args := m.Called()
+
+	return args.Error(0)
+}
+
+func (m *mockApiClient) Put(ctx context.Context, url string, body interface{}, headers map[string]string, response interface{}) error {
+	args := m.Called()
+
+	return args.Error(0)
+}
+
+func (m *mockApiClient) Patch(ctx context.Context, url string, body interface{}, headers map[string]string, response interface{}) error {
+	args := m.Called()
+
+	return args.Error(0)
+}
+
+func (m *mockApiClient) Delete(ctx context.Context, url string, headers map[string]string, response interface{}) error {
 	args := m.Called()
 
 	return args.Error(0)
@@ -23,27 +64,39 @@ type mockUserRepository struct {
 	mock.Mock
 }
 
-func (m *mockUserRepository) SaveUsers(users []e.User) error {
+func (m *mockUserRepository) SaveUsers(ctx context.Context, users []e.User) (*e.UserSaveReport, error) {
 	args := m.Called()
 
-	return args.Error(0)
+	return args.Get(0).(*e.UserSaveReport), args.Error(1)
 }
 
-func (m *mockUserRepository) GetUserByID(id int) (*e.User, error) {
+func (m *mockUserRepository) GetUserByID(ctx context.Context, id int) (*e.User, error) {
 	args := m.Called()
 
 	return args.Get(0).(*e.User), args.Error(1)
 }
 
-func (m *mockUserRepository) GetUsers() ([]e.User, error) {
+func (m *mockUserRepository) GetUsers(ctx context.Context) ([]e.User, error) {
 	args := m.Called()
 
 	return args.Get(0).([]e.User), args.Error(1)
 }
 
+func (m *mockUserRepository) EachUser(ctx context.Context, fn func(u e.User) error) error {
+	args := m.Called()
+	users, _ := args.Get(0).([]e.User)
+	for _, u := range users {
+		if err := fn(u); err != nil {
+			return err
+		}
+	}
+
+	return args.Error(1)
+}
+
 func Test_NewUserService_ShouldReturnInstance(t *testing.T) {
-	instance := NewUserService(&mockUserRepository{}, &mockApiClient{}, "http://user.com")
-	instance2 := NewUserService(&mockUserRepository{}, &mockApiClient{}, "http://user.com")
+	instance := NewUserService(&mockUserRepository{}, &mockApiClient{}, "http://user.com", logger.Discard())
+	instance2 := NewUserService(&mockUserRepository{}, &mockApiClient{}, "http://user.com", logger.Discard())
 
 	assert.NotNil(t, instance)
 	assert.NotSame(t, instance, instance2)
@@ -59,10 +112,12 @@ func Test_GetUsers_Suite(t *testing.T) {
 		expectedGetUsersRepoCalls  int
 		expectedSaveUsersRepoCalls int
 		expectedClientCalls        int
+		expectedErr                error
 	}{
 		{
 			name:                       "Should return error when client has error",
 			clientErr:                  errors.New("Error getting users"),
+			expectedErr:                errors.New("Error getting users"),
 			expectedSaveUsersRepoCalls: 0,
 			expectedGetUsersRepoCalls:  1,
 			expectedClientCalls:        1,
@@ -108,6 +163,7 @@ func Test_GetUsers_Suite(t *testing.T) {
 			name:                       "Save user error",
 			getUsersRepoErr:            errors.New("unknown error"),
 			saveUsersRepoErr:           errors.New("Error saving user"),
+			expectedErr:                errors.New("Error saving user"),
 			expectedSaveUsersRepoCalls: 1,
 			expectedGetUsersRepoCalls:  1,
 			expectedClientCalls:        1,
@@ -118,15 +174,15 @@ func Test_GetUsers_Suite(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			repoMock := new(mockUserRepository)
 			repoMock.On("GetUsers").Return(tc.response, tc.getUsersRepoErr)
-			repoMock.On("SaveUsers").Return(tc.saveUsersRepoErr)
+			repoMock.On("SaveUsers").Return(&e.UserSaveReport{}, tc.saveUsersRepoErr)
 			clientMock := new(mockApiClient)
 			clientMock.On("Get").Return(tc.clientErr)
-			service := NewUserService(repoMock, clientMock, "http://user.com")
+			service := NewUserService(repoMock, clientMock, "http://user.com", logger.Discard())
 
-			resp, err := service.GetUsers()
+			resp, err := service.GetUsers(context.Background())
 
 			if err != nil {
-				assert.Equal(t, tc.clientErr, err)
+				assert.Equal(t, tc.expectedErr, err)
 				assert.Nil(t, resp)
 
 			} else {
@@ -142,14 +198,18 @@ func Test_GetUsers_Suite(t *testing.T) {
 
 func Test_GetUserByID_Suite(t *testing.T) {
 	testCases := []struct {
-		name     string
-		response *e.User
-		err      error
+		name                string
+		response            *e.User
+		err                 error
+		clientErr           error
+		expectedErr         error
+		expectedClientCalls int
 	}{
 		{
-			name:     "Should return error when repo has error",
-			response: nil,
-			err:      errors.New("Error getting user"),
+			name:        "Should return error when repo has error",
+			response:    nil,
+			err:         errors.New("Error getting user"),
+			expectedErr: errors.New("Error getting user"),
 		},
 		{
 			name: "Should return user by id",
@@ -163,9 +223,17 @@ func Test_GetUserByID_Suite(t *testing.T) {
 			err: nil,
 		},
 		{
-			name:     "Should return no error nor user if not found",
-			response: nil,
-			err:      nil,
+			name:                "Should return no error nor user if not found upstream either",
+			response:            nil,
+			clientErr:           &apiclient.StatusError{StatusCode: http.StatusNotFound},
+			expectedClientCalls: 1,
+		},
+		{
+			name:                "Should return error when it can not be fetched upstream",
+			response:            nil,
+			clientErr:           &apiclient.StatusError{StatusCode: http.StatusInternalServerError},
+			expectedErr:         errors.New("unexpected status 500: "),
+			expectedClientCalls: 1,
 		},
 	}
 
@@ -174,17 +242,404 @@ func Test_GetUserByID_Suite(t *testing.T) {
 			repoMock := new(mockUserRepository)
 			clientMock := new(mockApiClient)
 			repoMock.On("GetUserByID").Return(tc.response, tc.err)
-			service := NewUserService(repoMock, clientMock, "http://user.com")
+			clientMock.On("Get").Return(tc.clientErr)
+			service := NewUserService(repoMock, clientMock, "http://user.com", logger.Discard())
 
-			resp, err := service.GetUserByID(1)
+			resp, err := service.GetUserByID(context.Background(), 1)
 
-			if err != nil {
-				assert.Equal(t, tc.err, err)
-			}
-			if resp != nil {
-				assert.Equal(t, tc.response, resp)
+			if tc.expectedErr != nil {
+				assert.EqualError(t, err, tc.expectedErr.Error())
+			} else {
+				assert.Nil(t, err)
 			}
+			assert.Equal(t, tc.response, resp)
 			repoMock.AssertNumberOfCalls(t, "GetUserByID", 1)
+			repoMock.AssertNotCalled(t, "SaveUsers")
+			clientMock.AssertNumberOfCalls(t, "Get", tc.expectedClientCalls)
+		})
+	}
+}
+
+func Test_GetUserByID_ShouldFetchMissingUsersUpstream(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+
+		if r.URL.Path != "/api/users/7" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("{}"))
+
+			return
+		}
+		w.Write([]byte(`{"data":{"id":7,"email":"michael.lawson@reqres.in","first_name":"Michael","last_name":"Lawson"}}`))
+	}))
+	defer server.Close()
+	repo := &memoryUserRepository{}
+	service := NewUserService(repo, newImportApiClient(), server.URL+"/api/users", logger.Discard()).
+		WithLookupCache(cache.New("users_api", 10, time.Minute, 0))
+	expected := e.User{ID: 7, Email: "michael.lawson@reqres.in", FirstName: "Michael", LastName: "Lawson"}
+
+	user, err := service.GetUserByID(context.Background(), 7)
+	assert.Nil(t, err)
+	assert.Equal(t, &expected, user)
+	assert.Equal(t, []e.User{expected}, repo.users)
+
+	user, err = service.GetUserByID(context.Background(), 7)
+	assert.Nil(t, err)
+	assert.Equal(t, &expected, user)
+
+	for i := 0; i < 2; i++ {
+		user, err = service.GetUserByID(context.Background(), 8)
+		assert.Nil(t, err)
+		assert.Nil(t, user)
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+}
+
+type memoryUserRepository struct {
+	mu        sync.Mutex
+	users     []e.User
+	saves     int
+	failSaves map[int]bool
+}
+
+func (m *memoryUserRepository) SaveUsers(ctx context.Context, users []e.User) (*e.UserSaveReport, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	report := &e.UserSaveReport{}
+	m.saves++
+
+	if m.failSaves[m.saves] {
+		return nil, errors.New("error writing the file")
+	}
+
+	for _, u := range users {
+		found := false
+		for i, stored := range m.users {
+			if stored.ID != u.ID {
+				continue
+			}
+			found = true
+
+			if stored == u {
+				report.Unchanged++
+			} else {
+				m.users[i] = u
+				report.Updated++
+			}
+		}
+
+		if !found {
+			m.users = append(m.users, u)
+			report.Inserted++
+		}
+	}
+
+	return report, nil
+}
+
+func (m *memoryUserRepository) GetUsers(ctx context.Context) ([]e.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]e.User(nil), m.users...), nil
+}
+
+func (m *memoryUserRepository) GetUserByID(ctx context.Context, id int) (*e.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, u := range m.users {
+		if u.ID == id {
+			return &u, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (m *memoryUserRepository) EachUser(ctx context.Context, fn func(u e.User) error) error {
+	users, _ := m.GetUsers(ctx)
+	for _, u := range users {
+		if err := fn(u); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type memoryUserImportRepository struct {
+	mu         sync.Mutex
+	userImport *e.UserImport
+}
+
+func (m *memoryUserImportRepository) GetUserImport(ctx context.Context) (*e.UserImport, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.userImport == nil {
+		return nil, nil
+	}
+	userImport := *m.userImport
+
+	return &userImport, nil
+}
+
+func (m *memoryUserImportRepository) SaveUserImport(ctx context.Context, userImport e.UserImport) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.userImport = &userImport
+
+	return nil
+}
+
+// newPagedUsersServer serves totalPages pages of perPage users, failing the pages in failOnce the first time they are requested.
+func newPagedUsersServer(totalPages int, perPage int, failOnce map[int]bool) (*httptest.Server, map[int]int) {
+	hits := map[int]int{}
+	m := new(sync.Mutex)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		m.Lock()
+		hits[page]++
+		fail := failOnce[page] && hits[page] == 1
+		m.Unlock()
+
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("<html>upstream error</html>"))
+
+			return
+		}
+		var users []e.User
+		for i := 1; i <= perPage; i++ {
+			id := (page-1)*perPage + i
+			users = append(users, e.User{ID: id, Email: strconv.Itoa(id) + "@reqres.in"})
+		}
+		json.NewEncoder(w).Encode(usersPage{
+			Page:       page,
+			PerPage:    perPage,
+			Total:      totalPages * perPage,
+			TotalPages: totalPages,
+			Data:       users,
 		})
+	}))
+
+	return server, hits
+}
+
+// newImportApiClient creates a client that does not retry, so failed pages reach the service.
+func newImportApiClient() *apiclient.HttpApiClient {
+	return apiclient.NewHttpApiClient(time.Second, apiclient.RetryPolicy{}, apiclient.BreakerPolicy{})
+}
+
+func Test_GetUsers_ShouldImportEveryPage(t *testing.T) {
+	server, hits := newPagedUsersServer(3, 2, nil)
+	defer server.Close()
+	repo := &memoryUserRepository{}
+	service := NewUserService(repo, newImportApiClient(), server.URL+"/api/users", logger.Discard())
+
+	users, err := service.GetUsers(context.Background())
+
+	assert.Nil(t, err)
+	assert.Len(t, users, 6)
+	assert.Equal(t, users, repo.users)
+	assert.Equal(t, 3, repo.saves)
+	assert.Equal(t, map[int]int{1: 1, 2: 1, 3: 1}, hits)
+
+	lastImport := service.GetLastImport()
+	assert.True(t, lastImport.Completed)
+	assert.Equal(t, 3, lastImport.PagesFetched)
+	assert.Equal(t, 3, lastImport.TotalPages)
+	assert.Equal(t, 6, lastImport.Total)
+	assert.Equal(t, 6, lastImport.Imported)
+	assert.Equal(t, 6, lastImport.Inserted)
+	assert.Equal(t, 0, lastImport.Updated+lastImport.Unchanged)
+	assert.NotNil(t, lastImport.FinishedAt)
+
+	users, err = service.GetUsers(context.Background())
+
+	assert.Nil(t, err)
+	assert.Len(t, users, 6)
+	assert.Equal(t, map[int]int{1: 1, 2: 1, 3: 1}, hits)
+}
+
+func Test_GetUsers_ShouldResumeFailedImport(t *testing.T) {
+	server, hits := newPagedUsersServer(3, 2, map[int]bool{2: true})
+	defer server.Close()
+	repo := &memoryUserRepository{}
+	service := NewUserService(repo, newImportApiClient(), server.URL+"/api/users", logger.Discard())
+
+	users, err := service.GetUsers(context.Background())
+
+	assert.NotNil(t, err)
+	assert.Nil(t, users)
+	assert.Len(t, repo.users, 2)
+
+	lastImport := service.GetLastImport()
+	assert.False(t, lastImport.Completed)
+	assert.Equal(t, 1, lastImport.PagesFetched)
+	assert.Equal(t, 2, lastImport.NextPage)
+	assert.NotEmpty(t, lastImport.LastError)
+
+	users, err = service.GetUsers(context.Background())
+
+	assert.Nil(t, err)
+	assert.Len(t, users, 6)
+	assert.Equal(t, users, repo.users)
+	assert.Equal(t, map[int]int{1: 1, 2: 2, 3: 1}, hits)
+
+	lastImport = service.GetLastImport()
+	assert.True(t, lastImport.Completed)
+	assert.Equal(t, 3, lastImport.PagesFetched)
+	assert.Equal(t, 6, lastImport.Inserted)
+	assert.Empty(t, lastImport.LastError)
+}
+
+func Test_GetUsers_ShouldResumeImportWhenSavingFails(t *testing.T) {
+	server, hits := newPagedUsersServer(3, 2, nil)
+	defer server.Close()
+	repo := &memoryUserRepository{failSaves: map[int]bool{2: true}}
+	service := NewUserService(repo, newImportApiClient(), server.URL+"/api/users", logger.Discard())
+
+	users, err := service.GetUsers(context.Background())
+
+	assert.Equal(t, errors.New("error writing the file"), err)
+	assert.Nil(t, users)
+	assert.Len(t, repo.users, 2)
+
+	lastImport := service.GetLastImport()
+	assert.False(t, lastImport.Completed)
+	assert.Equal(t, 1, lastImport.PagesFetched)
+	assert.Equal(t, 2, lastImport.NextPage)
+	assert.Equal(t, 2, lastImport.Imported)
+	assert.Equal(t, "error writing the file", lastImport.LastError)
+
+	users, err = service.GetUsers(context.Background())
+
+	assert.Nil(t, err)
+	assert.Len(t, users, 6)
+	assert.Equal(t, users, repo.users)
+	assert.Equal(t, map[int]int{1: 1, 2: 2, 3: 1}, hits)
+
+	lastImport = service.GetLastImport()
+	assert.True(t, lastImport.Completed)
+	assert.Equal(t, 3, lastImport.PagesFetched)
+	assert.Equal(t, 6, lastImport.Inserted)
+	assert.Empty(t, lastImport.LastError)
+}
+
+func Test_GetUsers_ShouldResumeTheImportAfterARestart(t *testing.T) {
+	server, hits := newPagedUsersServer(3, 2, map[int]bool{2: true})
+	defer server.Close()
+	repo := &memoryUserRepository{}
+	imports := &memoryUserImportRepository{}
+	service := NewUserService(repo, newImportApiClient(), server.URL+"/api/users", logger.Discard()).WithImportRepository(imports)
+
+	_, err := service.GetUsers(context.Background())
+
+	assert.NotNil(t, err)
+	assert.Len(t, repo.users, 2)
+
+	restarted := NewUserService(repo, newImportApiClient(), server.URL+"/api/users", logger.Discard()).WithImportRepository(imports)
+	lastImport := restarted.GetLastImport()
+	assert.False(t, lastImport.Completed)
+	assert.Equal(t, 2, lastImport.NextPage)
+
+	users, err := restarted.GetUsers(context.Background())
+
+	assert.Nil(t, err)
+	assert.Len(t, users, 6)
+	assert.Equal(t, users, repo.users)
+	assert.Equal(t, map[int]int{1: 1, 2: 2, 3: 1}, hits)
+
+	lastImport = restarted.GetLastImport()
+	assert.True(t, lastImport.Completed)
+	assert.Equal(t, 3, lastImport.PagesFetched)
+	assert.Equal(t, 6, lastImport.Imported)
+	stored, err := imports.GetUserImport(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, lastImport, stored)
+}
+
+func Test_GetUsers_ShouldNotLockTheServiceWhileFetchingAPage(t *testing.T) {
+	fetching := make(chan struct{})
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(fetching)
+		<-release
+		json.NewEncoder(w).Encode(usersPage{Page: 1, PerPage: 1, Total: 1, TotalPages: 1, Data: []e.User{{ID: 1}}})
+	}))
+	defer server.Close()
+	service := NewUserService(&memoryUserRepository{}, newImportApiClient(), server.URL+"/api/users", logger.Discard())
+	done := make(chan error)
+	go func() {
+		_, err := service.GetUsers(context.Background())
+		done <- err
+	}()
+	<-fetching
+	lastImport := make(chan *e.UserImport)
+	go func() { lastImport <- service.GetLastImport() }()
+
+	select {
+	case state := <-lastImport:
+		assert.False(t, state.Completed)
+		assert.Equal(t, 1, state.NextPage)
+	case <-time.After(time.Second):
+		t.Error("GetLastImport waited for the page")
 	}
+	close(release)
+	assert.Nil(t, <-done)
+	assert.True(t, service.GetLastImport().Completed)
+}
+
+func Test_StreamUsers_ShouldImportTheUsersFirst(t *testing.T) {
+	server, hits := newPagedUsersServer(2, 2, nil)
+	defer server.Close()
+	repo := &memoryUserRepository{}
+	service := NewUserService(repo, newImportApiClient(), server.URL+"/api/users", logger.Discard())
+	var streamed []e.User
+	stream := func(u e.User) error {
+		streamed = append(streamed, u)
+
+		return nil
+	}
+
+	err := service.StreamUsers(context.Background(), stream)
+
+	assert.Nil(t, err)
+	assert.Len(t, streamed, 4)
+	assert.Equal(t, repo.users, streamed)
+
+	streamed = nil
+	err = service.StreamUsers(context.Background(), stream)
+
+	assert.Nil(t, err)
+	assert.Equal(t, repo.users, streamed)
+	assert.Equal(t, map[int]int{1: 1, 2: 1}, hits)
+}
+
+func Test_CheckUpstream_ShouldUseTheProbeClient(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	retrying := apiclient.NewHttpApiClient(time.Second, apiclient.RetryPolicy{MaxRetries: 3}, apiclient.BreakerPolicy{FailureThreshold: 1, OpenTimeout: time.Minute})
+	service := NewUserService(&memoryUserRepository{}, retrying, server.URL+"/api/users", logger.Discard()).
+		WithProbeClient(newImportApiClient())
+
+	for i := 0; i < 3; i++ {
+		var statusErr *apiclient.StatusError
+		err := service.CheckUpstream(context.Background())
+
+		assert.True(t, errors.As(err, &statusErr))
+	}
+
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+	// The probes did not open the circuit, so the lookup still reaches the upstream API once.
+	_, err := service.GetUserByID(context.Background(), 1)
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&requests))
 }